@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/goodieshq/sweettooth/internal/crypto"
 	"github.com/goodieshq/sweettooth/internal/server"
 	"github.com/goodieshq/sweettooth/internal/server/core"
 	"github.com/goodieshq/sweettooth/internal/server/core_pgx"
@@ -53,18 +54,69 @@ func getConfig() *server.SweetToothServerConfig {
 		log.Fatal().Err(errors.New("missing server secret")).Send()
 	}
 
+	// source credentials and package secrets are encrypted with their own key, so rotating the secret keeps them readable
+	secretKey := os.Getenv("SWEETTOOTH_SECRET_KEY")
+	if secretKey == "" {
+		log.Fatal().Err(errors.New("missing server secret key")).Send()
+	}
+	if secretKey == secret {
+		log.Fatal().Err(errors.New("the server secret key must differ from the server secret")).Send()
+	}
+
+	// never enable this outside of development, every web request is treated as a super admin
+	var devBypassWebAuth bool
+	if bypass := os.Getenv("SWEETTOOTH_DEV_BYPASS_WEBAUTH"); bypass != "" {
+		devBypassWebAuth, err = strconv.ParseBool(bypass)
+		if err != nil {
+			log.Fatal().Str("invalid", bypass).Msg("invalid SWEETTOOTH_DEV_BYPASS_WEBAUTH value")
+		}
+	}
+
 	return &server.SweetToothServerConfig{
-		Secret:    []byte(secret),
-		DBConnStr: pgConnStr,
+		Secret:           []byte(secret),
+		SecretKey:        []byte(secretKey),
+		DBConnStr:        pgConnStr,
+		DevBypassWebAuth: devBypassWebAuth,
 	}
 }
 
 func connectDb(cfg *server.SweetToothServerConfig) core.Core {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
-	core, err := core_pgx.NewCorePGX(ctx, cfg.DBConnStr)
+	secrets, err := crypto.NewCipherAES(cfg.SecretKey)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to initialize the secret encryption")
+	}
+
+	core, err := core_pgx.NewCorePGX(ctx, cfg.DBConnStr, secrets)
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed to initialize the database connection")
 	}
 	return core
 }
+
+// create the initial super admin from the environment if it does not already exist
+func bootstrapAdmin(core core.Core) {
+	email := os.Getenv("SWEETTOOTH_ADMIN_EMAIL")
+	password := os.Getenv("SWEETTOOTH_ADMIN_PASSWORD")
+	if email == "" || password == "" {
+		return
+	}
+
+	hash, err := crypto.HashPassword(password)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to hash the admin password")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	user, err := core.CreateUser(ctx, email, hash, true)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to create the admin user")
+	}
+
+	if user != nil {
+		log.Info().Str("email", user.Email).Msg("created the admin user")
+	}
+}
@@ -22,6 +22,7 @@ func loop() {
 
 	cfg := getConfig()
 	core := connectDb(cfg)
+	bootstrapAdmin(core)
 
 	srv, err := server.NewSweetToothServer(cfg, core)
 	if err != nil {
@@ -10,6 +10,7 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/goodieshq/sweettooth/pkg/api"
@@ -24,6 +25,7 @@ const (
 	PKG_ACTION_UNINSTALL PkgAction = 3
 
 	PKG_DEFAULT_TIMEOUT = 600
+	PKG_WAIT_DELAY      = 10 * time.Second
 )
 
 func pkgactionName(action PkgAction) string {
@@ -47,21 +49,40 @@ const (
 	TYPE_SYSTEM  partType = iota
 )
 
-func streamOutput(pipe io.ReadCloser, buf *bytes.Buffer, name string) {
-	buffer := make([]byte, 1024)
-	for {
-		n, err := pipe.Read(buffer)
-		if err != nil {
-			if err != io.EOF {
-				log.Error().Err(err).Str("name", name).Msg("failed reading pipe")
-			}
-			break
-		}
-		if buf != nil && n > 0 {
-			fmt.Printf("%s: %s", name, buffer[:n])
-			buf.Write(buffer[:n])
-		}
+// collects the combined output of a command in the order it was produced, optionally copying it to a live writer
+type outputWriter struct {
+	mu   sync.Mutex
+	buf  bytes.Buffer
+	live io.Writer
+}
+
+func (out *outputWriter) Write(data []byte) (int, error) {
+	out.mu.Lock()
+	defer out.mu.Unlock()
+
+	out.buf.Write(data)
+	if out.live != nil {
+		out.live.Write(data)
 	}
+	return len(data), nil
+}
+
+func (out *outputWriter) Bytes() []byte {
+	out.mu.Lock()
+	defer out.mu.Unlock()
+
+	return out.buf.Bytes()
+}
+
+// traces a single stream of command output before adding it to the combined output
+type streamWriter struct {
+	name string
+	out  io.Writer
+}
+
+func (stream *streamWriter) Write(data []byte) (int, error) {
+	log.Trace().Str("stream", stream.name).Bytes("output", data).Msg("choco output")
+	return stream.out.Write(data)
 }
 
 func addCommandArg(args *[]string, condition bool, value string, additional ...string) {
@@ -80,7 +101,8 @@ func command(ctx context.Context, cmd string, args ...string) {
 
 }
 
-func Package(ctx context.Context, action PkgAction, params *api.PackageJobParameters) *api.PackageJobResult {
+// run a package action, the output is copied to live (if provided) as it is produced
+func Package(ctx context.Context, action PkgAction, params *api.PackageJobParameters, live io.Writer) *api.PackageJobResult {
 	log.Trace().Msg("choco.Package called")
 
 	var result = &api.PackageJobResult{}
@@ -102,45 +124,40 @@ func Package(ctx context.Context, action PkgAction, params *api.PackageJobParame
 		}
 	}
 
+	// adopts software which is already installed by registering the package without running its install script
+	addCommandArg(&args, action == PKG_ACTION_INSTALL && params.SkipPowershell, "--skip-powershell")
+
 	if params.Timeout == 0 {
 		params.Timeout = PKG_DEFAULT_TIMEOUT
 	}
 
 	addCommandArg(&args, true, "--timeout", strconv.Itoa(params.Timeout))
 
-	log.Debug().Str("cmd", "choco "+strings.Join(args, " ")).Msg("running command")
+	// the package parameters may hold secrets, so they are never included in the logged or reported command and are
+	// passed as sensitive so choco does not log them either
+	cmdline := "choco " + strings.Join(args, " ")
+	log.Debug().Str("cmd", cmdline).Msg("running command")
+
+	if params.PackageParameters != nil && *params.PackageParameters != "" {
+		addCommandArg(&args, true, "--package-parameters-sensitive="+*params.PackageParameters)
+	}
 
 	// Run the command
 	ctx, cancel := context.WithTimeout(ctx, time.Duration(params.Timeout)*time.Second+15*time.Second)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "choco", args...)
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		result.ExitCode = -1
-		msg := fmt.Errorf("failed to get stdout pipe: %w", err).Error()
-		result.Error = &msg
-		return result
-	}
+	out := &outputWriter{live: live}
 
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		result.ExitCode = -1
-		msg := fmt.Errorf("failed to get stdout pipe: %w", err).Error()
-		result.Error = &msg
-		return result
-	}
-
-	var bufout, buferr bytes.Buffer
-
-	go streamOutput(stdout, &bufout, "stdout")
-	go streamOutput(stderr, &buferr, "stderr")
+	cmd := exec.CommandContext(ctx, "choco", args...)
+	cmd.Stdout = &streamWriter{name: "stdout", out: out}
+	cmd.Stderr = &streamWriter{name: "stderr", out: out}
+	cmd.WaitDelay = PKG_WAIT_DELAY // child processes may hold the output open after choco is killed
 
 	// retrieve the stdout and stderr output
 	err = cmd.Start()
 	if err != nil {
 		result.ExitCode = -1
-		msg := fmt.Errorf("failed to start the command '%s': %w", "choco "+strings.Join(args, " "), err).Error()
+		msg := fmt.Errorf("failed to start the command '%s': %w", cmdline, err).Error()
 		result.Error = &msg
 		return result
 	}
@@ -153,7 +170,7 @@ func Package(ctx context.Context, action PkgAction, params *api.PackageJobParame
 		log.Error().Err(err).Msg("failed to get choco output")
 	}
 
-	output := append(bufout.Bytes(), buferr.Bytes()...)
+	output := out.Bytes()
 	log.Info().Msgf("Output is %d bytes", len(output))
 
 	// set the result
@@ -163,6 +180,9 @@ func Package(ctx context.Context, action PkgAction, params *api.PackageJobParame
 	if exitError, ok := err.(*exec.ExitError); ok {
 		// The program has exited with a non-zero status
 		result.ExitCode = exitError.ExitCode()
+		if result.ExitCode == ExitCodeInstallInProgress {
+			result.Status = StatusErrorLocked
+		}
 		err := err.Error()
 		result.Error = &err
 	} else if err != nil {
@@ -2,8 +2,9 @@ package choco
 
 import (
 	"context"
+	"fmt"
 	"os/exec"
-	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -20,20 +21,126 @@ const (
 	SRC_ACTION_DISABLE SrcAction = 4
 )
 
+func srcactionName(action SrcAction) string {
+	switch action {
+	case SRC_ACTION_ADD:
+		return "add"
+	case SRC_ACTION_REMOVE:
+		return "remove"
+	case SRC_ACTION_ENABLE:
+		return "enable"
+	case SRC_ACTION_DISABLE:
+		return "disable"
+	default:
+		return ""
+	}
+}
+
+// number of fields in each line of the sources list truncated format
+const SOURCE_LIST_FIELDS = 9
+
 type SourceParams struct {
 	Action      SrcAction
 	Name        string
 	URL         string
 	Username    string
 	Password    string
-	Credential  string // TODO: properly utilize this
+	Credential  string // password of the client certificate
+	Certificate string
 	Priority    int
 	BypassProxy bool
 	SelfService bool
 	AdminOnly   bool
 }
 
-func ListSources(ctx context.Context) ([]util.Repository, error) {
+// Run a single chocolatey source command, only an add will use the source settings
+func Source(ctx context.Context, params *SourceParams) error {
+	log.Trace().Msg("choco.Source called")
+
+	action := srcactionName(params.Action)
+	if action == "" {
+		return fmt.Errorf("invalid source action %d", params.Action)
+	}
+
+	var args = []string{"source", action, "--name=" + params.Name, "-y"}
+
+	if params.Action == SRC_ACTION_ADD {
+		addCommandArg(&args, true, "--source="+params.URL)
+		addCommandArg(&args, params.Username != "", "--user="+params.Username)
+		addCommandArg(&args, params.Certificate != "", "--cert="+params.Certificate)
+		addCommandArg(&args, params.Priority != 0, "--priority="+strconv.Itoa(params.Priority))
+		addCommandArg(&args, params.BypassProxy, "--bypass-proxy")
+		addCommandArg(&args, params.SelfService, "--allow-self-service")
+		addCommandArg(&args, params.AdminOnly, "--admin-only")
+	}
+
+	// log the command before the passwords are added so they are never written anywhere
+	log.Debug().Str("cmd", "choco "+strings.Join(args, " ")).Msg("running command")
+
+	if params.Action == SRC_ACTION_ADD {
+		addCommandArg(&args, params.Password != "", "--password="+params.Password)
+		addCommandArg(&args, params.Credential != "", "--certpassword="+params.Credential)
+	}
+
+	output, err := exec.CommandContext(ctx, "choco", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("choco source %s '%s' failed: %w: %s", action, params.Name, err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// Determine the source commands required to turn the actual sources into the desired sources. Sources which are not
+// desired are removed, new or changed sources are (re-)added, and then enabled or disabled as needed. Chocolatey never
+// lists credentials, so sources whose credentials changed must be named in readd to be added again.
+func SourceChanges(desired, actual util.RepositoryList, readd ...string) []SourceParams {
+	var changes []SourceParams
+
+	for _, repo := range actual {
+		if desired.Find(repo.Name) == nil {
+			changes = append(changes, SourceParams{Action: SRC_ACTION_REMOVE, Name: repo.Name})
+		}
+	}
+
+	for _, repo := range desired {
+		existing := actual.Find(repo.Name)
+
+		// adding a source over an existing one with the same name updates its settings
+		compare := repo
+		if existing != nil {
+			compare.Disabled = existing.Disabled
+		}
+		if existing == nil || !compare.Equal(*existing) || slices.ContainsFunc(readd, func(name string) bool {
+			return strings.EqualFold(name, repo.Name)
+		}) {
+			changes = append(changes, SourceParams{
+				Action:      SRC_ACTION_ADD,
+				Name:        repo.Name,
+				URL:         repo.URL,
+				Username:    repo.Username,
+				Password:    repo.Password,
+				Credential:  repo.CertificatePassword,
+				Certificate: repo.Certificate,
+				Priority:    repo.Priority,
+				BypassProxy: repo.BypassProxy,
+				SelfService: repo.SelfService,
+				AdminOnly:   repo.AdminOnly,
+			})
+		}
+
+		// newly added sources are enabled
+		enabled := existing == nil || !existing.Disabled
+		if repo.Disabled && enabled {
+			changes = append(changes, SourceParams{Action: SRC_ACTION_DISABLE, Name: repo.Name})
+		} else if !repo.Disabled && !enabled {
+			changes = append(changes, SourceParams{Action: SRC_ACTION_ENABLE, Name: repo.Name})
+		}
+	}
+
+	return changes
+}
+
+func ListSources(ctx context.Context) (util.RepositoryList, error) {
 	// choco sources list -r
 	cmd := exec.CommandContext(ctx, "choco", "sources", "list", "-r")
 
@@ -43,7 +150,7 @@ func ListSources(ctx context.Context) ([]util.Repository, error) {
 	}
 
 	// create a list of repositories to add the parsed entries
-	var repositories []util.Repository
+	var repositories util.RepositoryList
 	lines := strings.Split(string(output), "\n")
 
 	for _, line := range lines {
@@ -54,7 +161,7 @@ func ListSources(ctx context.Context) ([]util.Repository, error) {
 
 		// sources list truncated format is well-defined
 		parts := strings.Split(line, "|")
-		if len(parts) != reflect.TypeFor[util.Repository]().NumField() {
+		if len(parts) != SOURCE_LIST_FIELDS {
 			continue
 		}
 
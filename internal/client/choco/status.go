@@ -23,6 +23,7 @@ type ChocoStatus int
  */
 
 const (
+	StatusCancelled        = -2 // cancelled on the server before the job was completed
 	StatusUnknownFailure   = -1 // unhandled, but definitely failed
 	StatusUnknown          = 0  // default pending
 	StatusInstallSuccess   = 10 // successfully installed the target package
@@ -35,9 +36,15 @@ const (
 	StatusUpgradeNoExist   = 24 // failed to upgrade a package because it isn't installed
 	StatusUninstallSuccess = 30 // successfully uninstalled the target package
 	StatusUninstallNoExist = 34 // failed to uninstall a package because it isn't installed
+	StatusErrorSealed      = 56 // the sealed package parameters could not be opened with the node's key
 	StatusErrorChecksum    = 57 // checksum failed
+	StatusErrorDownload    = 58 // the package or installer could not be downloaded (e.g. timed out)
+	StatusErrorLocked      = 59 // another installation is in progress and holds the Windows Installer lock
 )
 
+// the Windows Installer exit code when another installation is already in progress
+const ExitCodeInstallInProgress = 1618
+
 var (
 	regexInstallSuccess   = regexp.MustCompile(`(?m)^\s*The install of .* was successful.\s*$`)
 	regexInstallAlready   = regexp.MustCompile(`(?m)^\s*- .* - .* v[\d\.]+ already installed.\s*$`)
@@ -51,8 +58,20 @@ var (
 	regexUninstallNoExist = regexp.MustCompile(`(?m)^\s*- .* - .* is not installed\. Cannot uninstall a non-existent package\.\s*$`)
 	/* Errors */
 	regexErrorChecksum = regexp.MustCompile(`(?m)^ERROR: Checksum for '.*' did not meet '[0-9a-f]+' for checksum type`)
+	regexErrorDownload = regexp.MustCompile(`(?m)(The operation has timed out|Unable to connect to the remote server|The remote name could not be resolved)`)
+	regexErrorLocked   = regexp.MustCompile(`(?m)Exit code was '1618'`)
 )
 
+// errors are checked in order before any other pattern, since the output of a failed task also reports that its action failed
+var errorPatterns = []struct {
+	pattern *regexp.Regexp
+	status  ChocoStatus
+}{
+	{regexErrorChecksum, StatusErrorChecksum},
+	{regexErrorLocked, StatusErrorLocked},
+	{regexErrorDownload, StatusErrorDownload},
+}
+
 var statusPatterns = map[*regexp.Regexp]ChocoStatus{
 	regexInstallSuccess:   StatusInstallSuccess,
 	regexInstallAlready:   StatusInstallAlready,
@@ -64,7 +83,6 @@ var statusPatterns = map[*regexp.Regexp]ChocoStatus{
 	regexUpgradeAlready:   StatusUpgradeAlready,
 	regexUpgradeNoExist:   StatusUpgradeNoExist,
 	regexUpgradeNewer:     StatusUpgradeNewer,
-	regexErrorChecksum:    StatusErrorChecksum,
 }
 
 var statusMessages = map[ChocoStatus]string{
@@ -78,8 +96,12 @@ var statusMessages = map[ChocoStatus]string{
 	StatusUpgradeAlready:   "already upgraded",
 	StatusUpgradeNoExist:   "package not installed",
 	StatusUpgradeNewer:     "newer version installed",
+	StatusErrorSealed:      "package parameters could not be opened",
 	StatusErrorChecksum:    "invalid checksum",
+	StatusErrorDownload:    "download failure",
+	StatusErrorLocked:      "another installation is in progress",
 	StatusUnknownFailure:   "unspecified failure",
+	StatusCancelled:        "cancelled",
 }
 
 // return a human-friendly message from a status code
@@ -91,16 +113,37 @@ func StatusMessage(s ChocoStatus) string {
 	}
 }
 
+// failures which will not change no matter how many times the task is retried
+var statusPermanent = map[ChocoStatus]bool{
+	StatusInstallNoExist: true,
+	StatusErrorSealed:    true,
+	StatusErrorChecksum:  true,
+}
+
+// determine if a failed status is permanent and the task should not be retried
+func StatusPermanent(s ChocoStatus) bool {
+	return statusPermanent[s]
+}
+
 // apply various regex patterns to the output of Chocolatey to determine the status of the command
 func StatusCheck(output []byte) (status ChocoStatus) {
 	status = StatusUnknownFailure
-	for pattern, s := range statusPatterns {
-		if pattern.Find(output) != nil {
-			status = s
+	for _, e := range errorPatterns {
+		if e.pattern.Find(output) != nil {
+			status = e.status
 			break
 		}
 	}
 
+	if status == StatusUnknownFailure {
+		for pattern, s := range statusPatterns {
+			if pattern.Find(output) != nil {
+				status = s
+				break
+			}
+		}
+	}
+
 	log.Trace().Int("status", int(status)).Str("message", StatusMessage(status)).Msg("choco output analyzed")
 	return
 }
@@ -23,6 +23,11 @@ type SweetToothEngine struct {
 	mu           sync.Mutex
 	stopch       chan bool
 	wg           sync.WaitGroup
+
+	sourceCredentials map[string][32]byte // hash of the credentials last applied to each source, by lowercase name
+	managedSources    map[string]bool     // lowercase names of the sources added by the client, loaded on the first sync
+	sourcesSynced     bool                // sources have been reconciled since the engine (re)started
+	scheduleSynced    bool                // schedule has been acquired since the engine (re)started
 }
 
 // create a new engine from a configuration file
@@ -25,19 +25,34 @@ func (engine *SweetToothEngine) loopOnce() bool {
 	// engine.Register(context.Background(), uuid.MustParse(""))
 
 	// wait for the first successful check in (wait for an admin to approve the public key if necessary)
-	engine.WaitCheck()
-	log.Debug().Msg("successfully checked in")
-
-	// TODO: update sources
+	check := engine.WaitCheck()
+	log.Debug().
+		Bool("pending_sources", check.PendingSources).
+		Bool("pending_schedule", check.PendingSchedule).
+		Int("pending_jobs", check.PendingJobs).
+		Int("overdue_jobs", check.OverdueJobs).
+		Msg("successfully checked in")
+
+	// reconcile the local chocolatey sources with the ones assigned to this node if they have changed
+	if check.PendingSources || !engine.sourcesSynced {
+		engine.Sources()
+		log.Debug().Msg("successfully updated the sources")
+	}
 
-	// acquire the schedule for this node, just in case it has changed.
-	engine.Schedule()
-	log.Debug().Msg("successfully loaded the schedule")
+	// acquire the schedule for this node if it has changed
+	if check.PendingSchedule || !engine.scheduleSynced {
+		engine.Schedule()
+		log.Debug().Msg("successfully loaded the schedule")
+	}
 
-	// check package jobs if it is currently in a maintenance schedule
-	if BYPASS_SCHEDULE || schedule.Matches() {
-		engine.PackageJobs()
+	// check package jobs if there are any and it is currently in a maintenance schedule,
+	// otherwise only perform the jobs which are past their deadline
+	if check.PendingJobs > 0 && schedule.Matches() {
+		engine.PackageJobs(false)
 		log.Debug().Msg("successfully performed all package jobs")
+	} else if check.OverdueJobs > 0 {
+		engine.PackageJobs(true)
+		log.Debug().Msg("successfully performed all overdue package jobs")
 	}
 
 	// inventory local software and compare with server's inventory, update if needed
@@ -96,6 +111,8 @@ func (engine *SweetToothEngine) loop() {
 			}
 
 			engine.client.Registered = false // reset the registration status just in case
+			engine.sourcesSynced = false     // reconcile the sources again regardless of the server's flags
+			engine.scheduleSynced = false    // acquire the schedule again regardless of the server's flags
 			tracker.Reset()                  // reset the software inventory to require a fresh server response
 
 			select {
@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/goodieshq/sweettooth/internal/util"
+	"github.com/goodieshq/sweettooth/pkg/api/client"
+	"github.com/google/uuid"
+)
+
+const (
+	INTERVAL_JOB_OUTPUT = 3 * time.Second // how often the output of a running job is streamed to the server (well within its lease)
+)
+
+// buffers the output of a running package job and periodically streams it to the server, which heartbeats
+// the node's lease of the job and lets the node learn that an operator has cancelled it so choco can be killed
+type jobOutputStream struct {
+	client    *client.SweetToothClient
+	jobid     uuid.UUID
+	cancel    context.CancelFunc
+	mu        sync.Mutex
+	pending   bytes.Buffer
+	cancelled bool
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+func newJobOutputStream(client *client.SweetToothClient, jobid uuid.UUID, cancel context.CancelFunc) *jobOutputStream {
+	stream := &jobOutputStream{
+		client: client,
+		jobid:  jobid,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	stream.wg.Add(1)
+	go stream.run()
+
+	return stream
+}
+
+func (stream *jobOutputStream) Write(data []byte) (int, error) {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	return stream.pending.Write(data)
+}
+
+// send the output buffered since the last flush, an empty chunk still checks for cancellation
+func (stream *jobOutputStream) flush() {
+	log := util.Logger("engine.jobOutputStream").With().Str("jobid", stream.jobid.String()).Logger()
+
+	stream.mu.Lock()
+	chunk := stream.pending.String()
+	stream.pending.Reset()
+	stream.mu.Unlock()
+
+	cancelled, err := stream.client.AppendPackageJobOutput(stream.jobid, chunk)
+	if err != nil {
+		// the complete output is still reported when the job is completed
+		log.Warn().Err(err).Msg("failed to stream package job output")
+		return
+	}
+
+	if cancelled && !stream.cancelled {
+		log.Warn().Msg("the package job was cancelled, stopping choco")
+		stream.cancelled = true
+		stream.cancel()
+	}
+}
+
+func (stream *jobOutputStream) run() {
+	defer stream.wg.Done()
+
+	ticker := time.NewTicker(INTERVAL_JOB_OUTPUT)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stream.done:
+			return
+		case <-ticker.C:
+			stream.flush()
+		}
+	}
+}
+
+// stop streaming and send any remaining output, returns true if the job was cancelled while running
+func (stream *jobOutputStream) Close() bool {
+	close(stream.done)
+	stream.wg.Wait()
+
+	stream.flush()
+	return stream.cancelled
+}
@@ -4,20 +4,23 @@ import (
 	"time"
 
 	"github.com/goodieshq/sweettooth/internal/client/choco"
+	"github.com/goodieshq/sweettooth/internal/client/keys"
 	"github.com/goodieshq/sweettooth/internal/client/schedule"
 	"github.com/goodieshq/sweettooth/internal/util"
+	"github.com/goodieshq/sweettooth/pkg/api"
 )
 
 const (
 	TIMEOUT_BUFFER_JOB = 30 * time.Second // give an added buffer
 )
 
-func (engine *SweetToothEngine) PackageJobs() error {
+// perform the pending package jobs, or only those past their deadline (which ignore the maintenance schedule)
+func (engine *SweetToothEngine) PackageJobs(overdue bool) error {
 	log := util.Logger("engine.PackageJobs")
 	log.Trace().Msg("called")
 	defer log.Trace().Msg("finish")
 
-	joblist, err := engine.client.GetPackageJobs()
+	joblist, err := engine.client.GetPackageJobs(overdue)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to get package jobs")
 		return err
@@ -27,7 +30,7 @@ func (engine *SweetToothEngine) PackageJobs() error {
 	for _, jobid := range joblist {
 		engine.mustRun()
 
-		if !schedule.Matches() && !BYPASS_SCHEDULE {
+		if !overdue && !schedule.Matches() {
 			log.Warn().Msg("scheduled maintenance has ended, no longer processing jobs")
 			break
 		}
@@ -42,16 +45,42 @@ func (engine *SweetToothEngine) PackageJobs() error {
 
 		// if the code reaches this point, the server has counted it as an attempt
 
+		// secret package parameters arrive sealed to the node's key and are only opened in memory
+		if job.Parameters.PackageParameters != nil {
+			params, err := keys.Open(*job.Parameters.PackageParameters)
+			if err != nil {
+				// the parameters were sealed to another key (e.g. the node re-registered), so retrying cannot succeed
+				log.Error().Err(err).Msg("failed to open the package parameters, they are not sealed to the node's key")
+				msg := "failed to open the package parameters with the node's key: " + err.Error()
+				engine.client.CompletePackageJob(jobid, &api.PackageJobResult{
+					Status:   choco.StatusErrorSealed,
+					ExitCode: -1,
+					Error:    &msg,
+				})
+				continue
+			}
+			packageParameters := string(params)
+			job.Parameters.PackageParameters = &packageParameters
+		}
+
 		// run the package job
 		ctx, cancel := engine.commandContext("choco", TIMEOUT_BUFFER_JOB+(time.Second*time.Duration(job.Parameters.Timeout)))
 		defer cancel()
 
+		stream := newJobOutputStream(engine.client, jobid, cancel)
 		result := choco.Package(
 			ctx,
 			choco.PkgAction(job.Action),
 			&job.Parameters,
+			stream,
 		)
 
+		// the server has already marked a cancelled job as completed, so its result is discarded
+		if stream.Close() {
+			log.Warn().Msg("package job was cancelled while running")
+			continue
+		}
+
 		engine.client.CompletePackageJob(jobid, result)
 	}
 
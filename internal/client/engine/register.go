@@ -26,7 +26,7 @@ func (engine *SweetToothEngine) Register(ctx context.Context, token uuid.UUID) b
 	// perform an initial check for the current status and determine if a registration is required
 	if !engine.client.Registered {
 		log.Debug().Msg("running a check to determine registration status")
-		err := engine.client.Check()
+		_, err := engine.client.Check()
 		switch err {
 		case nil:
 			engine.client.Registered = true
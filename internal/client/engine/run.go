@@ -11,8 +11,6 @@ const (
 	DEFAULT_PERIOD_CHECKIN = time.Second * 60 // checkin period
 	DEFAULT_PERIOD_LOOP    = time.Second * 10 // loop every 30 seconds
 	DEFAULT_PERIOD_RECOVER = time.Second * 10 // recover after 30 seconds
-
-	BYPASS_SCHEDULE = true // development only
 )
 
 func (engine *SweetToothEngine) run() {
@@ -30,7 +28,7 @@ func (engine *SweetToothEngine) backgroundCheckin() {
 	for engine.isRunning() {
 		func() {
 			defer util.Recoverable(true) // let this function re-run if it panics
-			err := engine.client.Check()
+			_, err := engine.client.Check()
 			log.Trace().Err(err).Msg("background check in") // only output on Trace level or it will fill up log files
 		}()
 		select {
@@ -28,4 +28,11 @@ func (engine *SweetToothEngine) Schedule() {
 		// set the schedule on the system
 		schedule.SetSchedule(sch)
 	}
+
+	// let the server know the schedule was applied so it stops flagging it as pending
+	if err := engine.client.ConfirmSchedule(sch); err != nil {
+		log.Error().Err(err).Msg("failed to confirm the schedule")
+	}
+
+	engine.scheduleSynced = true
 }
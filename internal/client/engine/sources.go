@@ -0,0 +1,175 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/goodieshq/sweettooth/internal/client/choco"
+	"github.com/goodieshq/sweettooth/internal/client/keys"
+	"github.com/goodieshq/sweettooth/internal/util"
+	"github.com/goodieshq/sweettooth/pkg/config"
+)
+
+const (
+	TIMEOUT_SOURCES = time.Minute * 2 // time to list and modify all of the chocolatey sources
+)
+
+// client routine which reconciles the local chocolatey sources with the sources assigned to the node
+func (engine *SweetToothEngine) Sources() {
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+
+	log := util.Logger("engine.Sources")
+	log.Trace().Msg("called")
+	defer log.Trace().Msg("finish")
+
+	engine.mustRun()
+
+	// acquire the effective sources from the server's database
+	desired, err := engine.client.GetSources()
+	if err != nil {
+		// any error is unexpected, go ahead and panic
+		log.Panic().Err(err).Msg("failed to get client sources")
+	}
+	log.Debug().Int("count", len(desired)).Msg("received sources from server")
+
+	ctx, cancel := engine.commandContext("choco.Sources", TIMEOUT_SOURCES)
+	defer cancel()
+
+	actual, err := choco.ListSources(ctx)
+	if err != nil {
+		log.Panic().Err(err).Msg("failed to list local sources")
+	}
+
+	// credentials arrive sealed to the node's key and are only opened in memory
+	var readd []string
+	credentials := make(map[string][32]byte)
+	for i := range desired {
+		if err := openSourceCredentials(&desired[i]); err != nil {
+			log.Panic().Err(err).Str("name", desired[i].Name).Msg("failed to open the source credentials")
+		}
+
+		// only a hash of the credentials is kept to detect when they change
+		name := strings.ToLower(desired[i].Name)
+		credentials[name] = sha256.Sum256([]byte(desired[i].Password + "\x00" + desired[i].CertificatePassword))
+		if previous, ok := engine.sourceCredentials[name]; !ok || previous != credentials[name] {
+			if desired[i].Password != "" || desired[i].CertificatePassword != "" || ok {
+				readd = append(readd, desired[i].Name)
+			}
+		}
+	}
+
+	// the sources added by the client are remembered across restarts, so they are removed once none are assigned
+	if engine.managedSources == nil {
+		engine.managedSources = loadManagedSources()
+	}
+
+	// without any assigned sources only the sources the client added are removed, any others are left unmanaged but
+	// they are still reported
+	var changes []choco.SourceParams
+	if len(desired) > 0 {
+		changes = choco.SourceChanges(desired, actual, readd...)
+	} else {
+		for _, repo := range actual {
+			if engine.managedSources[strings.ToLower(repo.Name)] {
+				changes = append(changes, choco.SourceParams{Action: choco.SRC_ACTION_REMOVE, Name: repo.Name})
+			}
+		}
+	}
+
+	managed := make(map[string]bool)
+	for _, repo := range desired {
+		managed[strings.ToLower(repo.Name)] = true
+	}
+
+	for i := range changes {
+		if err := choco.Source(ctx, &changes[i]); err != nil {
+			log.Error().Err(err).Str("name", changes[i].Name).Msg("failed to apply source change")
+			name := strings.ToLower(changes[i].Name)
+			// forget the credentials so the source is added again on the next iteration
+			delete(credentials, name)
+			// keep a source which failed to be removed so its removal is attempted again
+			if changes[i].Action == choco.SRC_ACTION_REMOVE && engine.managedSources[name] {
+				managed[name] = true
+			}
+		}
+	}
+
+	engine.sourceCredentials = credentials
+
+	if !maps.Equal(managed, engine.managedSources) {
+		if err := saveManagedSources(managed); err != nil {
+			log.Error().Err(err).Msg("failed to save the managed sources")
+		}
+		engine.managedSources = managed
+	}
+
+	if len(changes) > 0 {
+		log.Info().Int("changes", len(changes)).Msg("applied source changes")
+
+		// the sources have changed, report what was actually configured
+		actual, err = choco.ListSources(ctx)
+		if err != nil {
+			log.Panic().Err(err).Msg("failed to list local sources")
+		}
+	}
+
+	if actual == nil {
+		actual = make(util.RepositoryList, 0)
+	}
+
+	// reporting the sources also clears the server's pending flag once they match
+	if err := engine.client.UpdateSources(actual); err != nil {
+		log.Panic().Err(err).Msg("failed to update server source inventory")
+	}
+
+	engine.sourcesSynced = true
+}
+
+// replace the sealed credentials of a source with their opened values
+func openSourceCredentials(repo *util.Repository) error {
+	for _, credential := range []*string{&repo.Password, &repo.CertificatePassword} {
+		if *credential == "" {
+			continue
+		}
+		opened, err := keys.Open(*credential)
+		if err != nil {
+			return err
+		}
+		*credential = string(opened)
+	}
+	return nil
+}
+
+// load the lowercase names of the sources added by the client, none if they were never saved
+func loadManagedSources() map[string]bool {
+	managed := make(map[string]bool)
+
+	data, err := os.ReadFile(config.ManagedSources())
+	if err != nil {
+		return managed
+	}
+
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return managed
+	}
+	for _, name := range names {
+		managed[strings.ToLower(name)] = true
+	}
+	return managed
+}
+
+// save the lowercase names of the sources added by the client
+func saveManagedSources(managed map[string]bool) error {
+	data, err := json.Marshal(slices.Sorted(maps.Keys(managed)))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(config.ManagedSources(), data, 0644)
+}
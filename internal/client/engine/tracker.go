@@ -5,6 +5,7 @@ import (
 
 	"github.com/goodieshq/sweettooth/internal/client/tracker"
 	"github.com/goodieshq/sweettooth/internal/util"
+	"github.com/goodieshq/sweettooth/pkg/api/client"
 )
 
 const (
@@ -19,24 +20,19 @@ func (engine *SweetToothEngine) Tracker() {
 
 	log.Trace().Msg("doTracker called")
 
-	// get a list of packages from the server, really only needs to be done once per execution
+	// get the hash of the server's inventory, really only needs to be done once per execution
 	if tracker.IsEmpty() {
-		pkg, err := engine.client.GetPackages()
+		hash, err := engine.client.GetPackagesHash()
 		if err != nil {
-			log.Error().Err(err).Msg("failed to get existing packages")
+			log.Error().Err(err).Msg("failed to get existing packages hash")
 			panic(err)
 		}
 
-		// output the inventory
-		log.Debug().
-			Int("choco_count", len(pkg.PackagesChoco)).
-			Int("outdated_count", len(pkg.PackagesOutdated)).
-			Int("system_count", len(pkg.PackagesSystem)).
-			Msg("inventory received from server")
+		log.Debug().Str("hash", hash).Msg("inventory hash received from server")
 
-		log.Trace().Msg("cacheing the package inventory in the software tracker")
-		// update the tracker's local cache with the existing packages
-		tracker.SetPackages(*pkg)
+		log.Trace().Msg("cacheing the inventory hash in the software tracker")
+		// the local inventory is compared against the hash, the server's packages are only known once they match
+		tracker.SetHash(hash)
 	}
 
 	ctx, cancel := engine.commandContext("tracker.Track", TIMEOUT_TRACKER)
@@ -56,16 +52,38 @@ func (engine *SweetToothEngine) Tracker() {
 
 	// if the software has changed (server's inventory is out of date)...
 	if changed {
-		// ... then update tracker cache with the current packages
-		log.Debug().Msg("tracker has identified software changes, updating cache")
+		log.Debug().Msg("tracker has identified software changes, updating server inventory")
 
-		// and update the server's packages
-		if err := engine.client.UpdatePackages(pkg); err != nil {
-			log.Panic().Err(err).Msg("failed to update server package inventory")
+		// ... then send only the changes if the server's packages are known
+		var hash string
+		if delta := tracker.Delta(pkg); delta != nil {
+			log.Debug().
+				Int("choco_changes", len(delta.PackagesChoco)).
+				Int("outdated_changes", len(delta.PackagesOutdated)).
+				Int("system_changes", len(delta.PackagesSystem)).
+				Msg("sending inventory delta")
+
+			hash, err = engine.client.UpdatePackagesDelta(delta)
+			if err == client.ErrPackagesConflict {
+				// the server's inventory changed since it was cached, fall back to sending the whole inventory
+				log.Warn().Msg("server inventory is stale, sending the whole inventory")
+				hash, err = "", nil
+			}
+			if err != nil {
+				log.Panic().Err(err).Msg("failed to update server package inventory")
+			}
+		}
+
+		// ... otherwise send the whole inventory
+		if hash == "" {
+			hash, err = engine.client.UpdatePackages(pkg)
+			if err != nil {
+				log.Panic().Err(err).Msg("failed to update server package inventory")
+			}
 		}
 
 		// update the cached packages only if the server update succeeded
-		tracker.SetPackages(*pkg)
+		tracker.SetPackages(*pkg, hash)
 
 		log.Trace().Msg("successfully updated server's package inventory")
 	} else {
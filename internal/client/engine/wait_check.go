@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/goodieshq/sweettooth/internal/util"
+	"github.com/goodieshq/sweettooth/pkg/api"
 	"github.com/goodieshq/sweettooth/pkg/api/client"
 )
 
@@ -11,14 +12,15 @@ const (
 	DEFAULT_PERIOD_WAITCHECK = time.Second * 10 // time to wait while waiting for admin approval
 )
 
-func (engine *SweetToothEngine) WaitCheck() bool {
+// wait for a successful check in, returning the state the server has flagged as changed
+func (engine *SweetToothEngine) WaitCheck() *api.CheckResponse {
 	log := util.Logger("engine.WaitCheck")
 	log.Trace().Msg("called")
 	defer log.Trace().Msg("finish")
 
 	for engine.isRunning() {
-		if err := engine.client.Check(); err == nil {
-			return true
+		if check, err := engine.client.Check(); err == nil {
+			return check
 		} else if err == client.ErrNodeNotRegistered {
 			engine.client.Registered = false
 			log.Panic().Msg("node is no longer registered")
@@ -57,6 +57,11 @@ func Sign(data []byte) []byte {
 	return ed25519.Sign(getSecretKey(), data)
 }
 
+// decrypt a secret which the server sealed to the node's public key, the result should only ever be kept in memory
+func Open(sealed string) ([]byte, error) {
+	return crypto.Open(getSecretKey(), sealed)
+}
+
 // aquire the node's stored secret key
 func getSecretKey() ed25519.PrivateKey {
 	return nodeSecretKey
@@ -2,7 +2,6 @@ package tracker
 
 import (
 	"context"
-	"reflect"
 	"sync"
 
 	"github.com/goodieshq/sweettooth/internal/client/choco"
@@ -11,25 +10,41 @@ import (
 )
 
 var mu sync.Mutex
-var packages *api.Packages
-
-func isDifferent(p1, p2 *api.Packages) bool {
-	return (p1 == nil || p2 == nil) || !reflect.DeepEqual(*p1, *p2)
-}
+var packages *api.Packages // the server's inventory, nil if only its hash is known
+var packagesHash string    // hash of the server's inventory, empty if it is unknown
 
 func IsEmpty() bool {
 	defer util.Locker(&mu)()
-	return packages == nil
+	return packagesHash == ""
 }
 
 func Reset() {
 	defer util.Locker(&mu)()
 	packages = nil
+	packagesHash = ""
+}
+
+// cache the hash of the server's inventory when its packages are not known, e.g. after a restart
+func SetHash(hash string) {
+	defer util.Locker(&mu)()
+	packages = nil
+	packagesHash = hash
 }
 
-func SetPackages(packagesNew api.Packages) {
+// cache the server's inventory along with its hash, used as the base of the next delta
+func SetPackages(packagesNew api.Packages, hash string) {
 	defer util.Locker(&mu)()
 	packages = &packagesNew
+	packagesHash = hash
+}
+
+// compute the delta from the server's inventory to the current packages, nil if the server's packages are not known
+func Delta(current *api.Packages) *api.PackagesDelta {
+	defer util.Locker(&mu)()
+	if packages == nil {
+		return nil
+	}
+	return api.NewPackagesDelta(packages, packagesHash, current)
 }
 
 func Track(ctx context.Context) (*api.Packages, bool, error) {
@@ -55,7 +70,13 @@ func Track(ctx context.Context) (*api.Packages, bool, error) {
 		PackagesOutdated: pkgOutdated,
 	}
 
-	return &pkg, isDifferent(&pkg, packages), nil
+	// an unchanged inventory is the same as the server's, so it becomes the base of the next delta
+	changed := pkg.Hash() != packagesHash
+	if !changed {
+		packages = &pkg
+	}
+
+	return &pkg, changed, nil
 }
 
 func Bootstrap() error {
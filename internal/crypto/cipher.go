@@ -1,6 +1,15 @@
 package crypto
 
-import "os"
+import (
+	"crypto/aes"
+	stdcipher "crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"io"
+	"os"
+
+	"golang.org/x/crypto/hkdf"
+)
 
 type Cipher interface {
 	Encipher(data []byte) ([]byte, error)
@@ -35,3 +44,49 @@ func DecipherFile(cipher Cipher, filename string) ([]byte, error) {
 
 	return cipher.Decipher(data)
 }
+
+// HKDF info label of the AES key, a new label derives a new key from the same secret
+const CIPHER_AES_INFO = "sweettooth-secrets-v1"
+
+// Cipher that performs AES-256-GCM encryption using a key derived from a secret, each output begins with its nonce
+type CipherAES struct {
+	aead stdcipher.AEAD
+}
+
+func NewCipherAES(secret []byte) (*CipherAES, error) {
+	// the key is derived for this purpose alone so the secret is never used as a key directly
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, nil, []byte(CIPHER_AES_INFO)), key); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := stdcipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CipherAES{aead: aead}, nil
+}
+
+func (cipher *CipherAES) Encipher(data []byte) ([]byte, error) {
+	nonce := make([]byte, cipher.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return cipher.aead.Seal(nonce, nonce, data, nil), nil
+}
+
+func (cipher *CipherAES) Decipher(data []byte) ([]byte, error) {
+	size := cipher.aead.NonceSize()
+	if len(data) < size {
+		return nil, ErrInvalidSealedData
+	}
+
+	return cipher.aead.Open(nil, data[:size], data[size:], nil)
+}
@@ -0,0 +1,65 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCipherAES(t *testing.T) {
+	cipher, err := NewCipherAES([]byte("server secret key"))
+	if err != nil {
+		t.Fatalf("NewCipherAES: %v", err)
+	}
+
+	for _, data := range [][]byte{
+		[]byte(""),
+		[]byte("hunter2"),
+		bytes.Repeat([]byte{0x00}, 4096),
+	} {
+		encrypted, err := cipher.Encipher(data)
+		if err != nil {
+			t.Fatalf("Encipher: %v", err)
+		}
+		if len(data) > 0 && bytes.Contains(encrypted, data) {
+			t.Errorf("encrypted data contains the plaintext %q", data)
+		}
+
+		decrypted, err := cipher.Decipher(encrypted)
+		if err != nil {
+			t.Fatalf("Decipher: %v", err)
+		}
+		if !bytes.Equal(decrypted, data) {
+			t.Errorf("decrypted %q, want %q", decrypted, data)
+		}
+	}
+}
+
+func TestCipherAESRejects(t *testing.T) {
+	cipher, err := NewCipherAES([]byte("server secret key"))
+	if err != nil {
+		t.Fatalf("NewCipherAES: %v", err)
+	}
+	other, err := NewCipherAES([]byte("another secret key"))
+	if err != nil {
+		t.Fatalf("NewCipherAES: %v", err)
+	}
+
+	encrypted, err := cipher.Encipher([]byte("hunter2"))
+	if err != nil {
+		t.Fatalf("Encipher: %v", err)
+	}
+
+	if _, err := other.Decipher(encrypted); err == nil {
+		t.Error("deciphered with a different secret")
+	}
+
+	tampered := bytes.Clone(encrypted)
+	tampered[len(tampered)-1] ^= 0x01
+	if _, err := cipher.Decipher(tampered); err == nil {
+		t.Error("deciphered tampered data")
+	}
+
+	if _, err := cipher.Decipher(encrypted[:4]); err == nil {
+		t.Error("deciphered data shorter than its nonce")
+	}
+}
@@ -0,0 +1,76 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// argon2id parameters used for newly hashed passwords
+const (
+	PASSWORD_ARGON2_TIME    = 3
+	PASSWORD_ARGON2_MEMORY  = 64 * 1024
+	PASSWORD_ARGON2_THREADS = 2
+	PASSWORD_ARGON2_KEYLEN  = 32
+	PASSWORD_SALT_LEN       = 16
+)
+
+var ErrInvalidPasswordHash = errors.New("invalid password hash")
+
+// hash a password with argon2id and a random salt, encoded in the standard PHC string format
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, PASSWORD_SALT_LEN)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, PASSWORD_ARGON2_TIME, PASSWORD_ARGON2_MEMORY, PASSWORD_ARGON2_THREADS, PASSWORD_ARGON2_KEYLEN)
+
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		PASSWORD_ARGON2_MEMORY,
+		PASSWORD_ARGON2_TIME,
+		PASSWORD_ARGON2_THREADS,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// verify a password against an encoded argon2id hash using the parameters stored within the hash
+func VerifyPassword(password, encoded string) (bool, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false, ErrInvalidPasswordHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, ErrInvalidPasswordHash
+	}
+
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false, ErrInvalidPasswordHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, ErrInvalidPasswordHash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return false, ErrInvalidPasswordHash
+	}
+
+	// derive the key using the same parameters and compare in constant time
+	derived := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, derived) == 1, nil
+}
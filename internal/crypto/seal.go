@@ -0,0 +1,120 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"math/big"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/nacl/box"
+)
+
+var ErrInvalidPublicKey = errors.New("invalid ed25519 public key")
+var ErrInvalidSealedData = errors.New("sealed data could not be opened")
+
+// the prime 2^255 - 19 over which both curve25519 and edwards25519 are defined
+var curve25519P = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+
+// convert a little-endian byte slice to a big integer
+func leBytesToInt(b []byte) *big.Int {
+	be := make([]byte, len(b))
+	for i := range b {
+		be[len(b)-1-i] = b[i]
+	}
+	return new(big.Int).SetBytes(be)
+}
+
+// convert a big integer to a 32-byte little-endian array
+func intToLEBytes(n *big.Int) *[32]byte {
+	var out [32]byte
+	be := n.FillBytes(make([]byte, 32))
+	for i := range be {
+		out[31-i] = be[i]
+	}
+	return &out
+}
+
+// Convert an ed25519 public key into the X25519 public key used for key agreement, the montgomery u-coordinate is
+// derived from the edwards y-coordinate with u = (1 + y) / (1 - y)
+func Ed25519PublicKeyToX25519(publicKey ed25519.PublicKey) (*[32]byte, error) {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return nil, ErrInvalidPublicKey
+	}
+
+	// the high bit holds the sign of x, which the montgomery form does not need
+	y := make([]byte, ed25519.PublicKeySize)
+	copy(y, publicKey)
+	y[31] &= 0x7f
+
+	yInt := leBytesToInt(y)
+	if yInt.Cmp(curve25519P) >= 0 {
+		return nil, ErrInvalidPublicKey
+	}
+
+	num := new(big.Int).Add(big.NewInt(1), yInt)
+	den := new(big.Int).Sub(big.NewInt(1), yInt)
+	den.Mod(den, curve25519P)
+	if den.Sign() == 0 {
+		return nil, ErrInvalidPublicKey
+	}
+
+	u := num.Mul(num, den.ModInverse(den, curve25519P))
+	u.Mod(u, curve25519P)
+
+	return intToLEBytes(u), nil
+}
+
+// Convert an ed25519 private key into the X25519 private key used for key agreement, which is the same scalar the
+// ed25519 signatures are computed with
+func Ed25519PrivateKeyToX25519(secretKey ed25519.PrivateKey) *[32]byte {
+	var out [32]byte
+	h := sha512.Sum512(secretKey.Seed())
+	copy(out[:], h[:32])
+	out[0] &= 248
+	out[31] &= 127
+	out[31] |= 64
+	return &out
+}
+
+// Encrypt data so that only the holder of the ed25519 private key can decrypt it, base64 encoded for transport
+func Seal(publicKey ed25519.PublicKey, data []byte) (string, error) {
+	recipient, err := Ed25519PublicKeyToX25519(publicKey)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := box.SealAnonymous(nil, data, recipient, rand.Reader)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt data sealed to the public key of the ed25519 private key
+func Open(secretKey ed25519.PrivateKey, sealed string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, err
+	}
+
+	priv := Ed25519PrivateKeyToX25519(secretKey)
+
+	pubBytes, err := curve25519.X25519(priv[:], curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+
+	var pub [32]byte
+	copy(pub[:], pubBytes)
+
+	opened, ok := box.OpenAnonymous(nil, data, &pub, priv)
+	if !ok {
+		return nil, ErrInvalidSealedData
+	}
+
+	return opened, nil
+}
@@ -0,0 +1,118 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+func generateKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	t.Helper()
+	publicKey, secretKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	return publicKey, secretKey
+}
+
+func TestEd25519PublicKeyToX25519(t *testing.T) {
+	for i := 0; i < 16; i++ {
+		publicKey, secretKey := generateKey(t)
+
+		converted, err := Ed25519PublicKeyToX25519(publicKey)
+		if err != nil {
+			t.Fatalf("Ed25519PublicKeyToX25519: %v", err)
+		}
+
+		priv := Ed25519PrivateKeyToX25519(secretKey)
+		want, err := curve25519.X25519(priv[:], curve25519.Basepoint)
+		if err != nil {
+			t.Fatalf("X25519: %v", err)
+		}
+
+		if !bytes.Equal(converted[:], want) {
+			t.Errorf("converted public key %x, want %x", converted[:], want)
+		}
+	}
+}
+
+func TestEd25519PublicKeyToX25519Invalid(t *testing.T) {
+	if _, err := Ed25519PublicKeyToX25519(make(ed25519.PublicKey, 31)); !errors.Is(err, ErrInvalidPublicKey) {
+		t.Errorf("short key: got %v, want %v", err, ErrInvalidPublicKey)
+	}
+
+	// y = 1 is the identity point, which has no montgomery form
+	identity := make(ed25519.PublicKey, ed25519.PublicKeySize)
+	identity[0] = 1
+	if _, err := Ed25519PublicKeyToX25519(identity); !errors.Is(err, ErrInvalidPublicKey) {
+		t.Errorf("identity key: got %v, want %v", err, ErrInvalidPublicKey)
+	}
+}
+
+func TestSealOpen(t *testing.T) {
+	publicKey, secretKey := generateKey(t)
+
+	for _, data := range [][]byte{
+		[]byte(""),
+		[]byte("--license-key=ABCD-1234"),
+		bytes.Repeat([]byte{0xff}, 4096),
+	} {
+		sealed, err := Seal(publicKey, data)
+		if err != nil {
+			t.Fatalf("Seal: %v", err)
+		}
+
+		opened, err := Open(secretKey, sealed)
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		if !bytes.Equal(opened, data) {
+			t.Errorf("opened %q, want %q", opened, data)
+		}
+	}
+}
+
+func TestOpenWrongKey(t *testing.T) {
+	publicKey, _ := generateKey(t)
+	_, otherKey := generateKey(t)
+
+	sealed, err := Seal(publicKey, []byte("secret"))
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+
+	if _, err := Open(otherKey, sealed); !errors.Is(err, ErrInvalidSealedData) {
+		t.Errorf("got %v, want %v", err, ErrInvalidSealedData)
+	}
+}
+
+func TestOpenTampered(t *testing.T) {
+	publicKey, secretKey := generateKey(t)
+
+	sealed, err := Seal(publicKey, []byte("secret"))
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		t.Fatalf("DecodeString: %v", err)
+	}
+
+	// flip a bit of the ephemeral key, the ciphertext, and the tag in turn
+	for _, i := range []int{0, len(data) - 1, len(data) / 2} {
+		tampered := bytes.Clone(data)
+		tampered[i] ^= 0x01
+		if _, err := Open(secretKey, base64.StdEncoding.EncodeToString(tampered)); !errors.Is(err, ErrInvalidSealedData) {
+			t.Errorf("byte %d: got %v, want %v", i, err, ErrInvalidSealedData)
+		}
+	}
+
+	if _, err := Open(secretKey, base64.StdEncoding.EncodeToString(data[:len(data)-1])); !errors.Is(err, ErrInvalidSealedData) {
+		t.Errorf("truncated: got %v, want %v", err, ErrInvalidSealedData)
+	}
+}
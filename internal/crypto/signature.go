@@ -17,6 +17,7 @@ import (
 
 const TOKEN_DRIFT_TOLERANCE = 5 * time.Minute
 const TOKEN_VALIDITY_PERIOD = 30 * time.Minute
+const MFA_TOKEN_VALIDITY_PERIOD = 5 * time.Minute
 const CLAIM_PUBKEY = "pubkey"
 
 type TokenGenerator func() string
@@ -93,21 +94,14 @@ func RefreshWebJWT(tokenString string, jwtSecret []byte) (tokenRefreshed string,
 }
 
 func VerifyWebJWT(tokenString string, jwtSecret []byte) (userid uuid.UUID, superAdmin bool, orgRoles roles.OrgRoles, err error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
+	claims, err := parseWebJWT(tokenString, jwtSecret)
 	if err != nil {
 		return
 	}
 
-	claims, ok := token.Claims.(*Claims)
-	if !ok || !token.Valid {
-		err = fmt.Errorf("invalid token or claims")
-		return
-	}
-
-	if claims.Issuer != info.APP_NAME {
-		err = fmt.Errorf("invalid issuer: %s", claims.Issuer)
+	// tokens awaiting a second factor cannot be used as a full web token
+	if claims.MfaPending {
+		err = errors.New("the token is pending multi-factor authentication")
 		return
 	}
 
@@ -126,9 +120,68 @@ func VerifyWebJWT(tokenString string, jwtSecret []byte) (userid uuid.UUID, super
 	return
 }
 
+// parse and validate a server-signed web token, returning its claims
+func parseWebJWT(tokenString string, jwtSecret []byte) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		return jwtSecret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("invalid token or claims")
+	}
+
+	if claims.Issuer != info.APP_NAME {
+		return nil, fmt.Errorf("invalid issuer: %s", claims.Issuer)
+	}
+
+	return claims, nil
+}
+
+// create a short-lived token proving the password was verified, to be exchanged for a web token with an MFA code
+func CreateWebMfaJWT(userid uuid.UUID, jwtSecret []byte) (string, error) {
+	claims := Claims{
+		MfaPending: true,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    info.APP_NAME,
+			Audience:  jwt.ClaimStrings{info.APP_NAME},
+			Subject:   userid.String(),
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			NotBefore: jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(MFA_TOKEN_VALIDITY_PERIOD)),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(jwtSecret)
+}
+
+// verify a token created by CreateWebMfaJWT and return the user ID it was issued to
+func VerifyWebMfaJWT(tokenString string, jwtSecret []byte) (uuid.UUID, error) {
+	claims, err := parseWebJWT(tokenString, jwtSecret)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if !claims.MfaPending {
+		return uuid.Nil, errors.New("the token is not pending multi-factor authentication")
+	}
+
+	subjectString, err := claims.GetSubject()
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return uuid.Parse(subjectString)
+}
+
 func CreateWebJWT(userid uuid.UUID, superadmin bool, orgRoles roles.OrgRoles, jwtSecret []byte) (string, error) {
 	claims := Claims{
-		OrgRoles: orgRoles,
+		OrgRoles:   orgRoles,
+		SuperAdmin: superadmin,
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    info.APP_NAME,
 			Audience:  jwt.ClaimStrings{info.APP_NAME},
@@ -196,5 +249,6 @@ func VerifyNodeJWT(tokenString string) (nodeid uuid.UUID, pubkey ed25519.PublicK
 type Claims struct {
 	OrgRoles   roles.OrgRoles `json:"org_roles"`
 	SuperAdmin bool           `json:"superadmin"`
+	MfaPending bool           `json:"mfa_pending,omitempty"`
 	jwt.RegisteredClaims
 }
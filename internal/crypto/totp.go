@@ -0,0 +1,137 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/goodieshq/sweettooth/pkg/info"
+)
+
+// TOTP parameters (RFC 6238) compatible with common authenticator apps
+const (
+	TOTP_SECRET_LEN = 20               // 160-bit secret as recommended for HMAC-SHA1
+	TOTP_PERIOD     = 30 * time.Second // time step of each code
+	TOTP_DIGITS     = 6                // number of digits in each code
+	TOTP_SKEW       = 1                // number of time steps before and after the current one to accept
+
+	MFA_RECOVERY_CODE_COUNT = 10 // number of recovery codes generated on enrollment
+	MFA_RECOVERY_CODE_LEN   = 10 // number of characters in each recovery code
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// generate a random base32-encoded TOTP secret
+func GenerateTOTPSecret() (string, error) {
+	secret := make([]byte, TOTP_SECRET_LEN)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(secret), nil
+}
+
+// create the otpauth:// URI used by authenticator apps to enroll the secret (usually as a QR code)
+func TOTPProvisioningURI(secret, account string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", info.APP_NAME)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(TOTP_DIGITS))
+	query.Set("period", fmt.Sprint(int(TOTP_PERIOD.Seconds())))
+
+	return (&url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + info.APP_NAME + ":" + account,
+		RawQuery: query.Encode(),
+	}).String()
+}
+
+// calculate the HOTP code (RFC 4226) for the given counter
+func totpCode(key []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// dynamic truncation
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < TOTP_DIGITS; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", TOTP_DIGITS, value%mod)
+}
+
+// verify a TOTP code against the secret at the given time, allowing for a small amount of clock skew. Returns the time
+// step (counter) the code matched so the caller can refuse to accept it, or any earlier code, a second time
+func VerifyTOTP(secret, code string, t time.Time) (uint64, bool) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(strings.TrimSpace(secret)))
+	if err != nil {
+		return 0, false
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) != TOTP_DIGITS {
+		return 0, false
+	}
+
+	counter := uint64(t.Unix()) / uint64(TOTP_PERIOD.Seconds())
+
+	var matched uint64
+	valid := 0
+	for skew := -TOTP_SKEW; skew <= TOTP_SKEW; skew++ {
+		// check every window so the time taken does not depend on which one matches
+		eq := subtle.ConstantTimeCompare([]byte(totpCode(key, counter+uint64(skew))), []byte(code))
+		matched = uint64(subtle.ConstantTimeSelect(eq, int(counter+uint64(skew)), int(matched)))
+		valid |= eq
+	}
+
+	return matched, valid == 1
+}
+
+// generate a set of single-use recovery codes to be shown to the user once
+func GenerateRecoveryCodes() ([]string, error) {
+	const alphabet = "abcdefghjkmnpqrstuvwxyz023456789" // 32 unambiguous characters
+
+	codes := make([]string, MFA_RECOVERY_CODE_COUNT)
+	buf := make([]byte, MFA_RECOVERY_CODE_LEN)
+	for i := range codes {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+
+		var sb strings.Builder
+		for j, b := range buf {
+			if j == MFA_RECOVERY_CODE_LEN/2 {
+				sb.WriteByte('-')
+			}
+			sb.WriteByte(alphabet[int(b)%len(alphabet)])
+		}
+		codes[i] = sb.String()
+	}
+
+	return codes, nil
+}
+
+// hash a recovery code for storage, codes are random so a fast hash is sufficient
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, "-", "")
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,8 +1,11 @@
 package schedule
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"time"
+	_ "time/tzdata" // windows clients do not ship the IANA time zone database
 
 	"github.com/rs/zerolog/log"
 	"github.com/teambition/rrule-go"
@@ -21,11 +24,10 @@ const (
 
 type ScheduleGroupType string
 
-var (
-	// Unless otherwise specified RRULE entries should be processed starting Jan 1 1970 according to the local time zone
-	SCHED_START_TIME        = time.Date(1970, time.January, 1, 0, 0, 0, 0, time.Local).UTC()
-	SCHED_START_TIME_SUFFIX = fmt.Sprintf(";DTSTART=%s", SCHED_START_TIME.Format(ISO8601))
-)
+// Unless otherwise specified RRULE entries should be processed starting Jan 1 1970 according to the entry's time zone
+func SchedStartTime(loc *time.Location) time.Time {
+	return time.Date(1970, time.January, 1, 0, 0, 0, 0, loc)
+}
 
 // Schedule Groups can be applied to a single node, a group, or an organization. All schedules are inherited.
 const (
@@ -50,18 +52,96 @@ func NewTime16(h int, m int) Time16 {
 	return Time16((h&0xff)<<8 | (m & 0xff))
 }
 
+// Check that the time is a real time of day (00:00 through 23:59)
+func (t Time16) Valid() bool {
+	return t.H() < 24 && t.M() < 60
+}
+
 // Struct which takes a timestamp, T, and includes the beginning and ending of that given day
 type DayInterval struct {
 	T   time.Time // a given arbitrary timestamp
 	Beg time.Time // the beginning of the day T is in
-	End time.Time // the end of the day T is in (Beg + 24hrs)
+	End time.Time // the end of the day T is in (the beginning of the following day)
 }
 
-// An entry of the schedule including an RRule (day) and Beg/End time
+// An entry of the schedule including an RRule (day) and Beg/End time. An entry which ends before it begins crosses
+// midnight and ends on the day after the RRule instance.
 type ScheduleEntry struct {
-	RRule   string `json:"rrule"`
-	TimeBeg Time16 `json:"time_beg"`
-	TimeEnd Time16 `json:"time_end"`
+	RRule    string `json:"rrule"`
+	TimeBeg  Time16 `json:"time_beg"`
+	TimeEnd  Time16 `json:"time_end"`
+	TimeZone string `json:"time_zone,omitempty"` // IANA time zone of the RRule and times, the evaluating machine's zone if empty
+	Blackout bool   `json:"blackout,omitempty"`  // the entry excludes its windows from every other entry of the schedule
+}
+
+// A concrete maintenance window produced by a schedule entry on a particular day
+type Window struct {
+	Entry    int       `json:"entry"`              // index of the schedule entry which produced the window
+	Beg      time.Time `json:"beg"`                // the first minute of the window
+	End      time.Time `json:"end"`                // the end of the last minute of the window
+	Blackout bool      `json:"blackout,omitempty"` // the window is excluded rather than allowed
+}
+
+// Get the location the entry is evaluated in, which is def unless the entry has its own time zone
+func (se ScheduleEntry) location(def *time.Location) (*time.Location, error) {
+	if se.TimeZone == "" {
+		return def, nil
+	}
+	return time.LoadLocation(se.TimeZone)
+}
+
+// Parse the RRule of the entry within its location, using the default start time if no DTSTART was provided within
+// the rule. Instances are generated in the location of the start time, so days and DST changes follow that location.
+func (se ScheduleEntry) parseRRule(loc *time.Location) (*rrule.RRule, error) {
+	opt, err := rrule.StrToROptionInLocation(se.RRule, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	if opt.Dtstart.IsZero() {
+		opt.Dtstart = SchedStartTime(loc)
+	}
+
+	return rrule.NewRRule(*opt)
+}
+
+// Check if a rule instance begins within the day. Days do not include their end, so an instance at midnight only
+// belongs to the day it begins.
+func (di *DayInterval) hasInstance(rr *rrule.RRule) bool {
+	next := rr.After(di.Beg, true)
+	return !next.IsZero() && next.Before(di.End)
+}
+
+// Get the window of the entry on the day, which ends after the full TimeEnd minute has passed. A time skipped when the
+// clocks go forward is reached when they go forward, so a window entirely within the skipped hour is empty. A time which
+// occurs twice when the clocks go back is its first occurrence, so a window ending within the repeated hour ends the
+// first time it passes
+func (se ScheduleEntry) window(di *DayInterval) (time.Time, time.Time) {
+	y, m, d := di.Beg.Date()
+	loc := di.Beg.Location()
+	beg := wallClock(y, m, d, se.TimeBeg.H(), se.TimeBeg.M(), loc)
+	if se.TimeEnd < se.TimeBeg {
+		// the window crosses midnight and ends on the following day
+		d++
+	}
+	end := wallClock(y, m, d, se.TimeEnd.H(), se.TimeEnd.M()+1, loc)
+	return beg, end
+}
+
+// Get the first moment the clock in the location reads the time of day on the date, which is when the clock goes
+// forward if the time is skipped
+func wallClock(year int, month time.Month, day, hour, min int, loc *time.Location) time.Time {
+	t := time.Date(year, month, day, hour, min, 0, 0, loc)
+
+	// a skipped time is normalized to a clock reading before the requested one, the zone in effect ends at the jump
+	want := time.Date(year, month, day, hour, min, 0, 0, time.UTC)
+	got := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+	if got.Before(want) {
+		if _, end := t.ZoneBounds(); !end.IsZero() {
+			return end
+		}
+	}
+	return t
 }
 
 // Check if a given schedule entry resides within a particular day and time range according to the RRules
@@ -71,39 +151,73 @@ func (se ScheduleEntry) Matches(di *DayInterval) bool {
 		return false
 	}
 
+	loc, err := se.location(di.T.Location())
+	if err != nil {
+		// unknown time zones are always false
+		log.Warn().Err(err).Str("time_zone", se.TimeZone).Msg("invalid time zone")
+		return false
+	}
+
 	// convert the rule string to a rule object
-	rr, err := rrule.StrToRRule(se.RRule)
+	rr, err := se.parseRRule(loc)
 
 	if err != nil {
 		// invalid rules are always false
-		log.Warn().Err(err).Str("rrule", se.RRule+SCHED_START_TIME_SUFFIX).Msg("invalid rrule")
+		log.Warn().Err(err).Str("rrule", se.RRule).Msg("invalid rrule")
 		return false
 	}
 
-	// check if no DTSTART was provided within the rrule
-	if (rr.OrigOptions.Dtstart == time.Time{}) {
-		// no start time was provided in the rule, use default time and re-run
-		return ScheduleEntry{
-			RRule:   se.RRule + SCHED_START_TIME_SUFFIX,
-			TimeBeg: se.TimeBeg,
-			TimeEnd: se.TimeEnd,
-		}.Matches(di)
+	// the day and time are evaluated within the entry's time zone, and match the windows the schedule expands into
+	local := NewDayInterval(di.T.In(loc))
+	if beg, end := se.window(&local); !di.T.Before(beg) && di.T.Before(end) && local.hasInstance(rr) {
+		return true
 	}
 
-	// check rule instances between the start and end of today
-	if len(rr.Between(di.Beg, di.End, true)) == 0 {
-		return false
+	// a window which crosses midnight may have begun yesterday and not yet ended
+	if se.TimeEnd < se.TimeBeg {
+		yesterday := NewDayInterval(local.Beg.AddDate(0, 0, -1))
+		if beg, end := se.window(&yesterday); !di.T.Before(beg) && di.T.Before(end) && yesterday.hasInstance(rr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check that a schedule entry can be evaluated by a client before it is stored
+func (se ScheduleEntry) Validate() error {
+	if se.RRule == "" {
+		return errors.New("schedule entry rrule is required")
 	}
 
-	// the day matches, now check the time range
-	n := NewTime16(di.T.Hour(), di.T.Minute())
-	return n >= se.TimeBeg && n <= se.TimeEnd
+	loc, err := se.location(time.Local)
+	if err != nil {
+		return fmt.Errorf("schedule entry time zone is invalid: %w", err)
+	}
+
+	// the client uses the default start time when none is provided, so that must parse too
+	rr, err := se.parseRRule(loc)
+	if err != nil {
+		return fmt.Errorf("schedule entry rrule is invalid: %w", err)
+	}
+
+	// the rule selects days while the times select the window within them, and a finer rule would generate an
+	// instance every hour, minute or second since the start time each time it is evaluated
+	if rr.OrigOptions.Freq > rrule.DAILY {
+		return fmt.Errorf("schedule entry rrule frequency %s is finer than DAILY", rr.OrigOptions.Freq)
+	}
+
+	if !se.TimeBeg.Valid() || !se.TimeEnd.Valid() {
+		return errors.New("schedule entry times must be between 00:00 and 23:59")
+	}
+
+	return nil
 }
 
 func NewDayInterval(t time.Time) DayInterval {
 	// get the beginning and ending of the day at time t
+	// days are not always 24 hours long when DST begins or ends
 	beg := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
-	end := beg.Add(24 * time.Hour)
+	end := time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
 	return DayInterval{T: t, Beg: beg, End: end}
 }
 
@@ -112,12 +226,132 @@ func Now() DayInterval {
 }
 
 func (s *Schedule) Matches(di *DayInterval) bool {
+	matches := false
+
 	// iterate over the schedule entries
 	for _, entry := range *s {
 		// check if the entry includes the current timestamp
 		if entry.Matches(di) {
-			return true
+			if entry.Blackout {
+				// blackouts override every window, including those inherited from other schedules
+				return false
+			}
+			matches = true
 		}
 	}
-	return false
+	return matches
+}
+
+// Check that every entry of the schedule is valid
+func (s Schedule) Validate() error {
+	for i, entry := range s {
+		if err := entry.Validate(); err != nil {
+			return fmt.Errorf("entry %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Expand the schedule into every window which overlaps the range from and to, in the order the windows begin. Days are
+// evaluated in each entry's time zone, or in the location of from the same way a client evaluates them in its local time.
+// Blackout windows are included and flagged. Invalid entries never produce a window, just as they never match.
+func (s Schedule) Windows(from, to time.Time) []Window {
+	windows := make([]Window, 0)
+	if !from.Before(to) {
+		return windows
+	}
+
+	for i, entry := range s {
+		if entry.RRule == "" {
+			continue
+		}
+
+		loc, err := entry.location(from.Location())
+		if err != nil {
+			continue
+		}
+
+		rr, err := entry.parseRRule(loc)
+		if err != nil {
+			continue
+		}
+
+		// a window which crosses midnight may have begun the day before from
+		first := NewDayInterval(from.In(loc).AddDate(0, 0, -1))
+		last := NewDayInterval(to.In(loc))
+
+		// find every rule instance within the range at once rather than searching each day
+		var prev time.Time
+		for _, instance := range rr.Between(first.Beg, last.End, true) {
+			di := NewDayInterval(instance.In(loc))
+			if di.Beg.Equal(prev) {
+				// the day already has a window from an earlier instance
+				continue
+			}
+			prev = di.Beg
+
+			// a window entirely within the hour skipped when the clocks go forward is empty
+			beg, end := entry.window(&di)
+			if beg.Before(end) && end.After(from) && beg.Before(to) {
+				windows = append(windows, Window{Entry: i, Beg: beg, End: end, Blackout: entry.Blackout})
+			}
+		}
+	}
+
+	sort.SliceStable(windows, func(i, j int) bool {
+		return windows[i].Beg.Before(windows[j].Beg)
+	})
+
+	return windows
+}
+
+// Expand the schedule into the windows in which it actually matches between from and to, which are the windows of its
+// entries with every blackout window removed. A window interrupted by a blackout is split into the parts around it.
+func (s Schedule) EffectiveWindows(from, to time.Time) []Window {
+	var allowed, blackouts []Window
+	for _, window := range s.Windows(from, to) {
+		if window.Blackout {
+			blackouts = append(blackouts, window)
+		} else {
+			allowed = append(allowed, window)
+		}
+	}
+
+	windows := make([]Window, 0, len(allowed))
+	for _, window := range allowed {
+		parts := []Window{window}
+		for _, blackout := range blackouts {
+			var remaining []Window
+			for _, part := range parts {
+				if !blackout.Beg.Before(part.End) || !blackout.End.After(part.Beg) {
+					// the blackout does not overlap this part
+					remaining = append(remaining, part)
+					continue
+				}
+				if part.Beg.Before(blackout.Beg) {
+					remaining = append(remaining, Window{Entry: part.Entry, Beg: part.Beg, End: blackout.Beg})
+				}
+				if part.End.After(blackout.End) {
+					remaining = append(remaining, Window{Entry: part.Entry, Beg: blackout.End, End: part.End})
+				}
+			}
+			parts = remaining
+		}
+		windows = append(windows, parts...)
+	}
+
+	sort.SliceStable(windows, func(i, j int) bool {
+		return windows[i].Beg.Before(windows[j].Beg)
+	})
+
+	return windows
+}
+
+// Get up to n effective windows which have not ended as of from, searching no further than the horizon
+func (s Schedule) NextWindows(from time.Time, n int, horizon time.Duration) []Window {
+	windows := s.EffectiveWindows(from, from.Add(horizon))
+	if len(windows) > n {
+		windows = windows[:n]
+	}
+	return windows
 }
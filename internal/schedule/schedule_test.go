@@ -0,0 +1,192 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+// In America/New_York on 2024-03-10 the clocks go forward from 02:00 EST to 03:00 EDT, and on 2024-11-03 they go back
+// from 02:00 EDT to 01:00 EST. Instants are given in UTC so the repeated hour is unambiguous.
+const testTimeZone = "America/New_York"
+
+func utc(month time.Month, day, hour, min int) time.Time {
+	return time.Date(2024, month, day, hour, min, 0, 0, time.UTC)
+}
+
+func dailyEntry(beg, end Time16) ScheduleEntry {
+	return ScheduleEntry{RRule: "FREQ=DAILY", TimeBeg: beg, TimeEnd: end, TimeZone: testTimeZone}
+}
+
+func TestEntryMatchesDST(t *testing.T) {
+	skipped := dailyEntry(NewTime16(2, 0), NewTime16(2, 59))       // entirely within the skipped hour
+	spansGap := dailyEntry(NewTime16(1, 30), NewTime16(3, 30))     // spans the skipped hour
+	repeated := dailyEntry(NewTime16(1, 0), NewTime16(1, 59))      // the repeated hour
+	endsRepeated := dailyEntry(NewTime16(0, 30), NewTime16(1, 15)) // ends within the repeated hour
+	crossing := dailyEntry(NewTime16(22, 0), NewTime16(3, 0))      // crosses midnight over either change
+	crossingShort := dailyEntry(NewTime16(22, 0), NewTime16(1, 30))
+
+	tests := []struct {
+		name  string
+		entry ScheduleEntry
+		t     time.Time
+		want  bool
+	}{
+		{"skipped hour on a normal day", skipped, utc(time.March, 9, 7, 30), true},
+		{"skipped hour before the change", skipped, utc(time.March, 10, 6, 59), false},
+		{"skipped hour after the change", skipped, utc(time.March, 10, 7, 0), false},
+		{"spans gap before the change", spansGap, utc(time.March, 10, 6, 45), true},
+		{"spans gap after the change", spansGap, utc(time.March, 10, 7, 15), true},
+		{"spans gap last minute", spansGap, utc(time.March, 10, 7, 30), true},
+		{"spans gap ended", spansGap, utc(time.March, 10, 7, 31), false},
+		{"repeated hour before it begins", repeated, utc(time.November, 3, 4, 59), false},
+		{"repeated hour first occurrence", repeated, utc(time.November, 3, 5, 30), true},
+		{"repeated hour second occurrence", repeated, utc(time.November, 3, 6, 30), true},
+		{"repeated hour ended", repeated, utc(time.November, 3, 7, 0), false},
+		{"ends in repeated hour first occurrence", endsRepeated, utc(time.November, 3, 5, 10), true},
+		{"ends in repeated hour second occurrence", endsRepeated, utc(time.November, 3, 6, 10), false},
+		{"crossing spring before it begins", crossing, utc(time.March, 10, 2, 59), false},
+		{"crossing spring before the change", crossing, utc(time.March, 10, 6, 30), true},
+		{"crossing spring last minute", crossing, utc(time.March, 10, 7, 0), true},
+		{"crossing spring ended", crossing, utc(time.March, 10, 7, 1), false},
+		{"crossing fall first occurrence", crossing, utc(time.November, 3, 5, 30), true},
+		{"crossing fall second occurrence", crossing, utc(time.November, 3, 6, 30), true},
+		{"crossing fall last minute", crossing, utc(time.November, 3, 8, 0), true},
+		{"crossing fall ended", crossing, utc(time.November, 3, 8, 1), false},
+		{"crossing fall ends in repeated hour first occurrence", crossingShort, utc(time.November, 3, 5, 20), true},
+		{"crossing fall ends in repeated hour second occurrence", crossingShort, utc(time.November, 3, 6, 20), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			di := NewDayInterval(tt.t)
+			if got := tt.entry.Matches(&di); got != tt.want {
+				t.Errorf("Matches(%s) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleWindowsDST(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule Schedule
+		from, to time.Time
+		want     []Window
+	}{
+		{
+			name:     "skipped hour has no window",
+			schedule: Schedule{dailyEntry(NewTime16(2, 0), NewTime16(2, 59))},
+			from:     utc(time.March, 10, 5, 0),
+			to:       utc(time.March, 11, 4, 0),
+			want:     []Window{},
+		},
+		{
+			name:     "window spanning the skipped hour",
+			schedule: Schedule{dailyEntry(NewTime16(1, 30), NewTime16(3, 30))},
+			from:     utc(time.March, 10, 5, 0),
+			to:       utc(time.March, 11, 4, 0),
+			want:     []Window{{Entry: 0, Beg: utc(time.March, 10, 6, 30), End: utc(time.March, 10, 7, 31)}},
+		},
+		{
+			name:     "repeated hour is a single window of both occurrences",
+			schedule: Schedule{dailyEntry(NewTime16(1, 0), NewTime16(1, 59))},
+			from:     utc(time.November, 3, 4, 0),
+			to:       utc(time.November, 4, 5, 0),
+			want:     []Window{{Entry: 0, Beg: utc(time.November, 3, 5, 0), End: utc(time.November, 3, 7, 0)}},
+		},
+		{
+			name:     "window ending in the repeated hour ends at its first occurrence",
+			schedule: Schedule{dailyEntry(NewTime16(0, 30), NewTime16(1, 15))},
+			from:     utc(time.November, 3, 4, 0),
+			to:       utc(time.November, 4, 5, 0),
+			want:     []Window{{Entry: 0, Beg: utc(time.November, 3, 4, 30), End: utc(time.November, 3, 5, 16)}},
+		},
+		{
+			name:     "crossing midnight over the spring change",
+			schedule: Schedule{dailyEntry(NewTime16(22, 0), NewTime16(3, 0))},
+			from:     utc(time.March, 9, 12, 0),
+			to:       utc(time.March, 11, 12, 0),
+			want: []Window{
+				{Entry: 0, Beg: utc(time.March, 10, 3, 0), End: utc(time.March, 10, 7, 1)},
+				{Entry: 0, Beg: utc(time.March, 11, 2, 0), End: utc(time.March, 11, 7, 1)},
+			},
+		},
+		{
+			name:     "crossing midnight over the fall change",
+			schedule: Schedule{dailyEntry(NewTime16(22, 0), NewTime16(3, 0))},
+			from:     utc(time.November, 2, 12, 0),
+			to:       utc(time.November, 4, 12, 0),
+			want: []Window{
+				{Entry: 0, Beg: utc(time.November, 3, 2, 0), End: utc(time.November, 3, 8, 1)},
+				{Entry: 0, Beg: utc(time.November, 4, 3, 0), End: utc(time.November, 4, 8, 1)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertWindows(t, tt.schedule.Windows(tt.from, tt.to), tt.want)
+		})
+	}
+}
+
+func TestScheduleNextWindowsDST(t *testing.T) {
+	crossing := dailyEntry(NewTime16(22, 0), NewTime16(3, 0))
+	blackout := dailyEntry(NewTime16(1, 0), NewTime16(1, 59))
+	blackout.Blackout = true
+
+	tests := []struct {
+		name     string
+		schedule Schedule
+		from     time.Time
+		n        int
+		want     []Window
+	}{
+		{
+			name:     "spring change",
+			schedule: Schedule{crossing},
+			from:     utc(time.March, 9, 12, 0),
+			n:        2,
+			want: []Window{
+				{Entry: 0, Beg: utc(time.March, 10, 3, 0), End: utc(time.March, 10, 7, 1)},
+				{Entry: 0, Beg: utc(time.March, 11, 2, 0), End: utc(time.March, 11, 7, 1)},
+			},
+		},
+		{
+			name:     "fall change from within the window",
+			schedule: Schedule{crossing},
+			from:     utc(time.November, 3, 6, 30),
+			n:        1,
+			want:     []Window{{Entry: 0, Beg: utc(time.November, 3, 2, 0), End: utc(time.November, 3, 8, 1)}},
+		},
+		{
+			name:     "fall change with both occurrences of the repeated hour blacked out",
+			schedule: Schedule{crossing, blackout},
+			from:     utc(time.November, 2, 12, 0),
+			n:        2,
+			want: []Window{
+				{Entry: 0, Beg: utc(time.November, 3, 2, 0), End: utc(time.November, 3, 5, 0)},
+				{Entry: 0, Beg: utc(time.November, 3, 7, 0), End: utc(time.November, 3, 8, 1)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertWindows(t, tt.schedule.NextWindows(tt.from, tt.n, 72*time.Hour), tt.want)
+		})
+	}
+}
+
+func assertWindows(t *testing.T, got, want []Window) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d windows %v, want %d windows %v", len(got), got, len(want), want)
+	}
+	for i := range want {
+		if got[i].Entry != want[i].Entry || got[i].Blackout != want[i].Blackout ||
+			!got[i].Beg.Equal(want[i].Beg) || !got[i].End.Equal(want[i].End) {
+			t.Errorf("window %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/goodieshq/sweettooth/internal/crypto"
 	"github.com/goodieshq/sweettooth/internal/server/requests"
 	"github.com/goodieshq/sweettooth/internal/server/responses"
+	"github.com/goodieshq/sweettooth/internal/util"
 	"github.com/goodieshq/sweettooth/pkg/api"
 	"github.com/google/uuid"
 )
@@ -20,13 +21,24 @@ const (
 
 // GET /api/v1/node/check
 func (h *ApiNodeHandler) HandleGetNodeCheck(w http.ResponseWriter, r *http.Request) {
-	// a successful check should only yield a 204
-	// this should indicate a properly formed and valid JWT, node ID exists in the database and is enabled
+	// a successful check indicates a properly formed and valid JWT, node ID exists in the database and is enabled
 	// must be protected by middleware that does the above
-	responses.JsonResponse(w, r, http.StatusNoContent, nil)
+	check, err := h.core.GetNodeCheck(r.Context(), *requests.NodeNID(r), requests.RequestQueryAttemptsMax(r))
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	if check == nil {
+		responses.ErrNodeNotFound(w, r, nil)
+		return
+	}
+
+	// let the node know which of its state has changed since it was last applied
+	responses.JsonResponse(w, r, http.StatusOK, check)
 }
 
-// GET /api/v1/node/schedules
+// GET /api/v1/node/schedule
 func (h *ApiNodeHandler) HandleGetNodeSchedule(w http.ResponseWriter, r *http.Request) {
 	sched, err := h.core.GetNodeSchedule(r.Context(), *requests.NodeNID(r))
 	if err != nil {
@@ -37,6 +49,27 @@ func (h *ApiNodeHandler) HandleGetNodeSchedule(w http.ResponseWriter, r *http.Re
 	responses.JsonResponse(w, r, http.StatusOK, sched)
 }
 
+// PUT /api/v1/node/schedule
+func (h *ApiNodeHandler) HandlePutNodeSchedule(w http.ResponseWriter, r *http.Request) {
+	var sched api.Schedule
+
+	// decode the schedule the node has applied
+	err := json.NewDecoder(r.Body).Decode(&sched)
+	if err != nil {
+		responses.ErrInvalidRequestBody(w, r, err) // improper form submission
+		return
+	}
+
+	// the pending flag is only cleared if the node applied the current schedule, otherwise it will be acquired again
+	_, err = h.core.ConfirmNodeSchedule(r.Context(), *requests.NodeNID(r), sched)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusNoContent, nil)
+}
+
 // POST /api/v1/node/register
 func (h *ApiNodeHandler) HandlePostNodeRegister(w http.ResponseWriter, r *http.Request) {
 	var req api.RegistrationRequest
@@ -98,12 +131,18 @@ func (h *ApiNodeHandler) HandlePostNodeRegister(w http.ResponseWriter, r *http.R
 	}
 
 	// node does not exist and registration token does point to a valid organization
-	_, err = h.core.CreateNode(r.Context(), req)
+	node, err = h.core.CreateNode(r.Context(), req)
 	if err != nil {
 		responses.ErrServerError(w, r, err)
 		return
 	}
 
+	// the token expired or reached its use limit since it was checked
+	if node == nil {
+		responses.ErrRegistrationTokenInvalid(w, r, nil)
+		return
+	}
+
 	responses.JsonResponse(w, r, http.StatusCreated, nil)
 }
 
@@ -143,7 +182,109 @@ func (h *ApiNodeHandler) HandlePutNodePackages(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	// always return with a 204 if everything was successful, regardless of if packages were updated or not
+	// the node keeps the hash to compute its next delta against
+	responses.JsonResponse(w, r, http.StatusOK, &api.PackagesHash{Hash: packages.Hash()})
+}
+
+// GET /api/v1/node/packages/hash
+func (h *ApiNodeHandler) HandleGetNodePackagesHash(w http.ResponseWriter, r *http.Request) {
+	// the node compares the hash against its own inventory rather than acquiring the whole server inventory
+	pkg, err := h.core.GetNodePackages(r.Context(), *requests.NodeNID(r))
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	if pkg == nil {
+		responses.ErrNodeNotFound(w, r, errors.New("couldn't acquire node package"))
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, &api.PackagesHash{Hash: pkg.Hash()})
+}
+
+// PATCH /api/v1/node/packages
+func (h *ApiNodeHandler) HandlePatchNodePackages(w http.ResponseWriter, r *http.Request) {
+	var signed api.SignedPackagesDelta
+	err := json.NewDecoder(r.Body).Decode(&signed)
+	if err != nil {
+		responses.ErrInvalidRequestBody(w, r, err) // improper form submission
+		return
+	}
+
+	node, err := h.core.GetNode(r.Context(), *requests.NodeNID(r))
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	if node == nil {
+		responses.ErrNodeNotFound(w, r, nil)
+		return
+	}
+
+	// the delta must be signed by the same key the node registered with
+	pubkeyBytes, err := base64.StdEncoding.DecodeString(node.PublicKey)
+	if err != nil {
+		responses.ErrServerError(w, r, err)
+		return
+	}
+
+	if !crypto.VerifyBase64(ed25519.PublicKey(pubkeyBytes), signed.Delta, signed.Signature) {
+		responses.ErrInvalidRequestBody(w, r, errors.New("delta signature is invalid"))
+		return
+	}
+
+	var delta api.PackagesDelta
+	if err := json.Unmarshal(signed.Delta, &delta); err != nil {
+		responses.ErrInvalidRequestBody(w, r, err)
+		return
+	}
+
+	// a stale delta is rejected, the node should send its whole inventory instead
+	applied, err := h.core.UpdateNodePackagesDelta(r.Context(), node.ID, &delta)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	if !applied {
+		responses.ErrNodePackagesConflict(w, r, nil)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, &api.PackagesHash{Hash: delta.Hash})
+}
+
+// GET /api/v1/node/sources
+func (h *ApiNodeHandler) HandleGetNodeSources(w http.ResponseWriter, r *http.Request) {
+	// get the effective sources the node should have configured
+	sources, err := h.core.GetNodeSources(r.Context(), *requests.NodeNID(r))
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, sources)
+}
+
+// PUT /api/v1/node/sources
+func (h *ApiNodeHandler) HandlePutNodeSources(w http.ResponseWriter, r *http.Request) {
+	var sources util.RepositoryList
+
+	// decode the sources the node actually has configured
+	err := json.NewDecoder(r.Body).Decode(&sources)
+	if err != nil {
+		responses.ErrInvalidRequestBody(w, r, err) // improper form submission
+		return
+	}
+
+	err = h.core.ReportNodeSources(r.Context(), *requests.NodeNID(r), sources)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
 	responses.JsonResponse(w, r, http.StatusNoContent, nil)
 }
 
@@ -152,9 +293,10 @@ func (h *ApiNodeHandler) HandleGetNodePackagesJobs(w http.ResponseWriter, r *htt
 	// get the node ID from the request's JWT
 	nodeid := *requests.NodeNID(r)
 	attemptsMax := requests.RequestQueryAttemptsMax(r)
+	overdue := requests.RequestQueryOverdue(r)
 
 	// get job list from the database
-	jobs, err := h.core.GetPackageJobList(r.Context(), nodeid, attemptsMax)
+	jobs, err := h.core.GetPackageJobList(r.Context(), nodeid, attemptsMax, overdue)
 	if err != nil {
 		responses.ErrServiceUnavailable(w, r, errors.New("failed to get the job list"))
 		return
@@ -188,12 +330,13 @@ func (h *ApiNodeHandler) HandleGetNodePackagesJob(w http.ResponseWriter, r *http
 	}
 
 	// check that the job is assigned to this node
-	if job.NodeID != nodeid {
+	if job == nil || job.NodeID != nodeid {
 		responses.ErrJobMissingOrExpired(w, r, errors.New("the job ID is not assigned to this node"))
 		return
 	}
 
-	job, err = h.core.AttemptPackageJob(r.Context(), jobid, nodeid, attemptsMax)
+	// acquiring the job details leases the job to the node, which must heartbeat while it runs the job
+	job, err = h.core.LeasePackageJob(r.Context(), jobid, nodeid, attemptsMax)
 	if err != nil {
 		responses.ErrServerError(w, r, err)
 		return
@@ -238,13 +381,14 @@ func (h *ApiNodeHandler) HandlePostNodePackagesJob(w http.ResponseWriter, r *htt
 	}
 
 	// check that the job is assigned to this node
-	if job.NodeID != nodeid {
+	if job == nil || job.NodeID != nodeid {
 		responses.ErrJobMissingOrExpired(w, r, errors.New("the job ID is not assigned to this node"))
 		return
 	}
 
-	if job.Result.Status != 0 {
-		responses.ErrJobAlreadyCompleted(w, r, errors.New("job has already been completed"))
+	// only a job leased to the node can be completed
+	if job.State != api.JOB_STATE_LEASED && job.State != api.JOB_STATE_RUNNING {
+		responses.ErrJobAlreadyCompleted(w, r, errors.New("job is not leased to the node"))
 		return
 	}
 
@@ -260,3 +404,52 @@ func (h *ApiNodeHandler) HandlePostNodePackagesJob(w http.ResponseWriter, r *htt
 
 	responses.JsonResponse(w, r, http.StatusOK, nil)
 }
+
+// POST /api/v1/node/packages/jobs/{id}/output
+func (h *ApiNodeHandler) HandlePostNodePackagesJobOutput(w http.ResponseWriter, r *http.Request) {
+	var chunk api.PackageJobOutput
+
+	jobid, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		responses.ErrInvalidJobID(w, r, err)
+		return
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&chunk)
+	if err != nil {
+		responses.ErrInvalidRequestBody(w, r, err) // improper form submission
+		return
+	}
+
+	nodeid := *requests.NodeNID(r)
+
+	appended, err := h.core.AppendPackageJobOutput(r.Context(), jobid, nodeid, chunk.Output)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	if appended {
+		responses.JsonResponse(w, r, http.StatusOK, &api.PackageJobOutputResponse{Cancelled: false})
+		return
+	}
+
+	// the job is no longer leased to the node, find out whether the node should stop running it
+	job, err := h.core.GetPackageJob(r.Context(), jobid)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	if job == nil || job.NodeID != nodeid {
+		responses.ErrJobMissingOrExpired(w, r, errors.New("the job ID is not assigned to this node"))
+		return
+	}
+
+	if job.State != api.JOB_STATE_CANCELLED {
+		responses.ErrJobAlreadyCompleted(w, r, errors.New("job has already been completed"))
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, &api.PackageJobOutputResponse{Cancelled: true})
+}
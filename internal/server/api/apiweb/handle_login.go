@@ -2,47 +2,189 @@ package apiweb
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/goodieshq/sweettooth/internal/crypto"
 	"github.com/goodieshq/sweettooth/internal/server/responses"
-	"github.com/goodieshq/sweettooth/internal/server/roles"
-	"github.com/google/uuid"
+	"github.com/goodieshq/sweettooth/pkg/api"
 	"github.com/rs/zerolog/log"
 )
 
+const (
+	LOGIN_MAX_FAILURES     = 5                // consecutive failed logins before the user is locked out
+	LOGIN_LOCKOUT_DURATION = 15 * time.Minute // how long a user is locked out for
+)
+
+// a password hash to verify against when the user does not exist so that failures take the same amount of time
+var loginDummyHash, _ = crypto.HashPassword("")
+
 type LoginRequest struct {
-	Username string `json:"username"`
+	Email    string `json:"email"`
 	Password string `json:"password"`
 }
 
+type LoginMfaRequest struct {
+	MfaToken     string `json:"mfa_token"`               // token returned by the password login
+	Code         string `json:"code"`                    // TOTP code from the authenticator app
+	RecoveryCode string `json:"recovery_code,omitempty"` // single-use recovery code used instead of a TOTP code
+}
+
 // POST /api/v1/web/login
 func (h *ApiWebHandler) HandlePostWebLogin(secret []byte) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// TODO: process login properly
 		var creds LoginRequest
 		if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
 			responses.ErrFormFailure(w, r, err)
 			return
 		}
 
-		log.Info().Str("username", creds.Username).Str("password", creds.Password).Msg("Login attempt")
-		if creds.Username == "admin" && creds.Password == "admin123" {
-			token, err := crypto.CreateWebJWT(
-				uuid.MustParse("f3d670ad-7189-40be-b26e-ba4fbc9e2469"),
-				true,
-				roles.OrgRoles{},
-				secret,
-			)
-			if err == nil {
-				responses.JsonResponse(w, r, http.StatusOK, map[string]string{
-					"message": "Login successful",
-					"token":   token,
-				})
+		creds.Email = strings.TrimSpace(creds.Email)
+		log.Info().Str("email", creds.Email).Msg("Login attempt")
+
+		user, err := h.core.GetUserLogin(r.Context(), creds.Email)
+		if err != nil {
+			responses.ErrServiceUnavailable(w, r, err)
+			return
+		}
+
+		// always verify a password, even for unknown users, so the response time does not reveal which users exist
+		hash := loginDummyHash
+		if user != nil {
+			hash = user.PasswordHash
+		}
+
+		valid, err := crypto.VerifyPassword(creds.Password, hash)
+		if err != nil {
+			log.Error().Err(err).Str("email", creds.Email).Msg("failed to verify the password hash")
+			valid = false
+		}
+
+		if user == nil {
+			responses.ErrLoginError(w, r, errors.New("user not found"))
+			return
+		}
+
+		// locked out users are refused without counting the attempt against them
+		if user.Locked {
+			responses.ErrLoginError(w, r, errors.New("user is locked out"))
+			return
+		}
+
+		if !valid {
+			if err := h.core.UserLoginFailed(r.Context(), user.ID, LOGIN_MAX_FAILURES, LOGIN_LOCKOUT_DURATION); err != nil {
+				log.Error().Err(err).Str("email", creds.Email).Msg("failed to record the failed login")
+			}
+			responses.ErrLoginError(w, r, errors.New("invalid password"))
+			return
+		}
+
+		// users with MFA enabled must exchange a short-lived token and a code for the web token
+		if user.MfaEnabled {
+			mfaToken, err := crypto.CreateWebMfaJWT(user.ID, secret)
+			if err != nil {
+				responses.ErrServerError(w, r, err)
+				return
+			}
+
+			responses.JsonResponse(w, r, http.StatusOK, map[string]string{
+				"message":   "MFA required",
+				"mfa_token": mfaToken,
+			})
+			return
+		}
+
+		h.completeWebLogin(w, r, &user.User, secret)
+	}
+}
+
+// POST /api/v1/web/login/mfa
+func (h *ApiWebHandler) HandlePostWebLoginMfa(secret []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var creds LoginMfaRequest
+		if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
+			responses.ErrFormFailure(w, r, err)
+			return
+		}
+
+		userid, err := crypto.VerifyWebMfaJWT(creds.MfaToken, secret)
+		if err != nil {
+			responses.ErrLoginError(w, r, err)
+			return
+		}
+
+		user, err := h.core.GetUserLoginByID(r.Context(), userid)
+		if err != nil {
+			responses.ErrServiceUnavailable(w, r, err)
+			return
+		}
+
+		if user == nil || !user.MfaEnabled {
+			responses.ErrLoginError(w, r, errors.New("user not found or MFA is not enabled"))
+			return
+		}
+
+		// codes are guessable, so failures count towards the lockout just like passwords
+		if user.Locked {
+			responses.ErrLoginError(w, r, errors.New("user is locked out"))
+			return
+		}
+
+		var valid bool
+		if creds.RecoveryCode != "" {
+			valid, err = h.core.UseUserMfaRecoveryCode(r.Context(), user.ID, crypto.HashRecoveryCode(creds.RecoveryCode))
+			if err != nil {
+				responses.ErrServiceUnavailable(w, r, err)
 				return
 			}
+		} else {
+			var counter uint64
+			counter, valid = crypto.VerifyTOTP(user.MfaSecret, creds.Code, time.Now())
+			// a code which was already accepted (e.g. seen over a shoulder) cannot complete another login
+			if valid {
+				valid, err = h.core.UseUserTotpCounter(r.Context(), user.ID, counter)
+				if err != nil {
+					responses.ErrServiceUnavailable(w, r, err)
+					return
+				}
+			}
+		}
+
+		if !valid {
+			if err := h.core.UserLoginFailed(r.Context(), user.ID, LOGIN_MAX_FAILURES, LOGIN_LOCKOUT_DURATION); err != nil {
+				log.Error().Err(err).Str("email", user.Email).Msg("failed to record the failed login")
+			}
+			responses.ErrLoginError(w, r, errors.New("invalid MFA code"))
+			return
 		}
-		responses.ErrLoginError(w, r, nil)
+
+		h.completeWebLogin(w, r, &user.User, secret)
+	}
+}
+
+// issue the web token to a fully authenticated user and record the successful login
+func (h *ApiWebHandler) completeWebLogin(w http.ResponseWriter, r *http.Request, user *api.User, secret []byte) {
+	orgRoles, err := h.core.GetUserOrgRoles(r.Context(), user.ID)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	token, err := crypto.CreateWebJWT(user.ID, user.SuperAdmin, orgRoles, secret)
+	if err != nil {
+		responses.ErrServerError(w, r, err)
 		return
 	}
+
+	if err := h.core.UserLoginSucceeded(r.Context(), user.ID); err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, map[string]string{
+		"message": "Login successful",
+		"token":   token,
+	})
 }
@@ -0,0 +1,165 @@
+package apiweb
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/goodieshq/sweettooth/internal/server/requests"
+	"github.com/goodieshq/sweettooth/internal/server/responses"
+	"github.com/goodieshq/sweettooth/internal/util"
+	"github.com/goodieshq/sweettooth/pkg/api"
+)
+
+// GET /api/v1/web/organizations/{orgid}/software_maps
+func (h *ApiWebHandler) HandleGetWebOrganizationSoftwareMaps(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	catalog, err := h.core.GetOrganizationSoftwareMaps(r.Context(), *orgid)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	// organizations without their own software maps only use the defaults
+	if catalog == nil {
+		catalog = &api.SoftwareMapCatalog{
+			IncludeDefaults: true,
+			SoftwareMaps:    make(util.SoftwareMapList, 0),
+		}
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, catalog)
+}
+
+// GET /api/v1/web/organizations/{orgid}/software_maps/defaults
+func (h *ApiWebHandler) HandleGetWebOrganizationSoftwareMapsDefaults(w http.ResponseWriter, r *http.Request) {
+	responses.JsonResponse(w, r, http.StatusOK, util.DefaultSoftwareMaps())
+}
+
+// PUT /api/v1/web/organizations/{orgid}/software_maps
+func (h *ApiWebHandler) HandlePutWebOrganizationSoftwareMaps(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	var catalog api.SoftwareMapCatalog
+	if err := json.NewDecoder(r.Body).Decode(&catalog); err != nil {
+		responses.ErrInvalidRequestBody(w, r, err)
+		return
+	}
+
+	if catalog.SoftwareMaps == nil {
+		catalog.SoftwareMaps = make(util.SoftwareMapList, 0)
+	}
+
+	for i := range catalog.SoftwareMaps {
+		catalog.SoftwareMaps[i].Package = strings.TrimSpace(catalog.SoftwareMaps[i].Package)
+	}
+
+	// every regex must compile before it is matched against the nodes' software
+	if _, err := catalog.SoftwareMaps.Compile(); err != nil {
+		responses.ErrInvalidSoftwareMap(w, r, err)
+		return
+	}
+
+	if err := h.core.SetOrganizationSoftwareMaps(r.Context(), *orgid, &catalog); err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, &catalog)
+}
+
+// DELETE /api/v1/web/organizations/{orgid}/software_maps
+func (h *ApiWebHandler) HandleDeleteWebOrganizationSoftwareMaps(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	deleted, err := h.core.DeleteOrganizationSoftwareMaps(r.Context(), *orgid)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	if !deleted {
+		responses.ErrSoftwareMapsNotFound(w, r, nil)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusNoContent, nil)
+}
+
+// GET /api/v1/web/organizations/{orgid}/nodes/{nodeid}/adoptable
+func (h *ApiWebHandler) HandleGetWebOrganizationNodeAdoptable(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	nodeid := requests.Nid(r)
+	if nodeid == nil {
+		responses.ErrInvalidNodeID(w, r, nil)
+		return
+	}
+
+	adoptable, err := h.core.GetNodeAdoptableSoftware(r.Context(), *nodeid, *orgid)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	if adoptable == nil {
+		responses.ErrNodeNotFound(w, r, nil)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, adoptable)
+}
+
+// POST /api/v1/web/organizations/{orgid}/nodes/{nodeid}/adopt
+func (h *ApiWebHandler) HandlePostWebOrganizationNodeAdopt(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	nodeid := requests.Nid(r)
+	if nodeid == nil {
+		responses.ErrInvalidNodeID(w, r, nil)
+		return
+	}
+
+	// the body is optional, every adoptable package is adopted without one
+	var req api.AdoptRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		responses.ErrInvalidRequestBody(w, r, err)
+		return
+	}
+
+	jobs, err := h.core.AdoptNodeSoftware(r.Context(), *nodeid, *orgid, req.Packages)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	if jobs == nil {
+		responses.ErrNodeNotFound(w, r, nil)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusCreated, jobs)
+}
@@ -0,0 +1,307 @@
+package apiweb
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/goodieshq/sweettooth/internal/schedule"
+	"github.com/goodieshq/sweettooth/internal/server/requests"
+	"github.com/goodieshq/sweettooth/internal/server/responses"
+	"github.com/goodieshq/sweettooth/internal/util"
+	"github.com/goodieshq/sweettooth/pkg/api"
+	"github.com/google/uuid"
+)
+
+// decode and validate a baseline request body, sending an error response on failure
+func decodeBaseline(w http.ResponseWriter, r *http.Request) *api.BaselineRequest {
+	var req api.BaselineRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		responses.ErrInvalidRequestBody(w, r, err)
+		return nil
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		responses.ErrInvalidRequestBody(w, r, errors.New("baseline name is required"))
+		return nil
+	}
+
+	if req.Entries == nil {
+		req.Entries = make(util.BaselineEntryList, 0)
+	}
+
+	for i := range req.Entries {
+		req.Entries[i].Name = strings.TrimSpace(req.Entries[i].Name)
+		if req.Entries[i].MinVersion != nil {
+			version := strings.TrimSpace(*req.Entries[i].MinVersion)
+			req.Entries[i].MinVersion = &version
+		}
+	}
+
+	// every entry is evaluated against the nodes as soon as it is stored
+	if err := req.Entries.Validate(); err != nil {
+		responses.ErrInvalidBaseline(w, r, err)
+		return nil
+	}
+
+	return &req
+}
+
+// GET /api/v1/web/organizations/{orgid}/baselines
+func (h *ApiWebHandler) HandleGetWebOrganizationBaselines(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	baselines, err := h.core.GetBaselines(r.Context(), *orgid, requests.Paging(r))
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, baselines)
+}
+
+// POST /api/v1/web/organizations/{orgid}/baselines
+func (h *ApiWebHandler) HandlePostWebOrganizationBaseline(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	req := decodeBaseline(w, r)
+	if req == nil {
+		return
+	}
+
+	baseline, err := h.core.CreateBaseline(r.Context(), *orgid, req)
+	if err != nil {
+		if h.core.ErrConflict(err) {
+			responses.ErrBaselineConflict(w, r, err)
+			return
+		}
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusCreated, baseline)
+}
+
+// GET /api/v1/web/organizations/{orgid}/baselines/{baselineid}
+func (h *ApiWebHandler) HandleGetWebOrganizationBaseline(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	baselineid, err := uuid.Parse(r.PathValue("baselineid"))
+	if err != nil {
+		responses.ErrInvalidBaselineID(w, r, err)
+		return
+	}
+
+	baseline, err := h.core.GetBaseline(r.Context(), baselineid, *orgid)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	if baseline == nil {
+		responses.ErrBaselineNotFound(w, r, nil)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, baseline)
+}
+
+// PUT /api/v1/web/organizations/{orgid}/baselines/{baselineid}
+func (h *ApiWebHandler) HandlePutWebOrganizationBaseline(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	baselineid, err := uuid.Parse(r.PathValue("baselineid"))
+	if err != nil {
+		responses.ErrInvalidBaselineID(w, r, err)
+		return
+	}
+
+	req := decodeBaseline(w, r)
+	if req == nil {
+		return
+	}
+
+	// every node inheriting the baseline is evaluated against the new entries
+	baseline, err := h.core.UpdateBaseline(r.Context(), baselineid, *orgid, req)
+	if err != nil {
+		if h.core.ErrConflict(err) {
+			responses.ErrBaselineConflict(w, r, err)
+			return
+		}
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	if baseline == nil {
+		responses.ErrBaselineNotFound(w, r, nil)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, baseline)
+}
+
+// DELETE /api/v1/web/organizations/{orgid}/baselines/{baselineid}
+func (h *ApiWebHandler) HandleDeleteWebOrganizationBaseline(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	baselineid, err := uuid.Parse(r.PathValue("baselineid"))
+	if err != nil {
+		responses.ErrInvalidBaselineID(w, r, err)
+		return
+	}
+
+	// all assignments of the baseline are removed along with it, its queued jobs are left to run
+	deleted, err := h.core.DeleteBaseline(r.Context(), baselineid, *orgid)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	if !deleted {
+		responses.ErrBaselineNotFound(w, r, nil)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusNoContent, nil)
+}
+
+// assign a baseline to, or unassign it from, a node, group, or the organization itself
+func (h *ApiWebHandler) updateBaselineAssignment(w http.ResponseWriter, r *http.Request, grouptype schedule.ScheduleGroupType, assign bool) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	baselineid, err := uuid.Parse(r.PathValue("baselineid"))
+	if err != nil {
+		responses.ErrInvalidBaselineID(w, r, err)
+		return
+	}
+
+	// the target of an organization assignment is the organization itself
+	targetid := *orgid
+	switch grouptype {
+	case schedule.SchedGroupNode:
+		if targetid, err = uuid.Parse(r.PathValue("nodeid")); err != nil {
+			responses.ErrInvalidNodeID(w, r, err)
+			return
+		}
+	case schedule.SchedGroupGroup:
+		if targetid, err = uuid.Parse(r.PathValue("groupid")); err != nil {
+			responses.ErrInvalidGroupID(w, r, err)
+			return
+		}
+	}
+
+	var found bool
+	if assign {
+		found, err = h.core.AssignBaseline(r.Context(), baselineid, *orgid, grouptype, targetid)
+	} else {
+		found, err = h.core.UnassignBaseline(r.Context(), baselineid, *orgid, grouptype, targetid)
+	}
+
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	if !found {
+		if assign {
+			responses.ErrBaselineTargetNotFound(w, r, nil)
+		} else {
+			responses.ErrBaselineAssignmentNotFound(w, r, nil)
+		}
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusNoContent, nil)
+}
+
+// PUT /api/v1/web/organizations/{orgid}/baselines/{baselineid}/nodes/{nodeid}
+func (h *ApiWebHandler) HandlePutWebOrganizationBaselineNode(w http.ResponseWriter, r *http.Request) {
+	h.updateBaselineAssignment(w, r, schedule.SchedGroupNode, true)
+}
+
+// DELETE /api/v1/web/organizations/{orgid}/baselines/{baselineid}/nodes/{nodeid}
+func (h *ApiWebHandler) HandleDeleteWebOrganizationBaselineNode(w http.ResponseWriter, r *http.Request) {
+	h.updateBaselineAssignment(w, r, schedule.SchedGroupNode, false)
+}
+
+// PUT /api/v1/web/organizations/{orgid}/baselines/{baselineid}/groups/{groupid}
+func (h *ApiWebHandler) HandlePutWebOrganizationBaselineGroup(w http.ResponseWriter, r *http.Request) {
+	h.updateBaselineAssignment(w, r, schedule.SchedGroupGroup, true)
+}
+
+// DELETE /api/v1/web/organizations/{orgid}/baselines/{baselineid}/groups/{groupid}
+func (h *ApiWebHandler) HandleDeleteWebOrganizationBaselineGroup(w http.ResponseWriter, r *http.Request) {
+	h.updateBaselineAssignment(w, r, schedule.SchedGroupGroup, false)
+}
+
+// PUT /api/v1/web/organizations/{orgid}/baselines/{baselineid}/organization
+func (h *ApiWebHandler) HandlePutWebOrganizationBaselineOrganization(w http.ResponseWriter, r *http.Request) {
+	h.updateBaselineAssignment(w, r, schedule.SchedGroupOrganization, true)
+}
+
+// DELETE /api/v1/web/organizations/{orgid}/baselines/{baselineid}/organization
+func (h *ApiWebHandler) HandleDeleteWebOrganizationBaselineOrganization(w http.ResponseWriter, r *http.Request) {
+	h.updateBaselineAssignment(w, r, schedule.SchedGroupOrganization, false)
+}
+
+// GET /api/v1/web/organizations/{orgid}/nodes/{nodeid}/compliance
+func (h *ApiWebHandler) HandleGetWebOrganizationNodeCompliance(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	nodeid := requests.Nid(r)
+	if nodeid == nil {
+		responses.ErrInvalidNodeID(w, r, nil)
+		return
+	}
+
+	node, err := h.core.GetNode(r.Context(), *nodeid)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	// nodes from other organizations are treated as if they do not exist
+	if node == nil || node.OrganizationID == nil || *node.OrganizationID != *orgid {
+		responses.ErrNodeNotFound(w, r, nil)
+		return
+	}
+
+	// the compliance stored when the node's packages or baseline last changed
+	compliance, err := h.core.GetNodeCompliance(r.Context(), *nodeid)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, compliance)
+}
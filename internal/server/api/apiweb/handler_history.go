@@ -0,0 +1,107 @@
+package apiweb
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/goodieshq/sweettooth/internal/server/requests"
+	"github.com/goodieshq/sweettooth/internal/server/responses"
+)
+
+const (
+	PACKAGE_HISTORY_DEFAULT_RANGE = 30 * 24 * time.Hour // range of a node's history returned when no start is provided
+	PACKAGE_CHANGES_DEFAULT_DAYS  = 7                   // days of an organization's changes returned when none are provided
+	PACKAGE_CHANGES_MAX_DAYS      = 90                  // most days of an organization's changes which can be returned
+)
+
+// parse the optional range of a node's package history, ending now and starting a default range before the end
+func parsePackageHistory(r *http.Request) (from, to time.Time, err error) {
+	query := r.URL.Query()
+
+	to = time.Now()
+	if s := query.Get("to"); s != "" {
+		if to, err = time.Parse(time.RFC3339, s); err != nil {
+			return
+		}
+	}
+
+	from = to.Add(-PACKAGE_HISTORY_DEFAULT_RANGE)
+	if s := query.Get("from"); s != "" {
+		if from, err = time.Parse(time.RFC3339, s); err != nil {
+			return
+		}
+	}
+
+	if !to.After(from) {
+		err = errors.New("package history range must be positive")
+	}
+
+	return
+}
+
+// GET /api/v1/web/organizations/{orgid}/nodes/{nodeid}/packages/history
+func (h *ApiWebHandler) HandleGetWebOrganizationNodePackageHistory(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	nodeid := requests.Nid(r)
+	if nodeid == nil {
+		responses.ErrInvalidNodeID(w, r, nil)
+		return
+	}
+
+	from, to, err := parsePackageHistory(r)
+	if err != nil {
+		responses.ErrInvalidPackageHistory(w, r, err)
+		return
+	}
+
+	history, err := h.core.GetNodePackageHistory(r.Context(), *nodeid, *orgid, from, to)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	if history == nil {
+		responses.ErrNodeNotFound(w, r, nil)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, history)
+}
+
+// GET /api/v1/web/organizations/{orgid}/packages/changes
+func (h *ApiWebHandler) HandleGetWebOrganizationPackageChanges(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	days := PACKAGE_CHANGES_DEFAULT_DAYS
+	if s := r.URL.Query().Get("days"); s != "" {
+		var err error
+		if days, err = strconv.Atoi(s); err != nil {
+			responses.ErrInvalidPackageHistory(w, r, err)
+			return
+		}
+	}
+
+	if days < 1 || days > PACKAGE_CHANGES_MAX_DAYS {
+		responses.ErrInvalidPackageHistory(w, r, errors.New("package changes days is out of range"))
+		return
+	}
+
+	changes, err := h.core.GetOrganizationPackageChanges(r.Context(), *orgid, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, changes)
+}
@@ -0,0 +1,408 @@
+package apiweb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/goodieshq/sweettooth/internal/server/requests"
+	"github.com/goodieshq/sweettooth/internal/server/responses"
+	"github.com/goodieshq/sweettooth/pkg/api"
+	"github.com/google/uuid"
+)
+
+const (
+	JOB_OUTPUT_POLL_INTERVAL = time.Second // how often a live job output stream checks for new output
+)
+
+// validate a retry policy set on a job or an organization
+func validateRetryPolicy(policy *api.RetryPolicy) error {
+	if policy.MaxAttempts < 1 || policy.MaxAttempts > requests.LIMIT_ATTEMPTS_MAX {
+		return fmt.Errorf("retry policy max_attempts must be between 1 and %d", requests.LIMIT_ATTEMPTS_MAX)
+	}
+
+	if policy.BackoffSeconds < 0 {
+		return errors.New("retry policy backoff_seconds cannot be negative")
+	}
+
+	if policy.BackoffMaxSeconds < policy.BackoffSeconds {
+		return errors.New("retry policy backoff_max_seconds cannot be less than backoff_seconds")
+	}
+
+	if policy.BackoffMaxSeconds > requests.LIMIT_RETRY_BACKOFF_SECONDS {
+		return fmt.Errorf("retry policy backoff_max_seconds cannot exceed %d", requests.LIMIT_RETRY_BACKOFF_SECONDS)
+	}
+
+	return nil
+}
+
+// ensure the action and parameters of a package job are sane before they are sent to a node
+func validatePackageJob(action int, params *api.PackageJobParameters, expiresAt, notBefore, deadline *time.Time, retryPolicy *api.RetryPolicy) error {
+	switch action {
+	case api.JOB_ACTION_INSTALL, api.JOB_ACTION_UPGRADE, api.JOB_ACTION_UNINSTALL:
+	default:
+		return errors.New("invalid package job action")
+	}
+
+	params.Name = strings.TrimSpace(params.Name)
+	if params.Name == "" {
+		return errors.New("package name is required")
+	}
+
+	if params.SkipPowershell && action != api.JOB_ACTION_INSTALL {
+		return errors.New("skip_powershell is only supported by the install action")
+	}
+
+	if params.Version != nil && strings.TrimSpace(*params.Version) == "" {
+		// treat an empty version as the latest version
+		params.Version = nil
+	}
+
+	if params.Timeout < 0 {
+		return errors.New("package job timeout cannot be negative")
+	}
+
+	if params.Timeout == 0 {
+		params.Timeout = api.JOB_DEFAULT_TIMEOUT
+	}
+
+	if expiresAt != nil && expiresAt.Before(time.Now()) {
+		return errors.New("package job expiration must be in the future")
+	}
+
+	if notBefore != nil && expiresAt != nil && !notBefore.Before(*expiresAt) {
+		return errors.New("package job not_before must be before its expiration")
+	}
+
+	if deadline != nil && notBefore != nil && deadline.Before(*notBefore) {
+		return errors.New("package job deadline cannot be before its not_before")
+	}
+
+	if deadline != nil && expiresAt != nil && !deadline.Before(*expiresAt) {
+		return errors.New("package job deadline must be before its expiration")
+	}
+
+	if retryPolicy != nil {
+		return validateRetryPolicy(retryPolicy)
+	}
+
+	return nil
+}
+
+// GET /api/v1/web/organizations/{orgid}/jobs
+func (h *ApiWebHandler) HandleGetWebOrganizationJobs(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	// optionally filter the jobs by a single node
+	var nodeid *uuid.UUID
+	if nodeidString := r.URL.Query().Get("node_id"); nodeidString != "" {
+		id, err := uuid.Parse(nodeidString)
+		if err != nil {
+			responses.ErrInvalidNodeID(w, r, err)
+			return
+		}
+		nodeid = &id
+	}
+
+	jobs, err := h.core.GetPackageJobs(r.Context(), *orgid, nodeid, requests.Paging(r))
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	// the output can be quite large, it is only included when requesting a single job
+	for _, job := range jobs {
+		job.Result.Output = ""
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, jobs)
+}
+
+// POST /api/v1/web/organizations/{orgid}/jobs
+func (h *ApiWebHandler) HandlePostWebOrganizationJob(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	var req api.PackageJobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		responses.ErrInvalidRequestBody(w, r, err)
+		return
+	}
+
+	if err := validatePackageJob(req.Action, &req.Parameters, req.ExpiresAt, req.NotBefore, req.Deadline, req.RetryPolicy); err != nil {
+		responses.ErrInvalidRequestBody(w, r, err)
+		return
+	}
+
+	job, err := h.core.CreatePackageJob(r.Context(), *orgid, &req)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	// no job is created if the node is not a member of the organization
+	if job == nil {
+		responses.ErrNodeNotFound(w, r, errors.New("the node ID is not found in this organization"))
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusCreated, job)
+}
+
+// GET /api/v1/web/organizations/{orgid}/jobs/{jobid}
+func (h *ApiWebHandler) HandleGetWebOrganizationJob(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	jobid, err := uuid.Parse(r.PathValue("jobid"))
+	if err != nil {
+		responses.ErrInvalidJobID(w, r, err)
+		return
+	}
+
+	job, err := h.core.GetPackageJob(r.Context(), jobid)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	// jobs from other organizations are treated as if they do not exist
+	if job == nil || job.OrganizationID != *orgid {
+		responses.ErrJobNotFound(w, r, nil)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, job)
+}
+
+// POST /api/v1/web/organizations/{orgid}/jobs/{jobid}/cancel
+func (h *ApiWebHandler) HandlePostWebOrganizationJobCancel(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	jobid, err := uuid.Parse(r.PathValue("jobid"))
+	if err != nil {
+		responses.ErrInvalidJobID(w, r, err)
+		return
+	}
+
+	job, err := h.core.GetPackageJob(r.Context(), jobid)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	if job == nil || job.OrganizationID != *orgid {
+		responses.ErrJobNotFound(w, r, nil)
+		return
+	}
+
+	// only active jobs can be cancelled, a node already running the job stops it when it next heartbeats
+	if !job.Active() {
+		responses.ErrJobNotPending(w, r, nil)
+		return
+	}
+
+	job, err = h.core.CancelPackageJob(r.Context(), jobid, *orgid)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	// the job was completed by the node between the lookup and the cancellation
+	if job == nil {
+		responses.ErrJobNotPending(w, r, nil)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, job)
+}
+
+// GET /api/v1/web/organizations/{orgid}/jobs/{jobid}/output
+func (h *ApiWebHandler) HandleGetWebOrganizationJobOutput(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	jobid, err := uuid.Parse(r.PathValue("jobid"))
+	if err != nil {
+		responses.ErrInvalidJobID(w, r, err)
+		return
+	}
+
+	job, err := h.core.GetPackageJob(r.Context(), jobid)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	if job == nil || job.OrganizationID != *orgid {
+		responses.ErrJobNotFound(w, r, nil)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	ticker := time.NewTicker(JOB_OUTPUT_POLL_INTERVAL)
+	defer ticker.Stop()
+
+	// the node appends output to the job as it runs, only the new output is sent on each poll
+	var sent int
+	for {
+		// a new lease of the job starts with empty output
+		if len(job.Result.Output) < sent {
+			sent = 0
+		}
+
+		if len(job.Result.Output) > sent {
+			if err := responses.EventResponse(w, "output", &api.PackageJobOutput{Output: job.Result.Output[sent:]}); err != nil {
+				return
+			}
+			sent = len(job.Result.Output)
+		}
+
+		// the stream ends with the result once the job reaches a final state
+		if !job.Active() {
+			result := *job.Result
+			result.Output = "" // the output has already been streamed
+			if err := responses.EventResponse(w, "result", &result); err == nil {
+				rc.Flush()
+			}
+			return
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+
+		job, err = h.core.GetPackageJob(r.Context(), jobid)
+		if err != nil || job == nil {
+			return
+		}
+	}
+}
+
+// GET /api/v1/web/organizations/{orgid}/jobs/{jobid}/attempts
+func (h *ApiWebHandler) HandleGetWebOrganizationJobAttempts(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	jobid, err := uuid.Parse(r.PathValue("jobid"))
+	if err != nil {
+		responses.ErrInvalidJobID(w, r, err)
+		return
+	}
+
+	job, err := h.core.GetPackageJob(r.Context(), jobid)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	if job == nil || job.OrganizationID != *orgid {
+		responses.ErrJobNotFound(w, r, nil)
+		return
+	}
+
+	attempts, err := h.core.GetPackageJobAttempts(r.Context(), jobid)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, attempts)
+}
+
+// GET /api/v1/web/organizations/{orgid}/groups/{groupid}/jobs
+func (h *ApiWebHandler) HandleGetWebOrganizationGroupJobs(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	groupid, err := uuid.Parse(r.PathValue("groupid"))
+	if err != nil {
+		responses.ErrInvalidGroupID(w, r, err)
+		return
+	}
+
+	// summarize the statuses of the jobs fanned out to this group
+	rollups, err := h.core.GetGroupPackageJobRollups(r.Context(), *orgid, groupid, requests.Paging(r))
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, rollups)
+}
+
+// POST /api/v1/web/organizations/{orgid}/groups/{groupid}/jobs
+func (h *ApiWebHandler) HandlePostWebOrganizationGroupJobs(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	groupid, err := uuid.Parse(r.PathValue("groupid"))
+	if err != nil {
+		responses.ErrInvalidGroupID(w, r, err)
+		return
+	}
+
+	var req api.PackageJobGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		responses.ErrInvalidRequestBody(w, r, err)
+		return
+	}
+
+	if err := validatePackageJob(req.Action, &req.Parameters, req.ExpiresAt, req.NotBefore, req.Deadline, req.RetryPolicy); err != nil {
+		responses.ErrInvalidRequestBody(w, r, err)
+		return
+	}
+
+	// create a job for every member of the group
+	jobs, err := h.core.CreateGroupPackageJobs(r.Context(), *orgid, groupid, &req)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	if jobs == nil {
+		responses.ErrGroupNotFound(w, r, nil)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusCreated, jobs)
+}
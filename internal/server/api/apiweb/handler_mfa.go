@@ -0,0 +1,125 @@
+package apiweb
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/goodieshq/sweettooth/internal/crypto"
+	"github.com/goodieshq/sweettooth/internal/server/requests"
+	"github.com/goodieshq/sweettooth/internal/server/responses"
+	"github.com/goodieshq/sweettooth/pkg/api"
+)
+
+// POST /api/v1/web/mfa
+func (h *ApiWebHandler) HandlePostWebMfa(w http.ResponseWriter, r *http.Request) {
+	userid := requests.Uid(r)
+	if userid == nil {
+		responses.ErrUnauthorized(w, r, nil)
+		return
+	}
+
+	user, err := h.core.GetUserLoginByID(r.Context(), *userid)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	if user == nil {
+		responses.ErrUnauthorized(w, r, errors.New("user not found"))
+		return
+	}
+
+	if user.MfaEnabled {
+		responses.ErrMfaAlreadyEnabled(w, r, nil)
+		return
+	}
+
+	// the secret is not used for logins until a code generated from it is verified
+	secret, err := crypto.GenerateTOTPSecret()
+	if err != nil {
+		responses.ErrServerError(w, r, err)
+		return
+	}
+
+	if err := h.core.SetUserMfaPending(r.Context(), user.ID, secret); err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, &api.MfaEnrollResponse{
+		Secret: secret,
+		URI:    crypto.TOTPProvisioningURI(secret, user.Email),
+	})
+}
+
+// POST /api/v1/web/mfa/verify
+func (h *ApiWebHandler) HandlePostWebMfaVerify(w http.ResponseWriter, r *http.Request) {
+	userid := requests.Uid(r)
+	if userid == nil {
+		responses.ErrUnauthorized(w, r, nil)
+		return
+	}
+
+	var req api.MfaVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		responses.ErrInvalidRequestBody(w, r, err)
+		return
+	}
+
+	user, err := h.core.GetUserLoginByID(r.Context(), *userid)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	if user == nil {
+		responses.ErrUnauthorized(w, r, errors.New("user not found"))
+		return
+	}
+
+	if user.MfaEnabled {
+		responses.ErrMfaAlreadyEnabled(w, r, nil)
+		return
+	}
+
+	if user.MfaPending == "" {
+		responses.ErrMfaInvalidCode(w, r, nil)
+		return
+	}
+
+	counter, valid := crypto.VerifyTOTP(user.MfaPending, req.Code, time.Now())
+	if !valid {
+		responses.ErrMfaInvalidCode(w, r, nil)
+		return
+	}
+
+	// recovery codes are only returned once, the server keeps only their hashes
+	codes, err := crypto.GenerateRecoveryCodes()
+	if err != nil {
+		responses.ErrServerError(w, r, err)
+		return
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = crypto.HashRecoveryCode(code)
+	}
+
+	enabled, err := h.core.EnableUserMfa(r.Context(), user.ID, user.MfaPending, counter, hashes)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	// another enrollment replaced the pending secret after it was read
+	if !enabled {
+		responses.ErrMfaInvalidCode(w, r, errors.New("the pending MFA secret has changed"))
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, &api.MfaVerifyResponse{
+		RecoveryCodes: codes,
+	})
+}
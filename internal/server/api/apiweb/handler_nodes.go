@@ -0,0 +1,87 @@
+package apiweb
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/goodieshq/sweettooth/internal/server/requests"
+	"github.com/goodieshq/sweettooth/internal/server/responses"
+	"github.com/goodieshq/sweettooth/pkg/api"
+	"github.com/google/uuid"
+)
+
+// GET /api/v1/web/organizations/{orgid}/nodes/pending
+func (h *ApiWebHandler) HandleGetWebOrganizationNodesPending(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	nodes, err := h.core.GetPendingNodes(r.Context(), *orgid, requests.Paging(r))
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, nodes)
+}
+
+// POST /api/v1/web/organizations/{orgid}/nodes/{nodeid}/approve
+func (h *ApiWebHandler) HandlePostWebOrganizationNodeApprove(w http.ResponseWriter, r *http.Request) {
+	h.updateNodeApproval(w, r, h.core.ApproveNode)
+}
+
+// POST /api/v1/web/organizations/{orgid}/nodes/{nodeid}/reject
+func (h *ApiWebHandler) HandlePostWebOrganizationNodeReject(w http.ResponseWriter, r *http.Request) {
+	h.updateNodeApproval(w, r, h.core.RejectNode)
+}
+
+// POST /api/v1/web/organizations/{orgid}/nodes/{nodeid}/revoke
+func (h *ApiWebHandler) HandlePostWebOrganizationNodeRevoke(w http.ResponseWriter, r *http.Request) {
+	h.updateNodeApproval(w, r, h.core.RevokeNode)
+}
+
+// apply an approval state change to the node in the URL and invalidate its cached authorization
+func (h *ApiWebHandler) updateNodeApproval(w http.ResponseWriter, r *http.Request, update func(ctx context.Context, nodeid, orgid uuid.UUID) (*api.Node, error)) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	nodeid := requests.Nid(r)
+	if nodeid == nil {
+		responses.ErrInvalidNodeID(w, r, nil)
+		return
+	}
+
+	node, err := h.core.GetNode(r.Context(), *nodeid)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	// nodes from other organizations are treated as if they do not exist
+	if node == nil || node.OrganizationID == nil || *node.OrganizationID != *orgid {
+		responses.ErrNodeNotFound(w, r, nil)
+		return
+	}
+
+	node, err = update(r.Context(), *nodeid, *orgid)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	// the node is not in a state that allows this change
+	if node == nil {
+		responses.ErrNodeApprovalConflict(w, r, nil)
+		return
+	}
+
+	// the node middleware must not continue to serve a stale authorization
+	h.cache.DeleteNodeAuth(nodeid.String())
+
+	responses.JsonResponse(w, r, http.StatusOK, node)
+}
@@ -1,13 +1,27 @@
 package apiweb
 
 import (
+	"encoding/json"
 	"net/http"
 
 	"github.com/goodieshq/sweettooth/internal/server/requests"
 	"github.com/goodieshq/sweettooth/internal/server/responses"
+	"github.com/goodieshq/sweettooth/internal/server/roles"
+	"github.com/goodieshq/sweettooth/pkg/api"
+	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 )
 
+// determine if the requester has any role within the organization
+func hasOrgRole(r *http.Request, orgid uuid.UUID) bool {
+	if requests.IsSuperAdmin(r) {
+		return true
+	}
+
+	orgRoles := requests.ORoles(r)
+	return orgRoles != nil && orgRoles.GetRole(orgid) != roles.NONE
+}
+
 // GET /api/v1/web/organizations
 func (h *ApiWebHandler) HandleGetWebOrganizations(w http.ResponseWriter, r *http.Request) {
 	orgs, err := h.core.GetOrganizations(r.Context())
@@ -15,7 +29,16 @@ func (h *ApiWebHandler) HandleGetWebOrganizations(w http.ResponseWriter, r *http
 		responses.ErrServiceUnavailable(w, r, err)
 		return
 	}
-	responses.JsonResponse(w, r, http.StatusOK, orgs)
+
+	// only include the organizations the requester is a member of
+	visible := make([]*api.Organization, 0, len(orgs))
+	for _, org := range orgs {
+		if hasOrgRole(r, org.ID) {
+			visible = append(visible, org)
+		}
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, visible)
 }
 
 // GET /api/v1/web/organizations/summaries
@@ -25,7 +48,16 @@ func (h *ApiWebHandler) HandleGetWebOrganizationSummaries(w http.ResponseWriter,
 		responses.ErrServiceUnavailable(w, r, err)
 		return
 	}
-	responses.JsonResponse(w, r, http.StatusOK, orgs)
+
+	// only include the organizations the requester is a member of
+	visible := make([]*api.OrganizationSummary, 0, len(orgs))
+	for _, org := range orgs {
+		if hasOrgRole(r, org.ID) {
+			visible = append(visible, org)
+		}
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, visible)
 }
 
 // GET /api/v1/web/organizations/{orgid}
@@ -46,6 +78,78 @@ func (h *ApiWebHandler) HandleGetWebOrganization(w http.ResponseWriter, r *http.
 	responses.JsonResponse(w, r, http.StatusOK, org)
 }
 
+// GET /api/v1/web/organizations/{orgid}/retry_policy
+func (h *ApiWebHandler) HandleGetWebOrganizationRetryPolicy(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	policy, err := h.core.GetOrganizationRetryPolicy(r.Context(), *orgid)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	// organizations without their own policy use the default
+	if policy == nil {
+		policy = api.DefaultRetryPolicy()
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, policy)
+}
+
+// PUT /api/v1/web/organizations/{orgid}/retry_policy
+func (h *ApiWebHandler) HandlePutWebOrganizationRetryPolicy(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	var policy api.RetryPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		responses.ErrInvalidRequestBody(w, r, err)
+		return
+	}
+
+	if err := validateRetryPolicy(&policy); err != nil {
+		responses.ErrInvalidRequestBody(w, r, err)
+		return
+	}
+
+	// the policy applies to every job in the organization which does not set its own, including jobs already queued
+	if err := h.core.SetOrganizationRetryPolicy(r.Context(), *orgid, &policy); err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, &policy)
+}
+
+// DELETE /api/v1/web/organizations/{orgid}/retry_policy
+func (h *ApiWebHandler) HandleDeleteWebOrganizationRetryPolicy(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	deleted, err := h.core.DeleteOrganizationRetryPolicy(r.Context(), *orgid)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	if !deleted {
+		responses.ErrRetryPolicyNotFound(w, r, nil)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusNoContent, nil)
+}
+
 // GET /api/v1/web/organizations/{orgid}/nodes
 func (h *ApiWebHandler) HandleGetWebOrganizationNodes(w http.ResponseWriter, r *http.Request) {
 	orgid := requests.Oid(r)
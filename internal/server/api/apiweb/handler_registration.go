@@ -0,0 +1,185 @@
+package apiweb
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/goodieshq/sweettooth/internal/server/requests"
+	"github.com/goodieshq/sweettooth/internal/server/responses"
+	"github.com/goodieshq/sweettooth/pkg/api"
+	"github.com/google/uuid"
+)
+
+// ensure the values of a registration token are sane before it is created or updated
+func validateRegistrationToken(req *api.RegistrationTokenRequest) error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return errors.New("registration token name is required")
+	}
+
+	if req.MaxUses != nil && *req.MaxUses <= 0 {
+		return errors.New("registration token max uses must be positive")
+	}
+
+	if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+		return errors.New("registration token expiration must be in the future")
+	}
+
+	return nil
+}
+
+// decode and validate a registration token request body, sending an error response on failure
+func decodeRegistrationToken(w http.ResponseWriter, r *http.Request) *api.RegistrationTokenRequest {
+	var req api.RegistrationTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		responses.ErrInvalidRequestBody(w, r, err)
+		return nil
+	}
+
+	if err := validateRegistrationToken(&req); err != nil {
+		responses.ErrInvalidRequestBody(w, r, err)
+		return nil
+	}
+
+	return &req
+}
+
+// GET /api/v1/web/organizations/{orgid}/registration_tokens
+func (h *ApiWebHandler) HandleGetWebOrganizationRegistrationTokens(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	tokens, err := h.core.GetRegistrationTokens(r.Context(), *orgid, requests.Paging(r))
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, tokens)
+}
+
+// POST /api/v1/web/organizations/{orgid}/registration_tokens
+func (h *ApiWebHandler) HandlePostWebOrganizationRegistrationToken(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	req := decodeRegistrationToken(w, r)
+	if req == nil {
+		return
+	}
+
+	token, err := h.core.CreateRegistrationToken(r.Context(), *orgid, req)
+	if err != nil {
+		if h.core.ErrConflict(err) {
+			responses.ErrRegistrationTokenConflict(w, r, err)
+			return
+		}
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusCreated, token)
+}
+
+// GET /api/v1/web/organizations/{orgid}/registration_tokens/{tokenid}
+func (h *ApiWebHandler) HandleGetWebOrganizationRegistrationToken(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	tokenid, err := uuid.Parse(r.PathValue("tokenid"))
+	if err != nil {
+		responses.ErrInvalidRegistrationTokenID(w, r, err)
+		return
+	}
+
+	token, err := h.core.GetRegistrationToken(r.Context(), tokenid, *orgid)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	if token == nil {
+		responses.ErrRegistrationTokenNotFound(w, r, nil)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, token)
+}
+
+// PUT /api/v1/web/organizations/{orgid}/registration_tokens/{tokenid}
+func (h *ApiWebHandler) HandlePutWebOrganizationRegistrationToken(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	tokenid, err := uuid.Parse(r.PathValue("tokenid"))
+	if err != nil {
+		responses.ErrInvalidRegistrationTokenID(w, r, err)
+		return
+	}
+
+	req := decodeRegistrationToken(w, r)
+	if req == nil {
+		return
+	}
+
+	token, err := h.core.UpdateRegistrationToken(r.Context(), tokenid, *orgid, req)
+	if err != nil {
+		if h.core.ErrConflict(err) {
+			responses.ErrRegistrationTokenConflict(w, r, err)
+			return
+		}
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	if token == nil {
+		responses.ErrRegistrationTokenNotFound(w, r, nil)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, token)
+}
+
+// DELETE /api/v1/web/organizations/{orgid}/registration_tokens/{tokenid}
+func (h *ApiWebHandler) HandleDeleteWebOrganizationRegistrationToken(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	tokenid, err := uuid.Parse(r.PathValue("tokenid"))
+	if err != nil {
+		responses.ErrInvalidRegistrationTokenID(w, r, err)
+		return
+	}
+
+	// nodes which already registered with the token are not affected
+	deleted, err := h.core.DeleteRegistrationToken(r.Context(), tokenid, *orgid)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	if !deleted {
+		responses.ErrRegistrationTokenNotFound(w, r, nil)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusNoContent, nil)
+}
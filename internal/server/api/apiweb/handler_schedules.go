@@ -0,0 +1,360 @@
+package apiweb
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goodieshq/sweettooth/internal/schedule"
+	"github.com/goodieshq/sweettooth/internal/server/requests"
+	"github.com/goodieshq/sweettooth/internal/server/responses"
+	"github.com/goodieshq/sweettooth/pkg/api"
+	"github.com/google/uuid"
+)
+
+const (
+	SCHEDULE_PREVIEW_DEFAULT_RANGE = 7 * 24 * time.Hour   // range previewed when no end is provided
+	SCHEDULE_PREVIEW_MAX_RANGE     = 366 * 24 * time.Hour // longest range which can be previewed, also the search limit for the next windows
+	SCHEDULE_PREVIEW_DEFAULT_COUNT = 10                   // number of upcoming windows listed when no count is provided
+	SCHEDULE_PREVIEW_MAX_COUNT     = 100                  // most upcoming windows which can be listed
+)
+
+// decode and validate a schedule request body, sending an error response on failure
+func decodeSchedule(w http.ResponseWriter, r *http.Request) *api.ScheduleRequest {
+	var req api.ScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		responses.ErrInvalidRequestBody(w, r, err)
+		return nil
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		responses.ErrInvalidRequestBody(w, r, errors.New("schedule name is required"))
+		return nil
+	}
+
+	if req.Entries == nil {
+		req.Entries = make(api.Schedule, 0)
+	}
+
+	// every entry must be usable by the clients before it is stored
+	if err := schedule.Schedule(req.Entries).Validate(); err != nil {
+		responses.ErrInvalidSchedule(w, r, err)
+		return nil
+	}
+
+	return &req
+}
+
+// GET /api/v1/web/organizations/{orgid}/schedules
+func (h *ApiWebHandler) HandleGetWebOrganizationSchedules(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	schedules, err := h.core.GetSchedules(r.Context(), *orgid, requests.Paging(r))
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, schedules)
+}
+
+// POST /api/v1/web/organizations/{orgid}/schedules
+func (h *ApiWebHandler) HandlePostWebOrganizationSchedule(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	req := decodeSchedule(w, r)
+	if req == nil {
+		return
+	}
+
+	sched, err := h.core.CreateSchedule(r.Context(), *orgid, req)
+	if err != nil {
+		if h.core.ErrConflict(err) {
+			responses.ErrScheduleConflict(w, r, err)
+			return
+		}
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusCreated, sched)
+}
+
+// GET /api/v1/web/organizations/{orgid}/schedules/{scheduleid}
+func (h *ApiWebHandler) HandleGetWebOrganizationSchedule(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	scheduleid, err := uuid.Parse(r.PathValue("scheduleid"))
+	if err != nil {
+		responses.ErrInvalidScheduleID(w, r, err)
+		return
+	}
+
+	sched, err := h.core.GetSchedule(r.Context(), scheduleid, *orgid)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	if sched == nil {
+		responses.ErrScheduleNotFound(w, r, nil)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, sched)
+}
+
+// PUT /api/v1/web/organizations/{orgid}/schedules/{scheduleid}
+func (h *ApiWebHandler) HandlePutWebOrganizationSchedule(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	scheduleid, err := uuid.Parse(r.PathValue("scheduleid"))
+	if err != nil {
+		responses.ErrInvalidScheduleID(w, r, err)
+		return
+	}
+
+	req := decodeSchedule(w, r)
+	if req == nil {
+		return
+	}
+
+	// every node inheriting the schedule is flagged to fetch it again
+	sched, err := h.core.UpdateSchedule(r.Context(), scheduleid, *orgid, req)
+	if err != nil {
+		if h.core.ErrConflict(err) {
+			responses.ErrScheduleConflict(w, r, err)
+			return
+		}
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	if sched == nil {
+		responses.ErrScheduleNotFound(w, r, nil)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, sched)
+}
+
+// DELETE /api/v1/web/organizations/{orgid}/schedules/{scheduleid}
+func (h *ApiWebHandler) HandleDeleteWebOrganizationSchedule(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	scheduleid, err := uuid.Parse(r.PathValue("scheduleid"))
+	if err != nil {
+		responses.ErrInvalidScheduleID(w, r, err)
+		return
+	}
+
+	// all assignments of the schedule are removed along with it
+	deleted, err := h.core.DeleteSchedule(r.Context(), scheduleid, *orgid)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	if !deleted {
+		responses.ErrScheduleNotFound(w, r, nil)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusNoContent, nil)
+}
+
+// assign a schedule to, or unassign it from, a node, group, or the organization itself
+func (h *ApiWebHandler) updateScheduleAssignment(w http.ResponseWriter, r *http.Request, grouptype schedule.ScheduleGroupType, assign bool) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	scheduleid, err := uuid.Parse(r.PathValue("scheduleid"))
+	if err != nil {
+		responses.ErrInvalidScheduleID(w, r, err)
+		return
+	}
+
+	// the target of an organization assignment is the organization itself
+	targetid := *orgid
+	switch grouptype {
+	case schedule.SchedGroupNode:
+		if targetid, err = uuid.Parse(r.PathValue("nodeid")); err != nil {
+			responses.ErrInvalidNodeID(w, r, err)
+			return
+		}
+	case schedule.SchedGroupGroup:
+		if targetid, err = uuid.Parse(r.PathValue("groupid")); err != nil {
+			responses.ErrInvalidGroupID(w, r, err)
+			return
+		}
+	}
+
+	var found bool
+	if assign {
+		found, err = h.core.AssignSchedule(r.Context(), scheduleid, *orgid, grouptype, targetid)
+	} else {
+		found, err = h.core.UnassignSchedule(r.Context(), scheduleid, *orgid, grouptype, targetid)
+	}
+
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	if !found {
+		if assign {
+			responses.ErrScheduleTargetNotFound(w, r, nil)
+		} else {
+			responses.ErrScheduleAssignmentNotFound(w, r, nil)
+		}
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusNoContent, nil)
+}
+
+// PUT /api/v1/web/organizations/{orgid}/schedules/{scheduleid}/nodes/{nodeid}
+func (h *ApiWebHandler) HandlePutWebOrganizationScheduleNode(w http.ResponseWriter, r *http.Request) {
+	h.updateScheduleAssignment(w, r, schedule.SchedGroupNode, true)
+}
+
+// DELETE /api/v1/web/organizations/{orgid}/schedules/{scheduleid}/nodes/{nodeid}
+func (h *ApiWebHandler) HandleDeleteWebOrganizationScheduleNode(w http.ResponseWriter, r *http.Request) {
+	h.updateScheduleAssignment(w, r, schedule.SchedGroupNode, false)
+}
+
+// PUT /api/v1/web/organizations/{orgid}/schedules/{scheduleid}/groups/{groupid}
+func (h *ApiWebHandler) HandlePutWebOrganizationScheduleGroup(w http.ResponseWriter, r *http.Request) {
+	h.updateScheduleAssignment(w, r, schedule.SchedGroupGroup, true)
+}
+
+// DELETE /api/v1/web/organizations/{orgid}/schedules/{scheduleid}/groups/{groupid}
+func (h *ApiWebHandler) HandleDeleteWebOrganizationScheduleGroup(w http.ResponseWriter, r *http.Request) {
+	h.updateScheduleAssignment(w, r, schedule.SchedGroupGroup, false)
+}
+
+// PUT /api/v1/web/organizations/{orgid}/schedules/{scheduleid}/organization
+func (h *ApiWebHandler) HandlePutWebOrganizationScheduleOrganization(w http.ResponseWriter, r *http.Request) {
+	h.updateScheduleAssignment(w, r, schedule.SchedGroupOrganization, true)
+}
+
+// DELETE /api/v1/web/organizations/{orgid}/schedules/{scheduleid}/organization
+func (h *ApiWebHandler) HandleDeleteWebOrganizationScheduleOrganization(w http.ResponseWriter, r *http.Request) {
+	h.updateScheduleAssignment(w, r, schedule.SchedGroupOrganization, false)
+}
+
+// parse the optional range and window count of a schedule preview
+func parseSchedulePreview(r *http.Request) (from, to time.Time, count int, err error) {
+	query := r.URL.Query()
+
+	from = time.Now()
+	if s := query.Get("from"); s != "" {
+		if from, err = time.Parse(time.RFC3339, s); err != nil {
+			return
+		}
+	}
+
+	// the schedule's days are evaluated in the server's local time
+	from = from.In(time.Local)
+
+	to = from.Add(SCHEDULE_PREVIEW_DEFAULT_RANGE)
+	if s := query.Get("to"); s != "" {
+		if to, err = time.Parse(time.RFC3339, s); err != nil {
+			return
+		}
+	}
+	to = to.In(time.Local)
+
+	if !to.After(from) || to.Sub(from) > SCHEDULE_PREVIEW_MAX_RANGE {
+		err = errors.New("schedule preview range must be positive and no longer than the maximum")
+		return
+	}
+
+	count = SCHEDULE_PREVIEW_DEFAULT_COUNT
+	if s := query.Get("count"); s != "" {
+		if count, err = strconv.Atoi(s); err != nil {
+			return
+		}
+	}
+
+	if count < 1 || count > SCHEDULE_PREVIEW_MAX_COUNT {
+		err = errors.New("schedule preview count is out of range")
+	}
+
+	return
+}
+
+// GET /api/v1/web/organizations/{orgid}/nodes/{nodeid}/schedule/preview
+func (h *ApiWebHandler) HandleGetWebOrganizationNodeSchedulePreview(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	nodeid := requests.Nid(r)
+	if nodeid == nil {
+		responses.ErrInvalidNodeID(w, r, nil)
+		return
+	}
+
+	from, to, count, err := parseSchedulePreview(r)
+	if err != nil {
+		responses.ErrInvalidSchedulePreview(w, r, err)
+		return
+	}
+
+	node, err := h.core.GetNode(r.Context(), *nodeid)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	// nodes from other organizations are treated as if they do not exist
+	if node == nil || node.OrganizationID == nil || *node.OrganizationID != *orgid {
+		responses.ErrNodeNotFound(w, r, nil)
+		return
+	}
+
+	// the same combined schedule the node receives when it requests its schedule
+	sched, err := h.core.GetNodeSchedule(r.Context(), *nodeid)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, &api.SchedulePreview{
+		From:     from,
+		To:       to,
+		Schedule: sched,
+		Windows:  schedule.Schedule(sched).Windows(from, to),
+		Next:     schedule.Schedule(sched).NextWindows(from, count, SCHEDULE_PREVIEW_MAX_RANGE),
+	})
+}
@@ -0,0 +1,50 @@
+package apiweb
+
+import (
+	"net/http"
+
+	"github.com/goodieshq/sweettooth/internal/server/requests"
+	"github.com/goodieshq/sweettooth/internal/server/responses"
+	"github.com/goodieshq/sweettooth/pkg/api"
+)
+
+// GET /api/v1/web/organizations/{orgid}/packages/search
+func (h *ApiWebHandler) HandleGetWebOrganizationPackageSearch(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	query := r.URL.Query()
+	search := api.PackageSearch{
+		Name:    query.Get("name"),
+		Match:   query.Get("match"),
+		Source:  query.Get("source"),
+		Version: query.Get("version"),
+		Op:      query.Get("op"),
+	}
+	if search.Match == "" {
+		search.Match = api.SEARCH_MATCH_EXACT
+	}
+	if search.Op == "" {
+		search.Op = api.SEARCH_VERSION_EQ
+	}
+
+	if err := search.Validate(); err != nil {
+		responses.ErrInvalidPackageSearch(w, r, err)
+		return
+	}
+
+	results, err := h.core.SearchPackages(r.Context(), *orgid, &search, requests.Paging(r))
+	if err != nil {
+		if h.core.ErrInvalidRegex(err) {
+			responses.ErrInvalidPackageSearch(w, r, err)
+			return
+		}
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, results)
+}
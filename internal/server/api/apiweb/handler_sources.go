@@ -0,0 +1,110 @@
+package apiweb
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/goodieshq/sweettooth/internal/server/requests"
+	"github.com/goodieshq/sweettooth/internal/server/responses"
+	"github.com/goodieshq/sweettooth/pkg/api"
+	"github.com/google/uuid"
+)
+
+// GET /api/v1/web/organizations/{orgid}/nodes/{nodeid}/sources
+func (h *ApiWebHandler) HandleGetWebOrganizationNodeSources(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	nodeid := requests.Nid(r)
+	if nodeid == nil {
+		responses.ErrInvalidNodeID(w, r, nil)
+		return
+	}
+
+	node, err := h.core.GetNode(r.Context(), *nodeid)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	// nodes from other organizations are treated as if they do not exist
+	if node == nil || node.OrganizationID == nil || *node.OrganizationID != *orgid {
+		responses.ErrNodeNotFound(w, r, nil)
+		return
+	}
+
+	// compare the sources assigned to the node against the ones it last reported
+	status, err := h.core.GetNodeSourcesStatus(r.Context(), *nodeid)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, status)
+}
+
+// PUT /api/v1/web/organizations/{orgid}/sources/{sourceid}/credentials/{name}
+func (h *ApiWebHandler) HandlePutWebOrganizationSourceCredentials(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	sourceid, err := uuid.Parse(r.PathValue("sourceid"))
+	if err != nil {
+		responses.ErrInvalidSourceID(w, r, err)
+		return
+	}
+
+	var req api.SourceCredentialsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		responses.ErrInvalidRequestBody(w, r, err)
+		return
+	}
+
+	// the credentials are encrypted before they are stored and are never returned
+	updated, err := h.core.SetSourceCredentials(r.Context(), sourceid, *orgid, r.PathValue("name"), &req)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	if !updated {
+		responses.ErrSourceEntryNotFound(w, r, nil)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusNoContent, nil)
+}
+
+// DELETE /api/v1/web/organizations/{orgid}/sources/{sourceid}/credentials/{name}
+func (h *ApiWebHandler) HandleDeleteWebOrganizationSourceCredentials(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	sourceid, err := uuid.Parse(r.PathValue("sourceid"))
+	if err != nil {
+		responses.ErrInvalidSourceID(w, r, err)
+		return
+	}
+
+	deleted, err := h.core.DeleteSourceCredentials(r.Context(), sourceid, *orgid, r.PathValue("name"))
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	if !deleted {
+		responses.ErrSourceCredentialsNotFound(w, r, nil)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusNoContent, nil)
+}
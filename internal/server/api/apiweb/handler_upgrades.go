@@ -0,0 +1,234 @@
+package apiweb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/goodieshq/sweettooth/internal/server/requests"
+	"github.com/goodieshq/sweettooth/internal/server/responses"
+	"github.com/goodieshq/sweettooth/pkg/api"
+	"github.com/google/uuid"
+)
+
+// decode and validate an upgrade policy request body, sending an error response on failure
+func decodeUpgradePolicy(w http.ResponseWriter, r *http.Request) *api.UpgradePolicy {
+	var policy api.UpgradePolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		responses.ErrInvalidRequestBody(w, r, err)
+		return nil
+	}
+
+	if policy.Packages == nil {
+		policy.Packages = make([]string, 0)
+	}
+
+	for i := range policy.Packages {
+		policy.Packages[i] = strings.TrimSpace(policy.Packages[i])
+		if policy.Packages[i] == "" {
+			responses.ErrInvalidRequestBody(w, r, errors.New("upgrade policy packages cannot be empty"))
+			return nil
+		}
+	}
+
+	switch policy.Mode {
+	case api.UPGRADE_MODE_ALL:
+	case api.UPGRADE_MODE_ONLY:
+		if len(policy.Packages) == 0 {
+			responses.ErrInvalidRequestBody(w, r, fmt.Errorf("upgrade policy mode %q requires at least one package", api.UPGRADE_MODE_ONLY))
+			return nil
+		}
+	default:
+		responses.ErrInvalidRequestBody(w, r, fmt.Errorf("upgrade policy mode must be %q or %q", api.UPGRADE_MODE_ALL, api.UPGRADE_MODE_ONLY))
+		return nil
+	}
+
+	return &policy
+}
+
+// GET /api/v1/web/organizations/{orgid}/upgrade_policy
+func (h *ApiWebHandler) HandleGetWebOrganizationUpgradePolicy(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	policy, err := h.core.GetOrganizationUpgradePolicy(r.Context(), *orgid)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	// organizations without a policy do not upgrade automatically
+	if policy == nil {
+		responses.ErrUpgradePolicyNotFound(w, r, nil)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, policy)
+}
+
+// PUT /api/v1/web/organizations/{orgid}/upgrade_policy
+func (h *ApiWebHandler) HandlePutWebOrganizationUpgradePolicy(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	policy := decodeUpgradePolicy(w, r)
+	if policy == nil {
+		return
+	}
+
+	if err := h.core.SetOrganizationUpgradePolicy(r.Context(), *orgid, policy); err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, policy)
+}
+
+// DELETE /api/v1/web/organizations/{orgid}/upgrade_policy
+func (h *ApiWebHandler) HandleDeleteWebOrganizationUpgradePolicy(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	deleted, err := h.core.DeleteOrganizationUpgradePolicy(r.Context(), *orgid)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	if !deleted {
+		responses.ErrUpgradePolicyNotFound(w, r, nil)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusNoContent, nil)
+}
+
+// GET /api/v1/web/organizations/{orgid}/groups/{groupid}/upgrade_policy
+func (h *ApiWebHandler) HandleGetWebOrganizationGroupUpgradePolicy(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	groupid, err := uuid.Parse(r.PathValue("groupid"))
+	if err != nil {
+		responses.ErrInvalidGroupID(w, r, err)
+		return
+	}
+
+	policy, err := h.core.GetGroupUpgradePolicy(r.Context(), *orgid, groupid)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	// members of a group without a policy use the organization's
+	if policy == nil {
+		responses.ErrUpgradePolicyNotFound(w, r, nil)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, policy)
+}
+
+// PUT /api/v1/web/organizations/{orgid}/groups/{groupid}/upgrade_policy
+func (h *ApiWebHandler) HandlePutWebOrganizationGroupUpgradePolicy(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	groupid, err := uuid.Parse(r.PathValue("groupid"))
+	if err != nil {
+		responses.ErrInvalidGroupID(w, r, err)
+		return
+	}
+
+	policy := decodeUpgradePolicy(w, r)
+	if policy == nil {
+		return
+	}
+
+	found, err := h.core.SetGroupUpgradePolicy(r.Context(), *orgid, groupid, policy)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	if !found {
+		responses.ErrGroupNotFound(w, r, nil)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusOK, policy)
+}
+
+// DELETE /api/v1/web/organizations/{orgid}/groups/{groupid}/upgrade_policy
+func (h *ApiWebHandler) HandleDeleteWebOrganizationGroupUpgradePolicy(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	groupid, err := uuid.Parse(r.PathValue("groupid"))
+	if err != nil {
+		responses.ErrInvalidGroupID(w, r, err)
+		return
+	}
+
+	deleted, err := h.core.DeleteGroupUpgradePolicy(r.Context(), *orgid, groupid)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	if !deleted {
+		responses.ErrUpgradePolicyNotFound(w, r, nil)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusNoContent, nil)
+}
+
+// POST /api/v1/web/organizations/{orgid}/nodes/{nodeid}/upgrade
+func (h *ApiWebHandler) HandlePostWebOrganizationNodeUpgrade(w http.ResponseWriter, r *http.Request) {
+	orgid := requests.Oid(r)
+	if orgid == nil {
+		responses.ErrInvalidOrgID(w, r, nil)
+		return
+	}
+
+	nodeid := requests.Nid(r)
+	if nodeid == nil {
+		responses.ErrInvalidNodeID(w, r, nil)
+		return
+	}
+
+	// queue an upgrade of every outdated package the node last reported, pinned packages are left alone
+	jobs, err := h.core.UpgradeNodePackages(r.Context(), *nodeid, *orgid)
+	if err != nil {
+		responses.ErrServiceUnavailable(w, r, err)
+		return
+	}
+
+	if jobs == nil {
+		responses.ErrNodeNotFound(w, r, nil)
+		return
+	}
+
+	responses.JsonResponse(w, r, http.StatusCreated, jobs)
+}
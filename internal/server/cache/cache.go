@@ -9,6 +9,7 @@ type Cache interface {
 	SetAuthWithLifetime(nodeid string, authorized bool, lifetime time.Duration)
 	SetNodeAuth(nodeid string, authorized bool)                  // set node authorization status
 	GetNodeAuth(nodeid string) (found bool, authorized bool) // get node authorization status
+	DeleteNodeAuth(nodeid string)                            // remove node authorization status so it is reloaded
 	Flush()                                                  // clear the cache
 }
 
@@ -35,6 +35,11 @@ func (c *CacheGo) GetNodeAuth(nodeid string) (found, authorized bool) {
 	return
 }
 
+// Delete the auth status of a Node ID so the next request must consult the database
+func (c *CacheGo) DeleteNodeAuth(nodeid string) {
+	c.c.Delete(CacheSuffixAuth(nodeid))
+}
+
 // Flush the cache
 func (c *CacheGo) Flush() {
 	c.c.Flush()
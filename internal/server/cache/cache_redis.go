@@ -60,6 +60,13 @@ func (c *CacheRedis) GetNodeAuth(nodeid string) (found, authorized bool) {
 	return true, isAuthorized == REDIS_TRUE
 }
 
+// Delete the auth status of a Node ID so the next request must consult the database
+func (c *CacheRedis) DeleteNodeAuth(nodeid string) {
+	if err := c.c.Del(context.Background(), CacheSuffixAuth(nodeid)).Err(); err != nil {
+		log.Warn().Err(err).Msg("redis cache failure")
+	}
+}
+
 // Flush the cache
 func (c *CacheRedis) Flush() {
 	c.c.FlushDB(context.Background())
@@ -2,7 +2,11 @@ package core
 
 import (
 	"context"
+	"time"
 
+	"github.com/goodieshq/sweettooth/internal/schedule"
+	"github.com/goodieshq/sweettooth/internal/server/roles"
+	"github.com/goodieshq/sweettooth/internal/util"
 	"github.com/goodieshq/sweettooth/pkg/api"
 	"github.com/google/uuid"
 )
@@ -10,29 +14,122 @@ import (
 type Core interface {
 	Close()
 	ErrNotFound(err error) bool                                                        // determines if the err is the equivalent of no SQL rows being found
+	ErrConflict(err error) bool                                                        // determines if the err is the equivalent of a unique constraint violation
+	ErrInvalidRegex(err error) bool                                                    // determines if the err is the equivalent of an invalid regular expression
 	Seen(ctx context.Context, nodeid uuid.UUID) error                                  // update last seen attribute of a node
 	GetOrganizations(ctx context.Context, ) ([]*api.Organization, error)                 // get a list of all organizations
 	GetOrganizationSummaries(ctx context.Context) ([]*api.OrganizationSummary, error)  // get a list of all organizations
 	GetOrganization(ctx context.Context, orgid uuid.UUID) (*api.Organization, error)   // get an organization by ID
 	ProcessRegistrationToken(ctx context.Context, token uuid.UUID) (*uuid.UUID, error) // get the organization from a registration token
+	// organizations.retry
+	GetOrganizationRetryPolicy(ctx context.Context, orgid uuid.UUID) (*api.RetryPolicy, error)      // nil if the organization uses the default policy
+	SetOrganizationRetryPolicy(ctx context.Context, orgid uuid.UUID, policy *api.RetryPolicy) error // the policy applies to jobs which do not set their own
+	DeleteOrganizationRetryPolicy(ctx context.Context, orgid uuid.UUID) (bool, error)               // false if the organization uses the default policy
+	// organizations.upgrade
+	GetOrganizationUpgradePolicy(ctx context.Context, orgid uuid.UUID) (*api.UpgradePolicy, error)      // nil if the organization does not upgrade automatically
+	SetOrganizationUpgradePolicy(ctx context.Context, orgid uuid.UUID, policy *api.UpgradePolicy) error // applies to packages reported as outdated from now on
+	DeleteOrganizationUpgradePolicy(ctx context.Context, orgid uuid.UUID) (bool, error)                 // false if the organization has no policy
+	// organizations.softwaremaps
+	GetOrganizationSoftwareMaps(ctx context.Context, orgid uuid.UUID) (*api.SoftwareMapCatalog, error)       // nil if the organization only uses the defaults
+	SetOrganizationSoftwareMaps(ctx context.Context, orgid uuid.UUID, catalog *api.SoftwareMapCatalog) error // replaces the organization's own software maps
+	DeleteOrganizationSoftwareMaps(ctx context.Context, orgid uuid.UUID) (bool, error)                       // false if the organization only uses the defaults
+
+	// users
+	CreateUser(ctx context.Context, email, passwordHash string, superAdmin bool) (*api.User, error)      // create a user, nil if the email is taken
+	GetUserLogin(ctx context.Context, email string) (*api.UserLogin, error)                              // get a user and its credentials by email
+	GetUserLoginByID(ctx context.Context, userid uuid.UUID) (*api.UserLogin, error)                      // get a user and its credentials by ID
+	GetUserOrgRoles(ctx context.Context, userid uuid.UUID) (roles.OrgRoles, error)                       // get the roles of a user in each of its organizations
+	UserLoginSucceeded(ctx context.Context, userid uuid.UUID) error                                      // record a successful login and clear the failures
+	UserLoginFailed(ctx context.Context, userid uuid.UUID, maxFailures int, lockout time.Duration) error // record a failed login, locking the user out after too many
+	// users.mfa
+	SetUserMfaPending(ctx context.Context, userid uuid.UUID, secret string) error                                              // store a TOTP secret awaiting verification
+	EnableUserMfa(ctx context.Context, userid uuid.UUID, secret string, counter uint64, recoveryHashes []string) (bool, error) // enable the verified pending secret, false if it changed
+	UseUserMfaRecoveryCode(ctx context.Context, userid uuid.UUID, codeHash string) (bool, error)                               // consume a recovery code, false if it is not valid
+	UseUserTotpCounter(ctx context.Context, userid uuid.UUID, counter uint64) (bool, error)                                    // consume the time step of a TOTP code, false if it or a later one was already used
+
+	// registration tokens
+	GetRegistrationTokens(ctx context.Context, orgid uuid.UUID, paging *api.Pagination) ([]*api.RegistrationToken, error)
+	GetRegistrationToken(ctx context.Context, tokenid, orgid uuid.UUID) (*api.RegistrationToken, error)
+	CreateRegistrationToken(ctx context.Context, orgid uuid.UUID, req *api.RegistrationTokenRequest) (*api.RegistrationToken, error)
+	UpdateRegistrationToken(ctx context.Context, tokenid, orgid uuid.UUID, req *api.RegistrationTokenRequest) (*api.RegistrationToken, error)
+	DeleteRegistrationToken(ctx context.Context, tokenid, orgid uuid.UUID) (bool, error)
 
 	// nodes
 	GetNodes(ctx context.Context, orgid uuid.UUID) ([]*api.Node, error)
 	GetNode(ctx context.Context, nodeid uuid.UUID) (*api.Node, error)
 	CreateNode(ctx context.Context, req api.RegistrationRequest) (*api.Node, error)
+	GetNodeCheck(ctx context.Context, nodeid uuid.UUID, attemptsMax int) (*api.CheckResponse, error) // nil if the node does not exist
 	// nodes.approval
-	// UpdateNodeApproval(ctx context.Context, nodeid uuid.UUID, approved bool)
+	GetPendingNodes(ctx context.Context, orgid uuid.UUID, paging *api.Pagination) ([]*api.Node, error)
+	ApproveNode(ctx context.Context, nodeid, orgid uuid.UUID) (*api.Node, error)
+	RejectNode(ctx context.Context, nodeid, orgid uuid.UUID) (*api.Node, error)
+	RevokeNode(ctx context.Context, nodeid, orgid uuid.UUID) (*api.Node, error)
 
 	// nodes.packages
 	UpdateNodePackages(ctx context.Context, nodeid uuid.UUID, packages *api.Packages) error
+	UpdateNodePackagesDelta(ctx context.Context, nodeid uuid.UUID, delta *api.PackagesDelta) (bool, error) // false if the delta does not apply to the node's current inventory
 	GetNodePackages(ctx context.Context, nodeid uuid.UUID) (*api.Packages, error)
+	UpgradeNodePackages(ctx context.Context, nodeid, orgid uuid.UUID) ([]*api.PackageJob, error) // queue an upgrade of every outdated package which is not pinned, nil if the node is not in the org
+	// nodes.adoption
+	GetNodeAdoptableSoftware(ctx context.Context, nodeid, orgid uuid.UUID) ([]*api.AdoptableSoftware, error)      // nil if the node is not in the org
+	AdoptNodeSoftware(ctx context.Context, nodeid, orgid uuid.UUID, packages []string) ([]*api.PackageJob, error) // queue an adoption job for each package (all if empty), nil if the node is not in the org
+	// nodes.history
+	GetNodePackageHistory(ctx context.Context, nodeid, orgid uuid.UUID, from, to time.Time) ([]*api.PackageChangeSet, error) // newest first, nil if the node is not in the org
+	GetOrganizationPackageChanges(ctx context.Context, orgid uuid.UUID, since time.Time) ([]*api.PackageChangeSet, error)    // every node's changes, newest first
+	// nodes.search
+	SearchPackages(ctx context.Context, orgid uuid.UUID, search *api.PackageSearch, paging *api.Pagination) ([]*api.PackageSearchResult, error) // the packages of every node in the org matching the search
+
+	// sources
+	GetNodeSources(ctx context.Context, nodeid uuid.UUID) (util.RepositoryList, error)          // get the effective sources assigned to a node, credentials sealed to its key
+	ReportNodeSources(ctx context.Context, nodeid uuid.UUID, sources util.RepositoryList) error // store the sources a node has actually configured, clearing its pending flag
+	GetNodeSourcesStatus(ctx context.Context, nodeid uuid.UUID) (*api.NodeSources, error)       // compare the assigned sources against the reported ones
+	// sources.credentials
+	SetSourceCredentials(ctx context.Context, sourceid, orgid uuid.UUID, name string, req *api.SourceCredentialsRequest) (bool, error) // false if the source has no entry with the name
+	DeleteSourceCredentials(ctx context.Context, sourceid, orgid uuid.UUID, name string) (bool, error)                                 // false if the entry has no credentials
 
 	// schedule
 	GetNodeSchedule(ctx context.Context, nodeid uuid.UUID) (api.Schedule, error)
+	ConfirmNodeSchedule(ctx context.Context, nodeid uuid.UUID, applied api.Schedule) (bool, error) // false if the schedule changed since it was applied
+	// schedule.web
+	GetSchedules(ctx context.Context, orgid uuid.UUID, paging *api.Pagination) ([]*api.OrganizationSchedule, error)
+	GetSchedule(ctx context.Context, scheduleid, orgid uuid.UUID) (*api.OrganizationSchedule, error)
+	CreateSchedule(ctx context.Context, orgid uuid.UUID, req *api.ScheduleRequest) (*api.OrganizationSchedule, error)
+	UpdateSchedule(ctx context.Context, scheduleid, orgid uuid.UUID, req *api.ScheduleRequest) (*api.OrganizationSchedule, error)
+	DeleteSchedule(ctx context.Context, scheduleid, orgid uuid.UUID) (bool, error)
+	// schedule.assignments
+	AssignSchedule(ctx context.Context, scheduleid, orgid uuid.UUID, grouptype schedule.ScheduleGroupType, targetid uuid.UUID) (bool, error)   // false if the schedule or target is not in the org
+	UnassignSchedule(ctx context.Context, scheduleid, orgid uuid.UUID, grouptype schedule.ScheduleGroupType, targetid uuid.UUID) (bool, error) // false if the assignment does not exist
+
+	// baselines
+	GetBaselines(ctx context.Context, orgid uuid.UUID, paging *api.Pagination) ([]*api.Baseline, error)
+	GetBaseline(ctx context.Context, baselineid, orgid uuid.UUID) (*api.Baseline, error)
+	CreateBaseline(ctx context.Context, orgid uuid.UUID, req *api.BaselineRequest) (*api.Baseline, error)
+	UpdateBaseline(ctx context.Context, baselineid, orgid uuid.UUID, req *api.BaselineRequest) (*api.Baseline, error) // re-evaluates every node the baseline applies to
+	DeleteBaseline(ctx context.Context, baselineid, orgid uuid.UUID) (bool, error)
+	// baselines.assignments
+	AssignBaseline(ctx context.Context, baselineid, orgid uuid.UUID, grouptype schedule.ScheduleGroupType, targetid uuid.UUID) (bool, error)   // false if the baseline or target is not in the org
+	UnassignBaseline(ctx context.Context, baselineid, orgid uuid.UUID, grouptype schedule.ScheduleGroupType, targetid uuid.UUID) (bool, error) // false if the assignment does not exist
+	// baselines.compliance
+	GetNodeCompliance(ctx context.Context, nodeid uuid.UUID) (*api.NodeCompliance, error)
 
 	// jobs
-	GetPackageJobList(ctx context.Context, nodeid uuid.UUID, attemptsMax int) (api.PackageJobList, error)
+	GetPackageJobList(ctx context.Context, nodeid uuid.UUID, attemptsMax int, overdue bool) (api.PackageJobList, error) // overdue limits the list to jobs past their deadline
 	GetPackageJob(ctx context.Context, jobid uuid.UUID) (*api.PackageJob, error)
-	AttemptPackageJob(ctx context.Context, jobid, nodeid uuid.UUID, attemptsMax int) (*api.PackageJob, error)
+	LeasePackageJob(ctx context.Context, jobid, nodeid uuid.UUID, attemptsMax int) (*api.PackageJob, error) // nil if the job is not queued for the node
 	CompletePackageJob(ctx context.Context, jobid, nodeid uuid.UUID, result *api.PackageJobResult) error
+	AppendPackageJobOutput(ctx context.Context, jobid, nodeid uuid.UUID, output string) (bool, error) // heartbeats the lease, false if the job is not leased to the node
+	// jobs.web
+	CreatePackageJob(ctx context.Context, orgid uuid.UUID, req *api.PackageJobRequest) (*api.PackageJob, error)
+	GetPackageJobs(ctx context.Context, orgid uuid.UUID, nodeid *uuid.UUID, paging *api.Pagination) ([]*api.PackageJob, error)
+	CancelPackageJob(ctx context.Context, jobid, orgid uuid.UUID) (*api.PackageJob, error)
+	ExpirePackageJobs(ctx context.Context) (int, error)                        // mark queued jobs past their expiration as expired, returns the number of jobs expired
+	ReclaimPackageJobLeases(ctx context.Context, attemptsMax int) (int, error) // requeue (or fail) jobs whose lease expired, attemptsMax applies to jobs without a retry policy
+	GetPackageJobAttempts(ctx context.Context, jobid uuid.UUID) ([]*api.PackageJobAttempt, error)
+	// jobs.groups
+	CreateGroupPackageJobs(ctx context.Context, orgid, groupid uuid.UUID, req *api.PackageJobGroupRequest) ([]*api.PackageJob, error)
+	GetGroupPackageJobRollups(ctx context.Context, orgid, groupid uuid.UUID, paging *api.Pagination) ([]*api.PackageJobRollup, error)
+	// groups.upgrade
+	GetGroupUpgradePolicy(ctx context.Context, orgid, groupid uuid.UUID) (*api.UpgradePolicy, error)              // nil if the group has no policy
+	SetGroupUpgradePolicy(ctx context.Context, orgid, groupid uuid.UUID, policy *api.UpgradePolicy) (bool, error) // false if the group is not in the org
+	DeleteGroupUpgradePolicy(ctx context.Context, orgid, groupid uuid.UUID) (bool, error)                         // false if the group has no policy
 }
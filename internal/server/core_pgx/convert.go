@@ -2,6 +2,7 @@ package core_pgx
 
 import (
 	"github.com/goodieshq/sweettooth/internal/server/database"
+	"github.com/goodieshq/sweettooth/internal/util"
 	"github.com/goodieshq/sweettooth/pkg/api"
 	"github.com/google/uuid"
 )
@@ -49,6 +50,84 @@ func pgxOrgSummariesToCoreOrgSummariesPtr(dborgsums []database.GetOrganizationSu
 	return apiorgsums
 }
 
+// convert a pgx user to api user
+func pgxUserToCoreUser(dbuser *database.User) *api.User {
+	var user api.User
+	user.ID = dbuser.ID
+	user.Email = dbuser.Email
+	user.SuperAdmin = dbuser.Superadmin
+	user.MfaEnabled = dbuser.Mfatoken.Valid
+	user.CreatedAt = dbuser.CreatedAt.Time
+	if dbuser.LastLogin.Valid {
+		user.LastLogin = &dbuser.LastLogin.Time
+	}
+	return &user
+}
+
+// convert a pgx user row to api user login
+func pgxUserLoginToCoreUserLogin(dbuser *database.GetUserByEmailRow) *api.UserLogin {
+	var login api.UserLogin
+	login.ID = dbuser.ID
+	login.Email = dbuser.Email
+	login.SuperAdmin = dbuser.Superadmin
+	login.MfaEnabled = dbuser.Mfatoken.Valid
+	login.CreatedAt = dbuser.CreatedAt.Time
+	if dbuser.LastLogin.Valid {
+		login.LastLogin = &dbuser.LastLogin.Time
+	}
+	login.PasswordHash = dbuser.Password
+	login.Locked = dbuser.Locked
+	login.MfaSecret = dbuser.Mfatoken.String
+	login.MfaPending = dbuser.MfatokenPending.String
+	return &login
+}
+
+// convert a pgx registration token to api registration token
+func pgxRegistrationTokenToCoreRegistrationToken(dbtoken *database.RegistrationToken) *api.RegistrationToken {
+	var token api.RegistrationToken
+	token.ID = dbtoken.ID
+	token.OrganizationID = dbtoken.OrganizationID
+	token.Name = dbtoken.Name
+	token.CreatedAt = dbtoken.CreatedAt.Time
+	if dbtoken.ExpiresAt.Valid {
+		token.ExpiresAt = &dbtoken.ExpiresAt.Time
+	}
+	if dbtoken.MaxUses.Valid {
+		maxUses := int(dbtoken.MaxUses.Int32)
+		token.MaxUses = &maxUses
+	}
+	token.Uses = int(dbtoken.Uses)
+	token.AutoApprove = dbtoken.AutoApprove
+	return &token
+}
+
+// convert a pgx schedule to api organization schedule
+func pgxScheduleToCoreOrganizationSchedule(dbsched *database.Schedule) *api.OrganizationSchedule {
+	var sched api.OrganizationSchedule
+	sched.ID = dbsched.ID
+	sched.OrganizationID = dbsched.OrganizationID
+	sched.Name = dbsched.Name
+	sched.Entries = api.Schedule(dbsched.Entries)
+	if sched.Entries == nil {
+		sched.Entries = make(api.Schedule, 0)
+	}
+	return &sched
+}
+
+// convert a pgx baseline to api baseline
+func pgxBaselineToCoreBaseline(dbbaseline *database.Baseline) *api.Baseline {
+	var baseline api.Baseline
+	baseline.ID = dbbaseline.ID
+	baseline.OrganizationID = dbbaseline.OrganizationID
+	baseline.Name = dbbaseline.Name
+	baseline.Entries = dbbaseline.Entries
+	if baseline.Entries == nil {
+		baseline.Entries = make(util.BaselineEntryList, 0)
+	}
+	baseline.UpdatedAt = dbbaseline.UpdatedAt.Time
+	return &baseline
+}
+
 // convert a pgx package job to api package job
 func pgxPackageJobToCorePackageJob(dbjob *database.PackageJob) *api.PackageJob {
 	var job api.PackageJob
@@ -60,7 +139,12 @@ func pgxPackageJobToCorePackageJob(dbjob *database.PackageJob) *api.PackageJob {
 		gid := uuid.UUID(dbjob.GroupID.Bytes)
 		job.GroupID = &gid
 	}
+	if dbjob.BaselineID.Valid {
+		bid := uuid.UUID(dbjob.BaselineID.Bytes)
+		job.BaselineID = &bid
+	}
 	job.OrganizationID = dbjob.OrganizationID
+	job.State = dbjob.State
 	job.Attempts = int(dbjob.Attempts)
 
 	job.Action = int(dbjob.Action)
@@ -70,27 +154,48 @@ func pgxPackageJobToCorePackageJob(dbjob *database.PackageJob) *api.PackageJob {
 	if dbjob.Version.Valid {
 		job.Parameters.Version = &dbjob.Version.String
 	}
+	job.Parameters.Timeout = int(dbjob.Timeout)
 	job.Parameters.IgnoreChecksum = dbjob.IgnoreChecksum
 	job.Parameters.InstallOnUpgrade = dbjob.InstallOnUpgrade
 	job.Parameters.Force = dbjob.Force
 	job.Parameters.VerboseOutput = dbjob.VerboseOutput
 	job.Parameters.NotSilent = dbjob.NotSilent
+	job.Parameters.SkipPowershell = dbjob.SkipPowershell
+	job.RetryPolicy = dbjob.RetryPolicy
 
 	// set the result
 	job.Result = &api.PackageJobResult{}
 	job.Result.Status = int(dbjob.Status)
+	if dbjob.ExitCode.Valid {
+		job.Result.ExitCode = int(dbjob.ExitCode.Int32)
+	}
 	if dbjob.Output.Valid {
 		job.Result.Output = dbjob.Output.String
 	}
+	if dbjob.Error.Valid {
+		job.Result.Error = &dbjob.Error.String
+	}
 
 	// final metadata
 	job.CreatedAt = dbjob.CreatedAt.Time
 	if dbjob.ExpiresAt.Valid {
 		job.ExpiresAt = &dbjob.ExpiresAt.Time
 	}
+	if dbjob.NotBefore.Valid {
+		job.NotBefore = &dbjob.NotBefore.Time
+	}
+	if dbjob.Deadline.Valid {
+		job.Deadline = &dbjob.Deadline.Time
+	}
 	if dbjob.AttemptedAt.Valid {
 		job.AttemptedAt = &dbjob.AttemptedAt.Time
 	}
+	if dbjob.LeaseExpiresAt.Valid {
+		job.LeaseExpiresAt = &dbjob.LeaseExpiresAt.Time
+	}
+	if dbjob.RetryAt.Valid {
+		job.RetryAt = &dbjob.RetryAt.Time
+	}
 	if dbjob.CompletedAt.Valid {
 		job.CompletedAt = &dbjob.CompletedAt.Time
 	}
@@ -98,6 +203,78 @@ func pgxPackageJobToCorePackageJob(dbjob *database.PackageJob) *api.PackageJob {
 	return &job
 }
 
+// convert a pgx package job attempt to api package job attempt
+func pgxPackageJobAttemptToCorePackageJobAttempt(dbattempt *database.PackageJobAttempt) *api.PackageJobAttempt {
+	var attempt api.PackageJobAttempt
+
+	attempt.Attempt = int(dbattempt.Attempt)
+	if dbattempt.Outcome.Valid {
+		attempt.Outcome = &dbattempt.Outcome.String
+	}
+	if dbattempt.Status.Valid {
+		status := int(dbattempt.Status.Int32)
+		attempt.Status = &status
+	}
+	if dbattempt.ExitCode.Valid {
+		exitCode := int(dbattempt.ExitCode.Int32)
+		attempt.ExitCode = &exitCode
+	}
+	if dbattempt.Output.Valid {
+		attempt.Output = &dbattempt.Output.String
+	}
+	if dbattempt.Error.Valid {
+		attempt.Error = &dbattempt.Error.String
+	}
+
+	attempt.LeasedAt = dbattempt.LeasedAt.Time
+	if dbattempt.StartedAt.Valid {
+		attempt.StartedAt = &dbattempt.StartedAt.Time
+	}
+	if dbattempt.FinishedAt.Valid {
+		attempt.FinishedAt = &dbattempt.FinishedAt.Time
+	}
+	if dbattempt.Decision.Valid {
+		attempt.Decision = &dbattempt.Decision.String
+	}
+	if dbattempt.RetryAt.Valid {
+		attempt.RetryAt = &dbattempt.RetryAt.Time
+	}
+
+	return &attempt
+}
+
+func pgxPackageJobsToCorePackageJobsPtr(dbjobs []database.PackageJob) []*api.PackageJob {
+	apijobs := make([]*api.PackageJob, len(dbjobs))
+	for i := range dbjobs {
+		apijobs[i] = pgxPackageJobToCorePackageJob(&dbjobs[i])
+	}
+	return apijobs
+}
+
+// convert a pgx group job rollup to api job rollup
+func pgxPackageJobRollupToCorePackageJobRollup(groupid uuid.UUID, dbrollup *database.GetGroupPackageJobRollupsRow) *api.PackageJobRollup {
+	var rollup api.PackageJobRollup
+
+	rollup.GroupID = groupid
+	rollup.BatchID = uuid.UUID(dbrollup.BatchID.Bytes)
+	rollup.Action = int(dbrollup.Action)
+	rollup.Name = dbrollup.Name
+	if dbrollup.Version.Valid {
+		rollup.Version = &dbrollup.Version.String
+	}
+	rollup.CreatedAt = dbrollup.CreatedAt.Time
+
+	rollup.Total = int(dbrollup.Total)
+	rollup.Pending = int(dbrollup.Pending)
+	rollup.Succeeded = int(dbrollup.Succeeded)
+	rollup.Neutral = int(dbrollup.Neutral)
+	rollup.Failed = int(dbrollup.Failed)
+	rollup.Cancelled = int(dbrollup.Cancelled)
+	rollup.Expired = int(dbrollup.Expired)
+
+	return &rollup
+}
+
 // convert a pgx node to api node
 func pgxNodeToCoreNode(dbnode *database.Node) *api.Node {
 	var node api.Node
@@ -131,6 +308,39 @@ func pgxNodeToCoreNode(dbnode *database.Node) *api.Node {
 	}
 
 	node.Approved = dbnode.Approved
+	if dbnode.RejectedOn.Valid {
+		node.RejectedOn = &dbnode.RejectedOn.Time
+	}
+	if dbnode.RevokedOn.Valid {
+		node.RevokedOn = &dbnode.RevokedOn.Time
+	}
 
 	return &node
 }
+
+// convert the rows of a node's inventory (ordered by position) into the lists the node reported, lists are never nil
+func pgxNodePackagesToCorePackages(dbpackages []database.NodePackage) *api.Packages {
+	packages := api.Packages{
+		PackagesChoco:    util.SoftwareList{},
+		PackagesSystem:   util.SoftwareList{},
+		PackagesOutdated: util.SoftwareOutdatedList{},
+	}
+
+	for _, dbpkg := range dbpackages {
+		switch dbpkg.Source {
+		case api.PACKAGE_SOURCE_CHOCO:
+			packages.PackagesChoco = append(packages.PackagesChoco, util.Software{Name: dbpkg.Name, Version: dbpkg.Version})
+		case api.PACKAGE_SOURCE_SYSTEM:
+			packages.PackagesSystem = append(packages.PackagesSystem, util.Software{Name: dbpkg.Name, Version: dbpkg.Version})
+		case api.PACKAGE_SOURCE_OUTDATED:
+			packages.PackagesOutdated = append(packages.PackagesOutdated, util.SoftwareOutdated{
+				Name:       dbpkg.Name,
+				VersionOld: dbpkg.Version,
+				VersionNew: dbpkg.VersionNew.String,
+				Pinned:     dbpkg.Pinned,
+			})
+		}
+	}
+
+	return &packages
+}
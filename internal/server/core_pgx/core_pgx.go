@@ -2,30 +2,64 @@ package core_pgx
 
 import (
 	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math/rand"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/goodieshq/sweettooth/internal/client/choco"
 	"github.com/goodieshq/sweettooth/internal/crypto"
+	"github.com/goodieshq/sweettooth/internal/schedule"
 	"github.com/goodieshq/sweettooth/internal/server/database"
+	"github.com/goodieshq/sweettooth/internal/server/roles"
 	"github.com/goodieshq/sweettooth/internal/util"
 	"github.com/goodieshq/sweettooth/pkg/api"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog/log"
 )
 
+// postgres error code raised when a unique constraint is violated
+const PG_UNIQUE_VIOLATION = "23505"
+
+// postgres error code raised when a regular expression is invalid, postgres and go do not share a regex syntax
+const PG_INVALID_REGULAR_EXPRESSION = "2201B"
+
+// how many packages are read at a time when a package search is filtered by version
+const SEARCH_BATCH_SIZE = 1000
+
+// a node must heartbeat a leased package job within this many seconds or the lease is reclaimed
+const PACKAGE_JOB_LEASE_SECONDS = 60
+
 // PGX (postgres) implementation of SweetTooth Core
 type CorePGX struct {
-	pool *pgxpool.Pool     // connection pool
-	q    *database.Queries // sqlc query functions
+	pool    *pgxpool.Pool     // connection pool
+	q       *database.Queries // sqlc query functions
+	secrets crypto.Cipher     // encrypts the secrets stored in the database
 }
 
 func (CorePGX) ErrNotFound(err error) bool {
 	return err == pgx.ErrNoRows
 }
 
+func (CorePGX) ErrConflict(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == PG_UNIQUE_VIOLATION
+}
+
+func (CorePGX) ErrInvalidRegex(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == PG_INVALID_REGULAR_EXPRESSION
+}
+
 func (core *CorePGX) Close() {
 	core.pool.Close()
 }
@@ -64,256 +98,3055 @@ func (core *CorePGX) GetOrganizationSummaries(ctx context.Context) ([]*api.Organ
 	return pgxOrgSummariesToCoreOrgSummariesPtr(orgs), nil
 }
 
-func (core *CorePGX) ProcessRegistrationToken(ctx context.Context, token uuid.UUID) (*uuid.UUID, error) {
-	orgid, err := core.q.GetValidRegistrationToken(ctx, token)
+func (core *CorePGX) GetOrganizationRetryPolicy(ctx context.Context, orgid uuid.UUID) (*api.RetryPolicy, error) {
+	policy, err := core.q.GetOrganizationRetryPolicy(ctx, orgid)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, nil
 		}
-
-		log.Error().Err(err).Msg("failed to get registration token")
+		log.Error().Err(err).Msg("failed to get organization retry policy")
 		return nil, err
 	}
-
-	return &orgid, nil
+	return &policy, nil
 }
 
-func (core *CorePGX) UpdateNodePackages(ctx context.Context, nodeid uuid.UUID, packages *api.Packages) error {
-	err := core.q.UpdateNodePackages(ctx, database.UpdateNodePackagesParams{
-		ID:               nodeid,
-		PackagesChoco:    packages.PackagesChoco,
-		PackagesSystem:   packages.PackagesSystem,
-		PackagesOutdated: packages.PackagesOutdated,
+func (core *CorePGX) SetOrganizationRetryPolicy(ctx context.Context, orgid uuid.UUID, policy *api.RetryPolicy) error {
+	err := core.q.SetOrganizationRetryPolicy(ctx, database.SetOrganizationRetryPolicyParams{
+		OrganizationID: orgid,
+		RetryPolicy:    *policy,
 	})
 	if err != nil {
-		log.Error().Err(err).Msg("failed to update packages")
+		log.Error().Err(err).Msg("failed to set organization retry policy")
 	}
 	return err
 }
 
-func (core *CorePGX) GetNodePackages(ctx context.Context, nodeid uuid.UUID) (*api.Packages, error) {
-	var packages api.Packages
-	pkg, err := core.q.GetNodePackages(ctx, nodeid)
+func (core *CorePGX) DeleteOrganizationRetryPolicy(ctx context.Context, orgid uuid.UUID) (bool, error) {
+	count, err := core.q.DeleteOrganizationRetryPolicy(ctx, orgid)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to delete organization retry policy")
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (core *CorePGX) GetOrganizationUpgradePolicy(ctx context.Context, orgid uuid.UUID) (*api.UpgradePolicy, error) {
+	policy, err := core.q.GetOrganizationUpgradePolicy(ctx, orgid)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, nil
 		}
-		log.Error().Err(err).Send()
+		log.Error().Err(err).Msg("failed to get organization upgrade policy")
 		return nil, err
 	}
+	return &policy, nil
+}
 
-	packages.PackagesChoco = pkg.PackagesChoco
-	packages.PackagesSystem = pkg.PackagesSystem
-	packages.PackagesOutdated = pkg.PackagesOutdated
+func (core *CorePGX) SetOrganizationUpgradePolicy(ctx context.Context, orgid uuid.UUID, policy *api.UpgradePolicy) error {
+	err := core.q.SetOrganizationUpgradePolicy(ctx, database.SetOrganizationUpgradePolicyParams{
+		OrganizationID: orgid,
+		UpgradePolicy:  *policy,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to set organization upgrade policy")
+	}
+	return err
+}
 
-	return &packages, nil
+func (core *CorePGX) DeleteOrganizationUpgradePolicy(ctx context.Context, orgid uuid.UUID) (bool, error) {
+	count, err := core.q.DeleteOrganizationUpgradePolicy(ctx, orgid)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to delete organization upgrade policy")
+		return false, err
+	}
+	return count > 0, nil
 }
 
-func (core *CorePGX) GetNodes(ctx context.Context, orgid uuid.UUID) ([]*api.Node, error){
-	nodes, err := core.q.GetNodesByOrgID(ctx, database.GetNodesByOrgIDParams{
+func (core *CorePGX) GetOrganizationSoftwareMaps(ctx context.Context, orgid uuid.UUID) (*api.SoftwareMapCatalog, error) {
+	dbmaps, err := core.q.GetOrganizationSoftwareMaps(ctx, orgid)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Msg("failed to get organization software maps")
+		return nil, err
+	}
+
+	catalog := api.SoftwareMapCatalog{
+		IncludeDefaults: dbmaps.IncludeDefaults,
+		SoftwareMaps:    dbmaps.SoftwareMaps,
+	}
+	if catalog.SoftwareMaps == nil {
+		catalog.SoftwareMaps = make(util.SoftwareMapList, 0)
+	}
+	return &catalog, nil
+}
 
+func (core *CorePGX) SetOrganizationSoftwareMaps(ctx context.Context, orgid uuid.UUID, catalog *api.SoftwareMapCatalog) error {
+	err := core.q.SetOrganizationSoftwareMaps(ctx, database.SetOrganizationSoftwareMapsParams{
+		OrganizationID:  orgid,
+		IncludeDefaults: catalog.IncludeDefaults,
+		SoftwareMaps:    catalog.SoftwareMaps,
 	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to set organization software maps")
+	}
+	return err
+}
+
+func (core *CorePGX) DeleteOrganizationSoftwareMaps(ctx context.Context, orgid uuid.UUID) (bool, error) {
+	count, err := core.q.DeleteOrganizationSoftwareMaps(ctx, orgid)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to delete organization software maps")
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (core *CorePGX) ProcessRegistrationToken(ctx context.Context, token uuid.UUID) (*uuid.UUID, error) {
+	orgid, err := core.q.GetValidRegistrationToken(ctx, token)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			return []*api.Node{}, nil
+			return nil, nil
 		}
+
+		log.Error().Err(err).Msg("failed to get registration token")
+		return nil, err
 	}
 
-	nodesApi := make([]*api.Node, len(nodes))
-	for i, node := range nodes {
-		nodesApi[i] = pgxNodeToCoreNode(&node)
+	return &orgid, nil
+}
+
+func (core *CorePGX) CreateUser(ctx context.Context, email, passwordHash string, superAdmin bool) (*api.User, error) {
+	user, err := core.q.CreateUser(ctx, database.CreateUserParams{
+		Email:      email,
+		Password:   passwordHash,
+		Superadmin: superAdmin,
+	})
+	if err != nil {
+		// no row is returned if a user with the same email already exists
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Msg("failed to create user")
+		return nil, err
 	}
-	return nodesApi, nil
+	return pgxUserToCoreUser(&user), nil
 }
 
-func (core *CorePGX) GetNode(ctx context.Context, nodeid uuid.UUID) (*api.Node, error) {
-	node, err := core.q.GetNodeByID(ctx, nodeid)
+func (core *CorePGX) GetUserLogin(ctx context.Context, email string) (*api.UserLogin, error) {
+	user, err := core.q.GetUserByEmail(ctx, email)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, nil
 		}
+		log.Error().Err(err).Msg("failed to get user")
 		return nil, err
 	}
-	return pgxNodeToCoreNode(&node), nil
+	return pgxUserLoginToCoreUserLogin(&user), nil
 }
 
-func (core *CorePGX) GetNodeSchedule(ctx context.Context, nodeid uuid.UUID) (api.Schedule, error) {
-	q := database.New(core.pool)
-	dbschedules, err := q.GetCombinedScheduleByNode(ctx, nodeid)
+func (core *CorePGX) GetUserLoginByID(ctx context.Context, userid uuid.UUID) (*api.UserLogin, error) {
+	user, err := core.q.GetUserByID(ctx, userid)
 	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Msg("failed to get user")
 		return nil, err
 	}
+	dbuser := database.GetUserByEmailRow(user)
+	return pgxUserLoginToCoreUserLogin(&dbuser), nil
+}
 
-	// extract all of the entries from all of the schedules assigned
-	var sched api.Schedule
-	for _, dbsched := range dbschedules {
-		sched = append(sched, dbsched.Entries...)
+func (core *CorePGX) GetUserOrgRoles(ctx context.Context, userid uuid.UUID) (roles.OrgRoles, error) {
+	assignments, err := core.q.GetUserOrganizationRoles(ctx, userid)
+	if err != nil {
+		return nil, err
 	}
 
-	if sched == nil {
-		sched = make(api.Schedule, 0)
+	orgRoles := make(roles.OrgRoles, len(assignments))
+	for _, assignment := range assignments {
+		orgRoles[assignment.OrganizationID] = roles.OrgRole(assignment.Role)
 	}
 
-	return sched, nil
+	return orgRoles, nil
 }
 
-func (core *CorePGX) CreateNode(ctx context.Context, req api.RegistrationRequest) (*api.Node, error) {
-	q := database.New(core.pool)
-	var params database.CreateNodeParams
+func (core *CorePGX) UserLoginSucceeded(ctx context.Context, userid uuid.UUID) error {
+	return core.q.UpdateUserLoginSuccess(ctx, userid)
+}
 
-	pubkey, err := util.Base64toPubKey(req.PublicKey)
+func (core *CorePGX) UserLoginFailed(ctx context.Context, userid uuid.UUID, maxFailures int, lockout time.Duration) error {
+	return core.q.UpdateUserLoginFailure(ctx, database.UpdateUserLoginFailureParams{
+		MaxFailures:    int32(maxFailures),
+		LockoutSeconds: int32(lockout.Seconds()),
+		ID:             userid,
+	})
+}
+
+func (core *CorePGX) SetUserMfaPending(ctx context.Context, userid uuid.UUID, secret string) error {
+	return core.q.SetUserMfaPending(ctx, database.SetUserMfaPendingParams{
+		Secret: secret,
+		ID:     userid,
+	})
+}
+
+func (core *CorePGX) EnableUserMfa(ctx context.Context, userid uuid.UUID, secret string, counter uint64, recoveryHashes []string) (bool, error) {
+	n, err := core.q.EnableUserMfa(ctx, database.EnableUserMfaParams{
+		RecoveryCodes: recoveryHashes,
+		Counter:       int64(counter),
+		ID:            userid,
+		Secret:        secret,
+	})
 	if err != nil {
-		return nil, err
+		return false, err
 	}
+	return n > 0, nil
+}
 
-	// get the fingerprint of the public key
-	params.ID = crypto.Fingerprint(pubkey)
-	params.ID_2 = req.Token
+func (core *CorePGX) UseUserMfaRecoveryCode(ctx context.Context, userid uuid.UUID, codeHash string) (bool, error) {
+	n, err := core.q.UseUserMfaRecoveryCode(ctx, database.UseUserMfaRecoveryCodeParams{
+		CodeHash: codeHash,
+		ID:       userid,
+	})
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
 
-	// Client information
-	params.PublicKey = req.PublicKey
-	params.Hostname = req.Hostname
-	params.ClientVersion = req.ClientVersion
+func (core *CorePGX) UseUserTotpCounter(ctx context.Context, userid uuid.UUID, counter uint64) (bool, error) {
+	n, err := core.q.UseUserTotpCounter(ctx, database.UseUserTotpCounterParams{
+		Counter: int64(counter),
+		ID:      userid,
+	})
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
 
-	// OS Info
-	params.OsName = req.OSName
-	params.OsKernel = req.OSKernel
-	params.OsMajor = int32(req.OSMajor)
-	params.OsMinor = int32(req.OSMinor)
-	params.OsBuild = int32(req.OSBuild)
+func (core *CorePGX) GetRegistrationTokens(ctx context.Context, orgid uuid.UUID, paging *api.Pagination) ([]*api.RegistrationToken, error) {
+	tokens, err := core.q.GetRegistrationTokensByOrgID(ctx, database.GetRegistrationTokensByOrgIDParams{
+		OrganizationID: orgid,
+		Sort:           paging.Sort,
+		PageLimit:      int32(paging.Limit),
+		PageOffset:     int32(paging.Offset),
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	// Package info
-	params.PackagesChoco = req.PackagesChoco
-	params.PackagesSystem = req.PackagesSystem
-	params.PackagesOutdated = req.PackagesOutdated
+	apitokens := make([]*api.RegistrationToken, len(tokens))
+	for i := range tokens {
+		apitokens[i] = pgxRegistrationTokenToCoreRegistrationToken(&tokens[i])
+	}
+	return apitokens, nil
+}
 
-	node, err := q.CreateNode(ctx, params)
-	log.Info().Msg("Creating Node:")
+func (core *CorePGX) GetRegistrationToken(ctx context.Context, tokenid, orgid uuid.UUID) (*api.RegistrationToken, error) {
+	token, err := core.q.GetRegistrationToken(ctx, database.GetRegistrationTokenParams{
+		ID:             tokenid,
+		OrganizationID: orgid,
+	})
 	if err != nil {
-		log.Error().Err(err).Msg("failed to create node")
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Msg("failed to get registration token")
 		return nil, err
 	}
+	return pgxRegistrationTokenToCoreRegistrationToken(&token), nil
+}
 
-	// no errors, node was created
-	return pgxNodeToCoreNode(&node), nil
+// convert the optional registration token request values into their database types
+func registrationTokenRequestParams(req *api.RegistrationTokenRequest) (expiresAt pgtype.Timestamp, maxUses pgtype.Int4) {
+	if req.ExpiresAt != nil {
+		expiresAt = pgtype.Timestamp{Time: req.ExpiresAt.UTC(), Valid: true}
+	}
+	if req.MaxUses != nil {
+		maxUses = pgtype.Int4{Int32: int32(*req.MaxUses), Valid: true}
+	}
+	return
 }
 
-func testPool(ctx context.Context, pool *pgxpool.Pool) error {
-	var target = rand.Intn(10000)
-	var result int
+func (core *CorePGX) CreateRegistrationToken(ctx context.Context, orgid uuid.UUID, req *api.RegistrationTokenRequest) (*api.RegistrationToken, error) {
+	expiresAt, maxUses := registrationTokenRequestParams(req)
 
-	conn, err := pool.Acquire(ctx)
+	token, err := core.q.CreateRegistrationToken(ctx, database.CreateRegistrationTokenParams{
+		OrganizationID: orgid,
+		Name:           req.Name,
+		ExpiresAt:      expiresAt,
+		MaxUses:        maxUses,
+		AutoApprove:    req.AutoApprove,
+	})
 	if err != nil {
-		return err
+		log.Error().Err(err).Msg("failed to create registration token")
+		return nil, err
 	}
-	defer conn.Release()
+	return pgxRegistrationTokenToCoreRegistrationToken(&token), nil
+}
 
-	err = conn.QueryRow(ctx, fmt.Sprintf("SELECT %d", target)).Scan(&result)
+func (core *CorePGX) UpdateRegistrationToken(ctx context.Context, tokenid, orgid uuid.UUID, req *api.RegistrationTokenRequest) (*api.RegistrationToken, error) {
+	expiresAt, maxUses := registrationTokenRequestParams(req)
+
+	token, err := core.q.UpdateRegistrationToken(ctx, database.UpdateRegistrationTokenParams{
+		ID:             tokenid,
+		OrganizationID: orgid,
+		Name:           req.Name,
+		ExpiresAt:      expiresAt,
+		MaxUses:        maxUses,
+		AutoApprove:    req.AutoApprove,
+	})
 	if err != nil {
-		return err
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Msg("failed to update registration token")
+		return nil, err
 	}
+	return pgxRegistrationTokenToCoreRegistrationToken(&token), nil
+}
 
-	if result != target {
-		return fmt.Errorf("unexpected value from test query")
+func (core *CorePGX) DeleteRegistrationToken(ctx context.Context, tokenid, orgid uuid.UUID) (bool, error) {
+	n, err := core.q.DeleteRegistrationToken(ctx, database.DeleteRegistrationTokenParams{
+		ID:             tokenid,
+		OrganizationID: orgid,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to delete registration token")
+		return false, err
 	}
+	return n > 0, nil
+}
 
-	return nil
+// a package of a node's inventory, identified by everything the node reports about it
+type nodePackageKey struct {
+	source     string
+	name       string
+	version    string
+	versionNew string
+	pinned     bool
 }
 
-func NewCorePGX(ctx context.Context, connStr string) (*CorePGX, error) {
-	log.Info().Str("connstr", connStr).Msg("Setting Database Connection Parameters")
-	cfg, err := pgxpool.ParseConfig(connStr)
+// get the inventory of a node, every list is empty if the node has not reported any packages
+func getNodePackages(ctx context.Context, q *database.Queries, nodeid uuid.UUID) (*api.Packages, error) {
+	dbpackages, err := q.GetNodePackages(ctx, nodeid)
 	if err != nil {
+		log.Error().Err(err).Msg("failed to get node packages")
 		return nil, err
 	}
+	return pgxNodePackagesToCorePackages(dbpackages), nil
+}
 
-	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+// replace the stored inventory of a node with the one it reported by applying only the differences: packages which
+// are no longer reported are deleted, newly reported packages are inserted, and packages still reported at another
+// position within their list are moved. Unless told otherwise, the choco and system packages which were installed or
+// removed are recorded in the changelog. The previous inventory is returned
+func replaceNodePackages(ctx context.Context, q *database.Queries, node *database.Node, packages *api.Packages, record bool) (*api.Packages, error) {
+	dbpackages, err := q.GetNodePackages(ctx, node.ID)
 	if err != nil {
+		log.Error().Err(err).Msg("failed to get node packages")
 		return nil, err
 	}
 
-	// test the pool to determine if it is valid
-	if err := testPool(ctx, pool); err != nil {
+	// the same package can be listed more than once, so each reported package claims one of the stored rows
+	stored := make(map[nodePackageKey][]database.NodePackage)
+	for _, dbpkg := range dbpackages {
+		key := nodePackageKey{dbpkg.Source, dbpkg.Name, dbpkg.Version, dbpkg.VersionNew.String, dbpkg.Pinned}
+		stored[key] = append(stored[key], dbpkg)
+	}
+
+	inserted := database.CreateNodePackagesParams{NodeID: node.ID, OrganizationID: node.OrganizationID}
+	moved := database.MoveNodePackagesParams{NodeID: node.ID}
+	deleted := database.DeleteNodePackagesParams{NodeID: node.ID}
+	changes := database.CreateNodePackageChangesParams{NodeID: node.ID, OrganizationID: node.OrganizationID}
+
+	report := func(key nodePackageKey, position int) {
+		if claimed := stored[key]; len(claimed) > 0 {
+			stored[key] = claimed[1:]
+			if claimed[0].Position != int32(position) {
+				moved.Ids = append(moved.Ids, claimed[0].ID)
+				moved.Positions = append(moved.Positions, int32(position))
+			}
+			return
+		}
+
+		inserted.Sources = append(inserted.Sources, key.source)
+		inserted.Positions = append(inserted.Positions, int32(position))
+		inserted.Names = append(inserted.Names, key.name)
+		inserted.Versions = append(inserted.Versions, key.version)
+		inserted.VersionsNew = append(inserted.VersionsNew, key.versionNew)
+		inserted.Pinned = append(inserted.Pinned, key.pinned)
+
+		if key.source != api.PACKAGE_SOURCE_OUTDATED {
+			changes.Sources = append(changes.Sources, key.source)
+			changes.Changes = append(changes.Changes, api.PACKAGE_CHANGE_INSTALLED)
+			changes.Names = append(changes.Names, key.name)
+			changes.Versions = append(changes.Versions, key.version)
+		}
+	}
+
+	for i, sw := range packages.PackagesChoco {
+		report(nodePackageKey{source: api.PACKAGE_SOURCE_CHOCO, name: sw.Name, version: sw.Version}, i)
+	}
+	for i, sw := range packages.PackagesSystem {
+		report(nodePackageKey{source: api.PACKAGE_SOURCE_SYSTEM, name: sw.Name, version: sw.Version}, i)
+	}
+	for i, sw := range packages.PackagesOutdated {
+		report(nodePackageKey{api.PACKAGE_SOURCE_OUTDATED, sw.Name, sw.VersionOld, sw.VersionNew, sw.Pinned}, i)
+	}
+
+	// the rows left unclaimed are no longer reported, they are removed in the order they were stored
+	unclaimed := make(map[int64]bool)
+	for _, rows := range stored {
+		for _, dbpkg := range rows {
+			unclaimed[dbpkg.ID] = true
+		}
+	}
+	for _, dbpkg := range dbpackages {
+		if !unclaimed[dbpkg.ID] {
+			continue
+		}
+		deleted.Ids = append(deleted.Ids, dbpkg.ID)
+		if dbpkg.Source != api.PACKAGE_SOURCE_OUTDATED {
+			changes.Sources = append(changes.Sources, dbpkg.Source)
+			changes.Changes = append(changes.Changes, api.PACKAGE_CHANGE_REMOVED)
+			changes.Names = append(changes.Names, dbpkg.Name)
+			changes.Versions = append(changes.Versions, dbpkg.Version)
+		}
+	}
+
+	previous := pgxNodePackagesToCorePackages(dbpackages)
+	if len(inserted.Names) == 0 && len(moved.Ids) == 0 && len(deleted.Ids) == 0 {
+		return previous, nil
+	}
+
+	if len(deleted.Ids) > 0 {
+		if err := q.DeleteNodePackages(ctx, deleted); err != nil {
+			log.Error().Err(err).Msg("failed to delete node packages")
+			return nil, err
+		}
+	}
+
+	if len(moved.Ids) > 0 {
+		if err := q.MoveNodePackages(ctx, moved); err != nil {
+			log.Error().Err(err).Msg("failed to move node packages")
+			return nil, err
+		}
+	}
+
+	if len(inserted.Names) > 0 {
+		if err := q.CreateNodePackages(ctx, inserted); err != nil {
+			log.Error().Err(err).Msg("failed to create node packages")
+			return nil, err
+		}
+	}
+
+	if record && len(changes.Names) > 0 {
+		if err := q.CreateNodePackageChanges(ctx, changes); err != nil {
+			log.Error().Err(err).Msg("failed to create node package changes")
+			return nil, err
+		}
+	}
+
+	if err := q.SetNodePackagesUpdated(ctx, node.ID); err != nil {
+		log.Error().Err(err).Msg("failed to set node packages updated")
 		return nil, err
 	}
 
-	return &CorePGX{
-		pool: pool,
-		q:    database.New(pool),
-	}, nil
+	return previous, nil
 }
 
-func (core *CorePGX) GetPackageJobList(ctx context.Context, nodeid uuid.UUID, attemptsMax int) (api.PackageJobList, error) {
-	joblist, err := core.q.GetPackageJobListByNodeID(ctx, database.GetPackageJobListByNodeIDParams{
-		NodeID:   nodeid,
-		Attempts: int32(attemptsMax),
-	})
+func (core *CorePGX) UpdateNodePackages(ctx context.Context, nodeid uuid.UUID, packages *api.Packages) error {
+	tx, err := core.pool.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	q := core.q.WithTx(tx)
 
+	// the node stays locked until its inventory is replaced, so concurrent updates are applied one after the other
+	node, err := q.LockNodeByID(ctx, nodeid)
 	if err != nil {
-		log.Error().Err(err).Msg("failed to query db GetPackageJobListByNodeID")
-		return nil, err
+		if err == pgx.ErrNoRows {
+			return nil
+		}
+		log.Error().Err(err).Msg("failed to get node")
+		return err
 	}
 
-	if joblist == nil {
-		joblist = []uuid.UUID{}
+	if err := updateNodePackages(ctx, q, &node, packages); err != nil {
+		return err
 	}
 
-	return api.PackageJobList(joblist), nil
+	return tx.Commit(ctx)
 }
 
-func (core *CorePGX) GetPackageJob(ctx context.Context, jobid uuid.UUID) (*api.PackageJob, error) {
-	job, err := core.q.GetPackageJobByID(ctx, jobid)
+func (core *CorePGX) UpdateNodePackagesDelta(ctx context.Context, nodeid uuid.UUID, delta *api.PackagesDelta) (bool, error) {
+	tx, err := core.pool.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	q := core.q.WithTx(tx)
 
+	node, err := q.LockNodeByID(ctx, nodeid)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			return nil, nil
+			return false, nil
 		}
+		log.Error().Err(err).Msg("failed to get node")
+		return false, err
+	}
 
-		log.Error().Err(err).Msg("failed to get package job by ID")
-		return nil, err
+	current, err := getNodePackages(ctx, q, nodeid)
+	if err != nil {
+		return false, err
 	}
 
-	return pgxPackageJobToCorePackageJob(&job), nil
-}
+	// the delta only applies to the exact inventory it was computed against, and must result in the node's inventory
+	packages, err := current.ApplyDelta(delta)
+	if err != nil {
+		log.Debug().Err(err).Msg("node package delta does not apply")
+		return false, nil
+	}
 
-func (core *CorePGX) AttemptPackageJob(ctx context.Context, jobid, nodeid uuid.UUID, attemptsMax int) (*api.PackageJob, error) {
-	job, err := core.q.AttemptPackageJob(ctx, database.AttemptPackageJobParams{
-		ID:       jobid,
-		NodeID:   nodeid,
-		Attempts: int32(attemptsMax),
-	})
+	if err := updateNodePackages(ctx, q, &node, packages); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit(ctx)
+}
 
+// replace the inventory of a locked node with the reported packages, then queue the jobs the changes call for
+func updateNodePackages(ctx context.Context, q *database.Queries, node *database.Node, packages *api.Packages) error {
+	// the previously reported outdated packages are needed to find the ones which are newly outdated
+	previous, err := replaceNodePackages(ctx, q, node, packages, true)
 	if err != nil {
+		return err
+	}
+
+	// upgrades are queued first so the baseline does not queue its own jobs for the same packages
+	if err := autoUpgradeNodePackages(ctx, q, node, previous.PackagesOutdated, packages.PackagesOutdated); err != nil {
+		return err
+	}
+
+	// the reported packages are compared against the node's baseline, queueing jobs for any drift
+	return evaluateNodeBaseline(ctx, q, node.ID)
+}
+
+func (core *CorePGX) GetNodePackages(ctx context.Context, nodeid uuid.UUID) (*api.Packages, error) {
+	// a node which does not exist has no inventory at all, rather than an empty one
+	if _, err := core.q.GetNodeByID(ctx, nodeid); err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, nil
 		}
+		log.Error().Err(err).Send()
 		return nil, err
 	}
 
-	return pgxPackageJobToCorePackageJob(&job), nil
+	return getNodePackages(ctx, core.q, nodeid)
 }
 
-func (core *CorePGX) CompletePackageJob(ctx context.Context, jobid, nodeid uuid.UUID, result *api.PackageJobResult) error {
-	dberr := pgtype.Text{}
+// queue a job to upgrade each outdated package selected by the filter, skipping pinned packages and packages which
+// already have an active job
+func queueNodeUpgrades(ctx context.Context, q *database.Queries, node *database.Node, outdated util.SoftwareOutdatedList, selects func(name string) bool) ([]database.PackageJob, error) {
+	active, err := q.GetActivePackageJobNamesByNodeID(ctx, node.ID)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get active package jobs")
+		return nil, err
+	}
+
+	var dbjobs []database.PackageJob
+	for _, pkg := range outdated {
+		if pkg.Pinned || !selects(pkg.Name) {
+			continue
+		}
+		if slices.ContainsFunc(active, func(name string) bool { return strings.EqualFold(name, pkg.Name) }) {
+			continue
+		}
+
+		job, err := q.CreatePackageJob(ctx, database.CreatePackageJobParams{
+			NodeID:         node.ID,
+			OrganizationID: node.OrganizationID,
+			Action:         api.JOB_ACTION_UPGRADE,
+			Name:           pkg.Name,
+			Timeout:        api.JOB_DEFAULT_TIMEOUT,
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("failed to create upgrade package job")
+			return nil, err
+		}
+
+		// a package reported twice only needs a single job
+		active = append(active, pkg.Name)
+		dbjobs = append(dbjobs, job)
+	}
+
+	return dbjobs, nil
+}
+
+// queue upgrades for the packages a node newly reports as outdated, according to the node's upgrade policies
+func autoUpgradeNodePackages(ctx context.Context, q *database.Queries, node *database.Node, previous, outdated util.SoftwareOutdatedList) error {
+	// only approved nodes are sent jobs
+	if !node.Approved {
+		return nil
+	}
+
+	dbpolicies, err := q.GetNodeUpgradePolicies(ctx, node.ID)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get node upgrade policies")
+		return err
+	}
+
+	if len(dbpolicies) == 0 {
+		return nil
+	}
+
+	// the policies of the node's groups replace the policy of its organization, a package is upgraded if any of the
+	// most specific policies selects it
+	precedence := dbpolicies[len(dbpolicies)-1].Precedence
+	selects := func(name string) bool {
+		for i := range dbpolicies {
+			if dbpolicies[i].Precedence == precedence && dbpolicies[i].UpgradePolicy.Selects(name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// a package is only newly outdated if it was not already reported as outdated with the same new version, so a
+	// failed upgrade is not queued again until an even newer version is released
+	var fresh util.SoftwareOutdatedList
+	for _, pkg := range outdated {
+		if !slices.ContainsFunc(previous, func(prev util.SoftwareOutdated) bool {
+			return strings.EqualFold(prev.Name, pkg.Name) && prev.VersionNew == pkg.VersionNew
+		}) {
+			fresh = append(fresh, pkg)
+		}
+	}
+
+	_, err = queueNodeUpgrades(ctx, q, node, fresh, selects)
+	return err
+}
+
+// find the system software of a node which the organization's software maps match to a chocolatey package that is not
+// installed, each package is only adoptable once
+func nodeAdoptableSoftware(ctx context.Context, q *database.Queries, node *database.Node) ([]*api.AdoptableSoftware, error) {
+	maps := util.DefaultSoftwareMaps()
+
+	dbmaps, err := q.GetOrganizationSoftwareMaps(ctx, node.OrganizationID)
+	if err != nil && err != pgx.ErrNoRows {
+		log.Error().Err(err).Msg("failed to get organization software maps")
+		return nil, err
+	}
+
+	// the organization's own maps are checked before the defaults, unless it opted out of them entirely
+	if err == nil {
+		if dbmaps.IncludeDefaults {
+			maps = append(slices.Clone(dbmaps.SoftwareMaps), maps...)
+		} else {
+			maps = dbmaps.SoftwareMaps
+		}
+	}
+
+	matcher, err := maps.Compile()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to compile software maps")
+		return nil, err
+	}
+
+	packages, err := getNodePackages(ctx, q, node.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	adoptable := make([]*api.AdoptableSoftware, 0)
+	for _, sw := range packages.PackagesSystem {
+		match := matcher.Match(sw.Name)
+		if match == nil || packages.PackagesChoco.Find(match.Package) != nil {
+			continue
+		}
+		if slices.ContainsFunc(adoptable, func(a *api.AdoptableSoftware) bool { return strings.EqualFold(a.Package, match.Package) }) {
+			continue
+		}
+		adoptable = append(adoptable, &api.AdoptableSoftware{
+			Name:    sw.Name,
+			Version: sw.Version,
+			Package: match.Package,
+		})
+	}
+
+	return adoptable, nil
+}
+
+func (core *CorePGX) GetNodeAdoptableSoftware(ctx context.Context, nodeid, orgid uuid.UUID) ([]*api.AdoptableSoftware, error) {
+	node, err := core.q.GetNodeByID(ctx, nodeid)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Msg("failed to get node")
+		return nil, err
+	}
+
+	if node.OrganizationID != orgid {
+		return nil, nil
+	}
+
+	return nodeAdoptableSoftware(ctx, core.q, &node)
+}
+
+func (core *CorePGX) AdoptNodeSoftware(ctx context.Context, nodeid, orgid uuid.UUID, packages []string) ([]*api.PackageJob, error) {
+	tx, err := core.pool.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	q := core.q.WithTx(tx)
+
+	node, err := q.GetNodeByID(ctx, nodeid)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Msg("failed to get node")
+		return nil, err
+	}
+
+	if node.OrganizationID != orgid {
+		return nil, nil
+	}
+
+	adoptable, err := nodeAdoptableSoftware(ctx, q, &node)
+	if err != nil {
+		return nil, err
+	}
+
+	active, err := q.GetActivePackageJobNamesByNodeID(ctx, node.ID)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get active package jobs")
+		return nil, err
+	}
+
+	jobs := make([]*api.PackageJob, 0)
+	for _, sw := range adoptable {
+		requested := len(packages) == 0 || slices.ContainsFunc(packages, func(name string) bool { return strings.EqualFold(name, sw.Package) })
+		if !requested || slices.ContainsFunc(active, func(name string) bool { return strings.EqualFold(name, sw.Package) }) {
+			continue
+		}
+
+		// the package is registered at the installed version without running its install script, so the software is
+		// not reinstalled and chocolatey manages its upgrades from then on
+		params := database.CreatePackageJobParams{
+			NodeID:         node.ID,
+			OrganizationID: node.OrganizationID,
+			Action:         api.JOB_ACTION_INSTALL,
+			Name:           sw.Package,
+			Timeout:        api.JOB_DEFAULT_TIMEOUT,
+			SkipPowershell: true,
+		}
+		if sw.Version != "" {
+			params.Version = pgtype.Text{String: sw.Version, Valid: true}
+		}
+
+		job, err := q.CreatePackageJob(ctx, params)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to create adoption package job")
+			return nil, err
+		}
+		jobs = append(jobs, pgxPackageJobToCorePackageJob(&job))
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("failed to commit node adoption")
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// convert the changes between two lists of the same source into package changes
+func packageChanges(source string, older, newer util.SoftwareList) []api.PackageChange {
+	diff := older.Diff(newer)
+	changes := make([]api.PackageChange, len(diff))
+	for i, sw := range diff {
+		change := api.PACKAGE_CHANGE_VERSION
+		if sw.VersionOld == nil {
+			change = api.PACKAGE_CHANGE_INSTALLED
+		} else if sw.VersionNew == nil {
+			change = api.PACKAGE_CHANGE_REMOVED
+		}
+		changes[i] = api.PackageChange{
+			Source:     source,
+			Change:     change,
+			Name:       sw.Name,
+			VersionOld: sw.VersionOld,
+			VersionNew: sw.VersionNew,
+		}
+	}
+	return changes
+}
+
+// group a changelog ordered by timestamp into the changes of each inventory update, where the removal and install of
+// the same package are paired up as a new version
+func packageChangeSets(changelog []database.NodePackageChangelog) []*api.PackageChangeSet {
+	sets := make([]*api.PackageChangeSet, 0)
+	for start := 0; start < len(changelog); {
+		end := start + 1
+		for end < len(changelog) && changelog[end].NodeID == changelog[start].NodeID && changelog[end].Timestamp == changelog[start].Timestamp {
+			end++
+		}
+
+		removed := map[string]util.SoftwareList{}
+		installed := map[string]util.SoftwareList{}
+		for _, entry := range changelog[start:end] {
+			sw := util.Software{Name: entry.Name, Version: entry.Version}
+			if entry.Change == api.PACKAGE_CHANGE_REMOVED {
+				removed[entry.Source] = append(removed[entry.Source], sw)
+			} else {
+				installed[entry.Source] = append(installed[entry.Source], sw)
+			}
+		}
+
+		changes := packageChanges(api.PACKAGE_SOURCE_CHOCO, removed[api.PACKAGE_SOURCE_CHOCO], installed[api.PACKAGE_SOURCE_CHOCO])
+		changes = append(changes, packageChanges(api.PACKAGE_SOURCE_SYSTEM, removed[api.PACKAGE_SOURCE_SYSTEM], installed[api.PACKAGE_SOURCE_SYSTEM])...)
+
+		// nothing is left when only the letter case of a name changed
+		if len(changes) > 0 {
+			sets = append(sets, &api.PackageChangeSet{
+				NodeID:    changelog[start].NodeID,
+				Timestamp: changelog[start].Timestamp.Time,
+				Changes:   changes,
+			})
+		}
+		start = end
+	}
+	return sets
+}
+
+func (core *CorePGX) GetNodePackageHistory(ctx context.Context, nodeid, orgid uuid.UUID, from, to time.Time) ([]*api.PackageChangeSet, error) {
+	node, err := core.q.GetNodeByID(ctx, nodeid)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Msg("failed to get node")
+		return nil, err
+	}
+
+	if node.OrganizationID != orgid {
+		return nil, nil
+	}
+
+	changelog, err := core.q.GetNodePackageChangelog(ctx, database.GetNodePackageChangelogParams{
+		NodeID: nodeid,
+		TsFrom: pgtype.Timestamp{Time: from.UTC(), Valid: true},
+		TsTo:   pgtype.Timestamp{Time: to.UTC(), Valid: true},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get node package changelog")
+		return nil, err
+	}
+
+	return packageChangeSets(changelog), nil
+}
+
+func (core *CorePGX) GetOrganizationPackageChanges(ctx context.Context, orgid uuid.UUID, since time.Time) ([]*api.PackageChangeSet, error) {
+	changelog, err := core.q.GetOrganizationPackageChangelog(ctx, database.GetOrganizationPackageChangelogParams{
+		OrganizationID: orgid,
+		Since:          pgtype.Timestamp{Time: since.UTC(), Valid: true},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get organization package changelog")
+		return nil, err
+	}
+
+	return packageChangeSets(changelog), nil
+}
+
+func (core *CorePGX) SearchPackages(ctx context.Context, orgid uuid.UUID, search *api.PackageSearch, paging *api.Pagination) ([]*api.PackageSearchResult, error) {
+	params := database.SearchNodePackagesParams{
+		OrganizationID: orgid,
+		Match:          search.Match,
+		Name:           search.Name,
+		Sort:           paging.Sort,
+		PageLimit:      int32(paging.Limit),
+		PageOffset:     int32(paging.Offset),
+	}
+	if search.Source != "" {
+		params.Source = pgtype.Text{String: search.Source, Valid: true}
+	}
+
+	// versions are compared with chocolatey's version ordering which the database cannot do, so the packages are read
+	// in batches and the page is taken from the packages left after the comparison
+	if search.Version != "" {
+		params.PageLimit = SEARCH_BATCH_SIZE
+		params.PageOffset = 0
+	}
+
+	results := make([]*api.PackageSearchResult, 0)
+	skipped := 0
+	for {
+		rows, err := core.q.SearchNodePackages(ctx, params)
+		if err != nil {
+			if core.ErrInvalidRegex(err) {
+				return nil, err
+			}
+			log.Error().Err(err).Msg("failed to search node packages")
+			return nil, err
+		}
+
+		for _, row := range rows {
+			if len(results) >= paging.Limit {
+				break
+			}
+			if !search.MatchesVersion(row.Version) {
+				continue
+			}
+			if search.Version != "" && skipped < paging.Offset {
+				skipped++
+				continue
+			}
+
+			result := &api.PackageSearchResult{
+				NodeID:   row.NodeID,
+				Hostname: row.Hostname,
+				Source:   row.Source,
+				Name:     row.Name,
+				Version:  row.Version,
+			}
+			if row.Label.Valid {
+				result.Label = &row.Label.String
+			}
+			results = append(results, result)
+		}
+
+		if search.Version == "" || len(results) >= paging.Limit || len(rows) < int(params.PageLimit) {
+			return results, nil
+		}
+
+		// continue after the last package of the batch
+		last := rows[len(rows)-1]
+		params.AfterNodeID = pgtype.UUID{Bytes: last.NodeID, Valid: true}
+		params.AfterHostname = last.Hostname
+		params.AfterSource = last.Source
+		params.AfterPosition = last.Position
+	}
+}
+
+func (core *CorePGX) UpgradeNodePackages(ctx context.Context, nodeid, orgid uuid.UUID) ([]*api.PackageJob, error) {
+	tx, err := core.pool.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	q := core.q.WithTx(tx)
+
+	node, err := q.GetNodeByID(ctx, nodeid)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Msg("failed to get node")
+		return nil, err
+	}
+
+	if node.OrganizationID != orgid {
+		return nil, nil
+	}
+
+	packages, err := getNodePackages(ctx, q, node.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// every outdated package is upgraded regardless of the node's upgrade policies
+	dbjobs, err := queueNodeUpgrades(ctx, q, &node, packages.PackagesOutdated, func(string) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("failed to commit node upgrade")
+		return nil, err
+	}
+
+	jobs := make([]*api.PackageJob, len(dbjobs))
+	for i := range dbjobs {
+		jobs[i] = pgxPackageJobToCorePackageJob(&dbjobs[i])
+	}
+	return jobs, nil
+}
+
+// merge every source entry assigned to a node along with the ID of the source each entry came from
+func combinedNodeSources(ctx context.Context, q *database.Queries, nodeid uuid.UUID) (util.RepositoryList, []uuid.UUID, error) {
+	dbsources, err := q.GetCombinedSourcesByNode(ctx, nodeid)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get node sources")
+		return nil, nil, err
+	}
+
+	// sources are ordered from the least to the most specific assignment, so a more specific entry replaces any
+	// previous entry with the same name
+	sources := make(util.RepositoryList, 0)
+	var sourceids []uuid.UUID
+	for _, dbsource := range dbsources {
+		for _, entry := range dbsource.Entries {
+			// credentials are never stored within the entries themselves
+			entry.Password = ""
+			entry.CertificatePassword = ""
+
+			if i := sources.Index(entry.Name); i >= 0 {
+				sources[i] = entry
+				sourceids[i] = dbsource.ID
+				continue
+			}
+			sources = append(sources, entry)
+			sourceids = append(sourceids, dbsource.ID)
+		}
+	}
+
+	return sources, sourceids, nil
+}
+
+// get the public key a node registered with, which secrets are sealed to
+func (core *CorePGX) nodePublicKey(ctx context.Context, nodeid uuid.UUID) (ed25519.PublicKey, error) {
+	node, err := core.q.GetNodeByID(ctx, nodeid)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get node")
+		return nil, err
+	}
+
+	pubkey, err := base64.StdEncoding.DecodeString(node.PublicKey)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to decode node public key")
+		return nil, err
+	}
+
+	return ed25519.PublicKey(pubkey), nil
+}
+
+// decrypt a secret stored in the database and seal it to a node's public key, empty if there is no secret
+func (core *CorePGX) sealSecret(pubkey ed25519.PublicKey, encrypted []byte) (string, error) {
+	if encrypted == nil {
+		return "", nil
+	}
+
+	data, err := core.secrets.Decipher(encrypted)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to decrypt secret")
+		return "", err
+	}
+
+	sealed, err := crypto.Seal(pubkey, data)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to seal secret")
+		return "", err
+	}
+
+	return sealed, nil
+}
+
+// encrypt a secret to store in the database, nil if there is no secret
+func (core *CorePGX) encryptSecret(secret *string) ([]byte, error) {
+	if secret == nil || *secret == "" {
+		return nil, nil
+	}
+
+	encrypted, err := core.secrets.Encipher([]byte(*secret))
+	if err != nil {
+		log.Error().Err(err).Msg("failed to encrypt secret")
+		return nil, err
+	}
+
+	return encrypted, nil
+}
+
+func (core *CorePGX) GetNodeSources(ctx context.Context, nodeid uuid.UUID) (util.RepositoryList, error) {
+	sources, sourceids, err := combinedNodeSources(ctx, core.q, nodeid)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sources) == 0 {
+		return sources, nil
+	}
+
+	credentials, err := core.q.GetSourceCredentials(ctx, sourceids)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get source credentials")
+		return nil, err
+	}
+
+	if len(credentials) == 0 {
+		return sources, nil
+	}
+
+	pubkey, err := core.nodePublicKey(ctx, nodeid)
+	if err != nil {
+		return nil, err
+	}
+
+	// only the credentials of the source each entry was taken from are delivered
+	for _, credential := range credentials {
+		for i := range sources {
+			if sourceids[i] != credential.SourceID || !strings.EqualFold(sources[i].Name, credential.Name) {
+				continue
+			}
+			if sources[i].Password, err = core.sealSecret(pubkey, credential.Password); err != nil {
+				return nil, err
+			}
+			if sources[i].CertificatePassword, err = core.sealSecret(pubkey, credential.CertificatePassword); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return sources, nil
+}
+
+func (core *CorePGX) ReportNodeSources(ctx context.Context, nodeid uuid.UUID, sources util.RepositoryList) error {
+	if sources == nil {
+		sources = make(util.RepositoryList, 0)
+	}
+	err := core.q.UpdateNodeSources(ctx, database.UpdateNodeSourcesParams{
+		NodeID:  nodeid,
+		Sources: sources,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to update node sources")
+		return err
+	}
+
+	tx, err := core.pool.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	q := core.q.WithTx(tx)
+
+	// clearing the flag first locks the node, so a concurrent source change waits and flags the node again
+	if err := q.ClearNodePendingSources(ctx, nodeid); err != nil {
+		log.Error().Err(err).Msg("failed to clear pending sources")
+		return err
+	}
+
+	desired, _, err := combinedNodeSources(ctx, q, nodeid)
+	if err != nil {
+		return err
+	}
+
+	// the node must try again while its managed sources still differ from the assigned ones
+	if len(desired) > 0 && len(desired.Drift(sources)) > 0 {
+		return nil
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (core *CorePGX) GetNodeSourcesStatus(ctx context.Context, nodeid uuid.UUID) (*api.NodeSources, error) {
+	desired, _, err := combinedNodeSources(ctx, core.q, nodeid)
+	if err != nil {
+		return nil, err
+	}
+
+	status := api.NodeSources{
+		Desired:  desired,
+		Reported: make(util.RepositoryList, 0),
+	}
+
+	reported, err := core.q.GetNodeSources(ctx, nodeid)
+	if err != nil {
+		if err != pgx.ErrNoRows {
+			log.Error().Err(err).Msg("failed to get reported node sources")
+			return nil, err
+		}
+	} else {
+		if reported.Sources != nil {
+			status.Reported = reported.Sources
+		}
+		if reported.ReportedAt.Valid {
+			status.ReportedAt = &reported.ReportedAt.Time
+		}
+	}
+
+	status.Drift = status.Desired.Drift(status.Reported)
+	return &status, nil
+}
+
+func (core *CorePGX) SetSourceCredentials(ctx context.Context, sourceid, orgid uuid.UUID, name string, req *api.SourceCredentialsRequest) (bool, error) {
+	password, err := core.encryptSecret(req.Password)
+	if err != nil {
+		return false, err
+	}
+
+	certificatePassword, err := core.encryptSecret(req.CertificatePassword)
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := core.pool.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	q := core.q.WithTx(tx)
+
+	// the source must exist within the organization and contain an entry with the name
+	source, err := q.GetSource(ctx, database.GetSourceParams{
+		ID:             sourceid,
+		OrganizationID: orgid,
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return false, nil
+		}
+		log.Error().Err(err).Msg("failed to get source")
+		return false, err
+	}
+
+	entry := source.Entries.Find(name)
+	if entry == nil {
+		return false, nil
+	}
+
+	err = q.UpdateSourceCredentials(ctx, database.UpdateSourceCredentialsParams{
+		SourceID:            sourceid,
+		Name:                entry.Name,
+		Password:            password,
+		CertificatePassword: certificatePassword,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to update source credentials")
+		return false, err
+	}
+
+	// nodes using the source must add it again with the new credentials
+	err = q.SetSourcePendingBySourceID(ctx, database.SetSourcePendingBySourceIDParams{
+		OrganizationID: orgid,
+		SourceID:       sourceid,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to flag nodes for source changes")
+		return false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("failed to commit source credentials")
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (core *CorePGX) DeleteSourceCredentials(ctx context.Context, sourceid, orgid uuid.UUID, name string) (bool, error) {
+	tx, err := core.pool.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	q := core.q.WithTx(tx)
+
+	_, err = q.GetSource(ctx, database.GetSourceParams{
+		ID:             sourceid,
+		OrganizationID: orgid,
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return false, nil
+		}
+		log.Error().Err(err).Msg("failed to get source")
+		return false, err
+	}
+
+	n, err := q.DeleteSourceCredentials(ctx, database.DeleteSourceCredentialsParams{
+		SourceID: sourceid,
+		Name:     name,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to delete source credentials")
+		return false, err
+	}
+
+	if n == 0 {
+		return false, nil
+	}
+
+	err = q.SetSourcePendingBySourceID(ctx, database.SetSourcePendingBySourceIDParams{
+		OrganizationID: orgid,
+		SourceID:       sourceid,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to flag nodes for source changes")
+		return false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("failed to commit source credentials")
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (core *CorePGX) GetNodeCheck(ctx context.Context, nodeid uuid.UUID, attemptsMax int) (*api.CheckResponse, error) {
+	check, err := core.q.GetNodeCheck(ctx, database.GetNodeCheckParams{
+		AttemptsMax: int32(attemptsMax),
+		ID:          nodeid,
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Msg("failed to get node check")
+		return nil, err
+	}
+
+	return &api.CheckResponse{
+		PendingSources:  check.PendingSources,
+		PendingSchedule: check.PendingSchedule,
+		PendingJobs:     int(check.PendingJobs),
+		OverdueJobs:     int(check.OverdueJobs),
+	}, nil
+}
+
+func (core *CorePGX) GetNodes(ctx context.Context, orgid uuid.UUID) ([]*api.Node, error){
+	nodes, err := core.q.GetNodesByOrgID(ctx, database.GetNodesByOrgIDParams{
+
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return []*api.Node{}, nil
+		}
+	}
+
+	nodesApi := make([]*api.Node, len(nodes))
+	for i, node := range nodes {
+		nodesApi[i] = pgxNodeToCoreNode(&node)
+	}
+	return nodesApi, nil
+}
+
+func (core *CorePGX) GetNode(ctx context.Context, nodeid uuid.UUID) (*api.Node, error) {
+	node, err := core.q.GetNodeByID(ctx, nodeid)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return pgxNodeToCoreNode(&node), nil
+}
+
+func (core *CorePGX) GetPendingNodes(ctx context.Context, orgid uuid.UUID, paging *api.Pagination) ([]*api.Node, error) {
+	nodes, err := core.q.GetPendingNodesByOrgID(ctx, database.GetPendingNodesByOrgIDParams{
+		OrganizationID: orgid,
+		Sort:           paging.Sort,
+		PageLimit:      int32(paging.Limit),
+		PageOffset:     int32(paging.Offset),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	nodesApi := make([]*api.Node, len(nodes))
+	for i, node := range nodes {
+		nodesApi[i] = pgxNodeToCoreNode(&node)
+	}
+	return nodesApi, nil
+}
+
+func (core *CorePGX) ApproveNode(ctx context.Context, nodeid, orgid uuid.UUID) (*api.Node, error) {
+	node, err := core.q.ApproveNode(ctx, database.ApproveNodeParams{
+		ID:             nodeid,
+		OrganizationID: orgid,
+	})
+	if err != nil {
+		// the node is not in the organization or is already approved
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Msg("failed to approve node")
+		return nil, err
+	}
+	return pgxNodeToCoreNode(&node), nil
+}
+
+func (core *CorePGX) RejectNode(ctx context.Context, nodeid, orgid uuid.UUID) (*api.Node, error) {
+	node, err := core.q.RejectNode(ctx, database.RejectNodeParams{
+		ID:             nodeid,
+		OrganizationID: orgid,
+	})
+	if err != nil {
+		// the node is not in the organization or is no longer pending
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Msg("failed to reject node")
+		return nil, err
+	}
+	return pgxNodeToCoreNode(&node), nil
+}
+
+func (core *CorePGX) RevokeNode(ctx context.Context, nodeid, orgid uuid.UUID) (*api.Node, error) {
+	node, err := core.q.RevokeNode(ctx, database.RevokeNodeParams{
+		ID:             nodeid,
+		OrganizationID: orgid,
+	})
+	if err != nil {
+		// the node is not in the organization or is not approved
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Msg("failed to revoke node")
+		return nil, err
+	}
+	return pgxNodeToCoreNode(&node), nil
+}
+
+// extract all of the entries from all of the schedules assigned to a node
+func combinedNodeSchedule(ctx context.Context, q *database.Queries, nodeid uuid.UUID) (api.Schedule, error) {
+	dbschedules, err := q.GetCombinedScheduleByNode(ctx, nodeid)
+	if err != nil {
+		return nil, err
+	}
+
+	var sched api.Schedule
+	for _, dbsched := range dbschedules {
+		sched = append(sched, dbsched.Entries...)
+	}
+
+	if sched == nil {
+		sched = make(api.Schedule, 0)
+	}
+
+	return sched, nil
+}
+
+func (core *CorePGX) GetNodeSchedule(ctx context.Context, nodeid uuid.UUID) (api.Schedule, error) {
+	return combinedNodeSchedule(ctx, core.q, nodeid)
+}
+
+func (core *CorePGX) ConfirmNodeSchedule(ctx context.Context, nodeid uuid.UUID, applied api.Schedule) (bool, error) {
+	tx, err := core.pool.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	q := core.q.WithTx(tx)
+
+	// clearing the flag first locks the node, so a concurrent schedule change waits and flags the node again
+	if err := q.ClearNodePendingSchedule(ctx, nodeid); err != nil {
+		log.Error().Err(err).Msg("failed to clear pending schedule")
+		return false, err
+	}
+
+	current, err := combinedNodeSchedule(ctx, q, nodeid)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get node schedule")
+		return false, err
+	}
+
+	// the schedule changed after the node acquired it, leave the node flagged
+	if util.Dumps(current) != util.Dumps(applied) {
+		return false, nil
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("failed to commit schedule confirmation")
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (core *CorePGX) GetSchedules(ctx context.Context, orgid uuid.UUID, paging *api.Pagination) ([]*api.OrganizationSchedule, error) {
+	dbschedules, err := core.q.GetSchedulesByOrgID(ctx, database.GetSchedulesByOrgIDParams{
+		OrganizationID: orgid,
+		Sort:           paging.Sort,
+		PageLimit:      int32(paging.Limit),
+		PageOffset:     int32(paging.Offset),
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to query db GetSchedulesByOrgID")
+		return nil, err
+	}
+
+	schedules := make([]*api.OrganizationSchedule, len(dbschedules))
+	for i := range dbschedules {
+		schedules[i] = pgxScheduleToCoreOrganizationSchedule(&dbschedules[i])
+	}
+	return schedules, nil
+}
+
+func (core *CorePGX) GetSchedule(ctx context.Context, scheduleid, orgid uuid.UUID) (*api.OrganizationSchedule, error) {
+	dbsched, err := core.q.GetSchedule(ctx, database.GetScheduleParams{
+		ID:             scheduleid,
+		OrganizationID: orgid,
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Msg("failed to get schedule")
+		return nil, err
+	}
+	return pgxScheduleToCoreOrganizationSchedule(&dbsched), nil
+}
+
+func (core *CorePGX) CreateSchedule(ctx context.Context, orgid uuid.UUID, req *api.ScheduleRequest) (*api.OrganizationSchedule, error) {
+	// a new schedule has no assignments, so no nodes need to be notified
+	dbsched, err := core.q.CreateSchedule(ctx, database.CreateScheduleParams{
+		OrganizationID: orgid,
+		Name:           req.Name,
+		Entries:        schedule.Schedule(req.Entries),
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to create schedule")
+		return nil, err
+	}
+	return pgxScheduleToCoreOrganizationSchedule(&dbsched), nil
+}
+
+func (core *CorePGX) UpdateSchedule(ctx context.Context, scheduleid, orgid uuid.UUID, req *api.ScheduleRequest) (*api.OrganizationSchedule, error) {
+	tx, err := core.pool.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	q := core.q.WithTx(tx)
+
+	dbsched, err := q.UpdateSchedule(ctx, database.UpdateScheduleParams{
+		ID:             scheduleid,
+		OrganizationID: orgid,
+		Name:           req.Name,
+		Entries:        schedule.Schedule(req.Entries),
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Msg("failed to update schedule")
+		return nil, err
+	}
+
+	// every node inheriting the schedule must fetch its new combined schedule
+	err = q.SetSchedulePendingByScheduleID(ctx, database.SetSchedulePendingByScheduleIDParams{
+		OrganizationID: orgid,
+		ScheduleID:     scheduleid,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to set pending schedule")
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("failed to commit schedule update")
+		return nil, err
+	}
+
+	return pgxScheduleToCoreOrganizationSchedule(&dbsched), nil
+}
+
+func (core *CorePGX) DeleteSchedule(ctx context.Context, scheduleid, orgid uuid.UUID) (bool, error) {
+	tx, err := core.pool.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	q := core.q.WithTx(tx)
+
+	// the affected nodes can only be found while the assignments still exist
+	err = q.SetSchedulePendingByScheduleID(ctx, database.SetSchedulePendingByScheduleIDParams{
+		OrganizationID: orgid,
+		ScheduleID:     scheduleid,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to set pending schedule")
+		return false, err
+	}
+
+	if err := q.DeleteScheduleAssignments(ctx, scheduleid); err != nil {
+		log.Error().Err(err).Msg("failed to delete schedule assignments")
+		return false, err
+	}
+
+	n, err := q.DeleteSchedule(ctx, database.DeleteScheduleParams{
+		ID:             scheduleid,
+		OrganizationID: orgid,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to delete schedule")
+		return false, err
+	}
+
+	// the schedule is not in the organization, roll back the assignment removal
+	if n == 0 {
+		return false, nil
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("failed to commit schedule deletion")
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (core *CorePGX) AssignSchedule(ctx context.Context, scheduleid, orgid uuid.UUID, grouptype schedule.ScheduleGroupType, targetid uuid.UUID) (bool, error) {
+	tx, err := core.pool.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	q := core.q.WithTx(tx)
+
+	// the schedule must exist within the organization
+	_, err = q.GetSchedule(ctx, database.GetScheduleParams{
+		ID:             scheduleid,
+		OrganizationID: orgid,
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return false, nil
+		}
+		log.Error().Err(err).Msg("failed to get schedule")
+		return false, err
+	}
+
+	switch grouptype {
+	case schedule.SchedGroupNode:
+		// the node must exist within the organization
+		var node database.Node
+		node, err = q.GetNodeByID(ctx, targetid)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				return false, nil
+			}
+			log.Error().Err(err).Msg("failed to get node")
+			return false, err
+		}
+		if node.OrganizationID != orgid {
+			return false, nil
+		}
+
+		err = q.CreateNodeScheduleAssignment(ctx, database.CreateNodeScheduleAssignmentParams{
+			ScheduleID:     scheduleid,
+			NodeID:         targetid,
+			OrganizationID: orgid,
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("failed to assign schedule to node")
+			return false, err
+		}
+
+		err = q.SetSchedulePendingByNodeID(ctx, database.SetSchedulePendingByNodeIDParams{
+			ID:             targetid,
+			OrganizationID: orgid,
+		})
+	case schedule.SchedGroupGroup:
+		// the group must exist within the organization
+		_, err = q.GetGroupByID(ctx, database.GetGroupByIDParams{
+			ID:             targetid,
+			OrganizationID: orgid,
+		})
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				return false, nil
+			}
+			log.Error().Err(err).Msg("failed to get group")
+			return false, err
+		}
+
+		err = q.CreateGroupScheduleAssignment(ctx, database.CreateGroupScheduleAssignmentParams{
+			ScheduleID:     scheduleid,
+			GroupID:        targetid,
+			OrganizationID: orgid,
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("failed to assign schedule to group")
+			return false, err
+		}
+
+		err = q.SetSchedulePendingByGroupID(ctx, database.SetSchedulePendingByGroupIDParams{
+			GroupID:        targetid,
+			OrganizationID: orgid,
+		})
+	case schedule.SchedGroupOrganization:
+		err = q.CreateOrganizationScheduleAssignment(ctx, database.CreateOrganizationScheduleAssignmentParams{
+			ScheduleID:     scheduleid,
+			OrganizationID: orgid,
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("failed to assign schedule to organization")
+			return false, err
+		}
+
+		err = q.SetSchedulePendingByOrgID(ctx, orgid)
+	default:
+		return false, fmt.Errorf("invalid schedule group type %q", grouptype)
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msg("failed to set pending schedule")
+		return false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("failed to commit schedule assignment")
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (core *CorePGX) UnassignSchedule(ctx context.Context, scheduleid, orgid uuid.UUID, grouptype schedule.ScheduleGroupType, targetid uuid.UUID) (bool, error) {
+	tx, err := core.pool.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	q := core.q.WithTx(tx)
+
+	var n int64
+	switch grouptype {
+	case schedule.SchedGroupNode:
+		n, err = q.DeleteNodeScheduleAssignment(ctx, database.DeleteNodeScheduleAssignmentParams{
+			ScheduleID:     scheduleid,
+			NodeID:         targetid,
+			OrganizationID: orgid,
+		})
+		if err == nil && n > 0 {
+			err = q.SetSchedulePendingByNodeID(ctx, database.SetSchedulePendingByNodeIDParams{
+				ID:             targetid,
+				OrganizationID: orgid,
+			})
+		}
+	case schedule.SchedGroupGroup:
+		n, err = q.DeleteGroupScheduleAssignment(ctx, database.DeleteGroupScheduleAssignmentParams{
+			ScheduleID:     scheduleid,
+			GroupID:        targetid,
+			OrganizationID: orgid,
+		})
+		if err == nil && n > 0 {
+			err = q.SetSchedulePendingByGroupID(ctx, database.SetSchedulePendingByGroupIDParams{
+				GroupID:        targetid,
+				OrganizationID: orgid,
+			})
+		}
+	case schedule.SchedGroupOrganization:
+		n, err = q.DeleteOrganizationScheduleAssignment(ctx, database.DeleteOrganizationScheduleAssignmentParams{
+			ScheduleID:     scheduleid,
+			OrganizationID: orgid,
+		})
+		if err == nil && n > 0 {
+			err = q.SetSchedulePendingByOrgID(ctx, orgid)
+		}
+	default:
+		return false, fmt.Errorf("invalid schedule group type %q", grouptype)
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msg("failed to unassign schedule")
+		return false, err
+	}
+
+	// the schedule was not assigned to the target
+	if n == 0 {
+		return false, nil
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("failed to commit schedule unassignment")
+		return false, err
+	}
+
+	return true, nil
+}
+
+// merge every baseline entry assigned to a node along with the baseline each entry came from
+func combinedNodeBaseline(ctx context.Context, q *database.Queries, nodeid uuid.UUID) (util.BaselineEntryList, []*database.GetCombinedBaselinesByNodeRow, error) {
+	dbbaselines, err := q.GetCombinedBaselinesByNode(ctx, nodeid)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get node baselines")
+		return nil, nil, err
+	}
+
+	// baselines are ordered from the least to the most specific assignment, so a more specific entry replaces any
+	// previous entry for the same package
+	entries := make(util.BaselineEntryList, 0)
+	var origins []*database.GetCombinedBaselinesByNodeRow
+	for i := range dbbaselines {
+		for _, entry := range dbbaselines[i].Entries {
+			if j := entries.Index(entry.Name); j >= 0 {
+				entries[j] = entry
+				origins[j] = &dbbaselines[i]
+				continue
+			}
+			entries = append(entries, entry)
+			origins = append(origins, &dbbaselines[i])
+		}
+	}
+
+	return entries, origins, nil
+}
+
+// find the job which prevents a drifted package from being remediated again: the ID of an active job for the package,
+// or held if a job the baseline queued for the package since it last changed finished without remediating the drift
+func remediationJob(dbjobs []database.GetNodeRemediationJobsRow, name string, origin *database.GetCombinedBaselinesByNodeRow) (jobid *uuid.UUID, held bool) {
+	for i := range dbjobs {
+		if !strings.EqualFold(dbjobs[i].Name, name) {
+			continue
+		}
+		switch dbjobs[i].State {
+		case api.JOB_STATE_QUEUED, api.JOB_STATE_LEASED, api.JOB_STATE_RUNNING:
+			return &dbjobs[i].ID, false
+		default:
+			if dbjobs[i].BaselineID.Valid && uuid.UUID(dbjobs[i].BaselineID.Bytes) == origin.ID &&
+				!dbjobs[i].CreatedAt.Time.Before(origin.UpdatedAt.Time) {
+				held = true
+			}
+		}
+	}
+	return nil, held
+}
+
+// compare the chocolatey packages a node last reported against its combined baseline, queueing a job to remediate
+// each drifted package and storing the node's compliance
+func evaluateNodeBaseline(ctx context.Context, q *database.Queries, nodeid uuid.UUID) error {
+	node, err := q.GetNodeByID(ctx, nodeid)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil
+		}
+		log.Error().Err(err).Msg("failed to get node")
+		return err
+	}
+
+	entries, origins, err := combinedNodeBaseline(ctx, q, nodeid)
+	if err != nil {
+		return err
+	}
+
+	packages, err := getNodePackages(ctx, q, nodeid)
+	if err != nil {
+		return err
+	}
+
+	dbjobs, err := q.GetNodeRemediationJobs(ctx, nodeid)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get node remediation jobs")
+		return err
+	}
+
+	drift := make(api.BaselineDriftList, 0)
+	for i, entry := range entries {
+		d := api.BaselineDrift{
+			BaselineID: origins[i].ID,
+			Name:       entry.Name,
+			Ensure:     entry.Ensure,
+			MinVersion: entry.MinVersion,
+		}
+
+		installed := packages.PackagesChoco.Find(entry.Name)
+		if installed != nil {
+			d.Installed = &installed.Version
+		}
+
+		var action int
+		switch {
+		case entry.Ensure == util.BaselineEnsurePresent && installed == nil:
+			d.Reason, action = api.DRIFT_MISSING, api.JOB_ACTION_INSTALL
+		case entry.Ensure == util.BaselineEnsurePresent && entry.MinVersion != nil &&
+			util.CompareVersions(installed.Version, *entry.MinVersion) < 0:
+			d.Reason, action = api.DRIFT_OUTDATED, api.JOB_ACTION_UPGRADE
+		case entry.Ensure == util.BaselineEnsureAbsent && installed != nil:
+			d.Reason, action = api.DRIFT_UNWANTED, api.JOB_ACTION_UNINSTALL
+		default:
+			continue
+		}
+
+		jobid, held := remediationJob(dbjobs, entry.Name, origins[i])
+		d.JobID = jobid
+
+		// only approved nodes are sent jobs, the drift is remediated once the node is approved and reports again
+		if jobid == nil && !held && node.Approved {
+			job, err := q.CreatePackageJob(ctx, database.CreatePackageJobParams{
+				NodeID:         node.ID,
+				OrganizationID: node.OrganizationID,
+				BaselineID:     pgtype.UUID{Bytes: origins[i].ID, Valid: true},
+				Action:         int32(action),
+				Name:           entry.Name,
+				Timeout:        api.JOB_DEFAULT_TIMEOUT,
+			})
+			if err != nil {
+				log.Error().Err(err).Msg("failed to create remediation package job")
+				return err
+			}
+			d.JobID = &job.ID
+		}
+
+		drift = append(drift, d)
+	}
+
+	err = q.UpdateNodeCompliance(ctx, database.UpdateNodeComplianceParams{
+		NodeID:    nodeid,
+		Compliant: len(drift) == 0,
+		Drift:     drift,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to update node compliance")
+	}
+	return err
+}
+
+// evaluate every node in the list against its baseline
+func evaluateNodeBaselines(ctx context.Context, q *database.Queries, nodeids []uuid.UUID) error {
+	for _, nodeid := range nodeids {
+		if err := evaluateNodeBaseline(ctx, q, nodeid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (core *CorePGX) GetNodeCompliance(ctx context.Context, nodeid uuid.UUID) (*api.NodeCompliance, error) {
+	baseline, _, err := combinedNodeBaseline(ctx, core.q, nodeid)
+	if err != nil {
+		return nil, err
+	}
+
+	// a node which was never evaluated only complies if it has no baseline
+	compliance := api.NodeCompliance{
+		Compliant: len(baseline) == 0,
+		Baseline:  baseline,
+		Drift:     make(api.BaselineDriftList, 0),
+	}
+
+	dbcompliance, err := core.q.GetNodeCompliance(ctx, nodeid)
+	if err != nil {
+		if err != pgx.ErrNoRows {
+			log.Error().Err(err).Msg("failed to get node compliance")
+			return nil, err
+		}
+	} else {
+		compliance.Compliant = dbcompliance.Compliant
+		if dbcompliance.Drift != nil {
+			compliance.Drift = dbcompliance.Drift
+		}
+		if dbcompliance.EvaluatedAt.Valid {
+			compliance.EvaluatedAt = &dbcompliance.EvaluatedAt.Time
+		}
+	}
+
+	return &compliance, nil
+}
+
+func (core *CorePGX) GetBaselines(ctx context.Context, orgid uuid.UUID, paging *api.Pagination) ([]*api.Baseline, error) {
+	dbbaselines, err := core.q.GetBaselinesByOrgID(ctx, database.GetBaselinesByOrgIDParams{
+		OrganizationID: orgid,
+		Sort:           paging.Sort,
+		PageLimit:      int32(paging.Limit),
+		PageOffset:     int32(paging.Offset),
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to query db GetBaselinesByOrgID")
+		return nil, err
+	}
+
+	baselines := make([]*api.Baseline, len(dbbaselines))
+	for i := range dbbaselines {
+		baselines[i] = pgxBaselineToCoreBaseline(&dbbaselines[i])
+	}
+	return baselines, nil
+}
+
+func (core *CorePGX) GetBaseline(ctx context.Context, baselineid, orgid uuid.UUID) (*api.Baseline, error) {
+	dbbaseline, err := core.q.GetBaseline(ctx, database.GetBaselineParams{
+		ID:             baselineid,
+		OrganizationID: orgid,
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Msg("failed to get baseline")
+		return nil, err
+	}
+	return pgxBaselineToCoreBaseline(&dbbaseline), nil
+}
+
+func (core *CorePGX) CreateBaseline(ctx context.Context, orgid uuid.UUID, req *api.BaselineRequest) (*api.Baseline, error) {
+	// a new baseline has no assignments, so no nodes need to be evaluated
+	dbbaseline, err := core.q.CreateBaseline(ctx, database.CreateBaselineParams{
+		OrganizationID: orgid,
+		Name:           req.Name,
+		Entries:        req.Entries,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to create baseline")
+		return nil, err
+	}
+	return pgxBaselineToCoreBaseline(&dbbaseline), nil
+}
+
+func (core *CorePGX) UpdateBaseline(ctx context.Context, baselineid, orgid uuid.UUID, req *api.BaselineRequest) (*api.Baseline, error) {
+	tx, err := core.pool.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	q := core.q.WithTx(tx)
+
+	dbbaseline, err := q.UpdateBaseline(ctx, database.UpdateBaselineParams{
+		ID:             baselineid,
+		OrganizationID: orgid,
+		Name:           req.Name,
+		Entries:        req.Entries,
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Msg("failed to update baseline")
+		return nil, err
+	}
+
+	// every node inheriting the baseline is evaluated against its new entries
+	nodeids, err := q.GetBaselineNodeIDs(ctx, database.GetBaselineNodeIDsParams{
+		OrganizationID: orgid,
+		BaselineID:     baselineid,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get baseline nodes")
+		return nil, err
+	}
+
+	if err := evaluateNodeBaselines(ctx, q, nodeids); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("failed to commit baseline update")
+		return nil, err
+	}
+
+	return pgxBaselineToCoreBaseline(&dbbaseline), nil
+}
+
+func (core *CorePGX) DeleteBaseline(ctx context.Context, baselineid, orgid uuid.UUID) (bool, error) {
+	tx, err := core.pool.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	q := core.q.WithTx(tx)
+
+	// the affected nodes can only be found while the assignments still exist
+	nodeids, err := q.GetBaselineNodeIDs(ctx, database.GetBaselineNodeIDsParams{
+		OrganizationID: orgid,
+		BaselineID:     baselineid,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get baseline nodes")
+		return false, err
+	}
+
+	if err := q.DeleteBaselineAssignments(ctx, baselineid); err != nil {
+		log.Error().Err(err).Msg("failed to delete baseline assignments")
+		return false, err
+	}
+
+	n, err := q.DeleteBaseline(ctx, database.DeleteBaselineParams{
+		ID:             baselineid,
+		OrganizationID: orgid,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to delete baseline")
+		return false, err
+	}
+
+	// the baseline is not in the organization, roll back the assignment removal
+	if n == 0 {
+		return false, nil
+	}
+
+	if err := evaluateNodeBaselines(ctx, q, nodeids); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("failed to commit baseline deletion")
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (core *CorePGX) AssignBaseline(ctx context.Context, baselineid, orgid uuid.UUID, grouptype schedule.ScheduleGroupType, targetid uuid.UUID) (bool, error) {
+	tx, err := core.pool.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	q := core.q.WithTx(tx)
+
+	// the baseline must exist within the organization
+	_, err = q.GetBaseline(ctx, database.GetBaselineParams{
+		ID:             baselineid,
+		OrganizationID: orgid,
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return false, nil
+		}
+		log.Error().Err(err).Msg("failed to get baseline")
+		return false, err
+	}
+
+	switch grouptype {
+	case schedule.SchedGroupNode:
+		// the node must exist within the organization
+		var node database.Node
+		node, err = q.GetNodeByID(ctx, targetid)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				return false, nil
+			}
+			log.Error().Err(err).Msg("failed to get node")
+			return false, err
+		}
+		if node.OrganizationID != orgid {
+			return false, nil
+		}
+
+		err = q.CreateNodeBaselineAssignment(ctx, database.CreateNodeBaselineAssignmentParams{
+			BaselineID:     baselineid,
+			NodeID:         targetid,
+			OrganizationID: orgid,
+		})
+	case schedule.SchedGroupGroup:
+		// the group must exist within the organization
+		_, err = q.GetGroupByID(ctx, database.GetGroupByIDParams{
+			ID:             targetid,
+			OrganizationID: orgid,
+		})
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				return false, nil
+			}
+			log.Error().Err(err).Msg("failed to get group")
+			return false, err
+		}
+
+		err = q.CreateGroupBaselineAssignment(ctx, database.CreateGroupBaselineAssignmentParams{
+			BaselineID:     baselineid,
+			GroupID:        targetid,
+			OrganizationID: orgid,
+		})
+	case schedule.SchedGroupOrganization:
+		err = q.CreateOrganizationBaselineAssignment(ctx, database.CreateOrganizationBaselineAssignmentParams{
+			BaselineID:     baselineid,
+			OrganizationID: orgid,
+		})
+	default:
+		return false, fmt.Errorf("invalid schedule group type %q", grouptype)
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msg("failed to assign baseline")
+		return false, err
+	}
+
+	// the nodes which now inherit the baseline are evaluated against it
+	nodeids, err := q.GetBaselineNodeIDs(ctx, database.GetBaselineNodeIDsParams{
+		OrganizationID: orgid,
+		BaselineID:     baselineid,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get baseline nodes")
+		return false, err
+	}
+
+	if err := evaluateNodeBaselines(ctx, q, nodeids); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("failed to commit baseline assignment")
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (core *CorePGX) UnassignBaseline(ctx context.Context, baselineid, orgid uuid.UUID, grouptype schedule.ScheduleGroupType, targetid uuid.UUID) (bool, error) {
+	tx, err := core.pool.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	q := core.q.WithTx(tx)
+
+	// the affected nodes can only be found while the assignment still exists
+	nodeids, err := q.GetBaselineNodeIDs(ctx, database.GetBaselineNodeIDsParams{
+		OrganizationID: orgid,
+		BaselineID:     baselineid,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get baseline nodes")
+		return false, err
+	}
+
+	var n int64
+	switch grouptype {
+	case schedule.SchedGroupNode:
+		n, err = q.DeleteNodeBaselineAssignment(ctx, database.DeleteNodeBaselineAssignmentParams{
+			BaselineID:     baselineid,
+			NodeID:         targetid,
+			OrganizationID: orgid,
+		})
+	case schedule.SchedGroupGroup:
+		n, err = q.DeleteGroupBaselineAssignment(ctx, database.DeleteGroupBaselineAssignmentParams{
+			BaselineID:     baselineid,
+			GroupID:        targetid,
+			OrganizationID: orgid,
+		})
+	case schedule.SchedGroupOrganization:
+		n, err = q.DeleteOrganizationBaselineAssignment(ctx, database.DeleteOrganizationBaselineAssignmentParams{
+			BaselineID:     baselineid,
+			OrganizationID: orgid,
+		})
+	default:
+		return false, fmt.Errorf("invalid schedule group type %q", grouptype)
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msg("failed to unassign baseline")
+		return false, err
+	}
+
+	// the baseline was not assigned to the target
+	if n == 0 {
+		return false, nil
+	}
+
+	if err := evaluateNodeBaselines(ctx, q, nodeids); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("failed to commit baseline unassignment")
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (core *CorePGX) CreateNode(ctx context.Context, req api.RegistrationRequest) (*api.Node, error) {
+	var params database.CreateNodeParams
+
+	pubkey, err := util.Base64toPubKey(req.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := core.pool.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	q := core.q.WithTx(tx)
+
+	// consume a use of the token, it may have expired or reached its limit since it was checked
+	if _, err := q.UseRegistrationToken(ctx, req.Token); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Msg("failed to use registration token")
+		return nil, err
+	}
+
+	// get the fingerprint of the public key
+	params.ID = crypto.Fingerprint(pubkey)
+	params.ID_2 = req.Token
+
+	// Client information
+	params.PublicKey = req.PublicKey
+	params.Hostname = req.Hostname
+	params.ClientVersion = req.ClientVersion
+
+	// OS Info
+	params.OsName = req.OSName
+	params.OsKernel = req.OSKernel
+	params.OsMajor = int32(req.OSMajor)
+	params.OsMinor = int32(req.OSMinor)
+	params.OsBuild = int32(req.OSBuild)
+
+	node, err := q.CreateNode(ctx, params)
+	log.Info().Msg("Creating Node:")
+	if err != nil {
+		log.Error().Err(err).Msg("failed to create node")
+		return nil, err
+	}
+
+	// Package info, the initial inventory is not recorded as changes since later changes are compared against it
+	_, err = replaceNodePackages(ctx, q, &node, &api.Packages{
+		PackagesChoco:    req.PackagesChoco,
+		PackagesSystem:   req.PackagesSystem,
+		PackagesOutdated: req.PackagesOutdated,
+	}, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("failed to commit transaction")
+		return nil, err
+	}
+
+	// no errors, node was created
+	return pgxNodeToCoreNode(&node), nil
+}
+
+func testPool(ctx context.Context, pool *pgxpool.Pool) error {
+	var target = rand.Intn(10000)
+	var result int
+
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	err = conn.QueryRow(ctx, fmt.Sprintf("SELECT %d", target)).Scan(&result)
+	if err != nil {
+		return err
+	}
+
+	if result != target {
+		return fmt.Errorf("unexpected value from test query")
+	}
+
+	return nil
+}
+
+func NewCorePGX(ctx context.Context, connStr string, secrets crypto.Cipher) (*CorePGX, error) {
+	log.Info().Str("connstr", connStr).Msg("Setting Database Connection Parameters")
+	cfg, err := pgxpool.ParseConfig(connStr)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// test the pool to determine if it is valid
+	if err := testPool(ctx, pool); err != nil {
+		return nil, err
+	}
+
+	return &CorePGX{
+		pool:    pool,
+		q:       database.New(pool),
+		secrets: secrets,
+	}, nil
+}
+
+func (core *CorePGX) GetPackageJobList(ctx context.Context, nodeid uuid.UUID, attemptsMax int, overdue bool) (api.PackageJobList, error) {
+	joblist, err := core.q.GetPackageJobListByNodeID(ctx, database.GetPackageJobListByNodeIDParams{
+		NodeID:   nodeid,
+		Attempts: int32(attemptsMax),
+		Overdue:  overdue,
+	})
+
+	if err != nil {
+		log.Error().Err(err).Msg("failed to query db GetPackageJobListByNodeID")
+		return nil, err
+	}
+
+	if joblist == nil {
+		joblist = []uuid.UUID{}
+	}
+
+	return api.PackageJobList(joblist), nil
+}
+
+func (core *CorePGX) GetPackageJob(ctx context.Context, jobid uuid.UUID) (*api.PackageJob, error) {
+	job, err := core.q.GetPackageJobByID(ctx, jobid)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+
+		log.Error().Err(err).Msg("failed to get package job by ID")
+		return nil, err
+	}
+
+	return pgxPackageJobToCorePackageJob(&job), nil
+}
+
+func (core *CorePGX) LeasePackageJob(ctx context.Context, jobid, nodeid uuid.UUID, attemptsMax int) (*api.PackageJob, error) {
+	tx, err := core.pool.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	q := core.q.WithTx(tx)
+
+	job, err := q.LeasePackageJob(ctx, database.LeasePackageJobParams{
+		LeaseSeconds: PACKAGE_JOB_LEASE_SECONDS,
+		ID:           jobid,
+		NodeID:       nodeid,
+		Attempts:     int32(attemptsMax),
+	})
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Msg("failed to lease package job")
+		return nil, err
+	}
+
+	// every lease is recorded in the job's attempt history
+	err = q.CreatePackageJobAttempt(ctx, database.CreatePackageJobAttemptParams{
+		JobID:   jobid,
+		Attempt: job.Attempts,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to create package job attempt")
+		return nil, err
+	}
+
+	apijob := pgxPackageJobToCorePackageJob(&job)
+
+	// secret package parameters are only ever delivered sealed to the node's key
+	secret, err := q.GetPackageJobSecret(ctx, jobid)
+	if err == nil {
+		pubkey, err := core.nodePublicKey(ctx, nodeid)
+		if err != nil {
+			return nil, err
+		}
+
+		sealed, err := core.sealSecret(pubkey, secret.PackageParameters)
+		if err != nil {
+			return nil, err
+		}
+		apijob.Parameters.PackageParameters = &sealed
+	} else if err != pgx.ErrNoRows {
+		log.Error().Err(err).Msg("failed to get package job secret")
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("failed to commit package job lease")
+		return nil, err
+	}
+
+	return apijob, nil
+}
+
+func (core *CorePGX) GetPackageJobAttempts(ctx context.Context, jobid uuid.UUID) ([]*api.PackageJobAttempt, error) {
+	attempts, err := core.q.GetPackageJobAttempts(ctx, jobid)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get package job attempts")
+		return nil, err
+	}
+
+	apiattempts := make([]*api.PackageJobAttempt, len(attempts))
+	for i := range attempts {
+		apiattempts[i] = pgxPackageJobAttemptToCorePackageJobAttempt(&attempts[i])
+	}
+
+	return apiattempts, nil
+}
+
+func (core *CorePGX) CompletePackageJob(ctx context.Context, jobid, nodeid uuid.UUID, result *api.PackageJobResult) error {
+	dberr := pgtype.Text{}
 
 	if result.Error != nil {
 		dberr.Valid = true
 		dberr.String = *result.Error
 	}
 
-	_, err := core.q.CompletePackageJob(ctx, database.CompletePackageJobParams{
+	exitCode := pgtype.Int4{
+		Int32: int32(result.ExitCode),
+		Valid: true,
+	}
+
+	output := pgtype.Text{
+		String: result.Output,
+		Valid:  true,
+	}
+
+	tx, err := core.pool.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	q := core.q.WithTx(tx)
+
+	// the job is locked while its retry policy decides whether a failed attempt is retried
+	leased, err := q.GetLeasedPackageJobRetryPolicy(ctx, database.GetLeasedPackageJobRetryPolicyParams{
 		ID:     jobid,
 		NodeID: nodeid,
-		Status: int32(result.Status),
-		ExitCode: pgtype.Int4{
-			Int32: int32(result.ExitCode),
-			Valid: true,
-		},
-		Output: pgtype.Text{
-			String: result.Output,
-			Valid:  true,
-		},
-		Error: dberr,
 	})
-	return err
+	if err != nil {
+		return err
+	}
+
+	policy := api.DefaultRetryPolicy()
+	if leased.RetryPolicy != nil {
+		if err := json.Unmarshal(leased.RetryPolicy, policy); err != nil {
+			log.Error().Err(err).Msg("failed to parse package job retry policy")
+			return err
+		}
+	}
+
+	state := api.JobResultState(result.Status)
+
+	decision := pgtype.Text{}
+	if state == api.JOB_STATE_FAILED {
+		decision.Valid = true
+		switch {
+		case choco.StatusPermanent(choco.ChocoStatus(result.Status)):
+			decision.String = api.RETRY_DECISION_PERMANENT
+		case int(leased.Attempts) >= policy.MaxAttempts:
+			decision.String = api.RETRY_DECISION_EXHAUSTED
+		default:
+			decision.String = api.RETRY_DECISION_RETRY
+		}
+	}
+
+	var job database.PackageJob
+	retryAt := pgtype.Timestamp{}
+
+	if decision.String == api.RETRY_DECISION_RETRY {
+		// transient failures are requeued and back off exponentially with every attempt
+		job, err = q.RetryPackageJob(ctx, database.RetryPackageJobParams{
+			ID:             jobid,
+			NodeID:         nodeid,
+			ExitCode:       exitCode,
+			Output:         output,
+			Error:          dberr,
+			BackoffSeconds: int32(policy.Backoff(int(leased.Attempts))),
+		})
+		retryAt = job.RetryAt
+	} else {
+		job, err = q.CompletePackageJob(ctx, database.CompletePackageJobParams{
+			ID:       jobid,
+			NodeID:   nodeid,
+			State:    state,
+			Status:   int32(result.Status),
+			ExitCode: exitCode,
+			Output:   output,
+			Error:    dberr,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	outcome := api.ATTEMPT_OUTCOME_FAILED
+	if state == api.JOB_STATE_SUCCEEDED {
+		outcome = api.ATTEMPT_OUTCOME_SUCCEEDED
+	}
+
+	err = q.FinishPackageJobAttempt(ctx, database.FinishPackageJobAttemptParams{
+		JobID:    jobid,
+		Attempt:  job.Attempts,
+		Outcome:  pgtype.Text{String: outcome, Valid: true},
+		Status:   pgtype.Int4{Int32: int32(result.Status), Valid: true},
+		ExitCode: job.ExitCode,
+		Output:   job.Output,
+		Error:    job.Error,
+		Decision: decision,
+		RetryAt:  retryAt,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to finish package job attempt")
+		return err
+	}
+
+	// a retried job still needs its secrets for the next attempt
+	if job.State != api.JOB_STATE_QUEUED {
+		if err := q.DeletePackageJobSecret(ctx, jobid); err != nil {
+			log.Error().Err(err).Msg("failed to delete package job secret")
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (core *CorePGX) AppendPackageJobOutput(ctx context.Context, jobid, nodeid uuid.UUID, output string) (bool, error) {
+	tx, err := core.pool.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	q := core.q.WithTx(tx)
+
+	attempt, err := q.AppendPackageJobOutput(ctx, database.AppendPackageJobOutputParams{
+		LeaseSeconds: PACKAGE_JOB_LEASE_SECONDS,
+		Output:       output,
+		ID:           jobid,
+		NodeID:       nodeid,
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			// the job is not leased to the node
+			return false, nil
+		}
+		log.Error().Err(err).Msg("failed to append package job output")
+		return false, err
+	}
+
+	// the first heartbeat marks when the attempt started running
+	err = q.StartPackageJobAttempt(ctx, database.StartPackageJobAttemptParams{
+		JobID:   jobid,
+		Attempt: attempt,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to start package job attempt")
+		return false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (core *CorePGX) ReclaimPackageJobLeases(ctx context.Context, attemptsMax int) (int, error) {
+	tx, err := core.pool.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	q := core.q.WithTx(tx)
+
+	reclaimed, err := q.ReclaimPackageJobLeases(ctx, database.ReclaimPackageJobLeasesParams{
+		AttemptsMax: int32(attemptsMax),
+		Status:      api.JOB_STATUS_FAILED,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to reclaim package job leases")
+		return 0, err
+	}
+
+	var failed []uuid.UUID
+	for _, job := range reclaimed {
+		// a node which stopped heartbeating is not backed off, the job is retried as soon as the node returns
+		decision := api.RETRY_DECISION_RETRY
+		if job.State == api.JOB_STATE_FAILED {
+			decision = api.RETRY_DECISION_EXHAUSTED
+		}
+
+		err := q.FinishPackageJobAttempt(ctx, database.FinishPackageJobAttemptParams{
+			JobID:    job.ID,
+			Attempt:  job.Attempts,
+			Outcome:  pgtype.Text{String: api.ATTEMPT_OUTCOME_LEASE_EXPIRED, Valid: true},
+			Output:   job.Output,
+			Decision: pgtype.Text{String: decision, Valid: true},
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("failed to finish package job attempt")
+			return 0, err
+		}
+
+		if job.State == api.JOB_STATE_FAILED {
+			failed = append(failed, job.ID)
+		}
+	}
+
+	// jobs which ran out of attempts will never be run, so their secrets are no longer needed
+	if len(failed) > 0 {
+		if err := q.DeletePackageJobSecrets(ctx, failed); err != nil {
+			log.Error().Err(err).Msg("failed to delete failed package job secrets")
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	return len(reclaimed), nil
+}
+
+func (core *CorePGX) ExpirePackageJobs(ctx context.Context) (int, error) {
+	tx, err := core.pool.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	q := core.q.WithTx(tx)
+
+	jobids, err := q.ExpirePackageJobs(ctx, api.JOB_STATUS_EXPIRED)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to expire package jobs")
+		return 0, err
+	}
+
+	if len(jobids) == 0 {
+		return 0, nil
+	}
+
+	// expired jobs will never be run, so their secrets are no longer needed
+	if err := q.DeletePackageJobSecrets(ctx, jobids); err != nil {
+		log.Error().Err(err).Msg("failed to delete expired package job secrets")
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	return len(jobids), nil
+}
+
+func (core *CorePGX) CreatePackageJob(ctx context.Context, orgid uuid.UUID, req *api.PackageJobRequest) (*api.PackageJob, error) {
+	params := database.CreatePackageJobParams{
+		NodeID:           req.NodeID,
+		OrganizationID:   orgid,
+		Action:           int32(req.Action),
+		Name:             req.Parameters.Name,
+		Timeout:          int32(req.Parameters.Timeout),
+		IgnoreChecksum:   req.Parameters.IgnoreChecksum,
+		InstallOnUpgrade: req.Parameters.InstallOnUpgrade,
+		Force:            req.Parameters.Force,
+		VerboseOutput:    req.Parameters.VerboseOutput,
+		NotSilent:        req.Parameters.NotSilent,
+		SkipPowershell:   req.Parameters.SkipPowershell,
+	}
+
+	if req.Parameters.Version != nil {
+		params.Version = pgtype.Text{String: *req.Parameters.Version, Valid: true}
+	}
+
+	if req.ExpiresAt != nil {
+		params.ExpiresAt = pgtype.Timestamp{Time: req.ExpiresAt.UTC(), Valid: true}
+	}
+	if req.NotBefore != nil {
+		params.NotBefore = pgtype.Timestamp{Time: req.NotBefore.UTC(), Valid: true}
+	}
+	if req.Deadline != nil {
+		params.Deadline = pgtype.Timestamp{Time: req.Deadline.UTC(), Valid: true}
+	}
+	params.RetryPolicy = req.RetryPolicy
+
+	secret, err := core.encryptSecret(req.Parameters.PackageParameters)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := core.pool.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	q := core.q.WithTx(tx)
+
+	job, err := q.CreatePackageJob(ctx, params)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			// the node does not exist within this organization
+			return nil, nil
+		}
+		log.Error().Err(err).Msg("failed to create package job")
+		return nil, err
+	}
+
+	if secret != nil {
+		err = q.CreatePackageJobSecret(ctx, database.CreatePackageJobSecretParams{
+			JobID:             job.ID,
+			PackageParameters: secret,
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("failed to create package job secret")
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("failed to commit package job")
+		return nil, err
+	}
+
+	return pgxPackageJobToCorePackageJob(&job), nil
+}
+
+func (core *CorePGX) GetPackageJobs(ctx context.Context, orgid uuid.UUID, nodeid *uuid.UUID, paging *api.Pagination) ([]*api.PackageJob, error) {
+	params := database.GetPackageJobsByOrgIDParams{
+		OrganizationID: orgid,
+		Sort:           paging.Sort,
+		PageLimit:      int32(paging.Limit),
+		PageOffset:     int32(paging.Offset),
+	}
+
+	if nodeid != nil {
+		params.NodeID = pgtype.UUID{Bytes: *nodeid, Valid: true}
+	}
+
+	jobs, err := core.q.GetPackageJobsByOrgID(ctx, params)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to query db GetPackageJobsByOrgID")
+		return nil, err
+	}
+
+	return pgxPackageJobsToCorePackageJobsPtr(jobs), nil
+}
+
+func (core *CorePGX) CancelPackageJob(ctx context.Context, jobid, orgid uuid.UUID) (*api.PackageJob, error) {
+	tx, err := core.pool.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	q := core.q.WithTx(tx)
+
+	job, err := q.CancelPackageJob(ctx, database.CancelPackageJobParams{
+		ID:             jobid,
+		OrganizationID: orgid,
+		Status:         api.JOB_STATUS_CANCELLED,
+	})
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			// the job does not exist or is no longer pending
+			return nil, nil
+		}
+		log.Error().Err(err).Msg("failed to cancel package job")
+		return nil, err
+	}
+
+	// finish the attempt if the job was cancelled while leased or running
+	if job.Attempts > 0 {
+		err = q.FinishPackageJobAttempt(ctx, database.FinishPackageJobAttemptParams{
+			JobID:   jobid,
+			Attempt: job.Attempts,
+			Outcome: pgtype.Text{String: api.ATTEMPT_OUTCOME_CANCELLED, Valid: true},
+			Output:  job.Output,
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("failed to finish package job attempt")
+			return nil, err
+		}
+	}
+
+	if err := q.DeletePackageJobSecret(ctx, jobid); err != nil {
+		log.Error().Err(err).Msg("failed to delete package job secret")
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("failed to commit cancelled package job")
+		return nil, err
+	}
+
+	return pgxPackageJobToCorePackageJob(&job), nil
+}
+
+func (core *CorePGX) CreateGroupPackageJobs(ctx context.Context, orgid, groupid uuid.UUID, req *api.PackageJobGroupRequest) ([]*api.PackageJob, error) {
+	secret, err := core.encryptSecret(req.Parameters.PackageParameters)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := core.pool.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin transaction")
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	q := core.q.WithTx(tx)
+
+	// the group must exist within the organization
+	_, err = q.GetGroupByID(ctx, database.GetGroupByIDParams{
+		ID:             groupid,
+		OrganizationID: orgid,
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Msg("failed to get group")
+		return nil, err
+	}
+
+	params := database.CreateGroupPackageJobsParams{
+		BatchID:          uuid.New(), // the jobs of this fan-out are rolled up together
+		GroupID:          groupid,
+		OrganizationID:   orgid,
+		Action:           int32(req.Action),
+		Name:             req.Parameters.Name,
+		Timeout:          int32(req.Parameters.Timeout),
+		IgnoreChecksum:   req.Parameters.IgnoreChecksum,
+		InstallOnUpgrade: req.Parameters.InstallOnUpgrade,
+		Force:            req.Parameters.Force,
+		VerboseOutput:    req.Parameters.VerboseOutput,
+		NotSilent:        req.Parameters.NotSilent,
+		SkipPowershell:   req.Parameters.SkipPowershell,
+	}
+
+	if req.Parameters.Version != nil {
+		params.Version = pgtype.Text{String: *req.Parameters.Version, Valid: true}
+	}
+
+	if req.ExpiresAt != nil {
+		params.ExpiresAt = pgtype.Timestamp{Time: req.ExpiresAt.UTC(), Valid: true}
+	}
+	if req.NotBefore != nil {
+		params.NotBefore = pgtype.Timestamp{Time: req.NotBefore.UTC(), Valid: true}
+	}
+	if req.Deadline != nil {
+		params.Deadline = pgtype.Timestamp{Time: req.Deadline.UTC(), Valid: true}
+	}
+	params.RetryPolicy = req.RetryPolicy
+
+	// one job is created for every member of the group
+	jobs, err := q.CreateGroupPackageJobs(ctx, params)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to create group package jobs")
+		return nil, err
+	}
+
+	if secret != nil {
+		for _, job := range jobs {
+			err = q.CreatePackageJobSecret(ctx, database.CreatePackageJobSecretParams{
+				JobID:             job.ID,
+				PackageParameters: secret,
+			})
+			if err != nil {
+				log.Error().Err(err).Msg("failed to create package job secret")
+				return nil, err
+			}
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("failed to commit group package jobs")
+		return nil, err
+	}
+
+	return pgxPackageJobsToCorePackageJobsPtr(jobs), nil
+}
+
+func (core *CorePGX) GetGroupPackageJobRollups(ctx context.Context, orgid, groupid uuid.UUID, paging *api.Pagination) ([]*api.PackageJobRollup, error) {
+	dbrollups, err := core.q.GetGroupPackageJobRollups(ctx, database.GetGroupPackageJobRollupsParams{
+		GroupID:        pgtype.UUID{Bytes: groupid, Valid: true},
+		OrganizationID: orgid,
+		Sort:           paging.Sort,
+		PageLimit:      int32(paging.Limit),
+		PageOffset:     int32(paging.Offset),
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to query db GetGroupPackageJobRollups")
+		return nil, err
+	}
+
+	rollups := make([]*api.PackageJobRollup, len(dbrollups))
+	for i := range dbrollups {
+		rollups[i] = pgxPackageJobRollupToCorePackageJobRollup(groupid, &dbrollups[i])
+	}
+
+	return rollups, nil
+}
+
+func (core *CorePGX) GetGroupUpgradePolicy(ctx context.Context, orgid, groupid uuid.UUID) (*api.UpgradePolicy, error) {
+	policy, err := core.q.GetGroupUpgradePolicy(ctx, database.GetGroupUpgradePolicyParams{
+		GroupID:        groupid,
+		OrganizationID: orgid,
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		log.Error().Err(err).Msg("failed to get group upgrade policy")
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (core *CorePGX) SetGroupUpgradePolicy(ctx context.Context, orgid, groupid uuid.UUID, policy *api.UpgradePolicy) (bool, error) {
+	count, err := core.q.SetGroupUpgradePolicy(ctx, database.SetGroupUpgradePolicyParams{
+		UpgradePolicy:  *policy,
+		GroupID:        groupid,
+		OrganizationID: orgid,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to set group upgrade policy")
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (core *CorePGX) DeleteGroupUpgradePolicy(ctx context.Context, orgid, groupid uuid.UUID) (bool, error) {
+	count, err := core.q.DeleteGroupUpgradePolicy(ctx, database.DeleteGroupUpgradePolicyParams{
+		GroupID:        groupid,
+		OrganizationID: orgid,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to delete group upgrade policy")
+		return false, err
+	}
+	return count > 0, nil
 }
@@ -8,34 +8,72 @@ package database
 import (
 	"context"
 
+	"github.com/goodieshq/sweettooth/pkg/api"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
-const attemptPackageJob = `-- name: AttemptPackageJob :one
+const appendPackageJobOutput = `-- name: AppendPackageJobOutput :one
 UPDATE
     package_jobs
 SET
-    attempts = attempts + 1
+    state = 'running',
+    lease_expires_at = CURRENT_TIMESTAMP + make_interval(secs => $1::int),
+    output = COALESCE(output, '') || $2::text
 WHERE
-    id = $1 AND node_id=$2 AND attempts < $3
-RETURNING id, node_id, group_id, organization_id, attempts, action, name, version, ignore_checksum, install_on_upgrade, force, verbose_output, not_silent, timeout, status, exit_code, output, error, attempted_at, completed_at, expires_at, created_at
+    id=$3 AND node_id=$4 AND state IN ('leased', 'running')
+RETURNING attempts
 `
 
-type AttemptPackageJobParams struct {
-	ID       uuid.UUID `db:"id" json:"id"`
-	NodeID   uuid.UUID `db:"node_id" json:"node_id"`
-	Attempts int32     `db:"attempts" json:"attempts"`
+type AppendPackageJobOutputParams struct {
+	LeaseSeconds int32     `db:"lease_seconds" json:"lease_seconds"`
+	Output       string    `db:"output" json:"output"`
+	ID           uuid.UUID `db:"id" json:"id"`
+	NodeID       uuid.UUID `db:"node_id" json:"node_id"`
+}
+
+// output is streamed by the node while the job runs and doubles as the heartbeat which extends its lease
+func (q *Queries) AppendPackageJobOutput(ctx context.Context, arg AppendPackageJobOutputParams) (int32, error) {
+	row := q.db.QueryRow(ctx, appendPackageJobOutput,
+		arg.LeaseSeconds,
+		arg.Output,
+		arg.ID,
+		arg.NodeID,
+	)
+	var attempts int32
+	err := row.Scan(&attempts)
+	return attempts, err
+}
+
+const cancelPackageJob = `-- name: CancelPackageJob :one
+UPDATE
+    package_jobs
+SET
+    state='cancelled',
+    status=$3,
+    lease_expires_at=NULL,
+    completed_at=CURRENT_TIMESTAMP
+WHERE
+    id=$1 AND organization_id=$2 AND state IN ('queued', 'leased', 'running')
+RETURNING id, node_id, group_id, baseline_id, organization_id, state, attempts, action, name, version, ignore_checksum, install_on_upgrade, force, verbose_output, not_silent, skip_powershell, timeout, retry_policy, status, exit_code, output, error, attempted_at, lease_expires_at, completed_at, expires_at, not_before, deadline, retry_at, created_at, batch_id
+`
+
+type CancelPackageJobParams struct {
+	ID             uuid.UUID `db:"id" json:"id"`
+	OrganizationID uuid.UUID `db:"organization_id" json:"organization_id"`
+	Status         int32     `db:"status" json:"status"`
 }
 
-func (q *Queries) AttemptPackageJob(ctx context.Context, arg AttemptPackageJobParams) (PackageJob, error) {
-	row := q.db.QueryRow(ctx, attemptPackageJob, arg.ID, arg.NodeID, arg.Attempts)
+func (q *Queries) CancelPackageJob(ctx context.Context, arg CancelPackageJobParams) (PackageJob, error) {
+	row := q.db.QueryRow(ctx, cancelPackageJob, arg.ID, arg.OrganizationID, arg.Status)
 	var i PackageJob
 	err := row.Scan(
 		&i.ID,
 		&i.NodeID,
 		&i.GroupID,
+		&i.BaselineID,
 		&i.OrganizationID,
+		&i.State,
 		&i.Attempts,
 		&i.Action,
 		&i.Name,
@@ -45,15 +83,22 @@ func (q *Queries) AttemptPackageJob(ctx context.Context, arg AttemptPackageJobPa
 		&i.Force,
 		&i.VerboseOutput,
 		&i.NotSilent,
+		&i.SkipPowershell,
 		&i.Timeout,
+		&i.RetryPolicy,
 		&i.Status,
 		&i.ExitCode,
 		&i.Output,
 		&i.Error,
 		&i.AttemptedAt,
+		&i.LeaseExpiresAt,
 		&i.CompletedAt,
 		&i.ExpiresAt,
+		&i.NotBefore,
+		&i.Deadline,
+		&i.RetryAt,
 		&i.CreatedAt,
+		&i.BatchID,
 	)
 	return i, err
 }
@@ -62,19 +107,22 @@ const completePackageJob = `-- name: CompletePackageJob :one
 UPDATE
     package_jobs
 SET
-    status=$3,
-    exit_code=$4,
-    output=$5,
-    error=$6,
+    state=$3,
+    status=$4,
+    exit_code=$5,
+    output=$6,
+    error=$7,
+    lease_expires_at=NULL,
     completed_at=CURRENT_TIMESTAMP
 WHERE
-    id=$1 AND status=0 AND node_id=$2
-RETURNING id, node_id, group_id, organization_id, attempts, action, name, version, ignore_checksum, install_on_upgrade, force, verbose_output, not_silent, timeout, status, exit_code, output, error, attempted_at, completed_at, expires_at, created_at
+    id=$1 AND state IN ('leased', 'running') AND node_id=$2
+RETURNING id, node_id, group_id, baseline_id, organization_id, state, attempts, action, name, version, ignore_checksum, install_on_upgrade, force, verbose_output, not_silent, skip_powershell, timeout, retry_policy, status, exit_code, output, error, attempted_at, lease_expires_at, completed_at, expires_at, not_before, deadline, retry_at, created_at, batch_id
 `
 
 type CompletePackageJobParams struct {
 	ID       uuid.UUID   `db:"id" json:"id"`
 	NodeID   uuid.UUID   `db:"node_id" json:"node_id"`
+	State    string      `db:"state" json:"state"`
 	Status   int32       `db:"status" json:"status"`
 	ExitCode pgtype.Int4 `db:"exit_code" json:"exit_code"`
 	Output   pgtype.Text `db:"output" json:"output"`
@@ -85,6 +133,7 @@ func (q *Queries) CompletePackageJob(ctx context.Context, arg CompletePackageJob
 	row := q.db.QueryRow(ctx, completePackageJob,
 		arg.ID,
 		arg.NodeID,
+		arg.State,
 		arg.Status,
 		arg.ExitCode,
 		arg.Output,
@@ -95,7 +144,9 @@ func (q *Queries) CompletePackageJob(ctx context.Context, arg CompletePackageJob
 		&i.ID,
 		&i.NodeID,
 		&i.GroupID,
+		&i.BaselineID,
 		&i.OrganizationID,
+		&i.State,
 		&i.Attempts,
 		&i.Action,
 		&i.Name,
@@ -105,20 +156,27 @@ func (q *Queries) CompletePackageJob(ctx context.Context, arg CompletePackageJob
 		&i.Force,
 		&i.VerboseOutput,
 		&i.NotSilent,
+		&i.SkipPowershell,
 		&i.Timeout,
+		&i.RetryPolicy,
 		&i.Status,
 		&i.ExitCode,
 		&i.Output,
 		&i.Error,
 		&i.AttemptedAt,
+		&i.LeaseExpiresAt,
 		&i.CompletedAt,
 		&i.ExpiresAt,
+		&i.NotBefore,
+		&i.Deadline,
+		&i.RetryAt,
 		&i.CreatedAt,
+		&i.BatchID,
 	)
 	return i, err
 }
 
-const createPackageJob = `-- name: CreatePackageJob :one
+const createGroupPackageJobs = `-- name: CreateGroupPackageJobs :many
 INSERT INTO
     package_jobs(
         node_id,
@@ -127,57 +185,233 @@ INSERT INTO
         action,
         name,
         version,
+        timeout,
         ignore_checksum,
         install_on_upgrade,
         force,
-        not_silent
+        verbose_output,
+        not_silent,
+        skip_powershell,
+        expires_at,
+        not_before,
+        deadline,
+        retry_policy,
+        batch_id
     )
-VALUES
-    (
-        $1, -- Node ID
-        $2, -- Group ID (if the job was applied to a group)
-        (SELECT organization_id FROM nodes WHERE id=$1), 
-        $3, -- action (INSTALL, UPGRADE, UNINSTALL)
-        $4, -- package name
-        $5, -- verion
-        $6, -- ignore checksum
-        $7, -- install on upgrade
-        $8, -- force
-        $9 -- not_silent
+SELECT
+    nga.node_id, -- one job per member of the group
+    nga.group_id, -- the group the job was applied to
+    nga.organization_id, -- the group's organization
+    $1, -- action (INSTALL, UPGRADE, UNINSTALL)
+    $2, -- package name
+    $3, -- version
+    $4, -- timeout in seconds
+    $5, -- ignore checksum
+    $6, -- install on upgrade
+    $7, -- force
+    $8, -- verbose output
+    $9, -- not_silent
+    $10, -- skip powershell
+    $11, -- when the job expires
+    $12, -- when the job may first be run
+    $13, -- when the job is run regardless of the maintenance schedule
+    $14, -- overrides the organization's retry policy
+    $15 -- shared by every job of the fan-out
+FROM
+    node_group_assignments nga
+WHERE
+    nga.group_id=$16 AND nga.organization_id=$17
+RETURNING id, node_id, group_id, baseline_id, organization_id, state, attempts, action, name, version, ignore_checksum, install_on_upgrade, force, verbose_output, not_silent, skip_powershell, timeout, retry_policy, status, exit_code, output, error, attempted_at, lease_expires_at, completed_at, expires_at, not_before, deadline, retry_at, created_at, batch_id
+`
+
+type CreateGroupPackageJobsParams struct {
+	Action           int32            `db:"action" json:"action"`
+	Name             string           `db:"name" json:"name"`
+	Version          pgtype.Text      `db:"version" json:"version"`
+	Timeout          int32            `db:"timeout" json:"timeout"`
+	IgnoreChecksum   bool             `db:"ignore_checksum" json:"ignore_checksum"`
+	InstallOnUpgrade bool             `db:"install_on_upgrade" json:"install_on_upgrade"`
+	Force            bool             `db:"force" json:"force"`
+	VerboseOutput    bool             `db:"verbose_output" json:"verbose_output"`
+	NotSilent        bool             `db:"not_silent" json:"not_silent"`
+	SkipPowershell   bool             `db:"skip_powershell" json:"skip_powershell"`
+	ExpiresAt        pgtype.Timestamp `db:"expires_at" json:"expires_at"`
+	NotBefore        pgtype.Timestamp `db:"not_before" json:"not_before"`
+	Deadline         pgtype.Timestamp `db:"deadline" json:"deadline"`
+	RetryPolicy      *api.RetryPolicy `db:"retry_policy" json:"retry_policy"`
+	BatchID          uuid.UUID        `db:"batch_id" json:"batch_id"`
+	GroupID          uuid.UUID        `db:"group_id" json:"group_id"`
+	OrganizationID   uuid.UUID        `db:"organization_id" json:"organization_id"`
+}
+
+func (q *Queries) CreateGroupPackageJobs(ctx context.Context, arg CreateGroupPackageJobsParams) ([]PackageJob, error) {
+	rows, err := q.db.Query(ctx, createGroupPackageJobs,
+		arg.Action,
+		arg.Name,
+		arg.Version,
+		arg.Timeout,
+		arg.IgnoreChecksum,
+		arg.InstallOnUpgrade,
+		arg.Force,
+		arg.VerboseOutput,
+		arg.NotSilent,
+		arg.SkipPowershell,
+		arg.ExpiresAt,
+		arg.NotBefore,
+		arg.Deadline,
+		arg.RetryPolicy,
+		arg.BatchID,
+		arg.GroupID,
+		arg.OrganizationID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PackageJob
+	for rows.Next() {
+		var i PackageJob
+		if err := rows.Scan(
+			&i.ID,
+			&i.NodeID,
+			&i.GroupID,
+			&i.BaselineID,
+			&i.OrganizationID,
+			&i.State,
+			&i.Attempts,
+			&i.Action,
+			&i.Name,
+			&i.Version,
+			&i.IgnoreChecksum,
+			&i.InstallOnUpgrade,
+			&i.Force,
+			&i.VerboseOutput,
+			&i.NotSilent,
+			&i.SkipPowershell,
+			&i.Timeout,
+			&i.RetryPolicy,
+			&i.Status,
+			&i.ExitCode,
+			&i.Output,
+			&i.Error,
+			&i.AttemptedAt,
+			&i.LeaseExpiresAt,
+			&i.CompletedAt,
+			&i.ExpiresAt,
+			&i.NotBefore,
+			&i.Deadline,
+			&i.RetryAt,
+			&i.CreatedAt,
+			&i.BatchID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createPackageJob = `-- name: CreatePackageJob :one
+INSERT INTO
+    package_jobs(
+        node_id,
+        group_id,
+        organization_id,
+        action,
+        name,
+        version,
+        timeout,
+        ignore_checksum,
+        install_on_upgrade,
+        force,
+        verbose_output,
+        not_silent,
+        skip_powershell,
+        expires_at,
+        not_before,
+        deadline,
+        retry_policy,
+        baseline_id
     )
-RETURNING id, node_id, group_id, organization_id, attempts, action, name, version, ignore_checksum, install_on_upgrade, force, verbose_output, not_silent, timeout, status, exit_code, output, error, attempted_at, completed_at, expires_at, created_at
+SELECT
+    nodes.id, -- Node ID
+    $1, -- Group ID (if the job was applied to a group)
+    nodes.organization_id, -- the node's organization
+    $2, -- action (INSTALL, UPGRADE, UNINSTALL)
+    $3, -- package name
+    $4, -- version
+    $5, -- timeout in seconds
+    $6, -- ignore checksum
+    $7, -- install on upgrade
+    $8, -- force
+    $9, -- verbose output
+    $10, -- not_silent
+    $11, -- skip powershell
+    $12, -- when the job expires
+    $13, -- when the job may first be run
+    $14, -- when the job is run regardless of the maintenance schedule
+    $15, -- overrides the organization's retry policy
+    $16 -- the baseline which queued the job to remediate drift
+FROM
+    nodes
+WHERE
+    nodes.id=$17 AND nodes.organization_id=$18 -- the node must belong to the organization
+RETURNING id, node_id, group_id, baseline_id, organization_id, state, attempts, action, name, version, ignore_checksum, install_on_upgrade, force, verbose_output, not_silent, skip_powershell, timeout, retry_policy, status, exit_code, output, error, attempted_at, lease_expires_at, completed_at, expires_at, not_before, deadline, retry_at, created_at, batch_id
 `
 
 type CreatePackageJobParams struct {
-	NodeID           uuid.UUID   `db:"node_id" json:"node_id"`
-	GroupID          pgtype.UUID `db:"group_id" json:"group_id"`
-	Action           int32       `db:"action" json:"action"`
-	Name             string      `db:"name" json:"name"`
-	Version          pgtype.Text `db:"version" json:"version"`
-	IgnoreChecksum   bool        `db:"ignore_checksum" json:"ignore_checksum"`
-	InstallOnUpgrade bool        `db:"install_on_upgrade" json:"install_on_upgrade"`
-	Force            bool        `db:"force" json:"force"`
-	NotSilent        bool        `db:"not_silent" json:"not_silent"`
+	GroupID          pgtype.UUID      `db:"group_id" json:"group_id"`
+	Action           int32            `db:"action" json:"action"`
+	Name             string           `db:"name" json:"name"`
+	Version          pgtype.Text      `db:"version" json:"version"`
+	Timeout          int32            `db:"timeout" json:"timeout"`
+	IgnoreChecksum   bool             `db:"ignore_checksum" json:"ignore_checksum"`
+	InstallOnUpgrade bool             `db:"install_on_upgrade" json:"install_on_upgrade"`
+	Force            bool             `db:"force" json:"force"`
+	VerboseOutput    bool             `db:"verbose_output" json:"verbose_output"`
+	NotSilent        bool             `db:"not_silent" json:"not_silent"`
+	SkipPowershell   bool             `db:"skip_powershell" json:"skip_powershell"`
+	ExpiresAt        pgtype.Timestamp `db:"expires_at" json:"expires_at"`
+	NotBefore        pgtype.Timestamp `db:"not_before" json:"not_before"`
+	Deadline         pgtype.Timestamp `db:"deadline" json:"deadline"`
+	RetryPolicy      *api.RetryPolicy `db:"retry_policy" json:"retry_policy"`
+	BaselineID       pgtype.UUID      `db:"baseline_id" json:"baseline_id"`
+	NodeID           uuid.UUID        `db:"node_id" json:"node_id"`
+	OrganizationID   uuid.UUID        `db:"organization_id" json:"organization_id"`
 }
 
 func (q *Queries) CreatePackageJob(ctx context.Context, arg CreatePackageJobParams) (PackageJob, error) {
 	row := q.db.QueryRow(ctx, createPackageJob,
-		arg.NodeID,
 		arg.GroupID,
 		arg.Action,
 		arg.Name,
 		arg.Version,
+		arg.Timeout,
 		arg.IgnoreChecksum,
 		arg.InstallOnUpgrade,
 		arg.Force,
+		arg.VerboseOutput,
 		arg.NotSilent,
+		arg.SkipPowershell,
+		arg.ExpiresAt,
+		arg.NotBefore,
+		arg.Deadline,
+		arg.RetryPolicy,
+		arg.BaselineID,
+		arg.NodeID,
+		arg.OrganizationID,
 	)
 	var i PackageJob
 	err := row.Scan(
 		&i.ID,
 		&i.NodeID,
 		&i.GroupID,
+		&i.BaselineID,
 		&i.OrganizationID,
+		&i.State,
 		&i.Attempts,
 		&i.Action,
 		&i.Name,
@@ -187,22 +421,366 @@ func (q *Queries) CreatePackageJob(ctx context.Context, arg CreatePackageJobPara
 		&i.Force,
 		&i.VerboseOutput,
 		&i.NotSilent,
+		&i.SkipPowershell,
 		&i.Timeout,
+		&i.RetryPolicy,
 		&i.Status,
 		&i.ExitCode,
 		&i.Output,
 		&i.Error,
 		&i.AttemptedAt,
+		&i.LeaseExpiresAt,
 		&i.CompletedAt,
 		&i.ExpiresAt,
+		&i.NotBefore,
+		&i.Deadline,
+		&i.RetryAt,
 		&i.CreatedAt,
+		&i.BatchID,
+	)
+	return i, err
+}
+
+const createPackageJobAttempt = `-- name: CreatePackageJobAttempt :exec
+INSERT INTO
+    package_job_attempts (
+        job_id, attempt
+    )
+VALUES (
+    $1, $2
+)
+`
+
+type CreatePackageJobAttemptParams struct {
+	JobID   uuid.UUID `db:"job_id" json:"job_id"`
+	Attempt int32     `db:"attempt" json:"attempt"`
+}
+
+func (q *Queries) CreatePackageJobAttempt(ctx context.Context, arg CreatePackageJobAttemptParams) error {
+	_, err := q.db.Exec(ctx, createPackageJobAttempt, arg.JobID, arg.Attempt)
+	return err
+}
+
+const createPackageJobSecret = `-- name: CreatePackageJobSecret :exec
+INSERT INTO
+    package_job_secrets (
+        job_id, package_parameters
+    )
+VALUES (
+    $1, $2
+)
+`
+
+type CreatePackageJobSecretParams struct {
+	JobID             uuid.UUID `db:"job_id" json:"job_id"`
+	PackageParameters []byte    `db:"package_parameters" json:"package_parameters"`
+}
+
+func (q *Queries) CreatePackageJobSecret(ctx context.Context, arg CreatePackageJobSecretParams) error {
+	_, err := q.db.Exec(ctx, createPackageJobSecret, arg.JobID, arg.PackageParameters)
+	return err
+}
+
+const deletePackageJobSecret = `-- name: DeletePackageJobSecret :exec
+DELETE FROM
+    package_job_secrets
+WHERE
+    job_id=$1
+`
+
+// secrets are no longer needed once the job has been completed or cancelled
+func (q *Queries) DeletePackageJobSecret(ctx context.Context, jobID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, deletePackageJobSecret, jobID)
+	return err
+}
+
+const deletePackageJobSecrets = `-- name: DeletePackageJobSecrets :exec
+DELETE FROM
+    package_job_secrets
+WHERE
+    job_id = ANY($1::uuid[])
+`
+
+func (q *Queries) DeletePackageJobSecrets(ctx context.Context, jobIds []uuid.UUID) error {
+	_, err := q.db.Exec(ctx, deletePackageJobSecrets, jobIds)
+	return err
+}
+
+const expirePackageJobs = `-- name: ExpirePackageJobs :many
+UPDATE
+    package_jobs
+SET
+    state='expired',
+    status=$1,
+    completed_at=CURRENT_TIMESTAMP
+WHERE
+    state='queued' AND expires_at <= CURRENT_TIMESTAMP
+RETURNING id
+`
+
+// expire queued jobs past their expiration, a leased job is only expired once its lease is reclaimed
+func (q *Queries) ExpirePackageJobs(ctx context.Context, status int32) ([]uuid.UUID, error) {
+	rows, err := q.db.Query(ctx, expirePackageJobs, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const finishPackageJobAttempt = `-- name: FinishPackageJobAttempt :exec
+UPDATE
+    package_job_attempts
+SET
+    outcome=$3,
+    status=$4,
+    exit_code=$5,
+    output=$6,
+    error=$7,
+    decision=$8,
+    retry_at=$9,
+    finished_at=CURRENT_TIMESTAMP
+WHERE
+    job_id=$1 AND attempt=$2 AND finished_at IS NULL
+`
+
+type FinishPackageJobAttemptParams struct {
+	JobID    uuid.UUID        `db:"job_id" json:"job_id"`
+	Attempt  int32            `db:"attempt" json:"attempt"`
+	Outcome  pgtype.Text      `db:"outcome" json:"outcome"`
+	Status   pgtype.Int4      `db:"status" json:"status"`
+	ExitCode pgtype.Int4      `db:"exit_code" json:"exit_code"`
+	Output   pgtype.Text      `db:"output" json:"output"`
+	Error    pgtype.Text      `db:"error" json:"error"`
+	Decision pgtype.Text      `db:"decision" json:"decision"`
+	RetryAt  pgtype.Timestamp `db:"retry_at" json:"retry_at"`
+}
+
+func (q *Queries) FinishPackageJobAttempt(ctx context.Context, arg FinishPackageJobAttemptParams) error {
+	_, err := q.db.Exec(ctx, finishPackageJobAttempt,
+		arg.JobID,
+		arg.Attempt,
+		arg.Outcome,
+		arg.Status,
+		arg.ExitCode,
+		arg.Output,
+		arg.Error,
+		arg.Decision,
+		arg.RetryAt,
 	)
+	return err
+}
+
+const getActivePackageJobNamesByNodeID = `-- name: GetActivePackageJobNamesByNodeID :many
+SELECT DISTINCT
+    name
+FROM
+    package_jobs
+WHERE
+    node_id=$1 AND state IN ('queued', 'leased', 'running')
+`
+
+// the packages which already have a job that has not yet reached a final state
+func (q *Queries) GetActivePackageJobNamesByNodeID(ctx context.Context, nodeID uuid.UUID) ([]string, error) {
+	rows, err := q.db.Query(ctx, getActivePackageJobNamesByNodeID, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		items = append(items, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getGroupPackageJobRollups = `-- name: GetGroupPackageJobRollups :many
+SELECT
+    batch_id,
+    action,
+    name,
+    version,
+    MIN(created_at)::timestamp AS created_at,
+    COUNT(*) AS total,
+    COUNT(*) FILTER (WHERE status = 0) AS pending,
+    COUNT(*) FILTER (WHERE status > 0 AND status % 10 BETWEEN 0 AND 3) AS succeeded,
+    COUNT(*) FILTER (WHERE status > 0 AND status % 10 BETWEEN 4 AND 6) AS neutral,
+    COUNT(*) FILTER (WHERE status = -1 OR (status > 0 AND status % 10 BETWEEN 7 AND 9)) AS failed,
+    COUNT(*) FILTER (WHERE status = -2) AS cancelled,
+    COUNT(*) FILTER (WHERE status = -3) AS expired
+FROM
+    package_jobs
+WHERE
+    group_id=$1 AND organization_id=$2
+GROUP BY
+    batch_id, action, name, version
+ORDER BY
+    CASE WHEN $3::text = 'DESC' THEN MIN(created_at) END DESC,
+    CASE WHEN $3::text = 'DESC' THEN batch_id END DESC,
+    MIN(created_at) ASC, batch_id ASC
+LIMIT $4::int OFFSET $5::int
+`
+
+type GetGroupPackageJobRollupsParams struct {
+	GroupID        pgtype.UUID `db:"group_id" json:"group_id"`
+	OrganizationID uuid.UUID   `db:"organization_id" json:"organization_id"`
+	Sort           string      `db:"sort" json:"sort"`
+	PageLimit      int32       `db:"page_limit" json:"page_limit"`
+	PageOffset     int32       `db:"page_offset" json:"page_offset"`
+}
+
+type GetGroupPackageJobRollupsRow struct {
+	BatchID   pgtype.UUID      `db:"batch_id" json:"batch_id"`
+	Action    int32            `db:"action" json:"action"`
+	Name      string           `db:"name" json:"name"`
+	Version   pgtype.Text      `db:"version" json:"version"`
+	CreatedAt pgtype.Timestamp `db:"created_at" json:"created_at"`
+	Total     int64            `db:"total" json:"total"`
+	Pending   int64            `db:"pending" json:"pending"`
+	Succeeded int64            `db:"succeeded" json:"succeeded"`
+	Neutral   int64            `db:"neutral" json:"neutral"`
+	Failed    int64            `db:"failed" json:"failed"`
+	Cancelled int64            `db:"cancelled" json:"cancelled"`
+	Expired   int64            `db:"expired" json:"expired"`
+}
+
+// Roll up the statuses of the jobs fanned out to a group using the ranges of choco.ChocoStatus:
+// X[0-3] are successes, X[4-6] are neutral, X[7-9] are failures, and the negative statuses are set by the server.
+// All jobs fanned out to a group at once share the same batch ID.
+func (q *Queries) GetGroupPackageJobRollups(ctx context.Context, arg GetGroupPackageJobRollupsParams) ([]GetGroupPackageJobRollupsRow, error) {
+	rows, err := q.db.Query(ctx, getGroupPackageJobRollups,
+		arg.GroupID,
+		arg.OrganizationID,
+		arg.Sort,
+		arg.PageLimit,
+		arg.PageOffset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetGroupPackageJobRollupsRow
+	for rows.Next() {
+		var i GetGroupPackageJobRollupsRow
+		if err := rows.Scan(
+			&i.BatchID,
+			&i.Action,
+			&i.Name,
+			&i.Version,
+			&i.CreatedAt,
+			&i.Total,
+			&i.Pending,
+			&i.Succeeded,
+			&i.Neutral,
+			&i.Failed,
+			&i.Cancelled,
+			&i.Expired,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getLeasedPackageJobRetryPolicy = `-- name: GetLeasedPackageJobRetryPolicy :one
+SELECT
+    package_jobs.attempts,
+    COALESCE(package_jobs.retry_policy, orp.retry_policy) AS retry_policy
+FROM
+    package_jobs
+LEFT JOIN
+    organization_retry_policies orp ON orp.organization_id = package_jobs.organization_id
+WHERE
+    package_jobs.id=$1 AND package_jobs.node_id=$2 AND package_jobs.state IN ('leased', 'running')
+FOR UPDATE OF package_jobs
+`
+
+type GetLeasedPackageJobRetryPolicyParams struct {
+	ID     uuid.UUID `db:"id" json:"id"`
+	NodeID uuid.UUID `db:"node_id" json:"node_id"`
+}
+
+type GetLeasedPackageJobRetryPolicyRow struct {
+	Attempts    int32  `db:"attempts" json:"attempts"`
+	RetryPolicy []byte `db:"retry_policy" json:"retry_policy"`
+}
+
+// lock a job leased to the node while its result is recorded, along with its own retry policy or that of its organization
+func (q *Queries) GetLeasedPackageJobRetryPolicy(ctx context.Context, arg GetLeasedPackageJobRetryPolicyParams) (GetLeasedPackageJobRetryPolicyRow, error) {
+	row := q.db.QueryRow(ctx, getLeasedPackageJobRetryPolicy, arg.ID, arg.NodeID)
+	var i GetLeasedPackageJobRetryPolicyRow
+	err := row.Scan(&i.Attempts, &i.RetryPolicy)
 	return i, err
 }
 
+const getPackageJobAttempts = `-- name: GetPackageJobAttempts :many
+SELECT
+    job_id, attempt, outcome, status, exit_code, output, error, leased_at, started_at, finished_at, decision, retry_at
+FROM
+    package_job_attempts
+WHERE
+    job_id=$1
+ORDER BY attempt ASC
+`
+
+func (q *Queries) GetPackageJobAttempts(ctx context.Context, jobID uuid.UUID) ([]PackageJobAttempt, error) {
+	rows, err := q.db.Query(ctx, getPackageJobAttempts, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PackageJobAttempt
+	for rows.Next() {
+		var i PackageJobAttempt
+		if err := rows.Scan(
+			&i.JobID,
+			&i.Attempt,
+			&i.Outcome,
+			&i.Status,
+			&i.ExitCode,
+			&i.Output,
+			&i.Error,
+			&i.LeasedAt,
+			&i.StartedAt,
+			&i.FinishedAt,
+			&i.Decision,
+			&i.RetryAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getPackageJobByID = `-- name: GetPackageJobByID :one
 SELECT
-    id, node_id, group_id, organization_id, attempts, action, name, version, ignore_checksum, install_on_upgrade, force, verbose_output, not_silent, timeout, status, exit_code, output, error, attempted_at, completed_at, expires_at, created_at
+    id, node_id, group_id, baseline_id, organization_id, state, attempts, action, name, version, ignore_checksum, install_on_upgrade, force, verbose_output, not_silent, skip_powershell, timeout, retry_policy, status, exit_code, output, error, attempted_at, lease_expires_at, completed_at, expires_at, not_before, deadline, retry_at, created_at, batch_id
 FROM
     package_jobs
 WHERE
@@ -217,7 +795,9 @@ func (q *Queries) GetPackageJobByID(ctx context.Context, id uuid.UUID) (PackageJ
 		&i.ID,
 		&i.NodeID,
 		&i.GroupID,
+		&i.BaselineID,
 		&i.OrganizationID,
+		&i.State,
 		&i.Attempts,
 		&i.Action,
 		&i.Name,
@@ -227,15 +807,22 @@ func (q *Queries) GetPackageJobByID(ctx context.Context, id uuid.UUID) (PackageJ
 		&i.Force,
 		&i.VerboseOutput,
 		&i.NotSilent,
+		&i.SkipPowershell,
 		&i.Timeout,
+		&i.RetryPolicy,
 		&i.Status,
 		&i.ExitCode,
 		&i.Output,
 		&i.Error,
 		&i.AttemptedAt,
+		&i.LeaseExpiresAt,
 		&i.CompletedAt,
 		&i.ExpiresAt,
+		&i.NotBefore,
+		&i.Deadline,
+		&i.RetryAt,
 		&i.CreatedAt,
+		&i.BatchID,
 	)
 	return i, err
 }
@@ -246,17 +833,23 @@ SELECT
 FROM
     package_jobs
 WHERE
-    node_id=$1 AND status = 0 AND (attempts < $2 OR $2 = 0)
+    node_id=$1 AND state = 'queued' AND (attempts < $2 OR $2 = 0)
+    AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)
+    AND (not_before IS NULL OR not_before <= CURRENT_TIMESTAMP)
+    AND (retry_at IS NULL OR retry_at <= CURRENT_TIMESTAMP)
+    AND (NOT $3::boolean OR deadline <= CURRENT_TIMESTAMP)
 ORDER BY created_at ASC
 `
 
 type GetPackageJobListByNodeIDParams struct {
 	NodeID   uuid.UUID `db:"node_id" json:"node_id"`
 	Attempts int32     `db:"attempts" json:"attempts"`
+	Overdue  bool      `db:"overdue" json:"overdue"`
 }
 
+// only jobs which are due, have not expired, and are not backing off from a failure are provided, optionally only those past their deadline
 func (q *Queries) GetPackageJobListByNodeID(ctx context.Context, arg GetPackageJobListByNodeIDParams) ([]uuid.UUID, error) {
-	rows, err := q.db.Query(ctx, getPackageJobListByNodeID, arg.NodeID, arg.Attempts)
+	rows, err := q.db.Query(ctx, getPackageJobListByNodeID, arg.NodeID, arg.Attempts, arg.Overdue)
 	if err != nil {
 		return nil, err
 	}
@@ -275,9 +868,26 @@ func (q *Queries) GetPackageJobListByNodeID(ctx context.Context, arg GetPackageJ
 	return items, nil
 }
 
+const getPackageJobSecret = `-- name: GetPackageJobSecret :one
+SELECT
+    job_id, package_parameters
+FROM
+    package_job_secrets
+WHERE
+    job_id=$1
+LIMIT 1
+`
+
+func (q *Queries) GetPackageJobSecret(ctx context.Context, jobID uuid.UUID) (PackageJobSecret, error) {
+	row := q.db.QueryRow(ctx, getPackageJobSecret, jobID)
+	var i PackageJobSecret
+	err := row.Scan(&i.JobID, &i.PackageParameters)
+	return i, err
+}
+
 const getPackageJobsByNodeID = `-- name: GetPackageJobsByNodeID :many
 SELECT
-    id, node_id, group_id, organization_id, attempts, action, name, version, ignore_checksum, install_on_upgrade, force, verbose_output, not_silent, timeout, status, exit_code, output, error, attempted_at, completed_at, expires_at, created_at
+    id, node_id, group_id, baseline_id, organization_id, state, attempts, action, name, version, ignore_checksum, install_on_upgrade, force, verbose_output, not_silent, skip_powershell, timeout, retry_policy, status, exit_code, output, error, attempted_at, lease_expires_at, completed_at, expires_at, not_before, deadline, retry_at, created_at, batch_id
 FROM
     package_jobs
 WHERE
@@ -298,7 +908,9 @@ func (q *Queries) GetPackageJobsByNodeID(ctx context.Context, nodeID uuid.UUID)
 			&i.ID,
 			&i.NodeID,
 			&i.GroupID,
+			&i.BaselineID,
 			&i.OrganizationID,
+			&i.State,
 			&i.Attempts,
 			&i.Action,
 			&i.Name,
@@ -308,15 +920,240 @@ func (q *Queries) GetPackageJobsByNodeID(ctx context.Context, nodeID uuid.UUID)
 			&i.Force,
 			&i.VerboseOutput,
 			&i.NotSilent,
+			&i.SkipPowershell,
 			&i.Timeout,
+			&i.RetryPolicy,
 			&i.Status,
 			&i.ExitCode,
 			&i.Output,
 			&i.Error,
 			&i.AttemptedAt,
+			&i.LeaseExpiresAt,
 			&i.CompletedAt,
 			&i.ExpiresAt,
+			&i.NotBefore,
+			&i.Deadline,
+			&i.RetryAt,
 			&i.CreatedAt,
+			&i.BatchID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPackageJobsByOrgID = `-- name: GetPackageJobsByOrgID :many
+SELECT
+    id, node_id, group_id, baseline_id, organization_id, state, attempts, action, name, version, ignore_checksum, install_on_upgrade, force, verbose_output, not_silent, skip_powershell, timeout, retry_policy, status, exit_code, output, error, attempted_at, lease_expires_at, completed_at, expires_at, not_before, deadline, retry_at, created_at, batch_id
+FROM
+    package_jobs
+WHERE
+    organization_id=$1 AND ($2::uuid IS NULL OR node_id=$2)
+ORDER BY
+    CASE WHEN $3::text = 'DESC' THEN created_at END DESC,
+    created_at ASC
+LIMIT $4::int OFFSET $5::int
+`
+
+type GetPackageJobsByOrgIDParams struct {
+	OrganizationID uuid.UUID   `db:"organization_id" json:"organization_id"`
+	NodeID         pgtype.UUID `db:"node_id" json:"node_id"`
+	Sort           string      `db:"sort" json:"sort"`
+	PageLimit      int32       `db:"page_limit" json:"page_limit"`
+	PageOffset     int32       `db:"page_offset" json:"page_offset"`
+}
+
+func (q *Queries) GetPackageJobsByOrgID(ctx context.Context, arg GetPackageJobsByOrgIDParams) ([]PackageJob, error) {
+	rows, err := q.db.Query(ctx, getPackageJobsByOrgID,
+		arg.OrganizationID,
+		arg.NodeID,
+		arg.Sort,
+		arg.PageLimit,
+		arg.PageOffset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PackageJob
+	for rows.Next() {
+		var i PackageJob
+		if err := rows.Scan(
+			&i.ID,
+			&i.NodeID,
+			&i.GroupID,
+			&i.BaselineID,
+			&i.OrganizationID,
+			&i.State,
+			&i.Attempts,
+			&i.Action,
+			&i.Name,
+			&i.Version,
+			&i.IgnoreChecksum,
+			&i.InstallOnUpgrade,
+			&i.Force,
+			&i.VerboseOutput,
+			&i.NotSilent,
+			&i.SkipPowershell,
+			&i.Timeout,
+			&i.RetryPolicy,
+			&i.Status,
+			&i.ExitCode,
+			&i.Output,
+			&i.Error,
+			&i.AttemptedAt,
+			&i.LeaseExpiresAt,
+			&i.CompletedAt,
+			&i.ExpiresAt,
+			&i.NotBefore,
+			&i.Deadline,
+			&i.RetryAt,
+			&i.CreatedAt,
+			&i.BatchID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const leasePackageJob = `-- name: LeasePackageJob :one
+UPDATE
+    package_jobs
+SET
+    state = 'leased',
+    attempts = attempts + 1,
+    attempted_at = CURRENT_TIMESTAMP,
+    lease_expires_at = CURRENT_TIMESTAMP + make_interval(secs => $1::int),
+    output = NULL
+WHERE
+    id = $2 AND node_id=$3 AND state = 'queued' AND (attempts < $4 OR $4 = 0)
+    AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)
+    AND (not_before IS NULL OR not_before <= CURRENT_TIMESTAMP)
+    AND (retry_at IS NULL OR retry_at <= CURRENT_TIMESTAMP)
+RETURNING id, node_id, group_id, baseline_id, organization_id, state, attempts, action, name, version, ignore_checksum, install_on_upgrade, force, verbose_output, not_silent, skip_powershell, timeout, retry_policy, status, exit_code, output, error, attempted_at, lease_expires_at, completed_at, expires_at, not_before, deadline, retry_at, created_at, batch_id
+`
+
+type LeasePackageJobParams struct {
+	LeaseSeconds int32     `db:"lease_seconds" json:"lease_seconds"`
+	ID           uuid.UUID `db:"id" json:"id"`
+	NodeID       uuid.UUID `db:"node_id" json:"node_id"`
+	Attempts     int32     `db:"attempts" json:"attempts"`
+}
+
+// lease a queued job to its node, the output of any previous attempt is kept in the attempt history
+func (q *Queries) LeasePackageJob(ctx context.Context, arg LeasePackageJobParams) (PackageJob, error) {
+	row := q.db.QueryRow(ctx, leasePackageJob,
+		arg.LeaseSeconds,
+		arg.ID,
+		arg.NodeID,
+		arg.Attempts,
+	)
+	var i PackageJob
+	err := row.Scan(
+		&i.ID,
+		&i.NodeID,
+		&i.GroupID,
+		&i.BaselineID,
+		&i.OrganizationID,
+		&i.State,
+		&i.Attempts,
+		&i.Action,
+		&i.Name,
+		&i.Version,
+		&i.IgnoreChecksum,
+		&i.InstallOnUpgrade,
+		&i.Force,
+		&i.VerboseOutput,
+		&i.NotSilent,
+		&i.SkipPowershell,
+		&i.Timeout,
+		&i.RetryPolicy,
+		&i.Status,
+		&i.ExitCode,
+		&i.Output,
+		&i.Error,
+		&i.AttemptedAt,
+		&i.LeaseExpiresAt,
+		&i.CompletedAt,
+		&i.ExpiresAt,
+		&i.NotBefore,
+		&i.Deadline,
+		&i.RetryAt,
+		&i.CreatedAt,
+		&i.BatchID,
+	)
+	return i, err
+}
+
+const reclaimPackageJobLeases = `-- name: ReclaimPackageJobLeases :many
+WITH reclaimed AS (
+    SELECT
+        package_jobs.id,
+        package_jobs.attempts >= COALESCE(
+            (package_jobs.retry_policy->>'max_attempts')::int,
+            (orp.retry_policy->>'max_attempts')::int,
+            $1::int
+        ) AS exhausted
+    FROM
+        package_jobs
+    LEFT JOIN
+        organization_retry_policies orp ON orp.organization_id = package_jobs.organization_id
+    WHERE
+        package_jobs.state IN ('leased', 'running') AND package_jobs.lease_expires_at < CURRENT_TIMESTAMP
+    FOR UPDATE OF package_jobs
+)
+UPDATE
+    package_jobs
+SET
+    state = CASE WHEN reclaimed.exhausted THEN 'failed' ELSE 'queued' END,
+    status = CASE WHEN reclaimed.exhausted THEN $2::int ELSE package_jobs.status END,
+    completed_at = CASE WHEN reclaimed.exhausted THEN CURRENT_TIMESTAMP ELSE NULL END,
+    lease_expires_at = NULL
+FROM
+    reclaimed
+WHERE
+    package_jobs.id = reclaimed.id
+RETURNING package_jobs.id, package_jobs.state, package_jobs.attempts, package_jobs.output
+`
+
+type ReclaimPackageJobLeasesParams struct {
+	AttemptsMax int32 `db:"attempts_max" json:"attempts_max"`
+	Status      int32 `db:"status" json:"status"`
+}
+
+type ReclaimPackageJobLeasesRow struct {
+	ID       uuid.UUID   `db:"id" json:"id"`
+	State    string      `db:"state" json:"state"`
+	Attempts int32       `db:"attempts" json:"attempts"`
+	Output   pgtype.Text `db:"output" json:"output"`
+}
+
+// requeue jobs whose node stopped heartbeating before the lease expired, or fail them once they are out of attempts
+// according to their own retry policy, that of their organization, or the default maximum attempts
+func (q *Queries) ReclaimPackageJobLeases(ctx context.Context, arg ReclaimPackageJobLeasesParams) ([]ReclaimPackageJobLeasesRow, error) {
+	rows, err := q.db.Query(ctx, reclaimPackageJobLeases, arg.AttemptsMax, arg.Status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ReclaimPackageJobLeasesRow
+	for rows.Next() {
+		var i ReclaimPackageJobLeasesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.State,
+			&i.Attempts,
+			&i.Output,
 		); err != nil {
 			return nil, err
 		}
@@ -327,3 +1164,93 @@ func (q *Queries) GetPackageJobsByNodeID(ctx context.Context, nodeID uuid.UUID)
 	}
 	return items, nil
 }
+
+const retryPackageJob = `-- name: RetryPackageJob :one
+UPDATE
+    package_jobs
+SET
+    state='queued',
+    exit_code=$1,
+    output=$2,
+    error=$3,
+    lease_expires_at=NULL,
+    retry_at=CURRENT_TIMESTAMP + make_interval(secs => $4::int)
+WHERE
+    id=$5 AND state IN ('leased', 'running') AND node_id=$6
+RETURNING id, node_id, group_id, baseline_id, organization_id, state, attempts, action, name, version, ignore_checksum, install_on_upgrade, force, verbose_output, not_silent, skip_powershell, timeout, retry_policy, status, exit_code, output, error, attempted_at, lease_expires_at, completed_at, expires_at, not_before, deadline, retry_at, created_at, batch_id
+`
+
+type RetryPackageJobParams struct {
+	ExitCode       pgtype.Int4 `db:"exit_code" json:"exit_code"`
+	Output         pgtype.Text `db:"output" json:"output"`
+	Error          pgtype.Text `db:"error" json:"error"`
+	BackoffSeconds int32       `db:"backoff_seconds" json:"backoff_seconds"`
+	ID             uuid.UUID   `db:"id" json:"id"`
+	NodeID         uuid.UUID   `db:"node_id" json:"node_id"`
+}
+
+// requeue a job after a transient failure, it is not provided to the node again until it has backed off
+func (q *Queries) RetryPackageJob(ctx context.Context, arg RetryPackageJobParams) (PackageJob, error) {
+	row := q.db.QueryRow(ctx, retryPackageJob,
+		arg.ExitCode,
+		arg.Output,
+		arg.Error,
+		arg.BackoffSeconds,
+		arg.ID,
+		arg.NodeID,
+	)
+	var i PackageJob
+	err := row.Scan(
+		&i.ID,
+		&i.NodeID,
+		&i.GroupID,
+		&i.BaselineID,
+		&i.OrganizationID,
+		&i.State,
+		&i.Attempts,
+		&i.Action,
+		&i.Name,
+		&i.Version,
+		&i.IgnoreChecksum,
+		&i.InstallOnUpgrade,
+		&i.Force,
+		&i.VerboseOutput,
+		&i.NotSilent,
+		&i.SkipPowershell,
+		&i.Timeout,
+		&i.RetryPolicy,
+		&i.Status,
+		&i.ExitCode,
+		&i.Output,
+		&i.Error,
+		&i.AttemptedAt,
+		&i.LeaseExpiresAt,
+		&i.CompletedAt,
+		&i.ExpiresAt,
+		&i.NotBefore,
+		&i.Deadline,
+		&i.RetryAt,
+		&i.CreatedAt,
+		&i.BatchID,
+	)
+	return i, err
+}
+
+const startPackageJobAttempt = `-- name: StartPackageJobAttempt :exec
+UPDATE
+    package_job_attempts
+SET
+    started_at=CURRENT_TIMESTAMP
+WHERE
+    job_id=$1 AND attempt=$2 AND started_at IS NULL
+`
+
+type StartPackageJobAttemptParams struct {
+	JobID   uuid.UUID `db:"job_id" json:"job_id"`
+	Attempt int32     `db:"attempt" json:"attempt"`
+}
+
+func (q *Queries) StartPackageJobAttempt(ctx context.Context, arg StartPackageJobAttemptParams) error {
+	_, err := q.db.Exec(ctx, startPackageJobAttempt, arg.JobID, arg.Attempt)
+	return err
+}
@@ -7,16 +7,31 @@ package database
 import (
 	"github.com/goodieshq/sweettooth/internal/schedule"
 	"github.com/goodieshq/sweettooth/internal/util"
+	"github.com/goodieshq/sweettooth/pkg/api"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type Baseline struct {
+	ID             uuid.UUID              `db:"id" json:"id"`
+	OrganizationID uuid.UUID              `db:"organization_id" json:"organization_id"`
+	Name           string                 `db:"name" json:"name"`
+	Entries        util.BaselineEntryList `db:"entries" json:"entries"`
+	UpdatedAt      pgtype.Timestamp       `db:"updated_at" json:"updated_at"`
+}
+
 type Group struct {
 	ID             uuid.UUID `db:"id" json:"id"`
 	OrganizationID uuid.UUID `db:"organization_id" json:"organization_id"`
 	Name           string    `db:"name" json:"name"`
 }
 
+type GroupBaselineAssignment struct {
+	BaselineID     uuid.UUID `db:"baseline_id" json:"baseline_id"`
+	GroupID        uuid.UUID `db:"group_id" json:"group_id"`
+	OrganizationID uuid.UUID `db:"organization_id" json:"organization_id"`
+}
+
 type GroupScheduleAssignment struct {
 	ScheduleID     uuid.UUID `db:"schedule_id" json:"schedule_id"`
 	GroupID        uuid.UUID `db:"group_id" json:"group_id"`
@@ -29,28 +44,46 @@ type GroupSourceAssignment struct {
 	OrganizationID uuid.UUID `db:"organization_id" json:"organization_id"`
 }
 
+type GroupUpgradePolicy struct {
+	GroupID        uuid.UUID         `db:"group_id" json:"group_id"`
+	OrganizationID uuid.UUID         `db:"organization_id" json:"organization_id"`
+	UpgradePolicy  api.UpgradePolicy `db:"upgrade_policy" json:"upgrade_policy"`
+}
+
 type Node struct {
-	ID                uuid.UUID                 `db:"id" json:"id"`
-	OrganizationID    uuid.UUID                 `db:"organization_id" json:"organization_id"`
-	PublicKey         string                    `db:"public_key" json:"public_key"`
-	Label             pgtype.Text               `db:"label" json:"label"`
-	Hostname          string                    `db:"hostname" json:"hostname"`
-	ClientVersion     string                    `db:"client_version" json:"client_version"`
-	PendingSources    bool                      `db:"pending_sources" json:"pending_sources"`
-	PendingSchedule   bool                      `db:"pending_schedule" json:"pending_schedule"`
-	OsKernel          string                    `db:"os_kernel" json:"os_kernel"`
-	OsName            string                    `db:"os_name" json:"os_name"`
-	OsMajor           int32                     `db:"os_major" json:"os_major"`
-	OsMinor           int32                     `db:"os_minor" json:"os_minor"`
-	OsBuild           int32                     `db:"os_build" json:"os_build"`
-	PackagesChoco     util.SoftwareList         `db:"packages_choco" json:"packages_choco"`
-	PackagesSystem    util.SoftwareList         `db:"packages_system" json:"packages_system"`
-	PackagesOutdated  util.SoftwareOutdatedList `db:"packages_outdated" json:"packages_outdated"`
-	PackagesUpdatedAt pgtype.Timestamp          `db:"packages_updated_at" json:"packages_updated_at"`
-	ConnectedOn       pgtype.Timestamp          `db:"connected_on" json:"connected_on"`
-	ApprovedOn        pgtype.Timestamp          `db:"approved_on" json:"approved_on"`
-	LastSeen          pgtype.Timestamp          `db:"last_seen" json:"last_seen"`
-	Approved          bool                      `db:"approved" json:"approved"`
+	ID                uuid.UUID        `db:"id" json:"id"`
+	OrganizationID    uuid.UUID        `db:"organization_id" json:"organization_id"`
+	PublicKey         string           `db:"public_key" json:"public_key"`
+	Label             pgtype.Text      `db:"label" json:"label"`
+	Hostname          string           `db:"hostname" json:"hostname"`
+	ClientVersion     string           `db:"client_version" json:"client_version"`
+	PendingSources    bool             `db:"pending_sources" json:"pending_sources"`
+	PendingSchedule   bool             `db:"pending_schedule" json:"pending_schedule"`
+	OsKernel          string           `db:"os_kernel" json:"os_kernel"`
+	OsName            string           `db:"os_name" json:"os_name"`
+	OsMajor           int32            `db:"os_major" json:"os_major"`
+	OsMinor           int32            `db:"os_minor" json:"os_minor"`
+	OsBuild           int32            `db:"os_build" json:"os_build"`
+	PackagesUpdatedAt pgtype.Timestamp `db:"packages_updated_at" json:"packages_updated_at"`
+	ConnectedOn       pgtype.Timestamp `db:"connected_on" json:"connected_on"`
+	ApprovedOn        pgtype.Timestamp `db:"approved_on" json:"approved_on"`
+	LastSeen          pgtype.Timestamp `db:"last_seen" json:"last_seen"`
+	Approved          bool             `db:"approved" json:"approved"`
+	RejectedOn        pgtype.Timestamp `db:"rejected_on" json:"rejected_on"`
+	RevokedOn         pgtype.Timestamp `db:"revoked_on" json:"revoked_on"`
+}
+
+type NodeBaselineAssignment struct {
+	BaselineID     uuid.UUID `db:"baseline_id" json:"baseline_id"`
+	NodeID         uuid.UUID `db:"node_id" json:"node_id"`
+	OrganizationID uuid.UUID `db:"organization_id" json:"organization_id"`
+}
+
+type NodeBaselineCompliance struct {
+	NodeID      uuid.UUID             `db:"node_id" json:"node_id"`
+	Compliant   bool                  `db:"compliant" json:"compliant"`
+	Drift       api.BaselineDriftList `db:"drift" json:"drift"`
+	EvaluatedAt pgtype.Timestamp      `db:"evaluated_at" json:"evaluated_at"`
 }
 
 type NodeGroupAssignment struct {
@@ -59,14 +92,27 @@ type NodeGroupAssignment struct {
 	OrganizationID uuid.UUID `db:"organization_id" json:"organization_id"`
 }
 
+type NodePackage struct {
+	ID             int64       `db:"id" json:"id"`
+	NodeID         uuid.UUID   `db:"node_id" json:"node_id"`
+	OrganizationID uuid.UUID   `db:"organization_id" json:"organization_id"`
+	Source         string      `db:"source" json:"source"`
+	Position       int32       `db:"position" json:"position"`
+	Name           string      `db:"name" json:"name"`
+	Version        string      `db:"version" json:"version"`
+	VersionNew     pgtype.Text `db:"version_new" json:"version_new"`
+	Pinned         bool        `db:"pinned" json:"pinned"`
+}
+
 type NodePackageChangelog struct {
-	ID               int32                     `db:"id" json:"id"`
-	NodeID           uuid.UUID                 `db:"node_id" json:"node_id"`
-	OrganizationID   uuid.UUID                 `db:"organization_id" json:"organization_id"`
-	PackagesChoco    util.SoftwareList         `db:"packages_choco" json:"packages_choco"`
-	PackagesSystem   util.SoftwareList         `db:"packages_system" json:"packages_system"`
-	PackagesOutdated util.SoftwareOutdatedList `db:"packages_outdated" json:"packages_outdated"`
-	Timestamp        pgtype.Timestamp          `db:"timestamp" json:"timestamp"`
+	ID             int64            `db:"id" json:"id"`
+	NodeID         uuid.UUID        `db:"node_id" json:"node_id"`
+	OrganizationID uuid.UUID        `db:"organization_id" json:"organization_id"`
+	Source         string           `db:"source" json:"source"`
+	Change         string           `db:"change" json:"change"`
+	Name           string           `db:"name" json:"name"`
+	Version        string           `db:"version" json:"version"`
+	Timestamp      pgtype.Timestamp `db:"timestamp" json:"timestamp"`
 }
 
 type NodeScheduleAssignment struct {
@@ -75,6 +121,12 @@ type NodeScheduleAssignment struct {
 	OrganizationID uuid.UUID `db:"organization_id" json:"organization_id"`
 }
 
+type NodeSource struct {
+	NodeID     uuid.UUID           `db:"node_id" json:"node_id"`
+	Sources    util.RepositoryList `db:"sources" json:"sources"`
+	ReportedAt pgtype.Timestamp    `db:"reported_at" json:"reported_at"`
+}
+
 type NodeSourceAssignment struct {
 	SourceID       uuid.UUID `db:"source_id" json:"source_id"`
 	NodeID         uuid.UUID `db:"node_id" json:"node_id"`
@@ -86,21 +138,44 @@ type Organization struct {
 	Name string    `db:"name" json:"name"`
 }
 
+type OrganizationBaselineAssignment struct {
+	BaselineID     uuid.UUID `db:"baseline_id" json:"baseline_id"`
+	OrganizationID uuid.UUID `db:"organization_id" json:"organization_id"`
+}
+
+type OrganizationRetryPolicy struct {
+	OrganizationID uuid.UUID       `db:"organization_id" json:"organization_id"`
+	RetryPolicy    api.RetryPolicy `db:"retry_policy" json:"retry_policy"`
+}
+
 type OrganizationScheduleAssignment struct {
 	ScheduleID     uuid.UUID `db:"schedule_id" json:"schedule_id"`
 	OrganizationID uuid.UUID `db:"organization_id" json:"organization_id"`
 }
 
+type OrganizationSoftwareMap struct {
+	OrganizationID  uuid.UUID            `db:"organization_id" json:"organization_id"`
+	IncludeDefaults bool                 `db:"include_defaults" json:"include_defaults"`
+	SoftwareMaps    util.SoftwareMapList `db:"software_maps" json:"software_maps"`
+}
+
 type OrganizationSourceAssignment struct {
 	SourceID       uuid.UUID `db:"source_id" json:"source_id"`
 	OrganizationID uuid.UUID `db:"organization_id" json:"organization_id"`
 }
 
+type OrganizationUpgradePolicy struct {
+	OrganizationID uuid.UUID         `db:"organization_id" json:"organization_id"`
+	UpgradePolicy  api.UpgradePolicy `db:"upgrade_policy" json:"upgrade_policy"`
+}
+
 type PackageJob struct {
 	ID               uuid.UUID        `db:"id" json:"id"`
 	NodeID           uuid.UUID        `db:"node_id" json:"node_id"`
 	GroupID          pgtype.UUID      `db:"group_id" json:"group_id"`
+	BaselineID       pgtype.UUID      `db:"baseline_id" json:"baseline_id"`
 	OrganizationID   uuid.UUID        `db:"organization_id" json:"organization_id"`
+	State            string           `db:"state" json:"state"`
 	Attempts         int32            `db:"attempts" json:"attempts"`
 	Action           int32            `db:"action" json:"action"`
 	Name             string           `db:"name" json:"name"`
@@ -110,15 +185,42 @@ type PackageJob struct {
 	Force            bool             `db:"force" json:"force"`
 	VerboseOutput    bool             `db:"verbose_output" json:"verbose_output"`
 	NotSilent        bool             `db:"not_silent" json:"not_silent"`
+	SkipPowershell   bool             `db:"skip_powershell" json:"skip_powershell"`
 	Timeout          int32            `db:"timeout" json:"timeout"`
+	RetryPolicy      *api.RetryPolicy `db:"retry_policy" json:"retry_policy"`
 	Status           int32            `db:"status" json:"status"`
 	ExitCode         pgtype.Int4      `db:"exit_code" json:"exit_code"`
 	Output           pgtype.Text      `db:"output" json:"output"`
 	Error            pgtype.Text      `db:"error" json:"error"`
 	AttemptedAt      pgtype.Timestamp `db:"attempted_at" json:"attempted_at"`
+	LeaseExpiresAt   pgtype.Timestamp `db:"lease_expires_at" json:"lease_expires_at"`
 	CompletedAt      pgtype.Timestamp `db:"completed_at" json:"completed_at"`
 	ExpiresAt        pgtype.Timestamp `db:"expires_at" json:"expires_at"`
+	NotBefore        pgtype.Timestamp `db:"not_before" json:"not_before"`
+	Deadline         pgtype.Timestamp `db:"deadline" json:"deadline"`
+	RetryAt          pgtype.Timestamp `db:"retry_at" json:"retry_at"`
 	CreatedAt        pgtype.Timestamp `db:"created_at" json:"created_at"`
+	BatchID          pgtype.UUID      `db:"batch_id" json:"batch_id"`
+}
+
+type PackageJobAttempt struct {
+	JobID      uuid.UUID        `db:"job_id" json:"job_id"`
+	Attempt    int32            `db:"attempt" json:"attempt"`
+	Outcome    pgtype.Text      `db:"outcome" json:"outcome"`
+	Status     pgtype.Int4      `db:"status" json:"status"`
+	ExitCode   pgtype.Int4      `db:"exit_code" json:"exit_code"`
+	Output     pgtype.Text      `db:"output" json:"output"`
+	Error      pgtype.Text      `db:"error" json:"error"`
+	LeasedAt   pgtype.Timestamp `db:"leased_at" json:"leased_at"`
+	StartedAt  pgtype.Timestamp `db:"started_at" json:"started_at"`
+	FinishedAt pgtype.Timestamp `db:"finished_at" json:"finished_at"`
+	Decision   pgtype.Text      `db:"decision" json:"decision"`
+	RetryAt    pgtype.Timestamp `db:"retry_at" json:"retry_at"`
+}
+
+type PackageJobSecret struct {
+	JobID             uuid.UUID `db:"job_id" json:"job_id"`
+	PackageParameters []byte    `db:"package_parameters" json:"package_parameters"`
 }
 
 type RegistrationToken struct {
@@ -127,6 +229,9 @@ type RegistrationToken struct {
 	Name           string           `db:"name" json:"name"`
 	CreatedAt      pgtype.Timestamp `db:"created_at" json:"created_at"`
 	ExpiresAt      pgtype.Timestamp `db:"expires_at" json:"expires_at"`
+	MaxUses        pgtype.Int4      `db:"max_uses" json:"max_uses"`
+	Uses           int32            `db:"uses" json:"uses"`
+	AutoApprove    bool             `db:"auto_approve" json:"auto_approve"`
 }
 
 type Schedule struct {
@@ -137,19 +242,32 @@ type Schedule struct {
 }
 
 type Source struct {
-	ID             uuid.UUID `db:"id" json:"id"`
-	OrganizationID uuid.UUID `db:"organization_id" json:"organization_id"`
-	Name           string    `db:"name" json:"name"`
-	Entries        []byte    `db:"entries" json:"entries"`
+	ID             uuid.UUID           `db:"id" json:"id"`
+	OrganizationID uuid.UUID           `db:"organization_id" json:"organization_id"`
+	Name           string              `db:"name" json:"name"`
+	Entries        util.RepositoryList `db:"entries" json:"entries"`
+}
+
+type SourceCredential struct {
+	SourceID            uuid.UUID `db:"source_id" json:"source_id"`
+	Name                string    `db:"name" json:"name"`
+	Password            []byte    `db:"password" json:"password"`
+	CertificatePassword []byte    `db:"certificate_password" json:"certificate_password"`
 }
 
 type User struct {
-	ID        uuid.UUID        `db:"id" json:"id"`
-	Email     string           `db:"email" json:"email"`
-	Password  string           `db:"password" json:"password"`
-	Mfatoken  pgtype.Text      `db:"mfatoken" json:"mfatoken"`
-	CreatedAt pgtype.Timestamp `db:"created_at" json:"created_at"`
-	LastLogin pgtype.Timestamp `db:"last_login" json:"last_login"`
+	ID               uuid.UUID        `db:"id" json:"id"`
+	Email            string           `db:"email" json:"email"`
+	Password         string           `db:"password" json:"password"`
+	Mfatoken         pgtype.Text      `db:"mfatoken" json:"mfatoken"`
+	MfatokenPending  pgtype.Text      `db:"mfatoken_pending" json:"mfatoken_pending"`
+	MfaRecoveryCodes []string         `db:"mfa_recovery_codes" json:"mfa_recovery_codes"`
+	CreatedAt        pgtype.Timestamp `db:"created_at" json:"created_at"`
+	LastLogin        pgtype.Timestamp `db:"last_login" json:"last_login"`
+	Superadmin       bool             `db:"superadmin" json:"superadmin"`
+	FailedLogins     int32            `db:"failed_logins" json:"failed_logins"`
+	LockedUntil      pgtype.Timestamp `db:"locked_until" json:"locked_until"`
+	TotpLastCounter  int64            `db:"totp_last_counter" json:"totp_last_counter"`
 }
 
 type UserOrganizationAssignment struct {
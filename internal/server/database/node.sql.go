@@ -8,10 +8,55 @@ package database
 import (
 	"context"
 
-	"github.com/goodieshq/sweettooth/internal/util"
 	"github.com/google/uuid"
 )
 
+const approveNode = `-- name: ApproveNode :one
+UPDATE
+    nodes
+SET
+    approved=TRUE,
+    approved_on=CURRENT_TIMESTAMP,
+    rejected_on=NULL,
+    revoked_on=NULL
+WHERE
+    id=$1 AND organization_id=$2 AND approved=FALSE
+RETURNING id, organization_id, public_key, label, hostname, client_version, pending_sources, pending_schedule, os_kernel, os_name, os_major, os_minor, os_build, packages_updated_at, connected_on, approved_on, last_seen, approved, rejected_on, revoked_on
+`
+
+type ApproveNodeParams struct {
+	ID             uuid.UUID `db:"id" json:"id"`
+	OrganizationID uuid.UUID `db:"organization_id" json:"organization_id"`
+}
+
+func (q *Queries) ApproveNode(ctx context.Context, arg ApproveNodeParams) (Node, error) {
+	row := q.db.QueryRow(ctx, approveNode, arg.ID, arg.OrganizationID)
+	var i Node
+	err := row.Scan(
+		&i.ID,
+		&i.OrganizationID,
+		&i.PublicKey,
+		&i.Label,
+		&i.Hostname,
+		&i.ClientVersion,
+		&i.PendingSources,
+		&i.PendingSchedule,
+		&i.OsKernel,
+		&i.OsName,
+		&i.OsMajor,
+		&i.OsMinor,
+		&i.OsBuild,
+		&i.PackagesUpdatedAt,
+		&i.ConnectedOn,
+		&i.ApprovedOn,
+		&i.LastSeen,
+		&i.Approved,
+		&i.RejectedOn,
+		&i.RevokedOn,
+	)
+	return i, err
+}
+
 const checkInNode = `-- name: CheckInNode :exec
 UPDATE
     nodes
@@ -26,6 +71,34 @@ func (q *Queries) CheckInNode(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+const clearNodePendingSchedule = `-- name: ClearNodePendingSchedule :exec
+UPDATE
+    nodes
+SET
+    pending_schedule=FALSE
+WHERE
+    id=$1
+`
+
+func (q *Queries) ClearNodePendingSchedule(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.Exec(ctx, clearNodePendingSchedule, id)
+	return err
+}
+
+const clearNodePendingSources = `-- name: ClearNodePendingSources :exec
+UPDATE
+    nodes
+SET
+    pending_sources=FALSE
+WHERE
+    id=$1
+`
+
+func (q *Queries) ClearNodePendingSources(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.Exec(ctx, clearNodePendingSources, id)
+	return err
+}
+
 const createNode = `-- name: CreateNode :one
 INSERT INTO
     nodes (
@@ -35,7 +108,7 @@ INSERT INTO
         hostname,
         client_version,
         os_kernel, os_name, os_major, os_minor, os_build,
-        packages_choco, packages_system, packages_outdated
+        approved, approved_on
     )
 SELECT
     $1, -- id
@@ -48,30 +121,26 @@ SELECT
     $8, -- os_major
     $9, -- os_minor
     $10, -- os_build
-    $11, -- packages_choco
-    $12, -- packages_system
-    $13 -- packages_outdated
+    rt.auto_approve, -- approved from registration_tokens
+    CASE WHEN rt.auto_approve THEN CURRENT_TIMESTAMP END -- approved_on
 FROM
     registration_tokens rt
 WHERE
     rt.id = $2 -- registration token value
-RETURNING id, organization_id, public_key, label, hostname, client_version, pending_sources, pending_schedule, os_kernel, os_name, os_major, os_minor, os_build, packages_choco, packages_system, packages_outdated, packages_updated_at, connected_on, approved_on, last_seen, approved
+RETURNING id, organization_id, public_key, label, hostname, client_version, pending_sources, pending_schedule, os_kernel, os_name, os_major, os_minor, os_build, packages_updated_at, connected_on, approved_on, last_seen, approved, rejected_on, revoked_on
 `
 
 type CreateNodeParams struct {
-	ID               uuid.UUID                 `db:"id" json:"id"`
-	ID_2             uuid.UUID                 `db:"id_2" json:"id_2"`
-	PublicKey        string                    `db:"public_key" json:"public_key"`
-	Hostname         string                    `db:"hostname" json:"hostname"`
-	ClientVersion    string                    `db:"client_version" json:"client_version"`
-	OsKernel         string                    `db:"os_kernel" json:"os_kernel"`
-	OsName           string                    `db:"os_name" json:"os_name"`
-	OsMajor          int32                     `db:"os_major" json:"os_major"`
-	OsMinor          int32                     `db:"os_minor" json:"os_minor"`
-	OsBuild          int32                     `db:"os_build" json:"os_build"`
-	PackagesChoco    util.SoftwareList         `db:"packages_choco" json:"packages_choco"`
-	PackagesSystem   util.SoftwareList         `db:"packages_system" json:"packages_system"`
-	PackagesOutdated util.SoftwareOutdatedList `db:"packages_outdated" json:"packages_outdated"`
+	ID            uuid.UUID `db:"id" json:"id"`
+	ID_2          uuid.UUID `db:"id_2" json:"id_2"`
+	PublicKey     string    `db:"public_key" json:"public_key"`
+	Hostname      string    `db:"hostname" json:"hostname"`
+	ClientVersion string    `db:"client_version" json:"client_version"`
+	OsKernel      string    `db:"os_kernel" json:"os_kernel"`
+	OsName        string    `db:"os_name" json:"os_name"`
+	OsMajor       int32     `db:"os_major" json:"os_major"`
+	OsMinor       int32     `db:"os_minor" json:"os_minor"`
+	OsBuild       int32     `db:"os_build" json:"os_build"`
 }
 
 func (q *Queries) CreateNode(ctx context.Context, arg CreateNodeParams) (Node, error) {
@@ -86,9 +155,6 @@ func (q *Queries) CreateNode(ctx context.Context, arg CreateNodeParams) (Node, e
 		arg.OsMajor,
 		arg.OsMinor,
 		arg.OsBuild,
-		arg.PackagesChoco,
-		arg.PackagesSystem,
-		arg.PackagesOutdated,
 	)
 	var i Node
 	err := row.Scan(
@@ -105,20 +171,19 @@ func (q *Queries) CreateNode(ctx context.Context, arg CreateNodeParams) (Node, e
 		&i.OsMajor,
 		&i.OsMinor,
 		&i.OsBuild,
-		&i.PackagesChoco,
-		&i.PackagesSystem,
-		&i.PackagesOutdated,
 		&i.PackagesUpdatedAt,
 		&i.ConnectedOn,
 		&i.ApprovedOn,
 		&i.LastSeen,
 		&i.Approved,
+		&i.RejectedOn,
+		&i.RevokedOn,
 	)
 	return i, err
 }
 
 const getNodeByID = `-- name: GetNodeByID :one
-SELECT id, organization_id, public_key, label, hostname, client_version, pending_sources, pending_schedule, os_kernel, os_name, os_major, os_minor, os_build, packages_choco, packages_system, packages_outdated, packages_updated_at, connected_on, approved_on, last_seen, approved FROM nodes WHERE id=$1 LIMIT 1
+SELECT id, organization_id, public_key, label, hostname, client_version, pending_sources, pending_schedule, os_kernel, os_name, os_major, os_minor, os_build, packages_updated_at, connected_on, approved_on, last_seen, approved, rejected_on, revoked_on FROM nodes WHERE id=$1 LIMIT 1
 `
 
 func (q *Queries) GetNodeByID(ctx context.Context, id uuid.UUID) (Node, error) {
@@ -138,37 +203,69 @@ func (q *Queries) GetNodeByID(ctx context.Context, id uuid.UUID) (Node, error) {
 		&i.OsMajor,
 		&i.OsMinor,
 		&i.OsBuild,
-		&i.PackagesChoco,
-		&i.PackagesSystem,
-		&i.PackagesOutdated,
 		&i.PackagesUpdatedAt,
 		&i.ConnectedOn,
 		&i.ApprovedOn,
 		&i.LastSeen,
 		&i.Approved,
+		&i.RejectedOn,
+		&i.RevokedOn,
 	)
 	return i, err
 }
 
-const getNodePackages = `-- name: GetNodePackages :one
-SELECT packages_choco, packages_system, packages_outdated FROM nodes WHERE id = $1
+const getNodeCheck = `-- name: GetNodeCheck :one
+SELECT
+    pending_sources,
+    pending_schedule,
+    COALESCE(jobs.pending_jobs, 0)::int AS pending_jobs,
+    COALESCE(jobs.overdue_jobs, 0)::int AS overdue_jobs
+FROM
+    nodes
+LEFT JOIN LATERAL (
+    SELECT
+        COUNT(*) AS pending_jobs,
+        COUNT(*) FILTER (WHERE package_jobs.deadline <= CURRENT_TIMESTAMP) AS overdue_jobs
+    FROM
+        package_jobs
+    WHERE
+        package_jobs.node_id=nodes.id AND package_jobs.state='queued' AND (package_jobs.attempts < $1::int OR $1::int = 0)
+        AND (package_jobs.expires_at IS NULL OR package_jobs.expires_at > CURRENT_TIMESTAMP)
+        AND (package_jobs.not_before IS NULL OR package_jobs.not_before <= CURRENT_TIMESTAMP)
+        AND (package_jobs.retry_at IS NULL OR package_jobs.retry_at <= CURRENT_TIMESTAMP)
+) jobs ON TRUE
+WHERE
+    id=$2
+LIMIT 1
 `
 
-type GetNodePackagesRow struct {
-	PackagesChoco    util.SoftwareList         `db:"packages_choco" json:"packages_choco"`
-	PackagesSystem   util.SoftwareList         `db:"packages_system" json:"packages_system"`
-	PackagesOutdated util.SoftwareOutdatedList `db:"packages_outdated" json:"packages_outdated"`
+type GetNodeCheckParams struct {
+	AttemptsMax int32     `db:"attempts_max" json:"attempts_max"`
+	ID          uuid.UUID `db:"id" json:"id"`
+}
+
+type GetNodeCheckRow struct {
+	PendingSources  bool  `db:"pending_sources" json:"pending_sources"`
+	PendingSchedule bool  `db:"pending_schedule" json:"pending_schedule"`
+	PendingJobs     int32 `db:"pending_jobs" json:"pending_jobs"`
+	OverdueJobs     int32 `db:"overdue_jobs" json:"overdue_jobs"`
 }
 
-func (q *Queries) GetNodePackages(ctx context.Context, id uuid.UUID) (GetNodePackagesRow, error) {
-	row := q.db.QueryRow(ctx, getNodePackages, id)
-	var i GetNodePackagesRow
-	err := row.Scan(&i.PackagesChoco, &i.PackagesSystem, &i.PackagesOutdated)
+// the pending flags of a node along with the number of jobs it would receive in its job list
+func (q *Queries) GetNodeCheck(ctx context.Context, arg GetNodeCheckParams) (GetNodeCheckRow, error) {
+	row := q.db.QueryRow(ctx, getNodeCheck, arg.AttemptsMax, arg.ID)
+	var i GetNodeCheckRow
+	err := row.Scan(
+		&i.PendingSources,
+		&i.PendingSchedule,
+		&i.PendingJobs,
+		&i.OverdueJobs,
+	)
 	return i, err
 }
 
 const getNodesByOrgID = `-- name: GetNodesByOrgID :many
-SELECT id, organization_id, public_key, label, hostname, client_version, pending_sources, pending_schedule, os_kernel, os_name, os_major, os_minor, os_build, packages_choco, packages_system, packages_outdated, packages_updated_at, connected_on, approved_on, last_seen, approved FROM nodes WHERE organization_id=$1 LIMIT $2 OFFSET $3
+SELECT id, organization_id, public_key, label, hostname, client_version, pending_sources, pending_schedule, os_kernel, os_name, os_major, os_minor, os_build, packages_updated_at, connected_on, approved_on, last_seen, approved, rejected_on, revoked_on FROM nodes WHERE organization_id=$1 LIMIT $2 OFFSET $3
 `
 
 type GetNodesByOrgIDParams struct {
@@ -200,14 +297,13 @@ func (q *Queries) GetNodesByOrgID(ctx context.Context, arg GetNodesByOrgIDParams
 			&i.OsMajor,
 			&i.OsMinor,
 			&i.OsBuild,
-			&i.PackagesChoco,
-			&i.PackagesSystem,
-			&i.PackagesOutdated,
 			&i.PackagesUpdatedAt,
 			&i.ConnectedOn,
 			&i.ApprovedOn,
 			&i.LastSeen,
 			&i.Approved,
+			&i.RejectedOn,
+			&i.RevokedOn,
 		); err != nil {
 			return nil, err
 		}
@@ -219,115 +315,208 @@ func (q *Queries) GetNodesByOrgID(ctx context.Context, arg GetNodesByOrgIDParams
 	return items, nil
 }
 
-const setNodeApproval = `-- name: SetNodeApproval :exec
-UPDATE
+const getPendingNodesByOrgID = `-- name: GetPendingNodesByOrgID :many
+SELECT
+    id, organization_id, public_key, label, hostname, client_version, pending_sources, pending_schedule, os_kernel, os_name, os_major, os_minor, os_build, packages_updated_at, connected_on, approved_on, last_seen, approved, rejected_on, revoked_on
+FROM
     nodes
-SET
-    approved=$2
 WHERE
-    id=$1
+    organization_id=$1 AND approved=FALSE AND rejected_on IS NULL AND revoked_on IS NULL
+ORDER BY
+    CASE WHEN $2::text = 'DESC' THEN connected_on END DESC,
+    connected_on ASC
+LIMIT $3::int OFFSET $4::int
 `
 
-type SetNodeApprovalParams struct {
-	ID       uuid.UUID `db:"id" json:"id"`
-	Approved bool      `db:"approved" json:"approved"`
+type GetPendingNodesByOrgIDParams struct {
+	OrganizationID uuid.UUID `db:"organization_id" json:"organization_id"`
+	Sort           string    `db:"sort" json:"sort"`
+	PageLimit      int32     `db:"page_limit" json:"page_limit"`
+	PageOffset     int32     `db:"page_offset" json:"page_offset"`
 }
 
-func (q *Queries) SetNodeApproval(ctx context.Context, arg SetNodeApprovalParams) error {
-	_, err := q.db.Exec(ctx, setNodeApproval, arg.ID, arg.Approved)
-	return err
+func (q *Queries) GetPendingNodesByOrgID(ctx context.Context, arg GetPendingNodesByOrgIDParams) ([]Node, error) {
+	rows, err := q.db.Query(ctx, getPendingNodesByOrgID,
+		arg.OrganizationID,
+		arg.Sort,
+		arg.PageLimit,
+		arg.PageOffset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Node
+	for rows.Next() {
+		var i Node
+		if err := rows.Scan(
+			&i.ID,
+			&i.OrganizationID,
+			&i.PublicKey,
+			&i.Label,
+			&i.Hostname,
+			&i.ClientVersion,
+			&i.PendingSources,
+			&i.PendingSchedule,
+			&i.OsKernel,
+			&i.OsName,
+			&i.OsMajor,
+			&i.OsMinor,
+			&i.OsBuild,
+			&i.PackagesUpdatedAt,
+			&i.ConnectedOn,
+			&i.ApprovedOn,
+			&i.LastSeen,
+			&i.Approved,
+			&i.RejectedOn,
+			&i.RevokedOn,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-const updateNodePackages = `-- name: UpdateNodePackages :exec
-WITH
-    n AS (
-        SELECT
-            id, organization_id, packages_choco, packages_system, packages_outdated, packages_updated_at
-        FROM
-            nodes
-        WHERE
-            nodes.id=$1
-        FOR UPDATE
-    ),
-    i AS (
-        INSERT INTO
-            node_package_changelog(
-                node_id,
-                organization_id,
-                packages_choco,
-                packages_system,
-                packages_outdated,
-                timestamp
-            )
-        SELECT
-            id, organization_id, packages_choco, packages_system, packages_outdated, packages_updated_at
-        FROM
-            n
-        RETURNING id, node_id, organization_id, packages_choco, packages_system, packages_outdated, timestamp
-    )
-    UPDATE
-        nodes
-    SET
-        packages_updated_at=CURRENT_TIMESTAMP, packages_choco=$2, packages_system=$3, packages_outdated=$4
-    WHERE
-        id=(SELECT node_id FROM i) AND (packages_choco!=$2 OR packages_system!=$3 OR packages_outdated!=$4)
+const lockNodeByID = `-- name: LockNodeByID :one
+SELECT id, organization_id, public_key, label, hostname, client_version, pending_sources, pending_schedule, os_kernel, os_name, os_major, os_minor, os_build, packages_updated_at, connected_on, approved_on, last_seen, approved, rejected_on, revoked_on FROM nodes WHERE id=$1 LIMIT 1 FOR UPDATE
 `
 
-type UpdateNodePackagesParams struct {
-	ID               uuid.UUID                 `db:"id" json:"id"`
-	PackagesChoco    util.SoftwareList         `db:"packages_choco" json:"packages_choco"`
-	PackagesSystem   util.SoftwareList         `db:"packages_system" json:"packages_system"`
-	PackagesOutdated util.SoftwareOutdatedList `db:"packages_outdated" json:"packages_outdated"`
-}
-
-func (q *Queries) UpdateNodePackages(ctx context.Context, arg UpdateNodePackagesParams) error {
-	_, err := q.db.Exec(ctx, updateNodePackages,
-		arg.ID,
-		arg.PackagesChoco,
-		arg.PackagesSystem,
-		arg.PackagesOutdated,
+// lock the node while its inventory is replaced, so concurrent updates are applied one after the other
+func (q *Queries) LockNodeByID(ctx context.Context, id uuid.UUID) (Node, error) {
+	row := q.db.QueryRow(ctx, lockNodeByID, id)
+	var i Node
+	err := row.Scan(
+		&i.ID,
+		&i.OrganizationID,
+		&i.PublicKey,
+		&i.Label,
+		&i.Hostname,
+		&i.ClientVersion,
+		&i.PendingSources,
+		&i.PendingSchedule,
+		&i.OsKernel,
+		&i.OsName,
+		&i.OsMajor,
+		&i.OsMinor,
+		&i.OsBuild,
+		&i.PackagesUpdatedAt,
+		&i.ConnectedOn,
+		&i.ApprovedOn,
+		&i.LastSeen,
+		&i.Approved,
+		&i.RejectedOn,
+		&i.RevokedOn,
 	)
-	return err
+	return i, err
 }
 
-const updateNodePackagesChoco = `-- name: UpdateNodePackagesChoco :exec
-UPDATE nodes SET packages_choco=$2 WHERE id=$1
+const rejectNode = `-- name: RejectNode :one
+UPDATE
+    nodes
+SET
+    rejected_on=CURRENT_TIMESTAMP
+WHERE
+    id=$1 AND organization_id=$2 AND approved=FALSE AND rejected_on IS NULL AND revoked_on IS NULL
+RETURNING id, organization_id, public_key, label, hostname, client_version, pending_sources, pending_schedule, os_kernel, os_name, os_major, os_minor, os_build, packages_updated_at, connected_on, approved_on, last_seen, approved, rejected_on, revoked_on
 `
 
-type UpdateNodePackagesChocoParams struct {
-	ID            uuid.UUID         `db:"id" json:"id"`
-	PackagesChoco util.SoftwareList `db:"packages_choco" json:"packages_choco"`
+type RejectNodeParams struct {
+	ID             uuid.UUID `db:"id" json:"id"`
+	OrganizationID uuid.UUID `db:"organization_id" json:"organization_id"`
 }
 
-func (q *Queries) UpdateNodePackagesChoco(ctx context.Context, arg UpdateNodePackagesChocoParams) error {
-	_, err := q.db.Exec(ctx, updateNodePackagesChoco, arg.ID, arg.PackagesChoco)
-	return err
+// only nodes that are still pending approval can be rejected
+func (q *Queries) RejectNode(ctx context.Context, arg RejectNodeParams) (Node, error) {
+	row := q.db.QueryRow(ctx, rejectNode, arg.ID, arg.OrganizationID)
+	var i Node
+	err := row.Scan(
+		&i.ID,
+		&i.OrganizationID,
+		&i.PublicKey,
+		&i.Label,
+		&i.Hostname,
+		&i.ClientVersion,
+		&i.PendingSources,
+		&i.PendingSchedule,
+		&i.OsKernel,
+		&i.OsName,
+		&i.OsMajor,
+		&i.OsMinor,
+		&i.OsBuild,
+		&i.PackagesUpdatedAt,
+		&i.ConnectedOn,
+		&i.ApprovedOn,
+		&i.LastSeen,
+		&i.Approved,
+		&i.RejectedOn,
+		&i.RevokedOn,
+	)
+	return i, err
 }
 
-const updateNodePackagesOutdated = `-- name: UpdateNodePackagesOutdated :exec
-UPDATE nodes SET packages_outdated=$2 WHERE id=$1
+const revokeNode = `-- name: RevokeNode :one
+UPDATE
+    nodes
+SET
+    approved=FALSE,
+    revoked_on=CURRENT_TIMESTAMP
+WHERE
+    id=$1 AND organization_id=$2 AND approved=TRUE
+RETURNING id, organization_id, public_key, label, hostname, client_version, pending_sources, pending_schedule, os_kernel, os_name, os_major, os_minor, os_build, packages_updated_at, connected_on, approved_on, last_seen, approved, rejected_on, revoked_on
 `
 
-type UpdateNodePackagesOutdatedParams struct {
-	ID               uuid.UUID                 `db:"id" json:"id"`
-	PackagesOutdated util.SoftwareOutdatedList `db:"packages_outdated" json:"packages_outdated"`
+type RevokeNodeParams struct {
+	ID             uuid.UUID `db:"id" json:"id"`
+	OrganizationID uuid.UUID `db:"organization_id" json:"organization_id"`
 }
 
-func (q *Queries) UpdateNodePackagesOutdated(ctx context.Context, arg UpdateNodePackagesOutdatedParams) error {
-	_, err := q.db.Exec(ctx, updateNodePackagesOutdated, arg.ID, arg.PackagesOutdated)
-	return err
+func (q *Queries) RevokeNode(ctx context.Context, arg RevokeNodeParams) (Node, error) {
+	row := q.db.QueryRow(ctx, revokeNode, arg.ID, arg.OrganizationID)
+	var i Node
+	err := row.Scan(
+		&i.ID,
+		&i.OrganizationID,
+		&i.PublicKey,
+		&i.Label,
+		&i.Hostname,
+		&i.ClientVersion,
+		&i.PendingSources,
+		&i.PendingSchedule,
+		&i.OsKernel,
+		&i.OsName,
+		&i.OsMajor,
+		&i.OsMinor,
+		&i.OsBuild,
+		&i.PackagesUpdatedAt,
+		&i.ConnectedOn,
+		&i.ApprovedOn,
+		&i.LastSeen,
+		&i.Approved,
+		&i.RejectedOn,
+		&i.RevokedOn,
+	)
+	return i, err
 }
 
-const updateNodePackagesSystem = `-- name: UpdateNodePackagesSystem :exec
-UPDATE nodes SET packages_system=$2 WHERE id=$1
+const setNodeApproval = `-- name: SetNodeApproval :exec
+UPDATE
+    nodes
+SET
+    approved=$2
+WHERE
+    id=$1
 `
 
-type UpdateNodePackagesSystemParams struct {
-	ID             uuid.UUID         `db:"id" json:"id"`
-	PackagesSystem util.SoftwareList `db:"packages_system" json:"packages_system"`
+type SetNodeApprovalParams struct {
+	ID       uuid.UUID `db:"id" json:"id"`
+	Approved bool      `db:"approved" json:"approved"`
 }
 
-func (q *Queries) UpdateNodePackagesSystem(ctx context.Context, arg UpdateNodePackagesSystemParams) error {
-	_, err := q.db.Exec(ctx, updateNodePackagesSystem, arg.ID, arg.PackagesSystem)
+func (q *Queries) SetNodeApproval(ctx context.Context, arg SetNodeApprovalParams) error {
+	_, err := q.db.Exec(ctx, setNodeApproval, arg.ID, arg.Approved)
 	return err
 }
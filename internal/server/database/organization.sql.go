@@ -8,6 +8,8 @@ package database
 import (
 	"context"
 
+	"github.com/goodieshq/sweettooth/internal/util"
+	"github.com/goodieshq/sweettooth/pkg/api"
 	"github.com/google/uuid"
 )
 
@@ -27,6 +29,51 @@ func (q *Queries) CreateOrganization(ctx context.Context, name string) (Organiza
 	return i, err
 }
 
+const deleteOrganizationRetryPolicy = `-- name: DeleteOrganizationRetryPolicy :execrows
+DELETE FROM
+    organization_retry_policies
+WHERE
+    organization_id=$1
+`
+
+func (q *Queries) DeleteOrganizationRetryPolicy(ctx context.Context, organizationID uuid.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteOrganizationRetryPolicy, organizationID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteOrganizationSoftwareMaps = `-- name: DeleteOrganizationSoftwareMaps :execrows
+DELETE FROM
+    organization_software_maps
+WHERE
+    organization_id=$1
+`
+
+func (q *Queries) DeleteOrganizationSoftwareMaps(ctx context.Context, organizationID uuid.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteOrganizationSoftwareMaps, organizationID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteOrganizationUpgradePolicy = `-- name: DeleteOrganizationUpgradePolicy :execrows
+DELETE FROM
+    organization_upgrade_policies
+WHERE
+    organization_id=$1
+`
+
+func (q *Queries) DeleteOrganizationUpgradePolicy(ctx context.Context, organizationID uuid.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteOrganizationUpgradePolicy, organizationID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const getOrganizationByID = `-- name: GetOrganizationByID :one
 SELECT
     id, name
@@ -59,6 +106,40 @@ func (q *Queries) GetOrganizationIDFromRegistrationToken(ctx context.Context, id
 	return organization_id, err
 }
 
+const getOrganizationRetryPolicy = `-- name: GetOrganizationRetryPolicy :one
+SELECT
+    retry_policy
+FROM
+    organization_retry_policies
+WHERE
+    organization_id=$1
+LIMIT 1
+`
+
+func (q *Queries) GetOrganizationRetryPolicy(ctx context.Context, organizationID uuid.UUID) (api.RetryPolicy, error) {
+	row := q.db.QueryRow(ctx, getOrganizationRetryPolicy, organizationID)
+	var retry_policy api.RetryPolicy
+	err := row.Scan(&retry_policy)
+	return retry_policy, err
+}
+
+const getOrganizationSoftwareMaps = `-- name: GetOrganizationSoftwareMaps :one
+SELECT
+    organization_id, include_defaults, software_maps
+FROM
+    organization_software_maps
+WHERE
+    organization_id=$1
+LIMIT 1
+`
+
+func (q *Queries) GetOrganizationSoftwareMaps(ctx context.Context, organizationID uuid.UUID) (OrganizationSoftwareMap, error) {
+	row := q.db.QueryRow(ctx, getOrganizationSoftwareMaps, organizationID)
+	var i OrganizationSoftwareMap
+	err := row.Scan(&i.OrganizationID, &i.IncludeDefaults, &i.SoftwareMaps)
+	return i, err
+}
+
 const getOrganizationSummaries = `-- name: GetOrganizationSummaries :many
 SELECT
   o.id, o.name,
@@ -94,6 +175,23 @@ func (q *Queries) GetOrganizationSummaries(ctx context.Context) ([]GetOrganizati
 	return items, nil
 }
 
+const getOrganizationUpgradePolicy = `-- name: GetOrganizationUpgradePolicy :one
+SELECT
+    upgrade_policy
+FROM
+    organization_upgrade_policies
+WHERE
+    organization_id=$1
+LIMIT 1
+`
+
+func (q *Queries) GetOrganizationUpgradePolicy(ctx context.Context, organizationID uuid.UUID) (api.UpgradePolicy, error) {
+	row := q.db.QueryRow(ctx, getOrganizationUpgradePolicy, organizationID)
+	var upgrade_policy api.UpgradePolicy
+	err := row.Scan(&upgrade_policy)
+	return upgrade_policy, err
+}
+
 const getOrganizations = `-- name: GetOrganizations :many
 SELECT
     id, name
@@ -128,7 +226,7 @@ SELECT
 FROM
     registration_tokens
 WHERE
-    id=$1 AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)
+    id=$1 AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP) AND (max_uses IS NULL OR uses < max_uses)
 `
 
 func (q *Queries) GetValidRegistrationToken(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
@@ -137,3 +235,71 @@ func (q *Queries) GetValidRegistrationToken(ctx context.Context, id uuid.UUID) (
 	err := row.Scan(&organization_id)
 	return organization_id, err
 }
+
+const setOrganizationRetryPolicy = `-- name: SetOrganizationRetryPolicy :exec
+INSERT INTO
+    organization_retry_policies (
+        organization_id, retry_policy
+    )
+VALUES (
+    $1, $2
+)
+ON CONFLICT (organization_id) DO UPDATE SET
+    retry_policy=EXCLUDED.retry_policy
+`
+
+type SetOrganizationRetryPolicyParams struct {
+	OrganizationID uuid.UUID       `db:"organization_id" json:"organization_id"`
+	RetryPolicy    api.RetryPolicy `db:"retry_policy" json:"retry_policy"`
+}
+
+func (q *Queries) SetOrganizationRetryPolicy(ctx context.Context, arg SetOrganizationRetryPolicyParams) error {
+	_, err := q.db.Exec(ctx, setOrganizationRetryPolicy, arg.OrganizationID, arg.RetryPolicy)
+	return err
+}
+
+const setOrganizationSoftwareMaps = `-- name: SetOrganizationSoftwareMaps :exec
+INSERT INTO
+    organization_software_maps (
+        organization_id, include_defaults, software_maps
+    )
+VALUES (
+    $1, $2, $3
+)
+ON CONFLICT (organization_id) DO UPDATE SET
+    include_defaults=EXCLUDED.include_defaults,
+    software_maps=EXCLUDED.software_maps
+`
+
+type SetOrganizationSoftwareMapsParams struct {
+	OrganizationID  uuid.UUID            `db:"organization_id" json:"organization_id"`
+	IncludeDefaults bool                 `db:"include_defaults" json:"include_defaults"`
+	SoftwareMaps    util.SoftwareMapList `db:"software_maps" json:"software_maps"`
+}
+
+func (q *Queries) SetOrganizationSoftwareMaps(ctx context.Context, arg SetOrganizationSoftwareMapsParams) error {
+	_, err := q.db.Exec(ctx, setOrganizationSoftwareMaps, arg.OrganizationID, arg.IncludeDefaults, arg.SoftwareMaps)
+	return err
+}
+
+const setOrganizationUpgradePolicy = `-- name: SetOrganizationUpgradePolicy :exec
+INSERT INTO
+    organization_upgrade_policies (
+        organization_id, upgrade_policy
+    )
+VALUES (
+    $1, $2
+)
+ON CONFLICT (organization_id) DO UPDATE SET
+    upgrade_policy=EXCLUDED.upgrade_policy
+`
+
+type SetOrganizationUpgradePolicyParams struct {
+	OrganizationID uuid.UUID         `db:"organization_id" json:"organization_id"`
+	UpgradePolicy  api.UpgradePolicy `db:"upgrade_policy" json:"upgrade_policy"`
+}
+
+func (q *Queries) SetOrganizationUpgradePolicy(ctx context.Context, arg SetOrganizationUpgradePolicyParams) error {
+	_, err := q.db.Exec(ctx, setOrganizationUpgradePolicy, arg.OrganizationID, arg.UpgradePolicy)
+	return err
+}
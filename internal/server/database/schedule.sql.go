@@ -12,6 +12,200 @@ import (
 	"github.com/google/uuid"
 )
 
+const createGroupScheduleAssignment = `-- name: CreateGroupScheduleAssignment :exec
+INSERT INTO
+    group_schedule_assignments (
+        schedule_id, group_id, organization_id
+    )
+VALUES (
+    $1, $2, $3
+)
+ON CONFLICT DO NOTHING
+`
+
+type CreateGroupScheduleAssignmentParams struct {
+	ScheduleID     uuid.UUID `db:"schedule_id" json:"schedule_id"`
+	GroupID        uuid.UUID `db:"group_id" json:"group_id"`
+	OrganizationID uuid.UUID `db:"organization_id" json:"organization_id"`
+}
+
+func (q *Queries) CreateGroupScheduleAssignment(ctx context.Context, arg CreateGroupScheduleAssignmentParams) error {
+	_, err := q.db.Exec(ctx, createGroupScheduleAssignment, arg.ScheduleID, arg.GroupID, arg.OrganizationID)
+	return err
+}
+
+const createNodeScheduleAssignment = `-- name: CreateNodeScheduleAssignment :exec
+INSERT INTO
+    node_schedule_assignments (
+        schedule_id, node_id, organization_id
+    )
+VALUES (
+    $1, $2, $3
+)
+ON CONFLICT DO NOTHING
+`
+
+type CreateNodeScheduleAssignmentParams struct {
+	ScheduleID     uuid.UUID `db:"schedule_id" json:"schedule_id"`
+	NodeID         uuid.UUID `db:"node_id" json:"node_id"`
+	OrganizationID uuid.UUID `db:"organization_id" json:"organization_id"`
+}
+
+func (q *Queries) CreateNodeScheduleAssignment(ctx context.Context, arg CreateNodeScheduleAssignmentParams) error {
+	_, err := q.db.Exec(ctx, createNodeScheduleAssignment, arg.ScheduleID, arg.NodeID, arg.OrganizationID)
+	return err
+}
+
+const createOrganizationScheduleAssignment = `-- name: CreateOrganizationScheduleAssignment :exec
+INSERT INTO
+    organization_schedule_assignments (
+        schedule_id, organization_id
+    )
+VALUES (
+    $1, $2
+)
+ON CONFLICT DO NOTHING
+`
+
+type CreateOrganizationScheduleAssignmentParams struct {
+	ScheduleID     uuid.UUID `db:"schedule_id" json:"schedule_id"`
+	OrganizationID uuid.UUID `db:"organization_id" json:"organization_id"`
+}
+
+func (q *Queries) CreateOrganizationScheduleAssignment(ctx context.Context, arg CreateOrganizationScheduleAssignmentParams) error {
+	_, err := q.db.Exec(ctx, createOrganizationScheduleAssignment, arg.ScheduleID, arg.OrganizationID)
+	return err
+}
+
+const createSchedule = `-- name: CreateSchedule :one
+INSERT INTO
+    schedules (
+        organization_id, name, entries
+    )
+VALUES (
+    $1, $2, $3
+)
+RETURNING id, organization_id, name, entries
+`
+
+type CreateScheduleParams struct {
+	OrganizationID uuid.UUID         `db:"organization_id" json:"organization_id"`
+	Name           string            `db:"name" json:"name"`
+	Entries        schedule.Schedule `db:"entries" json:"entries"`
+}
+
+func (q *Queries) CreateSchedule(ctx context.Context, arg CreateScheduleParams) (Schedule, error) {
+	row := q.db.QueryRow(ctx, createSchedule, arg.OrganizationID, arg.Name, arg.Entries)
+	var i Schedule
+	err := row.Scan(
+		&i.ID,
+		&i.OrganizationID,
+		&i.Name,
+		&i.Entries,
+	)
+	return i, err
+}
+
+const deleteGroupScheduleAssignment = `-- name: DeleteGroupScheduleAssignment :execrows
+DELETE FROM
+    group_schedule_assignments
+WHERE
+    schedule_id=$1 AND group_id=$2 AND organization_id=$3
+`
+
+type DeleteGroupScheduleAssignmentParams struct {
+	ScheduleID     uuid.UUID `db:"schedule_id" json:"schedule_id"`
+	GroupID        uuid.UUID `db:"group_id" json:"group_id"`
+	OrganizationID uuid.UUID `db:"organization_id" json:"organization_id"`
+}
+
+func (q *Queries) DeleteGroupScheduleAssignment(ctx context.Context, arg DeleteGroupScheduleAssignmentParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteGroupScheduleAssignment, arg.ScheduleID, arg.GroupID, arg.OrganizationID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteNodeScheduleAssignment = `-- name: DeleteNodeScheduleAssignment :execrows
+DELETE FROM
+    node_schedule_assignments
+WHERE
+    schedule_id=$1 AND node_id=$2 AND organization_id=$3
+`
+
+type DeleteNodeScheduleAssignmentParams struct {
+	ScheduleID     uuid.UUID `db:"schedule_id" json:"schedule_id"`
+	NodeID         uuid.UUID `db:"node_id" json:"node_id"`
+	OrganizationID uuid.UUID `db:"organization_id" json:"organization_id"`
+}
+
+func (q *Queries) DeleteNodeScheduleAssignment(ctx context.Context, arg DeleteNodeScheduleAssignmentParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteNodeScheduleAssignment, arg.ScheduleID, arg.NodeID, arg.OrganizationID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteOrganizationScheduleAssignment = `-- name: DeleteOrganizationScheduleAssignment :execrows
+DELETE FROM
+    organization_schedule_assignments
+WHERE
+    schedule_id=$1 AND organization_id=$2
+`
+
+type DeleteOrganizationScheduleAssignmentParams struct {
+	ScheduleID     uuid.UUID `db:"schedule_id" json:"schedule_id"`
+	OrganizationID uuid.UUID `db:"organization_id" json:"organization_id"`
+}
+
+func (q *Queries) DeleteOrganizationScheduleAssignment(ctx context.Context, arg DeleteOrganizationScheduleAssignmentParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteOrganizationScheduleAssignment, arg.ScheduleID, arg.OrganizationID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteSchedule = `-- name: DeleteSchedule :execrows
+DELETE FROM
+    schedules
+WHERE
+    id=$1 AND organization_id=$2
+`
+
+type DeleteScheduleParams struct {
+	ID             uuid.UUID `db:"id" json:"id"`
+	OrganizationID uuid.UUID `db:"organization_id" json:"organization_id"`
+}
+
+func (q *Queries) DeleteSchedule(ctx context.Context, arg DeleteScheduleParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteSchedule, arg.ID, arg.OrganizationID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteScheduleAssignments = `-- name: DeleteScheduleAssignments :exec
+WITH node_assignments AS (
+    DELETE FROM node_schedule_assignments nsa WHERE nsa.schedule_id=$1
+), group_assignments AS (
+    DELETE FROM group_schedule_assignments gsa WHERE gsa.schedule_id=$1
+)
+DELETE FROM
+    organization_schedule_assignments osa
+WHERE
+    osa.schedule_id=$1
+`
+
+// remove every assignment of a schedule so the schedule itself can be deleted
+func (q *Queries) DeleteScheduleAssignments(ctx context.Context, scheduleID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, deleteScheduleAssignments, scheduleID)
+	return err
+}
+
 const getAllSchedulesByNode = `-- name: GetAllSchedulesByNode :many
 SELECT DISTINCT
     schedules.id, schedules.organization_id, schedules.name, schedules.entries,
@@ -149,6 +343,33 @@ func (q *Queries) GetCombinedScheduleByNode(ctx context.Context, nodeID uuid.UUI
 	return items, nil
 }
 
+const getSchedule = `-- name: GetSchedule :one
+SELECT
+    id, organization_id, name, entries
+FROM
+    schedules
+WHERE
+    id=$1 AND organization_id=$2
+LIMIT 1
+`
+
+type GetScheduleParams struct {
+	ID             uuid.UUID `db:"id" json:"id"`
+	OrganizationID uuid.UUID `db:"organization_id" json:"organization_id"`
+}
+
+func (q *Queries) GetSchedule(ctx context.Context, arg GetScheduleParams) (Schedule, error) {
+	row := q.db.QueryRow(ctx, getSchedule, arg.ID, arg.OrganizationID)
+	var i Schedule
+	err := row.Scan(
+		&i.ID,
+		&i.OrganizationID,
+		&i.Name,
+		&i.Entries,
+	)
+	return i, err
+}
+
 const getSchedulesByGroup = `-- name: GetSchedulesByGroup :many
 
 SELECT
@@ -186,3 +407,177 @@ func (q *Queries) GetSchedulesByGroup(ctx context.Context, groupID uuid.UUID) ([
 	}
 	return items, nil
 }
+
+const getSchedulesByOrgID = `-- name: GetSchedulesByOrgID :many
+SELECT
+    id, organization_id, name, entries
+FROM
+    schedules
+WHERE
+    organization_id=$1
+ORDER BY
+    CASE WHEN $2::text = 'DESC' THEN name END DESC,
+    name ASC
+LIMIT $3::int OFFSET $4::int
+`
+
+type GetSchedulesByOrgIDParams struct {
+	OrganizationID uuid.UUID `db:"organization_id" json:"organization_id"`
+	Sort           string    `db:"sort" json:"sort"`
+	PageLimit      int32     `db:"page_limit" json:"page_limit"`
+	PageOffset     int32     `db:"page_offset" json:"page_offset"`
+}
+
+func (q *Queries) GetSchedulesByOrgID(ctx context.Context, arg GetSchedulesByOrgIDParams) ([]Schedule, error) {
+	rows, err := q.db.Query(ctx, getSchedulesByOrgID,
+		arg.OrganizationID,
+		arg.Sort,
+		arg.PageLimit,
+		arg.PageOffset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Schedule
+	for rows.Next() {
+		var i Schedule
+		if err := rows.Scan(
+			&i.ID,
+			&i.OrganizationID,
+			&i.Name,
+			&i.Entries,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setSchedulePendingByGroupID = `-- name: SetSchedulePendingByGroupID :exec
+UPDATE
+    nodes
+SET
+    pending_schedule=TRUE
+WHERE
+    id IN (
+        SELECT nga.node_id FROM node_group_assignments nga WHERE nga.group_id=$1 AND nga.organization_id=$2
+    )
+`
+
+type SetSchedulePendingByGroupIDParams struct {
+	GroupID        uuid.UUID `db:"group_id" json:"group_id"`
+	OrganizationID uuid.UUID `db:"organization_id" json:"organization_id"`
+}
+
+func (q *Queries) SetSchedulePendingByGroupID(ctx context.Context, arg SetSchedulePendingByGroupIDParams) error {
+	_, err := q.db.Exec(ctx, setSchedulePendingByGroupID, arg.GroupID, arg.OrganizationID)
+	return err
+}
+
+const setSchedulePendingByNodeID = `-- name: SetSchedulePendingByNodeID :exec
+UPDATE
+    nodes
+SET
+    pending_schedule=TRUE
+WHERE
+    id=$1 AND organization_id=$2
+`
+
+type SetSchedulePendingByNodeIDParams struct {
+	ID             uuid.UUID `db:"id" json:"id"`
+	OrganizationID uuid.UUID `db:"organization_id" json:"organization_id"`
+}
+
+func (q *Queries) SetSchedulePendingByNodeID(ctx context.Context, arg SetSchedulePendingByNodeIDParams) error {
+	_, err := q.db.Exec(ctx, setSchedulePendingByNodeID, arg.ID, arg.OrganizationID)
+	return err
+}
+
+const setSchedulePendingByOrgID = `-- name: SetSchedulePendingByOrgID :exec
+UPDATE
+    nodes
+SET
+    pending_schedule=TRUE
+WHERE
+    organization_id=$1
+`
+
+func (q *Queries) SetSchedulePendingByOrgID(ctx context.Context, organizationID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, setSchedulePendingByOrgID, organizationID)
+	return err
+}
+
+const setSchedulePendingByScheduleID = `-- name: SetSchedulePendingByScheduleID :exec
+UPDATE
+    nodes
+SET
+    pending_schedule=TRUE
+WHERE
+    nodes.organization_id=$1 AND (
+        nodes.id IN (
+            SELECT nsa.node_id FROM node_schedule_assignments nsa WHERE nsa.schedule_id=$2
+        ) OR nodes.id IN (
+            SELECT
+                nga.node_id
+            FROM
+                node_group_assignments nga
+            JOIN
+                group_schedule_assignments gsa ON gsa.group_id = nga.group_id
+            WHERE
+                gsa.schedule_id=$2
+        ) OR EXISTS (
+            SELECT 1 FROM organization_schedule_assignments osa WHERE osa.schedule_id=$2 AND osa.organization_id=nodes.organization_id
+        )
+    )
+`
+
+type SetSchedulePendingByScheduleIDParams struct {
+	OrganizationID uuid.UUID `db:"organization_id" json:"organization_id"`
+	ScheduleID     uuid.UUID `db:"schedule_id" json:"schedule_id"`
+}
+
+// flag every node which inherits the schedule through any of its assignments
+func (q *Queries) SetSchedulePendingByScheduleID(ctx context.Context, arg SetSchedulePendingByScheduleIDParams) error {
+	_, err := q.db.Exec(ctx, setSchedulePendingByScheduleID, arg.OrganizationID, arg.ScheduleID)
+	return err
+}
+
+const updateSchedule = `-- name: UpdateSchedule :one
+UPDATE
+    schedules
+SET
+    name=$3,
+    entries=$4
+WHERE
+    id=$1 AND organization_id=$2
+RETURNING id, organization_id, name, entries
+`
+
+type UpdateScheduleParams struct {
+	ID             uuid.UUID         `db:"id" json:"id"`
+	OrganizationID uuid.UUID         `db:"organization_id" json:"organization_id"`
+	Name           string            `db:"name" json:"name"`
+	Entries        schedule.Schedule `db:"entries" json:"entries"`
+}
+
+func (q *Queries) UpdateSchedule(ctx context.Context, arg UpdateScheduleParams) (Schedule, error) {
+	row := q.db.QueryRow(ctx, updateSchedule,
+		arg.ID,
+		arg.OrganizationID,
+		arg.Name,
+		arg.Entries,
+	)
+	var i Schedule
+	err := row.Scan(
+		&i.ID,
+		&i.OrganizationID,
+		&i.Name,
+		&i.Entries,
+	)
+	return i, err
+}
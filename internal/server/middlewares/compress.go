@@ -0,0 +1,52 @@
+package middlewares
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/goodieshq/sweettooth/internal/server/responses"
+)
+
+// the most a gzip-compressed request body may decompress to, well above the largest node inventory
+const GZIP_MAX_DECOMPRESSED_BYTES = 32 << 20
+
+// compress JSON responses for clients which accept gzip
+var compressJSON = middleware.Compress(gzip.DefaultCompression, "application/json")
+
+// Middleware to accept gzip-compressed request bodies and gzip-compress responses when the client accepts it
+func MiddlewareGzip(next http.Handler) http.Handler {
+	return compressJSON(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			responses.ErrInvalidRequestBody(w, r, err)
+			return
+		}
+		defer gz.Close()
+
+		// handlers read the decompressed body as if it was sent uncompressed, reading past the limit fails so a small
+		// body cannot decompress into an unbounded one
+		r.Body = http.MaxBytesReader(w, gzipBody{Reader: gz, body: r.Body}, GZIP_MAX_DECOMPRESSED_BYTES)
+		r.Header.Del("Content-Encoding")
+		r.ContentLength = -1
+		next.ServeHTTP(w, r)
+	}))
+}
+
+// a decompressed request body which closes the original body
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (gb gzipBody) Close() error {
+	gb.Reader.Close()
+	return gb.body.Close()
+}
@@ -79,3 +79,8 @@ func (w *logResponseWriter) WriteHeader(statusCode int) {
 	w.statusCode = statusCode // save the status code before writing
 	w.w.WriteHeader(statusCode)
 }
+
+// allow http.ResponseController to reach the underlying writer (e.g. to flush server-sent events)
+func (w *logResponseWriter) Unwrap() http.ResponseWriter {
+	return w.w
+}
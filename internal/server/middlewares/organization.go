@@ -43,9 +43,6 @@ func MiddlewareOrganizationNode(next http.Handler) http.Handler {
 
 // handler middleware to set a minimum role for interaction
 func OrgRoleMinimum(handler http.HandlerFunc, roleMinimum roles.OrgRole) http.HandlerFunc {
-	if DEV_BYPASS_WEBAUTH {
-		return handler
-	}
 	return func(w http.ResponseWriter, r *http.Request) {
 		if !requests.IsSuperAdmin(r) {
 			orgid := requests.Oid(r)
@@ -60,7 +57,7 @@ func OrgRoleMinimum(handler http.HandlerFunc, roleMinimum roles.OrgRole) http.Ha
 				return
 			}
 
-			if requests.ORoles(r).GetRole(*orgid) <= roleMinimum {
+			if orgRoles.GetRole(*orgid) < roleMinimum {
 				responses.ErrForbidden(w, r, nil)
 				return
 			}
@@ -12,8 +12,8 @@ import (
 )
 
 const (
-	DEFAULT_ATTEMPTS_MAX = 5   //
-	LIMIT_ATTEMPTS_MAX   = 100 // set a maximum limit of 100 attempts
+	LIMIT_ATTEMPTS_MAX          = 100     // set a maximum limit of 100 attempts
+	LIMIT_RETRY_BACKOFF_SECONDS = 2592000 // never back off a retry for more than 30 days
 )
 
 type RequestState struct {
@@ -30,7 +30,7 @@ func (rs *RequestState) IsNodeRequest() bool {
 
 type ContextKey string
 
-// get the query parameter of attempts max
+// get the query parameter of attempts max, 0 when absent so the retry policy of each job decides when it stops
 func RequestQueryAttemptsMax(r *http.Request) int {
 	var attemptsMax int = 0
 
@@ -48,10 +48,7 @@ func RequestQueryAttemptsMax(r *http.Request) int {
 		}
 	}
 
-	if attemptsMax == 0 {
-		// default or invalid attepmts_max value set to a sane default
-		attemptsMax = DEFAULT_ATTEMPTS_MAX
-	} else if attemptsMax < 0 {
+	if attemptsMax < 0 {
 		// if any negative value was provided, set it to -1
 		attemptsMax = -1
 	}
@@ -59,6 +56,12 @@ func RequestQueryAttemptsMax(r *http.Request) int {
 	return attemptsMax
 }
 
+// get the query parameter limiting a job list to jobs past their deadline
+func RequestQueryOverdue(r *http.Request) bool {
+	overdue, err := strconv.ParseBool(r.URL.Query().Get("overdue"))
+	return err == nil && overdue
+}
+
 func NodeNID(r *http.Request) *uuid.UUID {
 	if state := State(r); state != nil {
 		return state.NodeID
@@ -71,7 +74,10 @@ func Nid(r *http.Request) *uuid.UUID {
 }
 
 func Uid(r *http.Request) *uuid.UUID {
-	return r.Context().Value(ContextKey("userid")).(*uuid.UUID)
+	if userid, ok := r.Context().Value(ContextKey("userid")).(*uuid.UUID); ok {
+		return userid
+	}
+	return nil
 }
 
 func Oid(r *http.Request) *uuid.UUID {
@@ -96,7 +102,7 @@ func WithRequestState(r *http.Request, state *RequestState) *http.Request {
 
 // request coming from a super admin
 func IsSuperAdmin(r *http.Request) bool {
-	if superadmin, ok := r.Context().Value("superadmin").(bool); ok {
+	if superadmin, ok := r.Context().Value(ContextKey("superadmin")).(bool); ok {
 		return superadmin
 	}
 	return false
@@ -104,12 +110,18 @@ func IsSuperAdmin(r *http.Request) bool {
 
 // request organization roles (web auth)
 func ORoles(r *http.Request) *roles.OrgRoles {
-	return r.Context().Value("orgroles").(*roles.OrgRoles)
+	if orgRoles, ok := r.Context().Value(ContextKey("orgroles")).(*roles.OrgRoles); ok {
+		return orgRoles
+	}
+	return nil
 }
 
 // request pagination value
 func Paging(r *http.Request) *api.Pagination {
-	return r.Context().Value("pagination").(*api.Pagination)
+	if pagination, ok := r.Context().Value(ContextKey("pagination")).(*api.Pagination); ok {
+		return pagination
+	}
+	return api.DefaultPagination()
 }
 
 func Err(r *http.Request) error {
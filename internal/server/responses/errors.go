@@ -27,24 +27,70 @@ func CreateJsonErr(status int, defaultMessage string) func(http.ResponseWriter,
 	}
 }
 
+// a request body which could not be read or decoded, or is too large when it is read past its size limit
+func ErrInvalidRequestBody(w http.ResponseWriter, r *http.Request, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		ErrRequestTooLarge(w, r, err)
+		return
+	}
+	errInvalidRequestBody(w, r, err)
+}
+
 // JSON Errors
 var ErrRegistrationTokenInvalid = CreateJsonErr(http.StatusUnauthorized, "the registration token is not found or is expired")
 var ErrNodeTokenInvalid = CreateJsonErr(http.StatusUnauthorized, "the token is invalid or exired")
+var ErrWebTokenInvalid = CreateJsonErr(http.StatusUnauthorized, "the web token is invalid or expired")
 var ErrNodeUnauthorized = CreateJsonErr(http.StatusUnauthorized, "the token is not authorized")
 var ErrNodeNotApproved = CreateJsonErr(http.StatusForbidden, "the node is not approved")
+var ErrNodeApprovalConflict = CreateJsonErr(http.StatusConflict, "the node's approval state does not allow this change")
 var ErrNodeNotFound = CreateJsonErr(http.StatusNotFound, "the node ID is not found")
+var ErrNodePackagesConflict = CreateJsonErr(http.StatusConflict, "the package delta does not apply to the server's inventory")
 var ErrOrgNotFound = CreateJsonErr(http.StatusNotFound, "the organization is not found")
 var ErrInvalidPagination = CreateJsonErr(http.StatusBadRequest, "invalid pagination parameters")
-var ErrInvalidRequestBody = CreateJsonErr(http.StatusBadRequest, "invalid request payload")
+var errInvalidRequestBody = CreateJsonErr(http.StatusBadRequest, "invalid request payload")
+var ErrRequestTooLarge = CreateJsonErr(http.StatusRequestEntityTooLarge, "the request payload is too large")
 var ErrServiceUnavailable = CreateJsonErr(http.StatusServiceUnavailable, "service unavailable")
 var ErrForbidden = CreateJsonErr(http.StatusForbidden, "insufficient privileges")
 var ErrFormFailure = CreateJsonErr(http.StatusUnauthorized, "form submission failed")
 var ErrLoginError = CreateJsonErr(http.StatusUnauthorized, "login failed")
+var ErrUnauthorized = CreateJsonErr(http.StatusUnauthorized, "the request is not authenticated")
+var ErrMfaAlreadyEnabled = CreateJsonErr(http.StatusConflict, "multi-factor authentication is already enabled")
+var ErrMfaInvalidCode = CreateJsonErr(http.StatusUnprocessableEntity, "the multi-factor authentication code is invalid")
 var ErrServerError = CreateJsonErr(http.StatusInternalServerError, "internal server error")
 var ErrInvalidOrgID = CreateJsonErr(http.StatusUnprocessableEntity, "the organization ID provided is invalid")
 var ErrInvalidOrgRoles = CreateJsonErr(http.StatusUnprocessableEntity, "the organization roles provided are invalid")
 var ErrInvalidNodeID = CreateJsonErr(http.StatusUnprocessableEntity, "the node ID provided is invalid")
+var ErrInvalidGroupID = CreateJsonErr(http.StatusUnprocessableEntity, "the group ID provided is invalid")
+var ErrGroupNotFound = CreateJsonErr(http.StatusNotFound, "the group ID is not found")
+var ErrInvalidRegistrationTokenID = CreateJsonErr(http.StatusUnprocessableEntity, "the registration token ID provided is invalid")
+var ErrRegistrationTokenNotFound = CreateJsonErr(http.StatusNotFound, "the registration token is not found")
+var ErrRegistrationTokenConflict = CreateJsonErr(http.StatusConflict, "a registration token with this name already exists")
+var ErrInvalidScheduleID = CreateJsonErr(http.StatusUnprocessableEntity, "the schedule ID provided is invalid")
+var ErrInvalidSchedule = CreateJsonErr(http.StatusUnprocessableEntity, "the schedule contains an invalid rrule or time range")
+var ErrInvalidSchedulePreview = CreateJsonErr(http.StatusBadRequest, "invalid schedule preview range or count")
+var ErrInvalidPackageHistory = CreateJsonErr(http.StatusBadRequest, "invalid package history range or days")
+var ErrInvalidPackageSearch = CreateJsonErr(http.StatusBadRequest, "invalid package search name, match, source, version or op")
+var ErrScheduleNotFound = CreateJsonErr(http.StatusNotFound, "the schedule is not found")
+var ErrScheduleConflict = CreateJsonErr(http.StatusConflict, "a schedule with this name already exists")
+var ErrScheduleTargetNotFound = CreateJsonErr(http.StatusNotFound, "the schedule, node, or group is not found in the organization")
+var ErrScheduleAssignmentNotFound = CreateJsonErr(http.StatusNotFound, "the schedule is not assigned to this target")
+var ErrInvalidBaselineID = CreateJsonErr(http.StatusUnprocessableEntity, "the baseline ID provided is invalid")
+var ErrInvalidBaseline = CreateJsonErr(http.StatusUnprocessableEntity, "the baseline contains an invalid entry")
+var ErrBaselineNotFound = CreateJsonErr(http.StatusNotFound, "the baseline is not found")
+var ErrBaselineConflict = CreateJsonErr(http.StatusConflict, "a baseline with this name already exists")
+var ErrBaselineTargetNotFound = CreateJsonErr(http.StatusNotFound, "the baseline, node, or group is not found in the organization")
+var ErrBaselineAssignmentNotFound = CreateJsonErr(http.StatusNotFound, "the baseline is not assigned to this target")
+var ErrInvalidSourceID = CreateJsonErr(http.StatusUnprocessableEntity, "the source ID provided is invalid")
+var ErrSourceEntryNotFound = CreateJsonErr(http.StatusNotFound, "the source or its entry is not found")
+var ErrSourceCredentialsNotFound = CreateJsonErr(http.StatusNotFound, "the source entry has no credentials")
 var ErrInvalidJobID = CreateJsonErr(http.StatusUnprocessableEntity, "the job ID provided is invalid")
 var ErrJobMissingOrExpired = CreateJsonErr(http.StatusNotFound, "this job ID is missing, expired, deleted, or has reached the attempt limit.")
 var ErrJobAlreadyCompleted = CreateJsonErr(http.StatusConflict, "this job ID has already been completed")
+var ErrJobNotFound = CreateJsonErr(http.StatusNotFound, "the job ID is not found")
+var ErrJobNotPending = CreateJsonErr(http.StatusConflict, "this job ID is no longer pending")
+var ErrRetryPolicyNotFound = CreateJsonErr(http.StatusNotFound, "the organization uses the default retry policy")
+var ErrUpgradePolicyNotFound = CreateJsonErr(http.StatusNotFound, "no upgrade policy is set")
+var ErrSoftwareMapsNotFound = CreateJsonErr(http.StatusNotFound, "the organization only uses the default software maps")
+var ErrInvalidSoftwareMap = CreateJsonErr(http.StatusUnprocessableEntity, "the software maps contain an invalid entry")
 var ErrDatabaseError = CreateJsonErr(http.StatusInternalServerError, "failed to connect to the database")
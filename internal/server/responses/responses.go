@@ -2,6 +2,7 @@ package responses
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 )
 
@@ -13,3 +14,13 @@ func JsonResponse(w http.ResponseWriter, r *http.Request, status int, data inter
 		}
 	}
 }
+
+// write a single server-sent event with a JSON payload, the caller is responsible for flushing
+func EventResponse(w http.ResponseWriter, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+	return err
+}
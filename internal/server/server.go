@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"time"
@@ -29,6 +30,9 @@ type SweetToothServerConfig struct {
 	Host      string        // local address to listen on (default :: or 0.0.0.0)
 	Port      uint16        // local port to listen on (default 7777)
 	Secret    []byte        // used for JWT HMAC creation/validation for web interactions
+	SecretKey []byte        // used to encrypt the source credentials and package secrets stored in the database
+
+	DevBypassWebAuth bool // treat every web request as a super admin without a token (development only)
 }
 
 type SweetToothServer struct {
@@ -77,6 +81,9 @@ func NewSweetToothServer(config *SweetToothServerConfig, core core.Core) (*Sweet
 func (srv *SweetToothServer) ApiNodeHandlers(routerNode chi.Router) {
 	handlerNode := apinode.NewApiNodeHandler(srv.cache, srv.core)
 
+	// nodes may gzip-compress their request bodies and always accept gzip-compressed responses
+	routerNode.Use(middlewares.MiddlewareGzip)
+
 	// Unauthorized endpoints do not require JWT tokens to be passed, e.g. for registering a new node
 	routerNode.Group(func(routerNodeUnauthorized chi.Router) {
 		// register a new node
@@ -89,19 +96,28 @@ func (srv *SweetToothServer) ApiNodeHandlers(routerNode chi.Router) {
 		routerNodeAuthorized.Use(
 			middlewares.MiddlewareAuthNode(srv.core, srv.cache),
 		)
-		// the simplest API endpoint: returns 204 on success. Use it to verify JWT auth.
+		// the simplest API endpoint: verifies JWT auth and returns the state the node should acquire again
 		routerNodeAuthorized.Get("/check", handlerNode.HandleGetNodeCheck)
 		// node should acquire a single array of all schedule entries that apply to it (assigned to node, group, etc...)
 		routerNodeAuthorized.Get("/schedule", handlerNode.HandleGetNodeSchedule) // acquire the combined schedule entries for this node
+		routerNodeAuthorized.Put("/schedule", handlerNode.HandlePutNodeSchedule) // confirm the schedule entries have been applied
 		// node can query or update the server's inventory of its packages
 		routerNodeAuthorized.Get("/packages", handlerNode.HandleGetNodePackages)
 		routerNodeAuthorized.Put("/packages", handlerNode.HandlePutNodePackages)
+		// node compares the hash of the server's inventory to its own, then sends a signed delta against that hash
+		routerNodeAuthorized.Get("/packages/hash", handlerNode.HandleGetNodePackagesHash)
+		routerNodeAuthorized.Patch("/packages", handlerNode.HandlePatchNodePackages)
+		// node acquires its effective chocolatey sources and reports the sources it actually has configured
+		routerNodeAuthorized.Get("/sources", handlerNode.HandleGetNodeSources)
+		routerNodeAuthorized.Put("/sources", handlerNode.HandlePutNodeSources)
 		// list package jobs
 		routerNodeAuthorized.Get("/packages/jobs", handlerNode.HandleGetNodePackagesJobs)
 		routerNodeAuthorized.Get("/packages/jobs/", handlerNode.HandleGetNodePackagesJobs)
-		// get details of a specific job (counts as an attempt as the details should only be acquired when ready to )
+		// get details of a specific job (leases the job as the details should only be acquired when ready to run it)
 		routerNodeAuthorized.Get("/packages/jobs/{id}", handlerNode.HandleGetNodePackagesJob)
 		routerNodeAuthorized.Post("/packages/jobs/{id}", handlerNode.HandlePostNodePackagesJob)
+		// stream the output of a running package job as a heartbeat for its lease, the response indicates if the job was cancelled
+		routerNodeAuthorized.Post("/packages/jobs/{id}/output", handlerNode.HandlePostNodePackagesJobOutput)
 	})
 }
 
@@ -109,11 +125,12 @@ func (srv *SweetToothServer) ApiWebHandlers(routerWeb chi.Router) {
 	handlerWeb := apiweb.NewApiNodeHandler(srv.cache, srv.core)
 	routerWeb.Group(func(routerWebUnauthorized chi.Router) {
 		routerWebUnauthorized.Post("/login", handlerWeb.HandlePostWebLogin(srv.config.Secret))
+		routerWebUnauthorized.Post("/login/mfa", handlerWeb.HandlePostWebLoginMfa(srv.config.Secret))
 	})
 
 	routerWeb.Group(func(routerWebAuthorized chi.Router) {
 		routerWebAuthorized.Use(
-			middlewares.MiddlewareAuthWeb(srv.core, srv.cache, srv.config.Secret),
+			middlewares.MiddlewareAuthWeb(srv.core, srv.cache, srv.config.Secret, srv.config.DevBypassWebAuth),
 			middlewares.MiddlewarePaginate,
 		)
 
@@ -121,6 +138,11 @@ func (srv *SweetToothServer) ApiWebHandlers(routerWeb chi.Router) {
 		routerWebAuthorized.Get("/organizations", handlerWeb.HandleGetWebOrganizations)
 		routerWebAuthorized.Get("/organizations_summary", handlerWeb.HandleGetWebOrganizationSummaries)
 
+		// POST /api/v1/web/mfa
+		routerWebAuthorized.Post("/mfa", handlerWeb.HandlePostWebMfa)
+		// POST /api/v1/web/mfa/verify
+		routerWebAuthorized.Post("/mfa/verify", handlerWeb.HandlePostWebMfaVerify)
+
 		routerWebAuthorized.Route("/organizations/{orgid}", func(routerOrg chi.Router) {
 			routerOrg.Use(
 				// Every request context will have an org ID extracted from the URL
@@ -133,11 +155,396 @@ func (srv *SweetToothServer) ApiWebHandlers(routerWeb chi.Router) {
 				middlewares.OrgRoleMinimum(handlerWeb.HandleGetWebOrganization, roles.READER),
 			)
 
+			// GET /api/v1/web/organizations/{orgid}/retry_policy
+			routerOrg.Get(
+				"/retry_policy",
+				middlewares.OrgRoleMinimum(handlerWeb.HandleGetWebOrganizationRetryPolicy, roles.OPERATOR),
+			)
+
+			// PUT /api/v1/web/organizations/{orgid}/retry_policy
+			routerOrg.Put(
+				"/retry_policy",
+				middlewares.OrgRoleMinimum(handlerWeb.HandlePutWebOrganizationRetryPolicy, roles.MANAGER),
+			)
+
+			// DELETE /api/v1/web/organizations/{orgid}/retry_policy
+			routerOrg.Delete(
+				"/retry_policy",
+				middlewares.OrgRoleMinimum(handlerWeb.HandleDeleteWebOrganizationRetryPolicy, roles.MANAGER),
+			)
+
+			// GET /api/v1/web/organizations/{orgid}/upgrade_policy
+			routerOrg.Get(
+				"/upgrade_policy",
+				middlewares.OrgRoleMinimum(handlerWeb.HandleGetWebOrganizationUpgradePolicy, roles.OPERATOR),
+			)
+
+			// PUT /api/v1/web/organizations/{orgid}/upgrade_policy
+			routerOrg.Put(
+				"/upgrade_policy",
+				middlewares.OrgRoleMinimum(handlerWeb.HandlePutWebOrganizationUpgradePolicy, roles.MANAGER),
+			)
+
+			// DELETE /api/v1/web/organizations/{orgid}/upgrade_policy
+			routerOrg.Delete(
+				"/upgrade_policy",
+				middlewares.OrgRoleMinimum(handlerWeb.HandleDeleteWebOrganizationUpgradePolicy, roles.MANAGER),
+			)
+
+			// GET /api/v1/web/organizations/{orgid}/software_maps
+			routerOrg.Get(
+				"/software_maps",
+				middlewares.OrgRoleMinimum(handlerWeb.HandleGetWebOrganizationSoftwareMaps, roles.READER),
+			)
+
+			// GET /api/v1/web/organizations/{orgid}/software_maps/defaults
+			routerOrg.Get(
+				"/software_maps/defaults",
+				middlewares.OrgRoleMinimum(handlerWeb.HandleGetWebOrganizationSoftwareMapsDefaults, roles.READER),
+			)
+
+			// PUT /api/v1/web/organizations/{orgid}/software_maps
+			routerOrg.Put(
+				"/software_maps",
+				middlewares.OrgRoleMinimum(handlerWeb.HandlePutWebOrganizationSoftwareMaps, roles.MANAGER),
+			)
+
+			// DELETE /api/v1/web/organizations/{orgid}/software_maps
+			routerOrg.Delete(
+				"/software_maps",
+				middlewares.OrgRoleMinimum(handlerWeb.HandleDeleteWebOrganizationSoftwareMaps, roles.MANAGER),
+			)
+
+			// GET /api/v1/web/organizations/{orgid}/packages/changes
+			routerOrg.Get(
+				"/packages/changes",
+				middlewares.OrgRoleMinimum(handlerWeb.HandleGetWebOrganizationPackageChanges, roles.READER),
+			)
+
+			// GET /api/v1/web/organizations/{orgid}/packages/search
+			routerOrg.Get(
+				"/packages/search",
+				middlewares.OrgRoleMinimum(handlerWeb.HandleGetWebOrganizationPackageSearch, roles.READER),
+			)
+
 			// GET /api/v1/web/organizations/{orgid}/nodes
 			routerOrg.Get(
 				"/nodes",
 				middlewares.OrgRoleMinimum(handlerWeb.HandleGetWebOrganizationNodes, roles.READER),
 			)
+
+			// GET /api/v1/web/organizations/{orgid}/nodes/pending
+			routerOrg.Get(
+				"/nodes/pending",
+				middlewares.OrgRoleMinimum(handlerWeb.HandleGetWebOrganizationNodesPending, roles.APPROVER),
+			)
+
+			routerOrg.Route("/nodes/{nodeid}", func(routerNode chi.Router) {
+				routerNode.Use(
+					// Every request context will have a node ID extracted from the URL
+					middlewares.MiddlewareOrganizationNode,
+				)
+
+				// GET /api/v1/web/organizations/{orgid}/nodes/{nodeid}/schedule/preview
+				routerNode.Get(
+					"/schedule/preview",
+					middlewares.OrgRoleMinimum(handlerWeb.HandleGetWebOrganizationNodeSchedulePreview, roles.READER),
+				)
+
+				// GET /api/v1/web/organizations/{orgid}/nodes/{nodeid}/compliance
+				routerNode.Get(
+					"/compliance",
+					middlewares.OrgRoleMinimum(handlerWeb.HandleGetWebOrganizationNodeCompliance, roles.READER),
+				)
+
+				// GET /api/v1/web/organizations/{orgid}/nodes/{nodeid}/sources
+				routerNode.Get(
+					"/sources",
+					middlewares.OrgRoleMinimum(handlerWeb.HandleGetWebOrganizationNodeSources, roles.READER),
+				)
+
+				// GET /api/v1/web/organizations/{orgid}/nodes/{nodeid}/packages/history
+				routerNode.Get(
+					"/packages/history",
+					middlewares.OrgRoleMinimum(handlerWeb.HandleGetWebOrganizationNodePackageHistory, roles.READER),
+				)
+
+				// POST /api/v1/web/organizations/{orgid}/nodes/{nodeid}/approve
+				routerNode.Post(
+					"/approve",
+					middlewares.OrgRoleMinimum(handlerWeb.HandlePostWebOrganizationNodeApprove, roles.APPROVER),
+				)
+
+				// POST /api/v1/web/organizations/{orgid}/nodes/{nodeid}/reject
+				routerNode.Post(
+					"/reject",
+					middlewares.OrgRoleMinimum(handlerWeb.HandlePostWebOrganizationNodeReject, roles.APPROVER),
+				)
+
+				// POST /api/v1/web/organizations/{orgid}/nodes/{nodeid}/revoke
+				routerNode.Post(
+					"/revoke",
+					middlewares.OrgRoleMinimum(handlerWeb.HandlePostWebOrganizationNodeRevoke, roles.APPROVER),
+				)
+
+				// POST /api/v1/web/organizations/{orgid}/nodes/{nodeid}/upgrade
+				routerNode.Post(
+					"/upgrade",
+					middlewares.OrgRoleMinimum(handlerWeb.HandlePostWebOrganizationNodeUpgrade, roles.OPERATOR),
+				)
+
+				// GET /api/v1/web/organizations/{orgid}/nodes/{nodeid}/adoptable
+				routerNode.Get(
+					"/adoptable",
+					middlewares.OrgRoleMinimum(handlerWeb.HandleGetWebOrganizationNodeAdoptable, roles.READER),
+				)
+
+				// POST /api/v1/web/organizations/{orgid}/nodes/{nodeid}/adopt
+				routerNode.Post(
+					"/adopt",
+					middlewares.OrgRoleMinimum(handlerWeb.HandlePostWebOrganizationNodeAdopt, roles.OPERATOR),
+				)
+			})
+
+			// GET /api/v1/web/organizations/{orgid}/registration_tokens
+			routerOrg.Get(
+				"/registration_tokens",
+				middlewares.OrgRoleMinimum(handlerWeb.HandleGetWebOrganizationRegistrationTokens, roles.MANAGER),
+			)
+
+			// POST /api/v1/web/organizations/{orgid}/registration_tokens
+			routerOrg.Post(
+				"/registration_tokens",
+				middlewares.OrgRoleMinimum(handlerWeb.HandlePostWebOrganizationRegistrationToken, roles.MANAGER),
+			)
+
+			// GET /api/v1/web/organizations/{orgid}/registration_tokens/{tokenid}
+			routerOrg.Get(
+				"/registration_tokens/{tokenid}",
+				middlewares.OrgRoleMinimum(handlerWeb.HandleGetWebOrganizationRegistrationToken, roles.MANAGER),
+			)
+
+			// PUT /api/v1/web/organizations/{orgid}/registration_tokens/{tokenid}
+			routerOrg.Put(
+				"/registration_tokens/{tokenid}",
+				middlewares.OrgRoleMinimum(handlerWeb.HandlePutWebOrganizationRegistrationToken, roles.MANAGER),
+			)
+
+			// DELETE /api/v1/web/organizations/{orgid}/registration_tokens/{tokenid}
+			routerOrg.Delete(
+				"/registration_tokens/{tokenid}",
+				middlewares.OrgRoleMinimum(handlerWeb.HandleDeleteWebOrganizationRegistrationToken, roles.MANAGER),
+			)
+
+			// GET /api/v1/web/organizations/{orgid}/schedules
+			routerOrg.Get(
+				"/schedules",
+				middlewares.OrgRoleMinimum(handlerWeb.HandleGetWebOrganizationSchedules, roles.MANAGER),
+			)
+
+			// POST /api/v1/web/organizations/{orgid}/schedules
+			routerOrg.Post(
+				"/schedules",
+				middlewares.OrgRoleMinimum(handlerWeb.HandlePostWebOrganizationSchedule, roles.MANAGER),
+			)
+
+			// GET /api/v1/web/organizations/{orgid}/schedules/{scheduleid}
+			routerOrg.Get(
+				"/schedules/{scheduleid}",
+				middlewares.OrgRoleMinimum(handlerWeb.HandleGetWebOrganizationSchedule, roles.MANAGER),
+			)
+
+			// PUT /api/v1/web/organizations/{orgid}/schedules/{scheduleid}
+			routerOrg.Put(
+				"/schedules/{scheduleid}",
+				middlewares.OrgRoleMinimum(handlerWeb.HandlePutWebOrganizationSchedule, roles.MANAGER),
+			)
+
+			// DELETE /api/v1/web/organizations/{orgid}/schedules/{scheduleid}
+			routerOrg.Delete(
+				"/schedules/{scheduleid}",
+				middlewares.OrgRoleMinimum(handlerWeb.HandleDeleteWebOrganizationSchedule, roles.MANAGER),
+			)
+
+			// PUT /api/v1/web/organizations/{orgid}/schedules/{scheduleid}/nodes/{nodeid}
+			routerOrg.Put(
+				"/schedules/{scheduleid}/nodes/{nodeid}",
+				middlewares.OrgRoleMinimum(handlerWeb.HandlePutWebOrganizationScheduleNode, roles.MANAGER),
+			)
+
+			// DELETE /api/v1/web/organizations/{orgid}/schedules/{scheduleid}/nodes/{nodeid}
+			routerOrg.Delete(
+				"/schedules/{scheduleid}/nodes/{nodeid}",
+				middlewares.OrgRoleMinimum(handlerWeb.HandleDeleteWebOrganizationScheduleNode, roles.MANAGER),
+			)
+
+			// PUT /api/v1/web/organizations/{orgid}/schedules/{scheduleid}/groups/{groupid}
+			routerOrg.Put(
+				"/schedules/{scheduleid}/groups/{groupid}",
+				middlewares.OrgRoleMinimum(handlerWeb.HandlePutWebOrganizationScheduleGroup, roles.MANAGER),
+			)
+
+			// DELETE /api/v1/web/organizations/{orgid}/schedules/{scheduleid}/groups/{groupid}
+			routerOrg.Delete(
+				"/schedules/{scheduleid}/groups/{groupid}",
+				middlewares.OrgRoleMinimum(handlerWeb.HandleDeleteWebOrganizationScheduleGroup, roles.MANAGER),
+			)
+
+			// PUT /api/v1/web/organizations/{orgid}/schedules/{scheduleid}/organization
+			routerOrg.Put(
+				"/schedules/{scheduleid}/organization",
+				middlewares.OrgRoleMinimum(handlerWeb.HandlePutWebOrganizationScheduleOrganization, roles.MANAGER),
+			)
+
+			// DELETE /api/v1/web/organizations/{orgid}/schedules/{scheduleid}/organization
+			routerOrg.Delete(
+				"/schedules/{scheduleid}/organization",
+				middlewares.OrgRoleMinimum(handlerWeb.HandleDeleteWebOrganizationScheduleOrganization, roles.MANAGER),
+			)
+
+			// GET /api/v1/web/organizations/{orgid}/baselines
+			routerOrg.Get(
+				"/baselines",
+				middlewares.OrgRoleMinimum(handlerWeb.HandleGetWebOrganizationBaselines, roles.MANAGER),
+			)
+
+			// POST /api/v1/web/organizations/{orgid}/baselines
+			routerOrg.Post(
+				"/baselines",
+				middlewares.OrgRoleMinimum(handlerWeb.HandlePostWebOrganizationBaseline, roles.MANAGER),
+			)
+
+			// GET /api/v1/web/organizations/{orgid}/baselines/{baselineid}
+			routerOrg.Get(
+				"/baselines/{baselineid}",
+				middlewares.OrgRoleMinimum(handlerWeb.HandleGetWebOrganizationBaseline, roles.MANAGER),
+			)
+
+			// PUT /api/v1/web/organizations/{orgid}/baselines/{baselineid}
+			routerOrg.Put(
+				"/baselines/{baselineid}",
+				middlewares.OrgRoleMinimum(handlerWeb.HandlePutWebOrganizationBaseline, roles.MANAGER),
+			)
+
+			// DELETE /api/v1/web/organizations/{orgid}/baselines/{baselineid}
+			routerOrg.Delete(
+				"/baselines/{baselineid}",
+				middlewares.OrgRoleMinimum(handlerWeb.HandleDeleteWebOrganizationBaseline, roles.MANAGER),
+			)
+
+			// PUT /api/v1/web/organizations/{orgid}/baselines/{baselineid}/nodes/{nodeid}
+			routerOrg.Put(
+				"/baselines/{baselineid}/nodes/{nodeid}",
+				middlewares.OrgRoleMinimum(handlerWeb.HandlePutWebOrganizationBaselineNode, roles.MANAGER),
+			)
+
+			// DELETE /api/v1/web/organizations/{orgid}/baselines/{baselineid}/nodes/{nodeid}
+			routerOrg.Delete(
+				"/baselines/{baselineid}/nodes/{nodeid}",
+				middlewares.OrgRoleMinimum(handlerWeb.HandleDeleteWebOrganizationBaselineNode, roles.MANAGER),
+			)
+
+			// PUT /api/v1/web/organizations/{orgid}/baselines/{baselineid}/groups/{groupid}
+			routerOrg.Put(
+				"/baselines/{baselineid}/groups/{groupid}",
+				middlewares.OrgRoleMinimum(handlerWeb.HandlePutWebOrganizationBaselineGroup, roles.MANAGER),
+			)
+
+			// DELETE /api/v1/web/organizations/{orgid}/baselines/{baselineid}/groups/{groupid}
+			routerOrg.Delete(
+				"/baselines/{baselineid}/groups/{groupid}",
+				middlewares.OrgRoleMinimum(handlerWeb.HandleDeleteWebOrganizationBaselineGroup, roles.MANAGER),
+			)
+
+			// PUT /api/v1/web/organizations/{orgid}/baselines/{baselineid}/organization
+			routerOrg.Put(
+				"/baselines/{baselineid}/organization",
+				middlewares.OrgRoleMinimum(handlerWeb.HandlePutWebOrganizationBaselineOrganization, roles.MANAGER),
+			)
+
+			// DELETE /api/v1/web/organizations/{orgid}/baselines/{baselineid}/organization
+			routerOrg.Delete(
+				"/baselines/{baselineid}/organization",
+				middlewares.OrgRoleMinimum(handlerWeb.HandleDeleteWebOrganizationBaselineOrganization, roles.MANAGER),
+			)
+
+			// PUT /api/v1/web/organizations/{orgid}/sources/{sourceid}/credentials/{name}
+			routerOrg.Put(
+				"/sources/{sourceid}/credentials/{name}",
+				middlewares.OrgRoleMinimum(handlerWeb.HandlePutWebOrganizationSourceCredentials, roles.MANAGER),
+			)
+
+			// DELETE /api/v1/web/organizations/{orgid}/sources/{sourceid}/credentials/{name}
+			routerOrg.Delete(
+				"/sources/{sourceid}/credentials/{name}",
+				middlewares.OrgRoleMinimum(handlerWeb.HandleDeleteWebOrganizationSourceCredentials, roles.MANAGER),
+			)
+
+			// GET /api/v1/web/organizations/{orgid}/jobs
+			routerOrg.Get(
+				"/jobs",
+				middlewares.OrgRoleMinimum(handlerWeb.HandleGetWebOrganizationJobs, roles.OPERATOR),
+			)
+
+			// POST /api/v1/web/organizations/{orgid}/jobs
+			routerOrg.Post(
+				"/jobs",
+				middlewares.OrgRoleMinimum(handlerWeb.HandlePostWebOrganizationJob, roles.OPERATOR),
+			)
+
+			// GET /api/v1/web/organizations/{orgid}/jobs/{jobid}
+			routerOrg.Get(
+				"/jobs/{jobid}",
+				middlewares.OrgRoleMinimum(handlerWeb.HandleGetWebOrganizationJob, roles.OPERATOR),
+			)
+
+			// POST /api/v1/web/organizations/{orgid}/jobs/{jobid}/cancel
+			routerOrg.Post(
+				"/jobs/{jobid}/cancel",
+				middlewares.OrgRoleMinimum(handlerWeb.HandlePostWebOrganizationJobCancel, roles.OPERATOR),
+			)
+
+			// GET /api/v1/web/organizations/{orgid}/jobs/{jobid}/output
+			routerOrg.Get(
+				"/jobs/{jobid}/output",
+				middlewares.OrgRoleMinimum(handlerWeb.HandleGetWebOrganizationJobOutput, roles.OPERATOR),
+			)
+
+			// GET /api/v1/web/organizations/{orgid}/jobs/{jobid}/attempts
+			routerOrg.Get(
+				"/jobs/{jobid}/attempts",
+				middlewares.OrgRoleMinimum(handlerWeb.HandleGetWebOrganizationJobAttempts, roles.OPERATOR),
+			)
+
+			// GET /api/v1/web/organizations/{orgid}/groups/{groupid}/jobs
+			routerOrg.Get(
+				"/groups/{groupid}/jobs",
+				middlewares.OrgRoleMinimum(handlerWeb.HandleGetWebOrganizationGroupJobs, roles.OPERATOR),
+			)
+
+			// POST /api/v1/web/organizations/{orgid}/groups/{groupid}/jobs
+			routerOrg.Post(
+				"/groups/{groupid}/jobs",
+				middlewares.OrgRoleMinimum(handlerWeb.HandlePostWebOrganizationGroupJobs, roles.OPERATOR),
+			)
+
+			// GET /api/v1/web/organizations/{orgid}/groups/{groupid}/upgrade_policy
+			routerOrg.Get(
+				"/groups/{groupid}/upgrade_policy",
+				middlewares.OrgRoleMinimum(handlerWeb.HandleGetWebOrganizationGroupUpgradePolicy, roles.OPERATOR),
+			)
+
+			// PUT /api/v1/web/organizations/{orgid}/groups/{groupid}/upgrade_policy
+			routerOrg.Put(
+				"/groups/{groupid}/upgrade_policy",
+				middlewares.OrgRoleMinimum(handlerWeb.HandlePutWebOrganizationGroupUpgradePolicy, roles.MANAGER),
+			)
+
+			// DELETE /api/v1/web/organizations/{orgid}/groups/{groupid}/upgrade_policy
+			routerOrg.Delete(
+				"/groups/{groupid}/upgrade_policy",
+				middlewares.OrgRoleMinimum(handlerWeb.HandleDeleteWebOrganizationGroupUpgradePolicy, roles.MANAGER),
+			)
 		})
 	})
 }
@@ -162,6 +569,11 @@ func (srv *SweetToothServer) Run() error {
 	staticFileServer := http.FileServer(http.Dir("./internal/server/web/static"))
 	router.Handle("/static/*", http.StripPrefix("/static/", staticFileServer))
 
+	// reclaim abandoned leases and expire stale package jobs in the background for as long as the server is running
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go srv.sweepJobs(ctx)
+
 	// router should listen on the configured host/port
 	listenStr := fmt.Sprintf("%s:%d", srv.config.Host, srv.config.Port)
 	log.Info().Str("listen", listenStr).Msgf("Starting %s Server", info.APP_NAME)
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/goodieshq/sweettooth/pkg/api"
+	"github.com/rs/zerolog/log"
+)
+
+const DEFAULT_SWEEP_INTERVAL = 1 * time.Minute // how often abandoned leases are reclaimed and stale package jobs are expired
+
+// periodically reclaim expired leases and expire queued jobs past their expiration until the context is cancelled
+func (srv *SweetToothServer) sweepJobs(ctx context.Context) {
+	ticker := time.NewTicker(DEFAULT_SWEEP_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		// reclaimed leases are requeued first so a job past its expiration is expired in the same sweep
+		count, err := srv.core.ReclaimPackageJobLeases(ctx, api.DEFAULT_RETRY_MAX_ATTEMPTS)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to reclaim package job leases")
+		} else if count > 0 {
+			log.Info().Int("count", count).Msg("reclaimed package job leases")
+		}
+
+		count, err = srv.core.ExpirePackageJobs(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to sweep expired package jobs")
+		} else if count > 0 {
+			log.Info().Int("count", count).Msg("expired package jobs")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
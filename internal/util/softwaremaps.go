@@ -0,0 +1,35 @@
+package util
+
+// The built-in software maps, used after an organization's own maps unless it opts out of them. These only cover
+// popular software whose chocolatey package installs the same product the vendor's own installer does
+var defaultSoftwareMaps = SoftwareMapList{
+	{Regex: `^7-Zip \d`, Package: "7zip"},
+	{Regex: `^Adobe Acrobat Reader`, Package: "adobereader"},
+	{Regex: `^Audacity \d`, Package: "audacity"},
+	{Regex: `^GIMP \d`, Package: "gimp"},
+	{Regex: `^Git$|^Git version \d`, Package: "git"},
+	{Regex: `^Google Chrome$`, Package: "googlechrome"},
+	{Regex: `^Greenshot \d`, Package: "greenshot"},
+	{Regex: `^KeePass Password Safe 2`, Package: "keepass"},
+	{Regex: `^Microsoft Visual Studio Code`, Package: "vscode"},
+	{Regex: `^Mozilla Firefox ESR`, Package: "firefoxesr"},
+	{Regex: `^Mozilla Firefox`, Package: "firefox"},
+	{Regex: `^Mozilla Thunderbird`, Package: "thunderbird"},
+	{Regex: `^Node\.js$`, Package: "nodejs"},
+	{Regex: `^Notepad\+\+`, Package: "notepadplusplus"},
+	{Regex: `^paint\.net$`, Package: "paint.net"},
+	{Regex: `^PuTTY release`, Package: "putty"},
+	{Regex: `^TeamViewer( \d+)?$`, Package: "teamviewer"},
+	{Regex: `^VLC media player$`, Package: "vlc"},
+	{Regex: `^WinRAR \d`, Package: "winrar"},
+	{Regex: `^WinSCP \d`, Package: "winscp"},
+	{Regex: `^Wireshark \d`, Package: "wireshark"},
+	{Regex: `^Zoom( Workplace)?( \(64-bit\))?$`, Package: "zoom"},
+}
+
+// Get a copy of the built-in software maps
+func DefaultSoftwareMaps() SoftwareMapList {
+	maps := make(SoftwareMapList, len(defaultSoftwareMaps))
+	copy(maps, defaultSoftwareMaps)
+	return maps
+}
@@ -1,7 +1,12 @@
 package util
 
 import (
+	"errors"
+	"fmt"
 	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 )
 
 // Simple software application definition
@@ -12,6 +17,115 @@ type Software struct {
 
 type SoftwareList []Software
 
+// Find a software application by its case-insensitive name, nil if it is not in the list
+func (list SoftwareList) Find(name string) *Software {
+	for i := range list {
+		if strings.EqualFold(list[i].Name, name) {
+			return &list[i]
+		}
+	}
+	return nil
+}
+
+// A difference in a software application between two software lists
+type SoftwareChange struct {
+	Name       string  `json:"name"`
+	VersionOld *string `json:"version_old"` // nil if the software was installed
+	VersionNew *string `json:"version_new"` // nil if the software was removed
+}
+
+// Compare the list against a newer one, names are case-insensitive. The same software can be listed more than once
+// with different versions (common for system software), so matching versions cancel out first and the remaining
+// versions of the same name are paired up as version changes
+func (list SoftwareList) Diff(newer SoftwareList) []SoftwareChange {
+	return list.diff(newer, strings.ToLower)
+}
+
+// Compare the list against a newer one like Diff, except names are case-sensitive so applying the changes to the list
+// reproduces the newer list exactly
+func (list SoftwareList) DiffExact(newer SoftwareList) []SoftwareChange {
+	return list.diff(newer, func(name string) string { return name })
+}
+
+// compare the list against a newer one, software with the same key is the same software
+func (list SoftwareList) diff(newer SoftwareList, nameKey func(name string) string) []SoftwareChange {
+	names := make([]string, 0)
+	versionsOld := make(map[string][]Software)
+	versionsNew := make(map[string][]Software)
+	for _, sw := range list {
+		key := nameKey(sw.Name)
+		if _, ok := versionsOld[key]; !ok {
+			names = append(names, key)
+		}
+		versionsOld[key] = append(versionsOld[key], sw)
+	}
+	for _, sw := range newer {
+		key := nameKey(sw.Name)
+		if _, ok := versionsOld[key]; !ok {
+			if _, ok := versionsNew[key]; !ok {
+				names = append(names, key)
+			}
+		}
+		versionsNew[key] = append(versionsNew[key], sw)
+	}
+
+	changes := make([]SoftwareChange, 0)
+	for _, key := range names {
+		olds := make([]Software, 0, len(versionsOld[key]))
+		news := slices.Clone(versionsNew[key])
+		for _, sw := range versionsOld[key] {
+			i := slices.IndexFunc(news, func(other Software) bool { return other.Version == sw.Version })
+			if i >= 0 {
+				news = slices.Delete(news, i, i+1)
+			} else {
+				olds = append(olds, sw)
+			}
+		}
+
+		for i := 0; i < len(olds) || i < len(news); i++ {
+			var change SoftwareChange
+			if i < len(olds) {
+				change.Name = olds[i].Name
+				change.VersionOld = &olds[i].Version
+			}
+			if i < len(news) {
+				change.Name = news[i].Name
+				change.VersionNew = &news[i].Version
+			}
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// Apply the changes of an exact diff to the list, returning a new list. Changed software keeps its place in the list
+// and installed software is appended. Fails if software which was changed or removed is not in the list
+func (list SoftwareList) Apply(changes []SoftwareChange) (SoftwareList, error) {
+	applied := slices.Clone(list)
+	for _, change := range changes {
+		if change.VersionOld == nil {
+			if change.VersionNew == nil {
+				return nil, fmt.Errorf("change of %q has neither an old nor a new version", change.Name)
+			}
+			applied = append(applied, Software{Name: change.Name, Version: *change.VersionNew})
+			continue
+		}
+
+		i := slices.IndexFunc(applied, func(sw Software) bool {
+			return sw.Name == change.Name && sw.Version == *change.VersionOld
+		})
+		if i < 0 {
+			return nil, fmt.Errorf("software %q version %q is not in the list", change.Name, *change.VersionOld)
+		}
+		if change.VersionNew == nil {
+			applied = slices.Delete(applied, i, i+1)
+		} else {
+			applied[i] = Software{Name: change.Name, Version: *change.VersionNew}
+		}
+	}
+	return applied, nil
+}
+
 // Software which has a newer version available
 type SoftwareOutdated struct {
 	Name       string `json:"name"`
@@ -21,9 +135,106 @@ type SoftwareOutdated struct {
 }
 
 type SoftwareOutdatedList []SoftwareOutdated
+
+// A difference in an outdated software application between two outdated software lists
+type SoftwareOutdatedChange struct {
+	Old *SoftwareOutdated `json:"old"` // nil if the software became outdated
+	New *SoftwareOutdated `json:"new"` // nil if the software is no longer outdated
+}
+
+// Compare the list against a newer one. Identical entries cancel out first and the remaining entries of the same
+// case-insensitive name are paired up as changes (e.g. a newer version became available or the package was pinned)
+func (list SoftwareOutdatedList) Diff(newer SoftwareOutdatedList) []SoftwareOutdatedChange {
+	olds := make(SoftwareOutdatedList, 0)
+	news := slices.Clone(newer)
+	for _, sw := range list {
+		if i := slices.Index(news, sw); i >= 0 {
+			news = slices.Delete(news, i, i+1)
+		} else {
+			olds = append(olds, sw)
+		}
+	}
+
+	changes := make([]SoftwareOutdatedChange, 0, len(olds)+len(news))
+	for i := range olds {
+		change := SoftwareOutdatedChange{Old: &olds[i]}
+		if j := slices.IndexFunc(news, func(sw SoftwareOutdated) bool { return strings.EqualFold(sw.Name, olds[i].Name) }); j >= 0 {
+			sw := news[j]
+			change.New = &sw
+			news = slices.Delete(news, j, j+1)
+		}
+		changes = append(changes, change)
+	}
+	for i := range news {
+		changes = append(changes, SoftwareOutdatedChange{New: &news[i]})
+	}
+	return changes
+}
+
+// Apply the changes of a diff to the list, returning a new list. Changed software keeps its place in the list and
+// newly outdated software is appended. Fails if software which was changed or removed is not in the list
+func (list SoftwareOutdatedList) Apply(changes []SoftwareOutdatedChange) (SoftwareOutdatedList, error) {
+	applied := slices.Clone(list)
+	for _, change := range changes {
+		if change.Old == nil {
+			if change.New == nil {
+				return nil, errors.New("outdated software change has neither an old nor a new entry")
+			}
+			applied = append(applied, *change.New)
+			continue
+		}
+
+		i := slices.Index(applied, *change.Old)
+		if i < 0 {
+			return nil, fmt.Errorf("outdated software %q is not in the list", change.Old.Name)
+		}
+		if change.New == nil {
+			applied = slices.Delete(applied, i, i+1)
+		} else {
+			applied[i] = *change.New
+		}
+	}
+	return applied, nil
+}
+
+// Maps software reported by the system (but not managed by chocolatey) to the chocolatey package which can adopt it
 type SoftwareMap struct {
-	Regex   regexp.Regexp // a regex to check against the system-reported software name
-	Package string        // the corresponding chocolatey package that should be targetted upon a match
+	Regex   string `json:"regex"`   // a regex to check against the system-reported software name (case-insensitive)
+	Package string `json:"package"` // the corresponding chocolatey package that should be targetted upon a match
+}
+
+type SoftwareMapList []SoftwareMap
+
+// Software maps with their regexes compiled, ready to match system-reported software names
+type SoftwareMatcher struct {
+	maps    SoftwareMapList
+	regexes []*regexp.Regexp
+}
+
+// Compile the regex of every software map, each regex matches case-insensitively
+func (list SoftwareMapList) Compile() (*SoftwareMatcher, error) {
+	matcher := SoftwareMatcher{maps: list, regexes: make([]*regexp.Regexp, len(list))}
+	for i := range list {
+		if strings.TrimSpace(list[i].Package) == "" {
+			return nil, fmt.Errorf("software map %d has no package name", i)
+		}
+		re, err := regexp.Compile("(?i)" + list[i].Regex)
+		if err != nil {
+			return nil, fmt.Errorf("software map %d has an invalid regex: %w", i, err)
+		}
+		matcher.regexes[i] = re
+	}
+	return &matcher, nil
+}
+
+// Find the first software map whose regex matches the system-reported software name, nil if none match
+func (matcher *SoftwareMatcher) Match(name string) *SoftwareMap {
+	for i, re := range matcher.regexes {
+		if re.MatchString(name) {
+			return &matcher.maps[i]
+		}
+	}
+	return nil
 }
 
 type Repository struct {
@@ -36,4 +247,185 @@ type Repository struct {
 	BypassProxy bool   `json:"bypass_proxy"`
 	SelfService bool   `json:"self_service"`
 	AdminOnly   bool   `json:"admin_only"`
+
+	// credentials are sealed to the node's key when delivered and are never reported back by the node
+	Password            string `json:"password,omitempty"`
+	CertificatePassword string `json:"certificate_password,omitempty"`
+}
+
+type RepositoryList []Repository
+
+// Check if every setting of two repositories is the same, chocolatey source names are case-insensitive. Credentials
+// are not compared since chocolatey never lists them
+func (repo Repository) Equal(other Repository) bool {
+	return strings.EqualFold(repo.Name, other.Name) &&
+		repo.URL == other.URL &&
+		repo.Disabled == other.Disabled &&
+		repo.Username == other.Username &&
+		repo.Certificate == other.Certificate &&
+		repo.Priority == other.Priority &&
+		repo.BypassProxy == other.BypassProxy &&
+		repo.SelfService == other.SelfService &&
+		repo.AdminOnly == other.AdminOnly
+}
+
+// Find the index of a repository by its case-insensitive name, -1 if it is not in the list
+func (list RepositoryList) Index(name string) int {
+	for i := range list {
+		if strings.EqualFold(list[i].Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Find a repository by its case-insensitive name, nil if it is not in the list
+func (list RepositoryList) Find(name string) *Repository {
+	if i := list.Index(name); i >= 0 {
+		return &list[i]
+	}
+	return nil
+}
+
+// Get the names of the repositories which differ between the list and the actual repositories, including those which
+// are missing from either one
+func (list RepositoryList) Drift(actual RepositoryList) []string {
+	drift := make([]string, 0)
+	for _, repo := range list {
+		if other := actual.Find(repo.Name); other == nil || !repo.Equal(*other) {
+			drift = append(drift, repo.Name)
+		}
+	}
+	for _, repo := range actual {
+		if list.Find(repo.Name) == nil {
+			drift = append(drift, repo.Name)
+		}
+	}
+	return drift
+}
+
+// Whether a baseline requires a package to be installed or not
+const (
+	BaselineEnsurePresent = "present"
+	BaselineEnsureAbsent  = "absent"
+)
+
+// A chocolatey package which a baseline requires to be present (optionally at a minimum version) or absent
+type BaselineEntry struct {
+	Name       string  `json:"name"`
+	Ensure     string  `json:"ensure"`
+	MinVersion *string `json:"min_version,omitempty"`
+}
+
+type BaselineEntryList []BaselineEntry
+
+// Find the index of a baseline entry by its case-insensitive package name, -1 if it is not in the list
+func (list BaselineEntryList) Index(name string) int {
+	for i := range list {
+		if strings.EqualFold(list[i].Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Validate every entry of a baseline, each package may only appear once
+func (list BaselineEntryList) Validate() error {
+	for i, entry := range list {
+		if strings.TrimSpace(entry.Name) == "" {
+			return fmt.Errorf("baseline entry %d has no package name", i)
+		}
+		if list.Index(entry.Name) != i {
+			return fmt.Errorf("baseline entry %q is duplicated", entry.Name)
+		}
+		switch entry.Ensure {
+		case BaselineEnsurePresent:
+			if entry.MinVersion != nil && strings.TrimSpace(*entry.MinVersion) == "" {
+				return fmt.Errorf("baseline entry %q has an empty minimum version", entry.Name)
+			}
+		case BaselineEnsureAbsent:
+			if entry.MinVersion != nil {
+				return fmt.Errorf("baseline entry %q cannot require a minimum version of an absent package", entry.Name)
+			}
+		default:
+			return fmt.Errorf("baseline entry %q must ensure the package is %q or %q", entry.Name, BaselineEnsurePresent, BaselineEnsureAbsent)
+		}
+	}
+	return nil
+}
+
+// Compare two chocolatey package versions following the NuGet ordering, returning -1, 0 or 1. Build metadata after a
+// '+' is ignored. The release segments before the first '-' are compared first, where a missing segment counts as 0.
+// A version with a prerelease label after the '-' sorts before the same version without one, and prerelease labels
+// are compared segment by segment where a shorter label sorts first. Numeric segments are compared as numbers and
+// sort before text segments, which are compared case-insensitively
+func CompareVersions(a, b string) int {
+	releaseA, prereleaseA, isPrereleaseA := splitVersion(a)
+	releaseB, prereleaseB, isPrereleaseB := splitVersion(b)
+
+	if c := compareVersionSegments(releaseA, releaseB, true); c != 0 {
+		return c
+	}
+
+	switch {
+	case !isPrereleaseA && !isPrereleaseB:
+		return 0
+	case !isPrereleaseA:
+		return 1
+	case !isPrereleaseB:
+		return -1
+	}
+	return compareVersionSegments(prereleaseA, prereleaseB, false)
+}
+
+// split a version into the segments of its release and of its prerelease label, dropping any build metadata
+func splitVersion(version string) (release, prerelease []string, isPrerelease bool) {
+	version, _, _ = strings.Cut(strings.TrimSpace(version), "+")
+	version, label, isPrerelease := strings.Cut(version, "-")
+	release = strings.FieldsFunc(version, isVersionSeparator)
+	prerelease = strings.FieldsFunc(label, isVersionSeparator)
+	return
+}
+
+// compare version segments in order, missing segments count as 0 when padded or otherwise sort first
+func compareVersionSegments(segsA, segsB []string, pad bool) int {
+	for i := 0; i < max(len(segsA), len(segsB)); i++ {
+		segA, segB := "0", "0"
+		if i < len(segsA) {
+			segA = segsA[i]
+		} else if !pad {
+			return -1
+		}
+		if i < len(segsB) {
+			segB = segsB[i]
+		} else if !pad {
+			return 1
+		}
+
+		numA, errA := strconv.ParseUint(segA, 10, 64)
+		numB, errB := strconv.ParseUint(segB, 10, 64)
+		switch {
+		case errA == nil && errB == nil:
+			if numA != numB {
+				if numA < numB {
+					return -1
+				}
+				return 1
+			}
+		case errA == nil:
+			return -1
+		case errB == nil:
+			return 1
+		default:
+			if c := strings.Compare(strings.ToLower(segA), strings.ToLower(segB)); c != 0 {
+				return c
+			}
+		}
+	}
+
+	return 0
+}
+
+func isVersionSeparator(r rune) bool {
+	return r == '.'
 }
@@ -0,0 +1,151 @@
+package util
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want int
+	}{
+		{"equal", "1.2.3", "1.2.3", 0},
+		{"older", "1.2.3", "1.2.4", -1},
+		{"newer", "1.10.0", "1.9.0", 1},
+		{"missing segment padded", "1.0", "1.0.0", 0},
+		{"missing segments padded", "1", "1.0.0.0", 0},
+		{"missing segment older", "1.0", "1.0.1", -1},
+		{"leading zeros", "1.01", "1.1", 0},
+		{"surrounding space", " 1.0 ", "1.0", 0},
+		{"prerelease before release", "1.0.0-beta", "1.0.0", -1},
+		{"release after prerelease", "1.0.0", "1.0.0-beta", 1},
+		{"prerelease of newer release", "1.0.1-alpha", "1.0.0", 1},
+		{"prerelease labels", "1.0.0-alpha", "1.0.0-beta", -1},
+		{"shorter prerelease first", "1.0.0-beta", "1.0.0-beta.1", -1},
+		{"numeric prerelease segments", "1.0.0-beta.2", "1.0.0-beta.10", -1},
+		{"numeric before text", "1.0.0-1", "1.0.0-alpha", -1},
+		{"text after numeric", "1.0.0-rc.alpha", "1.0.0-rc.1", 1},
+		{"case-insensitive labels", "1.0.0-BETA", "1.0.0-beta", 0},
+		{"case-insensitive label order", "1.0.0-Alpha", "1.0.0-beta", -1},
+		{"build metadata ignored", "1.0.0+build.5", "1.0.0", 0},
+		{"build metadata of prerelease ignored", "1.0.0-beta+exp.sha", "1.0.0-beta+other", 0},
+		{"build metadata not a prerelease", "1.0.0+build-1", "1.0.0", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CompareVersions(tt.a, tt.b); got != tt.want {
+				t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+			if got := CompareVersions(tt.b, tt.a); got != -tt.want {
+				t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.want)
+			}
+		})
+	}
+}
+
+func sortedSoftware(list SoftwareList) SoftwareList {
+	sorted := slices.Clone(list)
+	slices.SortFunc(sorted, func(a, b Software) int {
+		return cmp.Or(strings.Compare(a.Name, b.Name), strings.Compare(a.Version, b.Version))
+	})
+	return sorted
+}
+
+func sortedOutdated(list SoftwareOutdatedList) SoftwareOutdatedList {
+	sorted := slices.Clone(list)
+	slices.SortFunc(sorted, func(a, b SoftwareOutdated) int {
+		return cmp.Or(strings.Compare(a.Name, b.Name), strings.Compare(a.VersionOld, b.VersionOld),
+			strings.Compare(a.VersionNew, b.VersionNew))
+	})
+	return sorted
+}
+
+func TestSoftwareListDiffExactApply(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b SoftwareList
+	}{
+		{"empty", nil, nil},
+		{"unchanged", SoftwareList{{"7zip", "23.1"}, {"git", "2.45"}}, SoftwareList{{"7zip", "23.1"}, {"git", "2.45"}}},
+		{"installed", nil, SoftwareList{{"7zip", "23.1"}}},
+		{"removed", SoftwareList{{"7zip", "23.1"}}, SoftwareList{}},
+		{"new version", SoftwareList{{"7zip", "22.0"}, {"git", "2.45"}}, SoftwareList{{"7zip", "23.1"}, {"git", "2.45"}}},
+		{"reordered", SoftwareList{{"7zip", "23.1"}, {"git", "2.45"}}, SoftwareList{{"git", "2.45"}, {"7zip", "23.1"}}},
+		{"renamed case", SoftwareList{{"Git", "2.45"}}, SoftwareList{{"git", "2.45"}}},
+		{
+			"duplicated names",
+			SoftwareList{{"vcredist", "14.0"}, {"vcredist", "12.0"}, {"vcredist", "12.0"}},
+			SoftwareList{{"vcredist", "12.0"}, {"vcredist", "14.1"}, {"vcredist", "11.0"}, {"vcredist", "11.0"}},
+		},
+		{
+			"mixed",
+			SoftwareList{{"7zip", "22.0"}, {"chrome", "120"}, {"git", "2.45"}},
+			SoftwareList{{"git", "2.46"}, {"firefox", "128"}, {"7zip", "22.0"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applied, err := tt.a.Apply(tt.a.DiffExact(tt.b))
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if got, want := sortedSoftware(applied), sortedSoftware(tt.b); !slices.Equal(got, want) {
+				t.Errorf("Apply(DiffExact) = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestSoftwareListApplyMissing(t *testing.T) {
+	a := SoftwareList{{"7zip", "22.0"}}
+	changes := a.DiffExact(SoftwareList{{"7zip", "23.1"}})
+
+	if _, err := (SoftwareList{{"7zip", "21.0"}}).Apply(changes); err == nil {
+		t.Error("applied a change of a version which is not in the list")
+	}
+	if _, err := (SoftwareList{{"7Zip", "22.0"}}).Apply(changes); err == nil {
+		t.Error("applied a change of a name which is not in the list")
+	}
+}
+
+func TestSoftwareOutdatedListDiffApply(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b SoftwareOutdatedList
+	}{
+		{"empty", nil, nil},
+		{"newly outdated", nil, SoftwareOutdatedList{{"7zip", "22.0", "23.1", false}}},
+		{"no longer outdated", SoftwareOutdatedList{{"7zip", "22.0", "23.1", false}}, SoftwareOutdatedList{}},
+		{"newer version available", SoftwareOutdatedList{{"7zip", "22.0", "23.0", false}}, SoftwareOutdatedList{{"7zip", "22.0", "23.1", false}}},
+		{"pinned", SoftwareOutdatedList{{"7zip", "22.0", "23.1", false}}, SoftwareOutdatedList{{"7zip", "22.0", "23.1", true}}},
+		{"renamed case", SoftwareOutdatedList{{"Git", "2.45", "2.46", false}}, SoftwareOutdatedList{{"git", "2.45", "2.46", false}}},
+		{
+			"reordered",
+			SoftwareOutdatedList{{"7zip", "22.0", "23.1", false}, {"git", "2.45", "2.46", false}},
+			SoftwareOutdatedList{{"git", "2.45", "2.46", false}, {"7zip", "22.0", "23.1", false}},
+		},
+		{
+			"mixed",
+			SoftwareOutdatedList{{"7zip", "22.0", "23.1", false}, {"chrome", "120", "121", false}, {"chrome", "120", "121", false}},
+			SoftwareOutdatedList{{"chrome", "120", "122", false}, {"git", "2.45", "2.46", true}, {"chrome", "120", "121", false}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applied, err := tt.a.Apply(tt.a.Diff(tt.b))
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if got, want := sortedOutdated(applied), sortedOutdated(tt.b); !slices.Equal(got, want) {
+				t.Errorf("Apply(Diff) = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -1,7 +1,14 @@
 package api
 
 import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/goodieshq/sweettooth/internal/schedule"
@@ -27,6 +34,24 @@ type RegistrationRequest struct {
 	PackagesOutdated util.SoftwareOutdatedList `json:"packages_outdated"`
 }
 
+type RegistrationToken struct {
+	ID             uuid.UUID  `json:"id"`              // the random ID used as the token
+	OrganizationID uuid.UUID  `json:"organization_id"` // nodes registered with this token join this org
+	Name           string     `json:"name"`            // name of the token (unique within the org)
+	CreatedAt      time.Time  `json:"created_at"`      // when the token was created
+	ExpiresAt      *time.Time `json:"expires_at"`      // when the token expires, never if nil
+	MaxUses        *int       `json:"max_uses"`        // how many nodes can register with the token, unlimited if nil
+	Uses           int        `json:"uses"`            // how many nodes have registered with the token
+	AutoApprove    bool       `json:"auto_approve"`    // nodes registered with the token are approved immediately
+}
+
+type RegistrationTokenRequest struct {
+	Name        string     `json:"name"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	MaxUses     *int       `json:"max_uses,omitempty"`
+	AutoApprove bool       `json:"auto_approve"`
+}
+
 type SoftwareInventoryRequest struct {
 	PackagesChoco  util.SoftwareList `json:"packages_choco"`
 	PackagesSystem util.SoftwareList `json:"packages_system"`
@@ -35,7 +60,8 @@ type SoftwareInventoryRequest struct {
 type CheckResponse struct {
 	PendingSources  bool `json:"pending_sources"`  // client should update its sources
 	PendingSchedule bool `json:"pending_schedule"` // client should update its schedule
-	PendingJobs     bool `json:"pending_jobs"`     // client should perform pending jobs (schedule permitting)
+	PendingJobs     int  `json:"pending_jobs"`     // number of jobs the client should perform (schedule permitting)
+	OverdueJobs     int  `json:"overdue_jobs"`     // number of pending jobs past their deadline, performed regardless of the schedule
 }
 
 type ErrorResponse struct {
@@ -50,11 +76,62 @@ func (err ErrorResponse) Error() string {
 
 type Schedule schedule.Schedule
 
+type OrganizationSchedule struct {
+	ID             uuid.UUID `json:"id"`              // random schedule ID
+	OrganizationID uuid.UUID `json:"organization_id"` // organization in which the schedule exists
+	Name           string    `json:"name"`            // name of the schedule (unique within the org, case-insensitive)
+	Entries        Schedule  `json:"entries"`         // the RRule entries and time ranges of the schedule
+}
+
+type ScheduleRequest struct {
+	Name    string   `json:"name"`
+	Entries Schedule `json:"entries"`
+}
+
+type SchedulePreview struct {
+	From     time.Time         `json:"from"`     // the beginning of the previewed range
+	To       time.Time         `json:"to"`       // the end of the previewed range
+	Schedule Schedule          `json:"schedule"` // the combined schedule of the node, referenced by each window's entry
+	Windows  []schedule.Window `json:"windows"`  // every window overlapping the previewed range, including blackouts
+	Next     []schedule.Window `json:"next"`     // the next windows which have not ended as of from, with blackouts removed
+}
+
 type Organization struct {
 	ID   uuid.UUID `json:"id"`   // random org ID
 	Name string    `json:"name"` // org name (unique, case-insensitive)
 }
 
+type User struct {
+	ID         uuid.UUID  `json:"id"`                   // random user ID
+	Email      string     `json:"email"`                // email address (unique, case-insensitive)
+	SuperAdmin bool       `json:"superadmin"`           // super admins have full access to every organization
+	MfaEnabled bool       `json:"mfa_enabled"`          // the user must provide a TOTP code to log in
+	CreatedAt  time.Time  `json:"created_at"`           // when the user was created
+	LastLogin  *time.Time `json:"last_login,omitempty"` // when the user last logged in successfully
+}
+
+// the user along with the values needed to process a login attempt, never sent to clients
+type UserLogin struct {
+	User
+	PasswordHash string `json:"-"` // encoded argon2id password hash
+	Locked       bool   `json:"-"` // the user is currently locked out from too many failed logins
+	MfaSecret    string `json:"-"` // TOTP secret, empty if MFA is not enabled
+	MfaPending   string `json:"-"` // TOTP secret awaiting verification during enrollment
+}
+
+type MfaEnrollResponse struct {
+	Secret string `json:"secret"` // base32-encoded TOTP secret for manual entry
+	URI    string `json:"uri"`    // otpauth:// provisioning URI, usually displayed as a QR code
+}
+
+type MfaVerifyRequest struct {
+	Code string `json:"code"` // TOTP code generated from the pending secret
+}
+
+type MfaVerifyResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"` // single-use recovery codes, only ever shown once
+}
+
 type OrganizationSummary struct {
 	Organization
 	NodeCount int `json:"node_count"` // number of nodes in this org
@@ -78,6 +155,8 @@ type Node struct {
 	ApprovedOn      *time.Time `json:"approved_on"`      // when the node was approved
 	LastSeen        *time.Time `json:"last_seen"`        // when the node last checked in
 	Approved        bool       `json:"approved"`         // whether the node has been approved or not
+	RejectedOn      *time.Time `json:"rejected_on"`      // when the node's registration was rejected
+	RevokedOn       *time.Time `json:"revoked_on"`       // when the node's approval was revoked
 }
 
 type Packages struct {
@@ -86,15 +165,442 @@ type Packages struct {
 	PackagesOutdated util.SoftwareOutdatedList `json:"packages_outdated"` // list of outdated packages on the node managed by chocolatey
 }
 
+// Lists of packages reported by a node
+const (
+	PACKAGE_SOURCE_CHOCO    = "choco"    // packages managed by chocolatey
+	PACKAGE_SOURCE_SYSTEM   = "system"   // packages NOT managed by chocolatey
+	PACKAGE_SOURCE_OUTDATED = "outdated" // packages managed by chocolatey which have a newer version available
+)
+
+// Hash the inventory to identify the version of it the server holds for a node. Each list is sorted first, so the same
+// packages always hash the same regardless of the order they were reported in
+func (packages *Packages) Hash() string {
+	choco := append(util.SoftwareList{}, packages.PackagesChoco...)
+	system := append(util.SoftwareList{}, packages.PackagesSystem...)
+	outdated := append(util.SoftwareOutdatedList{}, packages.PackagesOutdated...)
+
+	compareSoftware := func(a, b util.Software) int {
+		return cmp.Or(strings.Compare(a.Name, b.Name), strings.Compare(a.Version, b.Version))
+	}
+	slices.SortFunc(choco, compareSoftware)
+	slices.SortFunc(system, compareSoftware)
+	slices.SortFunc(outdated, func(a, b util.SoftwareOutdated) int {
+		return cmp.Or(
+			strings.Compare(a.Name, b.Name),
+			strings.Compare(a.VersionOld, b.VersionOld),
+			strings.Compare(a.VersionNew, b.VersionNew),
+			cmp.Compare(boolToInt(a.Pinned), boolToInt(b.Pinned)),
+		)
+	})
+
+	// marshaling plain strings and bools cannot fail
+	data, _ := json.Marshal(Packages{PackagesChoco: choco, PackagesSystem: system, PackagesOutdated: outdated})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Apply a delta to the inventory, returning the resulting inventory. Fails if the delta changes or removes a package
+// which is not in the inventory, meaning the delta was not computed against it
+func (packages *Packages) Apply(delta *PackagesDelta) (*Packages, error) {
+	choco, err := packages.PackagesChoco.Apply(delta.PackagesChoco)
+	if err != nil {
+		return nil, fmt.Errorf("%s packages: %w", PACKAGE_SOURCE_CHOCO, err)
+	}
+	system, err := packages.PackagesSystem.Apply(delta.PackagesSystem)
+	if err != nil {
+		return nil, fmt.Errorf("%s packages: %w", PACKAGE_SOURCE_SYSTEM, err)
+	}
+	outdated, err := packages.PackagesOutdated.Apply(delta.PackagesOutdated)
+	if err != nil {
+		return nil, fmt.Errorf("%s packages: %w", PACKAGE_SOURCE_OUTDATED, err)
+	}
+	return &Packages{PackagesChoco: choco, PackagesSystem: system, PackagesOutdated: outdated}, nil
+}
+
+var ErrPackagesDeltaBase = errors.New("the delta was not computed against the inventory")
+var ErrPackagesDeltaHash = errors.New("the delta does not result in the inventory it was computed for")
+
+// Apply a delta to the exact inventory it was computed against (see Apply), also failing if the inventory is not the
+// delta's base or the result is not the inventory the delta was computed for
+func (packages *Packages) ApplyDelta(delta *PackagesDelta) (*Packages, error) {
+	if packages.Hash() != delta.BaseHash {
+		return nil, ErrPackagesDeltaBase
+	}
+	applied, err := packages.Apply(delta)
+	if err != nil {
+		return nil, err
+	}
+	if applied.Hash() != delta.Hash {
+		return nil, ErrPackagesDeltaHash
+	}
+	return applied, nil
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// The version of a node's inventory held by the server
+type PackagesHash struct {
+	Hash string `json:"hash"` // hash of the inventory (see Packages.Hash)
+}
+
+// The packages added, removed and changed in a node's inventory since the version of it held by the server
+type PackagesDelta struct {
+	BaseHash         string                        `json:"base_hash"`         // hash of the server's inventory the delta was computed against
+	Hash             string                        `json:"hash"`              // hash of the inventory once the delta is applied
+	PackagesChoco    []util.SoftwareChange         `json:"packages_choco"`    // changes to the packages managed by chocolatey
+	PackagesSystem   []util.SoftwareChange         `json:"packages_system"`   // changes to the packages NOT managed by chocolatey
+	PackagesOutdated []util.SoftwareOutdatedChange `json:"packages_outdated"` // changes to the outdated packages managed by chocolatey
+}
+
+// Compute the delta from the inventory the server holds (identified by its hash) to the current inventory
+func NewPackagesDelta(base *Packages, baseHash string, current *Packages) *PackagesDelta {
+	return &PackagesDelta{
+		BaseHash:         baseHash,
+		Hash:             current.Hash(),
+		PackagesChoco:    base.PackagesChoco.DiffExact(current.PackagesChoco),
+		PackagesSystem:   base.PackagesSystem.DiffExact(current.PackagesSystem),
+		PackagesOutdated: base.PackagesOutdated.Diff(current.PackagesOutdated),
+	}
+}
+
+// A delta signed by the node which computed it
+type SignedPackagesDelta struct {
+	Delta     json.RawMessage `json:"delta"`     // the JSON-encoded PackagesDelta, exactly as it was signed
+	Signature string          `json:"signature"` // the base64-encoded signature of the delta's raw bytes using the node's private key
+}
+
+// Ways a package can differ between two inventories of a node
+const (
+	PACKAGE_CHANGE_INSTALLED = "installed" // the package was not in the previous inventory
+	PACKAGE_CHANGE_REMOVED   = "removed"   // the package is no longer in the inventory
+	PACKAGE_CHANGE_VERSION   = "version"   // the package is still in the inventory with a different version
+)
+
+// A package which differs between two inventories of a node
+type PackageChange struct {
+	Source     string  `json:"source"`      // the list the package is reported in (see PACKAGE_SOURCE_*)
+	Change     string  `json:"change"`      // how the package differs (see PACKAGE_CHANGE_*)
+	Name       string  `json:"name"`        // the package name
+	VersionOld *string `json:"version_old"` // the previous version, nil if the package was installed
+	VersionNew *string `json:"version_new"` // the current version, nil if the package was removed
+}
+
+// Every package which changed in a single inventory update of a node
+type PackageChangeSet struct {
+	NodeID    uuid.UUID       `json:"node_id"`   // the node which reported the inventory
+	Timestamp time.Time       `json:"timestamp"` // when the node reported the inventory
+	Changes   []PackageChange `json:"changes"`   // the packages which differ from the node's previous inventory
+}
+
+// How the name of a package search is matched against package names, all case-insensitive
+const (
+	SEARCH_MATCH_EXACT  = "exact"  // the package name is the search name
+	SEARCH_MATCH_PREFIX = "prefix" // the package name starts with the search name
+	SEARCH_MATCH_REGEX  = "regex"  // the package name matches the search name as a regex
+)
+
+// How the version of a package search is compared against package versions
+const (
+	SEARCH_VERSION_LT = "lt" // older than the search version
+	SEARCH_VERSION_LE = "le" // older than or the same as the search version
+	SEARCH_VERSION_EQ = "eq" // the same as the search version
+	SEARCH_VERSION_NE = "ne" // not the same as the search version
+	SEARCH_VERSION_GE = "ge" // newer than or the same as the search version
+	SEARCH_VERSION_GT = "gt" // newer than the search version
+)
+
+// A search for packages across the inventories of an organization's nodes
+type PackageSearch struct {
+	Name    string `json:"name"`    // the package name to search for
+	Match   string `json:"match"`   // how the name is matched (see SEARCH_MATCH_*)
+	Source  string `json:"source"`  // only search the list of this source (see PACKAGE_SOURCE_*), both if empty
+	Version string `json:"version"` // the version compared against, any version if empty
+	Op      string `json:"op"`      // how the version is compared (see SEARCH_VERSION_*)
+}
+
+// Validate the search, a regex name is matched with the database's regex syntax so it is checked by the database
+func (search *PackageSearch) Validate() error {
+	if strings.TrimSpace(search.Name) == "" {
+		return errors.New("package search has no name")
+	}
+	switch search.Match {
+	case SEARCH_MATCH_EXACT, SEARCH_MATCH_PREFIX, SEARCH_MATCH_REGEX:
+	default:
+		return fmt.Errorf("package search match must be %q, %q or %q", SEARCH_MATCH_EXACT, SEARCH_MATCH_PREFIX, SEARCH_MATCH_REGEX)
+	}
+	switch search.Source {
+	case "", PACKAGE_SOURCE_CHOCO, PACKAGE_SOURCE_SYSTEM:
+	default:
+		return fmt.Errorf("package search source must be %q or %q", PACKAGE_SOURCE_CHOCO, PACKAGE_SOURCE_SYSTEM)
+	}
+	switch search.Op {
+	case SEARCH_VERSION_LT, SEARCH_VERSION_LE, SEARCH_VERSION_EQ, SEARCH_VERSION_NE, SEARCH_VERSION_GE, SEARCH_VERSION_GT:
+	default:
+		return fmt.Errorf("package search op %q is not a version comparison", search.Op)
+	}
+	return nil
+}
+
+// Check if a package version satisfies the version comparison of the search, using chocolatey's version ordering
+func (search *PackageSearch) MatchesVersion(version string) bool {
+	if search.Version == "" {
+		return true
+	}
+	c := util.CompareVersions(version, search.Version)
+	switch search.Op {
+	case SEARCH_VERSION_LT:
+		return c < 0
+	case SEARCH_VERSION_LE:
+		return c <= 0
+	case SEARCH_VERSION_EQ:
+		return c == 0
+	case SEARCH_VERSION_NE:
+		return c != 0
+	case SEARCH_VERSION_GE:
+		return c >= 0
+	case SEARCH_VERSION_GT:
+		return c > 0
+	}
+	return false
+}
+
+// A package in a node's inventory which matched a package search
+type PackageSearchResult struct {
+	NodeID   uuid.UUID `json:"node_id"`  // the node which reported the package
+	Hostname string    `json:"hostname"` // the node's system hostname
+	Label    *string   `json:"label"`    // the node's admin-provided name (if any)
+	Source   string    `json:"source"`   // the list the package is reported in (see PACKAGE_SOURCE_*)
+	Name     string    `json:"name"`     // the package name
+	Version  string    `json:"version"`  // the installed version
+}
+
+type NodeSources struct {
+	Desired    util.RepositoryList `json:"desired"`     // the effective sources assigned to the node
+	Reported   util.RepositoryList `json:"reported"`    // the sources the node last reported as configured
+	ReportedAt *time.Time          `json:"reported_at"` // when the node last reported its sources, nil if it never has
+	Drift      []string            `json:"drift"`       // names of the sources which differ between desired and reported
+}
+
+type Baseline struct {
+	ID             uuid.UUID              `json:"id"`              // random baseline ID
+	OrganizationID uuid.UUID              `json:"organization_id"` // organization in which the baseline exists
+	Name           string                 `json:"name"`            // name of the baseline (unique within the org, case-insensitive)
+	Entries        util.BaselineEntryList `json:"entries"`         // the packages which must be present or absent
+	UpdatedAt      time.Time              `json:"updated_at"`      // when the baseline was created or last updated
+}
+
+type BaselineRequest struct {
+	Name    string                 `json:"name"`
+	Entries util.BaselineEntryList `json:"entries"`
+}
+
+// Reasons a node does not comply with an entry of its baseline
+const (
+	DRIFT_MISSING  = "missing"  // the package must be present but is not installed
+	DRIFT_OUTDATED = "outdated" // the package is installed below the minimum version
+	DRIFT_UNWANTED = "unwanted" // the package must be absent but is installed
+)
+
+// A baseline entry the node does not comply with, along with the job queued to remediate it
+type BaselineDrift struct {
+	BaselineID uuid.UUID  `json:"baseline_id"`           // the baseline which the entry was inherited from
+	Name       string     `json:"name"`                  // the package name
+	Ensure     string     `json:"ensure"`                // whether the package must be present or absent
+	MinVersion *string    `json:"min_version,omitempty"` // the minimum version required by the entry (if any)
+	Installed  *string    `json:"installed"`             // the version installed on the node, nil if it is not installed
+	Reason     string     `json:"reason"`                // why the node does not comply (see DRIFT_*)
+	JobID      *uuid.UUID `json:"job_id"`                // the active job for this package, nil if remediation is held back
+}
+
+type BaselineDriftList []BaselineDrift
+
+type NodeCompliance struct {
+	Compliant   bool                   `json:"compliant"`    // the node complies with every entry of its baseline
+	Baseline    util.BaselineEntryList `json:"baseline"`     // the effective baseline of the node, combined from every assignment
+	Drift       BaselineDriftList      `json:"drift"`        // the entries the node does not comply with
+	EvaluatedAt *time.Time             `json:"evaluated_at"` // when the compliance was last evaluated, nil if it never was
+}
+
+// The software maps of an organization, used to find system software which chocolatey can adopt
+type SoftwareMapCatalog struct {
+	IncludeDefaults bool                 `json:"include_defaults"` // the built-in software maps are checked after the organization's own
+	SoftwareMaps    util.SoftwareMapList `json:"software_maps"`    // the organization's own software maps, checked in order
+}
+
+// Software reported by the system which a software map matched to a chocolatey package that is not installed
+type AdoptableSoftware struct {
+	Name    string `json:"name"`    // the system-reported software name
+	Version string `json:"version"` // the system-reported software version
+	Package string `json:"package"` // the chocolatey package which can adopt the software
+}
+
+// Request to adopt the adoptable software of a node
+type AdoptRequest struct {
+	Packages []string `json:"packages"` // the chocolatey packages to adopt, every adoptable package if empty
+}
+
+// Credentials of a single source entry, a nil value removes that credential
+type SourceCredentialsRequest struct {
+	Password            *string `json:"password"`             // password of the source entry's user
+	CertificatePassword *string `json:"certificate_password"` // password of the source entry's client certificate
+}
+
+// Package job actions, these match the chocolatey actions performed by the client
+const (
+	JOB_ACTION_INSTALL   = 1
+	JOB_ACTION_UPGRADE   = 2
+	JOB_ACTION_UNINSTALL = 3
+
+	JOB_DEFAULT_TIMEOUT = 600 // default of 10 minutes, matches the database default
+)
+
+// Package job statuses assigned by the server, all other statuses are reported by the client (see choco.ChocoStatus)
+const (
+	JOB_STATUS_PENDING   = 0  // the job has not yet been completed
+	JOB_STATUS_FAILED    = -1 // the job failed without a result from choco (e.g. every lease expired)
+	JOB_STATUS_CANCELLED = -2 // the job was cancelled before it was completed
+	JOB_STATUS_EXPIRED   = -3 // the job expired before it was completed
+)
+
+// Lifecycle states of a package job
+const (
+	JOB_STATE_QUEUED    = "queued"    // waiting to be leased by its node
+	JOB_STATE_LEASED    = "leased"    // leased by its node, which has not yet heartbeated
+	JOB_STATE_RUNNING   = "running"   // the node is running the job and heartbeating to keep its lease
+	JOB_STATE_SUCCEEDED = "succeeded" // completed with a successful or neutral status
+	JOB_STATE_FAILED    = "failed"    // completed with a failure status or ran out of attempts
+	JOB_STATE_EXPIRED   = "expired"   // expired before it was leased
+	JOB_STATE_CANCELLED = "cancelled" // cancelled by an operator
+)
+
+// Outcomes of a single attempt at a package job
+const (
+	ATTEMPT_OUTCOME_SUCCEEDED     = "succeeded"
+	ATTEMPT_OUTCOME_FAILED        = "failed"
+	ATTEMPT_OUTCOME_CANCELLED     = "cancelled"
+	ATTEMPT_OUTCOME_LEASE_EXPIRED = "lease_expired" // the node stopped heartbeating (e.g. it crashed or rebooted)
+)
+
+// Decisions of the retry policy after an attempt at a package job did not succeed
+const (
+	RETRY_DECISION_RETRY     = "retry"     // the job was requeued to be attempted again
+	RETRY_DECISION_PERMANENT = "permanent" // the result will not change if the job is retried (e.g. unknown package)
+	RETRY_DECISION_EXHAUSTED = "exhausted" // the job ran out of attempts
+)
+
+// Retry policy applied to jobs which set neither their own policy nor inherit one from their organization
+const (
+	DEFAULT_RETRY_MAX_ATTEMPTS        = 5
+	DEFAULT_RETRY_BACKOFF_SECONDS     = 900   // 15 minutes before the first retry
+	DEFAULT_RETRY_BACKOFF_MAX_SECONDS = 86400 // never wait more than a day between retries
+)
+
+// the final state of a job completed with the given status, using the ranges of choco.ChocoStatus:
+// X[0-6] are successes or neutral (e.g. already installed), X[7-9] and negative statuses are failures
+func JobResultState(status int) string {
+	if status > 0 && status%10 <= 6 {
+		return JOB_STATE_SUCCEEDED
+	}
+	return JOB_STATE_FAILED
+}
+
+// How a package job is retried after a transient failure, set per job or for an entire organization
+type RetryPolicy struct {
+	MaxAttempts       int `json:"max_attempts"`        // the job fails once this many attempts did not succeed
+	BackoffSeconds    int `json:"backoff_seconds"`     // delay before the first retry, doubled for every retry after it
+	BackoffMaxSeconds int `json:"backoff_max_seconds"` // upper limit of the delay between retries
+}
+
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:       DEFAULT_RETRY_MAX_ATTEMPTS,
+		BackoffSeconds:    DEFAULT_RETRY_BACKOFF_SECONDS,
+		BackoffMaxSeconds: DEFAULT_RETRY_BACKOFF_MAX_SECONDS,
+	}
+}
+
+// the delay in seconds before the job is retried after the given attempt failed
+func (policy *RetryPolicy) Backoff(attempt int) int {
+	backoff := policy.BackoffSeconds
+	for i := 1; i < attempt && backoff < policy.BackoffMaxSeconds; i++ {
+		backoff *= 2
+	}
+	return min(backoff, policy.BackoffMaxSeconds)
+}
+
+// Modes of an automatic upgrade policy
+const (
+	UPGRADE_MODE_ALL  = "all"  // upgrade every outdated package except the listed packages
+	UPGRADE_MODE_ONLY = "only" // upgrade only the listed packages
+)
+
+// Which outdated packages are upgraded automatically, set for an entire organization or a group
+type UpgradePolicy struct {
+	Mode     string   `json:"mode"`     // whether the packages are excluded from or the only packages upgraded (see UPGRADE_MODE_*)
+	Packages []string `json:"packages"` // chocolatey package names (case-insensitive)
+}
+
+// the policy upgrades the package with the given name
+func (policy *UpgradePolicy) Selects(name string) bool {
+	listed := slices.ContainsFunc(policy.Packages, func(pkg string) bool {
+		return strings.EqualFold(pkg, name)
+	})
+	if policy.Mode == UPGRADE_MODE_ONLY {
+		return listed
+	}
+	return !listed
+}
+
 type PackageJobParameters struct {
 	Name             string  `json:"name"`               // target package name
-	Version          *string `json:"string,omitempty"`   // target package version (optional)
+	Version          *string `json:"version,omitempty"`  // target package version (optional)
 	Timeout          int     `json:"timeout"`            // timeout for the command (sans grace period)
 	IgnoreChecksum   bool    `json:"ignore_checksum"`    // ignore package checksum
 	InstallOnUpgrade bool    `json:"install_on_upgrade"` // install if missing (upgrade action only)
 	Force            bool    `json:"force"`              // force the action
 	VerboseOutput    bool    `json:"verbose_output"`     // verbose output
-	NotSilent        bool    `json:"bool"`               // disable silent install
+	NotSilent        bool    `json:"not_silent"`         // disable silent install
+	SkipPowershell   bool    `json:"skip_powershell"`    // register the package without running its scripts (install action only)
+
+	// chocolatey package parameters (e.g. license keys), encrypted at rest and only ever delivered sealed to the node
+	PackageParameters *string `json:"package_parameters,omitempty"`
+}
+
+// the keys the version and not silent flag were sent as before they were renamed, they are still written and read for
+// one release so clients of the previous release keep both and can be upgraded after the server
+type packageJobParametersLegacy struct {
+	Version   *string `json:"string,omitempty"`
+	NotSilent bool    `json:"bool,omitempty"`
+}
+
+func (params PackageJobParameters) MarshalJSON() ([]byte, error) {
+	type plain PackageJobParameters
+	return json.Marshal(struct {
+		plain
+		packageJobParametersLegacy
+	}{plain(params), packageJobParametersLegacy{Version: params.Version, NotSilent: params.NotSilent}})
+}
+
+func (params *PackageJobParameters) UnmarshalJSON(data []byte) error {
+	type plain PackageJobParameters
+	var decoded struct {
+		plain
+		packageJobParametersLegacy
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*params = PackageJobParameters(decoded.plain)
+	if params.Version == nil {
+		params.Version = decoded.packageJobParametersLegacy.Version
+	}
+	params.NotSilent = params.NotSilent || decoded.packageJobParametersLegacy.NotSilent
+	return nil
 }
 
 type PackageJobResult struct {
@@ -104,19 +610,98 @@ type PackageJobResult struct {
 	Error    *string `json:"error"`
 }
 
+// A chunk of output streamed by the node while a package job is running
+type PackageJobOutput struct {
+	Output string `json:"output"` // the chocolatey command output since the previous chunk
+}
+
+type PackageJobOutputResponse struct {
+	Cancelled bool `json:"cancelled"` // the job was cancelled and the node should stop running it
+}
+
 type PackageJob struct {
-	ID             uuid.UUID            `json:"id"`                 // random package job ID
-	NodeID         uuid.UUID            `json:"node_id"`            // target node ID for this job
-	GroupID        *uuid.UUID           `json:"group_id,omitempty"` // the group ID the job was assigned to (if applicable)
-	OrganizationID uuid.UUID            `json:"organization_id"`    // the organization this job/node is associated with
-	Attempts       int                  `json:"attempts"`           // the number of attempts this task has undergone
-	Action         int                  `json:"action"`             // the action that should be performed
-	Parameters     PackageJobParameters `json:"parameters"`         // the parameters passed to chocolatey
-	CreatedAt      time.Time            `json:"created_at"`         // when the task was created
-	ExpiresAt      *time.Time           `json:"expires_at"`         // when the task expires
-	AttemptedAt    *time.Time           `json:"attempted_at"`       // when the task was last attempted
-	CompletedAt    *time.Time           `json:"completed_at"`       // when the task was completed
-	Result         *PackageJobResult    `json:"result"`             // the result of the task if completed
+	ID             uuid.UUID            `json:"id"`                    // random package job ID
+	NodeID         uuid.UUID            `json:"node_id"`               // target node ID for this job
+	GroupID        *uuid.UUID           `json:"group_id,omitempty"`    // the group ID the job was assigned to (if applicable)
+	BaselineID     *uuid.UUID           `json:"baseline_id,omitempty"` // the baseline which queued the job to remediate drift (if applicable)
+	OrganizationID uuid.UUID            `json:"organization_id"`       // the organization this job/node is associated with
+	State          string               `json:"state"`                 // the lifecycle state of the job (see JOB_STATE_*)
+	Attempts       int                  `json:"attempts"`              // the number of leases granted for this task
+	Action         int                  `json:"action"`                // the action that should be performed
+	Parameters     PackageJobParameters `json:"parameters"`            // the parameters passed to chocolatey
+	CreatedAt      time.Time            `json:"created_at"`            // when the task was created
+	ExpiresAt      *time.Time           `json:"expires_at"`            // when the task expires
+	NotBefore      *time.Time           `json:"not_before"`            // when the task may first be run
+	Deadline       *time.Time           `json:"deadline"`              // when the task is run regardless of the maintenance schedule
+	AttemptedAt    *time.Time           `json:"attempted_at"`          // when the task was last attempted
+	LeaseExpiresAt *time.Time           `json:"lease_expires_at"`      // when the current lease is reclaimed unless the node heartbeats
+	RetryAt        *time.Time           `json:"retry_at"`              // when the task may be retried after its last attempt failed
+	CompletedAt    *time.Time           `json:"completed_at"`          // when the task was completed
+	RetryPolicy    *RetryPolicy         `json:"retry_policy"`          // the retry policy of the task, nil to use the organization's
+	Result         *PackageJobResult    `json:"result"`                // the result of the task if completed
+}
+
+// the job has not yet reached a final state
+func (job *PackageJob) Active() bool {
+	switch job.State {
+	case JOB_STATE_QUEUED, JOB_STATE_LEASED, JOB_STATE_RUNNING:
+		return true
+	default:
+		return false
+	}
+}
+
+// A single lease granted for a package job and how it ended
+type PackageJobAttempt struct {
+	Attempt    int        `json:"attempt"`     // the attempt number, starting at 1
+	Outcome    *string    `json:"outcome"`     // how the attempt ended (see ATTEMPT_OUTCOME_*), nil while in progress
+	Status     *int       `json:"status"`      // the choco status of the attempt's result
+	ExitCode   *int       `json:"exit_code"`   // the choco process exit code
+	Output     *string    `json:"output"`      // the chocolatey command output
+	Error      *string    `json:"error"`       // any error running the command
+	LeasedAt   time.Time  `json:"leased_at"`   // when the node leased the job
+	StartedAt  *time.Time `json:"started_at"`  // when the node first heartbeated while running the job
+	FinishedAt *time.Time `json:"finished_at"` // when the attempt ended
+	Decision   *string    `json:"decision"`    // the retry policy's decision if the attempt did not succeed (see RETRY_DECISION_*)
+	RetryAt    *time.Time `json:"retry_at"`    // when the job may be attempted again if it was retried
+}
+
+// Request to create a package job for a single node
+type PackageJobRequest struct {
+	NodeID      uuid.UUID            `json:"node_id"`                // target node ID for this job
+	Action      int                  `json:"action"`                 // the action that should be performed
+	Parameters  PackageJobParameters `json:"parameters"`             // the parameters passed to chocolatey
+	ExpiresAt   *time.Time           `json:"expires_at,omitempty"`   // when the job expires (optional)
+	NotBefore   *time.Time           `json:"not_before,omitempty"`   // when the job may first be run (optional)
+	Deadline    *time.Time           `json:"deadline,omitempty"`     // when the job is run regardless of the maintenance schedule (optional)
+	RetryPolicy *RetryPolicy         `json:"retry_policy,omitempty"` // overrides the organization's retry policy (optional)
+}
+
+// Request to create a package job for every node within a group
+type PackageJobGroupRequest struct {
+	Action      int                  `json:"action"`                 // the action that should be performed
+	Parameters  PackageJobParameters `json:"parameters"`             // the parameters passed to chocolatey
+	ExpiresAt   *time.Time           `json:"expires_at,omitempty"`   // when the jobs expire (optional)
+	NotBefore   *time.Time           `json:"not_before,omitempty"`   // when the jobs may first be run (optional)
+	Deadline    *time.Time           `json:"deadline,omitempty"`     // when the jobs are run regardless of the maintenance schedule (optional)
+	RetryPolicy *RetryPolicy         `json:"retry_policy,omitempty"` // overrides the organization's retry policy (optional)
+}
+
+// Summary of the statuses of the jobs created for a group at the same time
+type PackageJobRollup struct {
+	GroupID   uuid.UUID `json:"group_id"`   // the group ID the jobs were assigned to
+	BatchID   uuid.UUID `json:"batch_id"`   // identifies the request which fanned the jobs out to the group
+	Action    int       `json:"action"`     // the action performed by the jobs
+	Name      string    `json:"name"`       // target package name
+	Version   *string   `json:"version"`    // target package version (if provided)
+	CreatedAt time.Time `json:"created_at"` // when the jobs were created
+	Total     int       `json:"total"`      // the number of jobs created
+	Pending   int       `json:"pending"`    // jobs which have not yet been completed
+	Succeeded int       `json:"succeeded"`  // jobs which completed successfully
+	Neutral   int       `json:"neutral"`    // jobs which did not perform the action, but did not fail (e.g. already installed)
+	Failed    int       `json:"failed"`     // jobs which failed to perform the action
+	Cancelled int       `json:"cancelled"`  // jobs which were cancelled before they were completed
+	Expired   int       `json:"expired"`    // jobs which expired before they were completed
 }
 
 type PackageJobList []uuid.UUID // a node receives a list of job IDs instead of the entire job
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/goodieshq/sweettooth/internal/util"
+)
+
+func TestPackageSearchMatchesVersion(t *testing.T) {
+	tests := []struct {
+		op      string
+		version string
+		want    bool
+	}{
+		{SEARCH_VERSION_LT, "22.9", true},
+		{SEARCH_VERSION_LT, "23.01", false},
+		{SEARCH_VERSION_LT, "23.1.1", false},
+		{SEARCH_VERSION_LE, "23.01", true},
+		{SEARCH_VERSION_LE, "23.1.0", true},
+		{SEARCH_VERSION_LE, "23.1.1", false},
+		{SEARCH_VERSION_EQ, "23.1", true},
+		{SEARCH_VERSION_EQ, "23.01.0", true},
+		{SEARCH_VERSION_EQ, "23.1-beta", false},
+		{SEARCH_VERSION_NE, "23.1-beta", true},
+		{SEARCH_VERSION_NE, "23.1", false},
+		{SEARCH_VERSION_GE, "23.1", true},
+		{SEARCH_VERSION_GE, "24.0", true},
+		{SEARCH_VERSION_GE, "23.1-rc", false},
+		{SEARCH_VERSION_GT, "23.1.0.1", true},
+		{SEARCH_VERSION_GT, "23.1+build", false},
+		{SEARCH_VERSION_GT, "9.0", false},
+	}
+
+	for _, tt := range tests {
+		search := PackageSearch{Name: "7zip", Version: "23.1", Op: tt.op}
+		if got := search.MatchesVersion(tt.version); got != tt.want {
+			t.Errorf("%s %s: MatchesVersion(%q) = %v, want %v", tt.op, search.Version, tt.version, got, tt.want)
+		}
+	}
+
+	anyVersion := PackageSearch{Name: "7zip", Op: SEARCH_VERSION_EQ}
+	if !anyVersion.MatchesVersion("1.0") {
+		t.Error("a search without a version must match any version")
+	}
+
+	unknown := PackageSearch{Name: "7zip", Version: "23.1", Op: "approx"}
+	if unknown.MatchesVersion("23.1") {
+		t.Error("a search with an unknown op must not match")
+	}
+}
+
+var testInventory = Packages{
+	PackagesChoco:    util.SoftwareList{{Name: "7zip", Version: "22.0"}, {Name: "git", Version: "2.45"}},
+	PackagesSystem:   util.SoftwareList{{Name: "vcredist", Version: "14.0"}, {Name: "vcredist", Version: "12.0"}},
+	PackagesOutdated: util.SoftwareOutdatedList{{Name: "7zip", VersionOld: "22.0", VersionNew: "23.1"}},
+}
+
+func TestPackagesHashOrder(t *testing.T) {
+	reordered := Packages{
+		PackagesChoco:    util.SoftwareList{{Name: "git", Version: "2.45"}, {Name: "7zip", Version: "22.0"}},
+		PackagesSystem:   util.SoftwareList{{Name: "vcredist", Version: "12.0"}, {Name: "vcredist", Version: "14.0"}},
+		PackagesOutdated: util.SoftwareOutdatedList{{Name: "7zip", VersionOld: "22.0", VersionNew: "23.1"}},
+	}
+	if testInventory.Hash() != reordered.Hash() {
+		t.Error("the same packages in another order hash differently")
+	}
+
+	empty := Packages{}
+	emptyLists := Packages{PackagesChoco: util.SoftwareList{}, PackagesSystem: util.SoftwareList{}, PackagesOutdated: util.SoftwareOutdatedList{}}
+	if empty.Hash() != emptyLists.Hash() {
+		t.Error("nil and empty lists hash differently")
+	}
+
+	moved := Packages{
+		PackagesChoco:    testInventory.PackagesSystem,
+		PackagesSystem:   testInventory.PackagesChoco,
+		PackagesOutdated: testInventory.PackagesOutdated,
+	}
+	if testInventory.Hash() == moved.Hash() {
+		t.Error("packages moved to another list hash the same")
+	}
+}
+
+func TestPackagesApplyDelta(t *testing.T) {
+	tests := []struct {
+		name    string
+		current Packages
+	}{
+		{"unchanged", testInventory},
+		{"empty", Packages{}},
+		{"reordered", Packages{
+			PackagesChoco:    util.SoftwareList{{Name: "git", Version: "2.45"}, {Name: "7zip", Version: "22.0"}},
+			PackagesSystem:   testInventory.PackagesSystem,
+			PackagesOutdated: testInventory.PackagesOutdated,
+		}},
+		{"upgraded", Packages{
+			PackagesChoco:    util.SoftwareList{{Name: "7zip", Version: "23.1"}, {Name: "git", Version: "2.45"}},
+			PackagesSystem:   util.SoftwareList{{Name: "vcredist", Version: "14.0"}, {Name: "vcredist", Version: "12.0"}},
+			PackagesOutdated: util.SoftwareOutdatedList{},
+		}},
+		{"installed and removed", Packages{
+			PackagesChoco:    util.SoftwareList{{Name: "firefox", Version: "128"}, {Name: "git", Version: "2.46"}},
+			PackagesSystem:   util.SoftwareList{{Name: "vcredist", Version: "12.0"}, {Name: "Zoom", Version: "6.0"}},
+			PackagesOutdated: util.SoftwareOutdatedList{{Name: "git", VersionOld: "2.46", VersionNew: "2.47", Pinned: true}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delta := NewPackagesDelta(&testInventory, testInventory.Hash(), &tt.current)
+			applied, err := testInventory.ApplyDelta(delta)
+			if err != nil {
+				t.Fatalf("ApplyDelta: %v", err)
+			}
+			if applied.Hash() != tt.current.Hash() {
+				t.Errorf("applied delta = %+v, want %+v", applied, tt.current)
+			}
+		})
+	}
+}
+
+func TestPackagesApplyDeltaStale(t *testing.T) {
+	current := Packages{
+		PackagesChoco:    util.SoftwareList{{Name: "7zip", Version: "23.1"}, {Name: "git", Version: "2.45"}},
+		PackagesSystem:   testInventory.PackagesSystem,
+		PackagesOutdated: util.SoftwareOutdatedList{},
+	}
+	stale := Packages{
+		PackagesChoco:    util.SoftwareList{{Name: "7zip", Version: "21.0"}, {Name: "git", Version: "2.45"}},
+		PackagesSystem:   testInventory.PackagesSystem,
+		PackagesOutdated: testInventory.PackagesOutdated,
+	}
+	delta := NewPackagesDelta(&testInventory, testInventory.Hash(), &current)
+
+	if _, err := stale.ApplyDelta(delta); !errors.Is(err, ErrPackagesDeltaBase) {
+		t.Errorf("stale inventory: got %v, want %v", err, ErrPackagesDeltaBase)
+	}
+
+	// a delta claiming the stale inventory as its base still cannot change packages which are not in it
+	forged := *delta
+	forged.BaseHash = stale.Hash()
+	if _, err := stale.ApplyDelta(&forged); err == nil {
+		t.Error("applied a delta computed against another inventory")
+	}
+
+	wrongHash := *delta
+	wrongHash.Hash = stale.Hash()
+	if _, err := testInventory.ApplyDelta(&wrongHash); !errors.Is(err, ErrPackagesDeltaHash) {
+		t.Errorf("wrong result hash: got %v, want %v", err, ErrPackagesDeltaHash)
+	}
+}
@@ -1,11 +1,15 @@
 package client
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"net/http"
 	"strings"
 	"sync"
 
+	"github.com/goodieshq/sweettooth/internal/client/keys"
 	"github.com/goodieshq/sweettooth/internal/schedule"
+	"github.com/goodieshq/sweettooth/internal/util"
 	"github.com/goodieshq/sweettooth/pkg/api"
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
@@ -64,16 +68,21 @@ func NewSweetToothClient(serverUrl string) *SweetToothClient {
 	}
 }
 
-func (client *SweetToothClient) Check() error {
+func (client *SweetToothClient) Check() (*api.CheckResponse, error) {
+	var check api.CheckResponse
+
 	_, err := client.doRequest(&requestParams{
 		method:     http.MethodGet,
 		path:       "/api/v1/node/check",
 		authorized: true,
+		target:     &check,
 	})
-	if err == nil {
-		client.Registered = true
+	if err != nil {
+		return nil, err
 	}
-	return err
+
+	client.Registered = true
+	return &check, nil
 }
 
 func (client *SweetToothClient) GetSchedule() (schedule.Schedule, error) {
@@ -94,6 +103,22 @@ func (client *SweetToothClient) GetSchedule() (schedule.Schedule, error) {
 	return schedule.Schedule(sched), nil
 }
 
+func (client *SweetToothClient) ConfirmSchedule(sched schedule.Schedule) error {
+	log.Trace().Msg("client.ConfirmSchedule called")
+
+	_, err := client.doRequest(&requestParams{
+		method:     http.MethodPut,
+		path:       "/api/v1/node/schedule",
+		authorized: true,
+		body:       api.Schedule(sched),
+		optionalMap: StatusMap{
+			http.StatusNoContent: nil,
+		},
+	})
+
+	return err
+}
+
 func (client *SweetToothClient) GetPackages() (*api.Packages, error) {
 	log.Trace().Msg("client.GetPackages called")
 
@@ -114,21 +139,117 @@ func (client *SweetToothClient) GetPackages() (*api.Packages, error) {
 	return &pkg, nil
 }
 
-func (client *SweetToothClient) UpdatePackages(packages *api.Packages) error {
+func (client *SweetToothClient) GetPackagesHash() (string, error) {
+	log.Trace().Msg("client.GetPackagesHash called")
+
+	var hash api.PackagesHash
+
+	_, err := client.doRequest(&requestParams{
+		method:     http.MethodGet,
+		path:       "/api/v1/node/packages/hash",
+		authorized: true,
+		target:     &hash,
+	})
+
+	if err != nil {
+		log.Error().Err(err).Msg("failed to acquire package inventory hash")
+		return "", err
+	}
+
+	return hash.Hash, nil
+}
+
+// replace the server's whole package inventory, returning the hash of the server's new inventory
+func (client *SweetToothClient) UpdatePackages(packages *api.Packages) (string, error) {
 	log.Trace().Msg("client.UpdatePackages called")
 
+	var hash api.PackagesHash
+
 	_, err := client.doRequest(&requestParams{
 		method:     http.MethodPut,
 		path:       "/api/v1/node/packages",
 		authorized: true,
 		body:       packages,
-		optionalMap: StatusMap{ // all of these responses for this endpoint mean the device is successfully registered
+		target:     &hash,
+	})
+
+	if err != nil {
+		log.Debug().Msg("failed to update server's package inventory")
+		return "", err
+	}
+
+	return hash.Hash, nil
+}
+
+// sign and apply a delta to the server's package inventory, returning the hash of the server's new inventory. Returns
+// ErrPackagesConflict if the server's inventory is no longer the one the delta was computed against
+func (client *SweetToothClient) UpdatePackagesDelta(delta *api.PackagesDelta) (string, error) {
+	log.Trace().Msg("client.UpdatePackagesDelta called")
+
+	data, err := json.Marshal(delta)
+	if err != nil {
+		return "", err
+	}
+
+	var hash api.PackagesHash
+
+	_, err = client.doRequest(&requestParams{
+		method:     http.MethodPatch,
+		path:       "/api/v1/node/packages",
+		authorized: true,
+		body: api.SignedPackagesDelta{
+			Delta:     data,
+			Signature: base64.StdEncoding.EncodeToString(keys.Sign(data)),
+		},
+		target: &hash,
+		optionalMap: StatusMap{
+			http.StatusConflict: ErrPackagesConflict,
+		},
+	})
+
+	if err != nil {
+		log.Debug().Msg("failed to apply delta to server's package inventory")
+		return "", err
+	}
+
+	return hash.Hash, nil
+}
+
+func (client *SweetToothClient) GetSources() (util.RepositoryList, error) {
+	log.Trace().Msg("client.GetSources called")
+
+	var sources util.RepositoryList
+
+	_, err := client.doRequest(&requestParams{
+		method:     http.MethodGet,
+		path:       "/api/v1/node/sources",
+		authorized: true,
+		target:     &sources,
+	})
+
+	if err != nil {
+		log.Error().Err(err).Msg("failed to acquire assigned sources")
+		return nil, err
+	}
+
+	return sources, nil
+}
+
+func (client *SweetToothClient) UpdateSources(sources util.RepositoryList) error {
+	log.Trace().Msg("client.UpdateSources called")
+
+	_, err := client.doRequest(&requestParams{
+		method:     http.MethodPut,
+		path:       "/api/v1/node/sources",
+		authorized: true,
+		body:       sources,
+		optionalMap: StatusMap{
 			http.StatusNoContent: nil,
 		},
 	})
 
 	if err != nil {
-		log.Debug().Msg("failed to update server's package inventory")
+		log.Debug().Msg("failed to update server's source inventory")
 		return err
 	}
 
@@ -197,12 +318,37 @@ func (client *SweetToothClient) CompletePackageJob(jobid uuid.UUID, result *api.
 	return err
 }
 
-func (client *SweetToothClient) GetPackageJobs() (api.PackageJobList, error) {
+// stream a chunk of a running job's output, returns true if the job was cancelled and should be stopped
+func (client *SweetToothClient) AppendPackageJobOutput(jobid uuid.UUID, output string) (bool, error) {
+	var res api.PackageJobOutputResponse
+
+	_, err := client.doRequest(&requestParams{
+		method:     http.MethodPost,
+		path:       "/api/v1/node/packages/jobs/" + jobid.String() + "/output",
+		authorized: true,
+		body:       &api.PackageJobOutput{Output: output},
+		target:     &res,
+	})
+
+	if err != nil {
+		return false, err
+	}
+
+	return res.Cancelled, nil
+}
+
+// get the list of pending job IDs, or only those past their deadline if overdue is set
+func (client *SweetToothClient) GetPackageJobs(overdue bool) (api.PackageJobList, error) {
 	var jobs api.PackageJobList
 
+	path := "/api/v1/node/packages/jobs"
+	if overdue {
+		path += "?overdue=true"
+	}
+
 	_, err := client.doRequest(&requestParams{
 		method:     http.MethodGet,
-		path:       "/api/v1/node/packages/jobs",
+		path:       path,
 		authorized: true,
 		target:     &jobs,
 	})
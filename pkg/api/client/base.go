@@ -2,6 +2,7 @@ package client
 
 import (
 	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"net/http"
 
@@ -25,12 +26,14 @@ type requestParams struct {
  * - method = http method
  */
 func (cli *SweetToothClient) doRequest(params *requestParams) (*http.Response, error) {
-	// convert the request data to JSON
-	var body []byte
+	// convert the request data to gzip-compressed JSON
+	var body bytes.Buffer
 	if params.body != nil {
-		var err error
-		body, err = json.Marshal(params.body)
-		if err != nil {
+		gz := gzip.NewWriter(&body)
+		if err := json.NewEncoder(gz).Encode(params.body); err != nil {
+			return nil, err
+		}
+		if err := gz.Close(); err != nil {
 			return nil, err
 		}
 	}
@@ -41,13 +44,17 @@ func (cli *SweetToothClient) doRequest(params *requestParams) (*http.Response, e
 	// add URL to logs
 	log := log.With().Str("url", url).Logger()
 
-	req, err := http.NewRequest(params.method, url, bytes.NewBuffer(body))
+	req, err := http.NewRequest(params.method, url, &body)
 	if err != nil {
 		log.Panic().Err(err).Send()
 	}
 
 	// Set the JSON header for all requests
 	req.Header.Set("Content-Type", "application/json")
+	if params.body != nil {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	// Accept-Encoding is left to the transport, which then transparently decompresses gzip responses
 
 	// set the authorization header if the request is authorized
 	if params.authorized {
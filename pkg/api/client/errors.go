@@ -12,6 +12,7 @@ var (
 	ErrNodeNotApproved       = errors.New("node is not approved")
 	ErrNodeNotRegistered     = errors.New("node is not registered")
 	ErrNodeAlreadyRegistered = errors.New("node is already registered")
+	ErrPackagesConflict      = errors.New("package delta does not apply to the server's inventory")
 )
 
 type StatusMap map[int]error
@@ -130,6 +130,11 @@ func Cache() string {
 	return configPath("cache.json")
 }
 
+// the names of the chocolatey sources added by the client, so they can be removed once they are no longer assigned
+func ManagedSources() string {
+	return configPath("sources.json")
+}
+
 func LogDir() string {
 	return dirLogs()
 }
@@ -0,0 +1,113 @@
+-- Moves a database created before node inventories were stored as one row per package to the current schema. Run it
+-- after schema.sql, which creates node_packages but leaves the existing nodes and node_package_changelog tables as
+-- they were. It does nothing when the nodes table no longer has the packages_* columns, so it is safe to run again.
+--
+-- Before: every node kept its inventory in the packages_choco, packages_system and packages_outdated JSONB columns,
+-- and every inventory update stored the previous inventory as a whole in node_package_changelog.
+-- After: the inventory is in node_packages, and node_package_changelog has one row for every package installed or
+-- removed, computed from each stored inventory and the one before it.
+BEGIN;
+
+DO $$
+BEGIN
+  IF NOT EXISTS (
+    SELECT 1 FROM information_schema.columns
+    WHERE table_schema = current_schema() AND table_name = 'nodes' AND column_name = 'packages_choco'
+  ) THEN
+    RETURN;
+  END IF;
+
+  -- the inventory of every node, in the order each list was reported (a list stored as JSON null is empty)
+  INSERT INTO node_packages (node_id, organization_id, source, position, name, version, version_new, pinned)
+  SELECT
+    n.id, n.organization_id, p.source, p.position, p.name, p.version, p.version_new, p.pinned
+  FROM
+    nodes n
+  CROSS JOIN LATERAL (
+    SELECT 'choco' AS source, (ord - 1)::int AS position, sw->>'name' AS name, sw->>'version' AS version,
+      NULL::text AS version_new, FALSE AS pinned
+    FROM jsonb_array_elements(CASE jsonb_typeof(n.packages_choco) WHEN 'array' THEN n.packages_choco ELSE '[]' END) WITH ORDINALITY AS e(sw, ord)
+    UNION ALL
+    SELECT 'system', (ord - 1)::int, sw->>'name', sw->>'version', NULL, FALSE
+    FROM jsonb_array_elements(CASE jsonb_typeof(n.packages_system) WHEN 'array' THEN n.packages_system ELSE '[]' END) WITH ORDINALITY AS e(sw, ord)
+    UNION ALL
+    SELECT 'outdated', (ord - 1)::int, sw->>'name', sw->>'version_old', NULLIF(sw->>'version_new', ''),
+      COALESCE((sw->>'pinned')::boolean, FALSE)
+    FROM jsonb_array_elements(CASE jsonb_typeof(n.packages_outdated) WHEN 'array' THEN n.packages_outdated ELSE '[]' END) WITH ORDINALITY AS e(sw, ord)
+  ) p
+  WHERE
+    NOT EXISTS (SELECT 1 FROM node_packages np WHERE np.node_id = n.id);
+
+  -- schema.sql created the changelog indexes on the old table, they are recreated on the new one along with its key
+  ALTER TABLE node_package_changelog RENAME TO node_package_changelog_inventories;
+  ALTER TABLE node_package_changelog_inventories RENAME CONSTRAINT node_package_changelog_pkey TO node_package_changelog_inventories_pkey;
+  ALTER SEQUENCE IF EXISTS node_package_changelog_id_seq RENAME TO node_package_changelog_inventories_id_seq;
+  ALTER INDEX IF EXISTS node_package_changelog_node_idx RENAME TO node_package_changelog_inventories_node_idx;
+  ALTER INDEX IF EXISTS node_package_changelog_org_idx RENAME TO node_package_changelog_inventories_org_idx;
+
+  CREATE TABLE node_package_changelog (
+    id BIGSERIAL PRIMARY KEY,
+    node_id UUID NOT NULL REFERENCES nodes(id),
+    organization_id UUID NOT NULL REFERENCES organizations(id),
+    source TEXT NOT NULL,
+    change TEXT NOT NULL,
+    name TEXT NOT NULL,
+    version TEXT NOT NULL,
+    timestamp TIMESTAMP NOT NULL
+  );
+  CREATE INDEX node_package_changelog_node_idx ON node_package_changelog(node_id, timestamp);
+  CREATE INDEX node_package_changelog_org_idx ON node_package_changelog(organization_id, timestamp);
+
+  -- every inventory a node reported, oldest first: the stored previous inventories, then the current one
+  WITH inventories AS (
+    SELECT
+      node_id, organization_id, packages_choco, packages_system, timestamp,
+      row_number() OVER (PARTITION BY node_id ORDER BY timestamp, is_current, id) AS seq
+    FROM (
+      SELECT node_id, organization_id, packages_choco, packages_system, timestamp, FALSE AS is_current, id::bigint
+      FROM node_package_changelog_inventories
+      UNION ALL
+      SELECT id, organization_id, packages_choco, packages_system, packages_updated_at, TRUE, 0
+      FROM nodes
+    ) all_inventories
+  ),
+  -- how many times each package is listed in each inventory, a package can be listed more than once
+  counted AS (
+    SELECT i.node_id, i.seq, p.source, p.name, p.version, count(*) AS listed
+    FROM inventories i
+    CROSS JOIN LATERAL (
+      SELECT 'choco' AS source, sw->>'name' AS name, sw->>'version' AS version
+      FROM jsonb_array_elements(CASE jsonb_typeof(i.packages_choco) WHEN 'array' THEN i.packages_choco ELSE '[]' END) AS sw
+      UNION ALL
+      SELECT 'system', sw->>'name', sw->>'version'
+      FROM jsonb_array_elements(CASE jsonb_typeof(i.packages_system) WHEN 'array' THEN i.packages_system ELSE '[]' END) AS sw
+    ) p
+    GROUP BY i.node_id, i.seq, p.source, p.name, p.version
+  ),
+  -- the difference of each inventory from the one before it, the first inventory of a node is its registration
+  differences AS (
+    SELECT i.node_id, i.organization_id, i.timestamp, i.seq, d.source, d.name, d.version, d.listed
+    FROM inventories i
+    CROSS JOIN LATERAL (
+      SELECT source, name, version, COALESCE(cur.listed, 0) - COALESCE(prev.listed, 0) AS listed
+      FROM (SELECT * FROM counted WHERE counted.node_id = i.node_id AND counted.seq = i.seq) cur
+      FULL OUTER JOIN (SELECT * FROM counted WHERE counted.node_id = i.node_id AND counted.seq = i.seq - 1) prev
+      USING (source, name, version)
+    ) d
+    WHERE i.seq > 1 AND d.listed != 0
+  )
+  INSERT INTO node_package_changelog (node_id, organization_id, source, change, name, version, timestamp)
+  SELECT
+    node_id, organization_id, source, CASE WHEN listed > 0 THEN 'installed' ELSE 'removed' END, name, version, timestamp
+  FROM
+    differences, generate_series(1, abs(listed))
+  ORDER BY
+    node_id, seq, listed < 0, source, name, version;
+
+  DROP TABLE node_package_changelog_inventories;
+
+  ALTER TABLE nodes DROP COLUMN packages_choco, DROP COLUMN packages_system, DROP COLUMN packages_outdated;
+END
+$$;
+
+COMMIT;
@@ -0,0 +1,229 @@
+-- name: GetCombinedBaselinesByNode :many
+-- every baseline which applies to a node, ordered from the least to the most specific assignment
+SELECT
+    baselines.*,
+    0::int AS precedence
+FROM
+    baselines
+JOIN
+    organization_baseline_assignments oba ON oba.baseline_id = baselines.id
+JOIN
+    nodes ON nodes.organization_id = oba.organization_id
+WHERE
+    nodes.id = @node_id
+UNION ALL
+SELECT
+    baselines.*,
+    1::int AS precedence
+FROM
+    baselines
+JOIN
+    group_baseline_assignments gba ON gba.baseline_id = baselines.id
+JOIN
+    node_group_assignments nga ON nga.group_id = gba.group_id
+WHERE
+    nga.node_id = @node_id
+UNION ALL
+SELECT
+    baselines.*,
+    2::int AS precedence
+FROM
+    baselines
+JOIN
+    node_baseline_assignments nba ON nba.baseline_id = baselines.id
+WHERE
+    nba.node_id = @node_id
+ORDER BY
+    precedence ASC,
+    name ASC;
+
+
+-- name: GetBaselineNodeIDs :many
+-- every node which inherits the baseline through any of its assignments
+SELECT
+    nodes.id
+FROM
+    nodes
+WHERE
+    nodes.organization_id=@organization_id AND (
+        nodes.id IN (
+            SELECT nba.node_id FROM node_baseline_assignments nba WHERE nba.baseline_id=@baseline_id
+        ) OR nodes.id IN (
+            SELECT
+                nga.node_id
+            FROM
+                node_group_assignments nga
+            JOIN
+                group_baseline_assignments gba ON gba.group_id = nga.group_id
+            WHERE
+                gba.baseline_id=@baseline_id
+        ) OR EXISTS (
+            SELECT 1 FROM organization_baseline_assignments oba WHERE oba.baseline_id=@baseline_id AND oba.organization_id=nodes.organization_id
+        )
+    );
+
+
+-- name: GetBaselinesByOrgID :many
+SELECT
+    *
+FROM
+    baselines
+WHERE
+    organization_id=@organization_id
+ORDER BY
+    CASE WHEN @sort::text = 'DESC' THEN name END DESC,
+    name ASC
+LIMIT @page_limit::int OFFSET @page_offset::int;
+
+
+-- name: GetBaseline :one
+SELECT
+    *
+FROM
+    baselines
+WHERE
+    id=$1 AND organization_id=$2
+LIMIT 1;
+
+
+-- name: CreateBaseline :one
+INSERT INTO
+    baselines (
+        organization_id, name, entries
+    )
+VALUES (
+    $1, $2, $3
+)
+RETURNING *;
+
+
+-- name: UpdateBaseline :one
+UPDATE
+    baselines
+SET
+    name=$3,
+    entries=$4,
+    updated_at=CURRENT_TIMESTAMP
+WHERE
+    id=$1 AND organization_id=$2
+RETURNING *;
+
+
+-- name: DeleteBaseline :execrows
+DELETE FROM
+    baselines
+WHERE
+    id=$1 AND organization_id=$2;
+
+
+-- name: DeleteBaselineAssignments :exec
+-- remove every assignment of a baseline so the baseline itself can be deleted
+WITH node_assignments AS (
+    DELETE FROM node_baseline_assignments nba WHERE nba.baseline_id=$1
+), group_assignments AS (
+    DELETE FROM group_baseline_assignments gba WHERE gba.baseline_id=$1
+)
+DELETE FROM
+    organization_baseline_assignments oba
+WHERE
+    oba.baseline_id=$1;
+
+
+-- name: CreateNodeBaselineAssignment :exec
+INSERT INTO
+    node_baseline_assignments (
+        baseline_id, node_id, organization_id
+    )
+VALUES (
+    $1, $2, $3
+)
+ON CONFLICT DO NOTHING;
+
+
+-- name: DeleteNodeBaselineAssignment :execrows
+DELETE FROM
+    node_baseline_assignments
+WHERE
+    baseline_id=$1 AND node_id=$2 AND organization_id=$3;
+
+
+-- name: CreateGroupBaselineAssignment :exec
+INSERT INTO
+    group_baseline_assignments (
+        baseline_id, group_id, organization_id
+    )
+VALUES (
+    $1, $2, $3
+)
+ON CONFLICT DO NOTHING;
+
+
+-- name: DeleteGroupBaselineAssignment :execrows
+DELETE FROM
+    group_baseline_assignments
+WHERE
+    baseline_id=$1 AND group_id=$2 AND organization_id=$3;
+
+
+-- name: CreateOrganizationBaselineAssignment :exec
+INSERT INTO
+    organization_baseline_assignments (
+        baseline_id, organization_id
+    )
+VALUES (
+    $1, $2
+)
+ON CONFLICT DO NOTHING;
+
+
+-- name: DeleteOrganizationBaselineAssignment :execrows
+DELETE FROM
+    organization_baseline_assignments
+WHERE
+    baseline_id=$1 AND organization_id=$2;
+
+
+-- name: GetNodeRemediationJobs :many
+-- the jobs which prevent drift from being remediated again: any active job, and the jobs queued by a baseline which
+-- failed, were cancelled, or succeeded without the node's packages having changed since
+SELECT
+    id,
+    name,
+    baseline_id,
+    state,
+    created_at
+FROM
+    package_jobs
+WHERE
+    node_id=$1 AND (
+        state IN ('queued', 'leased', 'running') OR (baseline_id IS NOT NULL AND (
+            state IN ('failed', 'cancelled') OR (
+                state = 'succeeded' AND completed_at >= (SELECT packages_updated_at FROM nodes WHERE nodes.id=$1)
+            )
+        ))
+    )
+ORDER BY created_at DESC;
+
+
+-- name: GetNodeCompliance :one
+SELECT
+    *
+FROM
+    node_baseline_compliance
+WHERE
+    node_id=$1
+LIMIT 1;
+
+
+-- name: UpdateNodeCompliance :exec
+INSERT INTO
+    node_baseline_compliance (
+        node_id, compliant, drift
+    )
+VALUES (
+    $1, $2, $3
+)
+ON CONFLICT (node_id) DO UPDATE SET
+    compliant=EXCLUDED.compliant,
+    drift=EXCLUDED.drift,
+    evaluated_at=CURRENT_TIMESTAMP;
@@ -0,0 +1,66 @@
+-- name: GetGroupByID :one
+SELECT
+    *
+FROM
+    groups
+WHERE
+    id=$1 AND organization_id=$2
+LIMIT 1;
+
+
+-- name: GetGroupUpgradePolicy :one
+SELECT
+    upgrade_policy
+FROM
+    group_upgrade_policies
+WHERE
+    group_id=$1 AND organization_id=$2
+LIMIT 1;
+
+
+-- name: SetGroupUpgradePolicy :execrows
+-- the group must exist within the organization
+INSERT INTO
+    group_upgrade_policies (
+        group_id, organization_id, upgrade_policy
+    )
+SELECT
+    groups.id, groups.organization_id, @upgrade_policy
+FROM
+    groups
+WHERE
+    groups.id=@group_id AND groups.organization_id=@organization_id
+ON CONFLICT (group_id) DO UPDATE SET
+    upgrade_policy=EXCLUDED.upgrade_policy;
+
+
+-- name: DeleteGroupUpgradePolicy :execrows
+DELETE FROM
+    group_upgrade_policies
+WHERE
+    group_id=$1 AND organization_id=$2;
+
+
+-- name: GetNodeUpgradePolicies :many
+-- every upgrade policy which applies to a node, ordered from the least to the most specific
+SELECT
+    oup.upgrade_policy,
+    0::int AS precedence
+FROM
+    organization_upgrade_policies oup
+JOIN
+    nodes ON nodes.organization_id = oup.organization_id
+WHERE
+    nodes.id = @node_id
+UNION ALL
+SELECT
+    gup.upgrade_policy,
+    1::int AS precedence
+FROM
+    group_upgrade_policies gup
+JOIN
+    node_group_assignments nga ON nga.group_id = gup.group_id
+WHERE
+    nga.node_id = @node_id
+ORDER BY
+    precedence ASC;
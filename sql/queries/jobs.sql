@@ -8,14 +8,28 @@ WHERE
 ORDER BY created_at ASC;
 
 -- name: GetPackageJobListByNodeID :many
+-- only jobs which are due, have not expired, and are not backing off from a failure are provided, optionally only those past their deadline
 SELECT
     id
 FROM
     package_jobs
 WHERE
-    node_id=$1 AND status = 0 AND (attempts < $2 OR $2 = 0)
+    node_id=@node_id AND state = 'queued' AND (attempts < @attempts OR @attempts = 0)
+    AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)
+    AND (not_before IS NULL OR not_before <= CURRENT_TIMESTAMP)
+    AND (retry_at IS NULL OR retry_at <= CURRENT_TIMESTAMP)
+    AND (NOT @overdue::boolean OR deadline <= CURRENT_TIMESTAMP)
 ORDER BY created_at ASC;
 
+-- name: GetActivePackageJobNamesByNodeID :many
+-- the packages which already have a job that has not yet reached a final state
+SELECT DISTINCT
+    name
+FROM
+    package_jobs
+WHERE
+    node_id=$1 AND state IN ('queued', 'leased', 'running');
+
 -- name: GetPackageJobByID :one
 SELECT
     *
@@ -26,26 +40,76 @@ WHERE
 LIMIT 1;
 
 
--- name: AttemptPackageJob :one
+-- name: LeasePackageJob :one
+-- lease a queued job to its node, the output of any previous attempt is kept in the attempt history
 UPDATE
     package_jobs
 SET
-    attempts = attempts + 1
+    state = 'leased',
+    attempts = attempts + 1,
+    attempted_at = CURRENT_TIMESTAMP,
+    lease_expires_at = CURRENT_TIMESTAMP + make_interval(secs => @lease_seconds::int),
+    output = NULL
 WHERE
-    id = $1 AND node_id=$2 AND attempts < $3
+    id = @id AND node_id=@node_id AND state = 'queued' AND (attempts < @attempts OR @attempts = 0)
+    AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)
+    AND (not_before IS NULL OR not_before <= CURRENT_TIMESTAMP)
+    AND (retry_at IS NULL OR retry_at <= CURRENT_TIMESTAMP)
 RETURNING *;
 
+-- name: AppendPackageJobOutput :one
+-- output is streamed by the node while the job runs and doubles as the heartbeat which extends its lease
+UPDATE
+    package_jobs
+SET
+    state = 'running',
+    lease_expires_at = CURRENT_TIMESTAMP + make_interval(secs => @lease_seconds::int),
+    output = COALESCE(output, '') || @output::text
+WHERE
+    id=@id AND node_id=@node_id AND state IN ('leased', 'running')
+RETURNING attempts;
+
 -- name: CompletePackageJob :one
 UPDATE
     package_jobs
 SET
-    status=$3,
-    exit_code=$4,
-    output=$5,
-    error=$6,
+    state=$3,
+    status=$4,
+    exit_code=$5,
+    output=$6,
+    error=$7,
+    lease_expires_at=NULL,
     completed_at=CURRENT_TIMESTAMP
 WHERE
-    id=$1 AND status=0 AND node_id=$2
+    id=$1 AND state IN ('leased', 'running') AND node_id=$2
+RETURNING *;
+
+-- name: GetLeasedPackageJobRetryPolicy :one
+-- lock a job leased to the node while its result is recorded, along with its own retry policy or that of its organization
+SELECT
+    package_jobs.attempts,
+    COALESCE(package_jobs.retry_policy, orp.retry_policy) AS retry_policy
+FROM
+    package_jobs
+LEFT JOIN
+    organization_retry_policies orp ON orp.organization_id = package_jobs.organization_id
+WHERE
+    package_jobs.id=$1 AND package_jobs.node_id=$2 AND package_jobs.state IN ('leased', 'running')
+FOR UPDATE OF package_jobs;
+
+-- name: RetryPackageJob :one
+-- requeue a job after a transient failure, it is not provided to the node again until it has backed off
+UPDATE
+    package_jobs
+SET
+    state='queued',
+    exit_code=@exit_code,
+    output=@output,
+    error=@error,
+    lease_expires_at=NULL,
+    retry_at=CURRENT_TIMESTAMP + make_interval(secs => @backoff_seconds::int)
+WHERE
+    id=@id AND state IN ('leased', 'running') AND node_id=@node_id
 RETURNING *;
 
 -- name: CreatePackageJob :one
@@ -57,22 +121,265 @@ INSERT INTO
         action,
         name,
         version,
+        timeout,
+        ignore_checksum,
+        install_on_upgrade,
+        force,
+        verbose_output,
+        not_silent,
+        skip_powershell,
+        expires_at,
+        not_before,
+        deadline,
+        retry_policy,
+        baseline_id
+    )
+SELECT
+    nodes.id, -- Node ID
+    sqlc.narg(group_id), -- Group ID (if the job was applied to a group)
+    nodes.organization_id, -- the node's organization
+    @action, -- action (INSTALL, UPGRADE, UNINSTALL)
+    @name, -- package name
+    sqlc.narg(version), -- version
+    @timeout, -- timeout in seconds
+    @ignore_checksum, -- ignore checksum
+    @install_on_upgrade, -- install on upgrade
+    @force, -- force
+    @verbose_output, -- verbose output
+    @not_silent, -- not_silent
+    @skip_powershell, -- skip powershell
+    sqlc.narg(expires_at), -- when the job expires
+    sqlc.narg(not_before), -- when the job may first be run
+    sqlc.narg(deadline), -- when the job is run regardless of the maintenance schedule
+    sqlc.narg(retry_policy), -- overrides the organization's retry policy
+    sqlc.narg(baseline_id) -- the baseline which queued the job to remediate drift
+FROM
+    nodes
+WHERE
+    nodes.id=@node_id AND nodes.organization_id=@organization_id -- the node must belong to the organization
+RETURNING *;
+
+-- name: GetPackageJobsByOrgID :many
+SELECT
+    *
+FROM
+    package_jobs
+WHERE
+    organization_id=@organization_id AND (sqlc.narg(node_id)::uuid IS NULL OR node_id=sqlc.narg(node_id))
+ORDER BY
+    CASE WHEN @sort::text = 'DESC' THEN created_at END DESC,
+    created_at ASC
+LIMIT @page_limit::int OFFSET @page_offset::int;
+
+-- name: ExpirePackageJobs :many
+-- expire queued jobs past their expiration, a leased job is only expired once its lease is reclaimed
+UPDATE
+    package_jobs
+SET
+    state='expired',
+    status=$1,
+    completed_at=CURRENT_TIMESTAMP
+WHERE
+    state='queued' AND expires_at <= CURRENT_TIMESTAMP
+RETURNING id;
+
+-- name: CancelPackageJob :one
+UPDATE
+    package_jobs
+SET
+    state='cancelled',
+    status=$3,
+    lease_expires_at=NULL,
+    completed_at=CURRENT_TIMESTAMP
+WHERE
+    id=$1 AND organization_id=$2 AND state IN ('queued', 'leased', 'running')
+RETURNING *;
+
+-- name: ReclaimPackageJobLeases :many
+-- requeue jobs whose node stopped heartbeating before the lease expired, or fail them once they are out of attempts
+-- according to their own retry policy, that of their organization, or the default maximum attempts
+WITH reclaimed AS (
+    SELECT
+        package_jobs.id,
+        package_jobs.attempts >= COALESCE(
+            (package_jobs.retry_policy->>'max_attempts')::int,
+            (orp.retry_policy->>'max_attempts')::int,
+            @attempts_max::int
+        ) AS exhausted
+    FROM
+        package_jobs
+    LEFT JOIN
+        organization_retry_policies orp ON orp.organization_id = package_jobs.organization_id
+    WHERE
+        package_jobs.state IN ('leased', 'running') AND package_jobs.lease_expires_at < CURRENT_TIMESTAMP
+    FOR UPDATE OF package_jobs
+)
+UPDATE
+    package_jobs
+SET
+    state = CASE WHEN reclaimed.exhausted THEN 'failed' ELSE 'queued' END,
+    status = CASE WHEN reclaimed.exhausted THEN @status::int ELSE package_jobs.status END,
+    completed_at = CASE WHEN reclaimed.exhausted THEN CURRENT_TIMESTAMP ELSE NULL END,
+    lease_expires_at = NULL
+FROM
+    reclaimed
+WHERE
+    package_jobs.id = reclaimed.id
+RETURNING package_jobs.id, package_jobs.state, package_jobs.attempts, package_jobs.output;
+
+
+-- name: CreateGroupPackageJobs :many
+INSERT INTO
+    package_jobs(
+        node_id,
+        group_id,
+        organization_id,
+        action,
+        name,
+        version,
+        timeout,
         ignore_checksum,
         install_on_upgrade,
         force,
-        not_silent
+        verbose_output,
+        not_silent,
+        skip_powershell,
+        expires_at,
+        not_before,
+        deadline,
+        retry_policy,
+        batch_id
     )
-VALUES
-    (
-        $1, -- Node ID
-        $2, -- Group ID (if the job was applied to a group)
-        (SELECT organization_id FROM nodes WHERE id=$1), 
-        $3, -- action (INSTALL, UPGRADE, UNINSTALL)
-        $4, -- package name
-        $5, -- verion
-        $6, -- ignore checksum
-        $7, -- install on upgrade
-        $8, -- force
-        $9 -- not_silent
+SELECT
+    nga.node_id, -- one job per member of the group
+    nga.group_id, -- the group the job was applied to
+    nga.organization_id, -- the group's organization
+    @action, -- action (INSTALL, UPGRADE, UNINSTALL)
+    @name, -- package name
+    sqlc.narg(version), -- version
+    @timeout, -- timeout in seconds
+    @ignore_checksum, -- ignore checksum
+    @install_on_upgrade, -- install on upgrade
+    @force, -- force
+    @verbose_output, -- verbose output
+    @not_silent, -- not_silent
+    @skip_powershell, -- skip powershell
+    sqlc.narg(expires_at), -- when the job expires
+    sqlc.narg(not_before), -- when the job may first be run
+    sqlc.narg(deadline), -- when the job is run regardless of the maintenance schedule
+    sqlc.narg(retry_policy), -- overrides the organization's retry policy
+    @batch_id -- shared by every job of the fan-out
+FROM
+    node_group_assignments nga
+WHERE
+    nga.group_id=@group_id AND nga.organization_id=@organization_id
+RETURNING *;
+
+-- name: GetGroupPackageJobRollups :many
+-- Roll up the statuses of the jobs fanned out to a group using the ranges of choco.ChocoStatus:
+-- X[0-3] are successes, X[4-6] are neutral, X[7-9] are failures, and the negative statuses are set by the server.
+-- All jobs fanned out to a group at once share the same batch ID.
+SELECT
+    batch_id,
+    action,
+    name,
+    version,
+    MIN(created_at)::timestamp AS created_at,
+    COUNT(*) AS total,
+    COUNT(*) FILTER (WHERE status = 0) AS pending,
+    COUNT(*) FILTER (WHERE status > 0 AND status % 10 BETWEEN 0 AND 3) AS succeeded,
+    COUNT(*) FILTER (WHERE status > 0 AND status % 10 BETWEEN 4 AND 6) AS neutral,
+    COUNT(*) FILTER (WHERE status = -1 OR (status > 0 AND status % 10 BETWEEN 7 AND 9)) AS failed,
+    COUNT(*) FILTER (WHERE status = -2) AS cancelled,
+    COUNT(*) FILTER (WHERE status = -3) AS expired
+FROM
+    package_jobs
+WHERE
+    group_id=@group_id AND organization_id=@organization_id
+GROUP BY
+    batch_id, action, name, version
+ORDER BY
+    CASE WHEN @sort::text = 'DESC' THEN MIN(created_at) END DESC,
+    CASE WHEN @sort::text = 'DESC' THEN batch_id END DESC,
+    MIN(created_at) ASC, batch_id ASC
+LIMIT @page_limit::int OFFSET @page_offset::int;
+
+
+-- name: CreatePackageJobSecret :exec
+INSERT INTO
+    package_job_secrets (
+        job_id, package_parameters
     )
-RETURNING *;
\ No newline at end of file
+VALUES (
+    $1, $2
+);
+
+
+-- name: GetPackageJobSecret :one
+SELECT
+    *
+FROM
+    package_job_secrets
+WHERE
+    job_id=$1
+LIMIT 1;
+
+
+-- name: DeletePackageJobSecret :exec
+-- secrets are no longer needed once the job has been completed or cancelled
+DELETE FROM
+    package_job_secrets
+WHERE
+    job_id=$1;
+
+
+-- name: DeletePackageJobSecrets :exec
+DELETE FROM
+    package_job_secrets
+WHERE
+    job_id = ANY(@job_ids::uuid[]);
+
+
+-- name: CreatePackageJobAttempt :exec
+INSERT INTO
+    package_job_attempts (
+        job_id, attempt
+    )
+VALUES (
+    $1, $2
+);
+
+
+-- name: StartPackageJobAttempt :exec
+UPDATE
+    package_job_attempts
+SET
+    started_at=CURRENT_TIMESTAMP
+WHERE
+    job_id=$1 AND attempt=$2 AND started_at IS NULL;
+
+
+-- name: FinishPackageJobAttempt :exec
+UPDATE
+    package_job_attempts
+SET
+    outcome=$3,
+    status=$4,
+    exit_code=$5,
+    output=$6,
+    error=$7,
+    decision=$8,
+    retry_at=$9,
+    finished_at=CURRENT_TIMESTAMP
+WHERE
+    job_id=$1 AND attempt=$2 AND finished_at IS NULL;
+
+
+-- name: GetPackageJobAttempts :many
+SELECT
+    *
+FROM
+    package_job_attempts
+WHERE
+    job_id=$1
+ORDER BY attempt ASC;
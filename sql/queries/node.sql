@@ -13,7 +13,7 @@ INSERT INTO
         hostname,
         client_version,
         os_kernel, os_name, os_major, os_minor, os_build,
-        packages_choco, packages_system, packages_outdated
+        approved, approved_on
     )
 SELECT
     $1, -- id
@@ -26,17 +26,17 @@ SELECT
     $8, -- os_major
     $9, -- os_minor
     $10, -- os_build
-    $11, -- packages_choco
-    $12, -- packages_system
-    $13 -- packages_outdated
+    rt.auto_approve, -- approved from registration_tokens
+    CASE WHEN rt.auto_approve THEN CURRENT_TIMESTAMP END -- approved_on
 FROM
     registration_tokens rt
 WHERE
     rt.id = $2 -- registration token value
 RETURNING *;
 
--- name: GetNodePackages :one
-SELECT packages_choco, packages_system, packages_outdated FROM nodes WHERE id = $1;
+-- name: LockNodeByID :one
+-- lock the node while its inventory is replaced, so concurrent updates are applied one after the other
+SELECT * FROM nodes WHERE id=$1 LIMIT 1 FOR UPDATE;
 
 -- name: SetNodeApproval :exec
 UPDATE
@@ -54,48 +54,87 @@ SET
 WHERE
     id=$1;
 
--- name: UpdateNodePackages :exec
-WITH
-    n AS (
-        SELECT
-            id, organization_id, packages_choco, packages_system, packages_outdated, packages_updated_at
-        FROM
-            nodes
-        WHERE
-            nodes.id=$1
-        FOR UPDATE
-    ),
-    i AS (
-        INSERT INTO
-            node_package_changelog(
-                node_id,
-                organization_id,
-                packages_choco,
-                packages_system,
-                packages_outdated,
-                timestamp
-            )
-        SELECT
-            id, organization_id, packages_choco, packages_system, packages_outdated, packages_updated_at
-        FROM
-            n
-        RETURNING *
-    )
-    UPDATE
-        nodes
-    SET
-        packages_updated_at=CURRENT_TIMESTAMP, packages_choco=$2, packages_system=$3, packages_outdated=$4
-    WHERE
-        id=(SELECT node_id FROM i) AND (packages_choco!=$2 OR packages_system!=$3 OR packages_outdated!=$4);
+-- name: GetPendingNodesByOrgID :many
+SELECT
+    *
+FROM
+    nodes
+WHERE
+    organization_id=@organization_id AND approved=FALSE AND rejected_on IS NULL AND revoked_on IS NULL
+ORDER BY
+    CASE WHEN @sort::text = 'DESC' THEN connected_on END DESC,
+    connected_on ASC
+LIMIT @page_limit::int OFFSET @page_offset::int;
 
--- name: UpdateNodePackagesChoco :exec
-UPDATE nodes SET packages_choco=$2 WHERE id=$1;
+-- name: ApproveNode :one
+UPDATE
+    nodes
+SET
+    approved=TRUE,
+    approved_on=CURRENT_TIMESTAMP,
+    rejected_on=NULL,
+    revoked_on=NULL
+WHERE
+    id=$1 AND organization_id=$2 AND approved=FALSE
+RETURNING *;
+
+-- name: RejectNode :one
+-- only nodes that are still pending approval can be rejected
+UPDATE
+    nodes
+SET
+    rejected_on=CURRENT_TIMESTAMP
+WHERE
+    id=$1 AND organization_id=$2 AND approved=FALSE AND rejected_on IS NULL AND revoked_on IS NULL
+RETURNING *;
+
+-- name: RevokeNode :one
+UPDATE
+    nodes
+SET
+    approved=FALSE,
+    revoked_on=CURRENT_TIMESTAMP
+WHERE
+    id=$1 AND organization_id=$2 AND approved=TRUE
+RETURNING *;
 
--- name: UpdateNodePackagesSystem :exec
-UPDATE nodes SET packages_system=$2 WHERE id=$1;
+-- name: GetNodeCheck :one
+-- the pending flags of a node along with the number of jobs it would receive in its job list and how many of them are past their deadline
+SELECT
+    pending_sources,
+    pending_schedule,
+    COALESCE(jobs.pending_jobs, 0)::int AS pending_jobs,
+    COALESCE(jobs.overdue_jobs, 0)::int AS overdue_jobs
+FROM
+    nodes
+LEFT JOIN LATERAL (
+    SELECT
+        COUNT(*) AS pending_jobs,
+        COUNT(*) FILTER (WHERE package_jobs.deadline <= CURRENT_TIMESTAMP) AS overdue_jobs
+    FROM
+        package_jobs
+    WHERE
+        package_jobs.node_id=nodes.id AND package_jobs.state='queued' AND (package_jobs.attempts < @attempts_max::int OR @attempts_max::int = 0)
+        AND (package_jobs.expires_at IS NULL OR package_jobs.expires_at > CURRENT_TIMESTAMP)
+        AND (package_jobs.not_before IS NULL OR package_jobs.not_before <= CURRENT_TIMESTAMP)
+        AND (package_jobs.retry_at IS NULL OR package_jobs.retry_at <= CURRENT_TIMESTAMP)
+) jobs ON TRUE
+WHERE
+    id=@id
+LIMIT 1;
 
--- name: UpdateNodePackagesOutdated :exec
-UPDATE nodes SET packages_outdated=$2 WHERE id=$1;
+-- name: ClearNodePendingSchedule :exec
+UPDATE
+    nodes
+SET
+    pending_schedule=FALSE
+WHERE
+    id=$1;
 
----- name:UpdateNodePackages:exec
--- UPDATE nodes SET packages_choco=$2, packages_system=$3, packages_outdated=$4 WHERE nodes.id=$1;
+-- name: ClearNodePendingSources :exec
+UPDATE
+    nodes
+SET
+    pending_sources=FALSE
+WHERE
+    id=$1;
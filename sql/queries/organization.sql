@@ -28,7 +28,7 @@ SELECT
 FROM
     registration_tokens
 WHERE
-    id=$1 AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP);
+    id=$1 AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP) AND (max_uses IS NULL OR uses < max_uses);
 
 
 -- name: GetOrganizationIDFromRegistrationToken :one
@@ -47,3 +47,91 @@ organizations (
 ) VALUES ( 
     $1
 ) RETURNING *;
+
+
+-- name: GetOrganizationRetryPolicy :one
+SELECT
+    retry_policy
+FROM
+    organization_retry_policies
+WHERE
+    organization_id=$1
+LIMIT 1;
+
+
+-- name: SetOrganizationRetryPolicy :exec
+INSERT INTO
+    organization_retry_policies (
+        organization_id, retry_policy
+    )
+VALUES (
+    $1, $2
+)
+ON CONFLICT (organization_id) DO UPDATE SET
+    retry_policy=EXCLUDED.retry_policy;
+
+
+-- name: DeleteOrganizationRetryPolicy :execrows
+DELETE FROM
+    organization_retry_policies
+WHERE
+    organization_id=$1;
+
+
+-- name: GetOrganizationUpgradePolicy :one
+SELECT
+    upgrade_policy
+FROM
+    organization_upgrade_policies
+WHERE
+    organization_id=$1
+LIMIT 1;
+
+
+-- name: SetOrganizationUpgradePolicy :exec
+INSERT INTO
+    organization_upgrade_policies (
+        organization_id, upgrade_policy
+    )
+VALUES (
+    $1, $2
+)
+ON CONFLICT (organization_id) DO UPDATE SET
+    upgrade_policy=EXCLUDED.upgrade_policy;
+
+
+-- name: DeleteOrganizationUpgradePolicy :execrows
+DELETE FROM
+    organization_upgrade_policies
+WHERE
+    organization_id=$1;
+
+
+-- name: GetOrganizationSoftwareMaps :one
+SELECT
+    *
+FROM
+    organization_software_maps
+WHERE
+    organization_id=$1
+LIMIT 1;
+
+
+-- name: SetOrganizationSoftwareMaps :exec
+INSERT INTO
+    organization_software_maps (
+        organization_id, include_defaults, software_maps
+    )
+VALUES (
+    $1, $2, $3
+)
+ON CONFLICT (organization_id) DO UPDATE SET
+    include_defaults=EXCLUDED.include_defaults,
+    software_maps=EXCLUDED.software_maps;
+
+
+-- name: DeleteOrganizationSoftwareMaps :execrows
+DELETE FROM
+    organization_software_maps
+WHERE
+    organization_id=$1;
@@ -0,0 +1,104 @@
+-- name: GetNodePackages :many
+SELECT
+    *
+FROM
+    node_packages
+WHERE
+    node_id=$1
+ORDER BY source, position ASC;
+
+-- name: CreateNodePackages :exec
+-- the packages are provided as parallel arrays, an empty new version is stored as NULL
+INSERT INTO
+    node_packages (
+        node_id, organization_id, source, position, name, version, version_new, pinned
+    )
+SELECT
+    @node_id, @organization_id, p.source, p.position, p.name, p.version, NULLIF(p.version_new, ''), p.pinned
+FROM
+    unnest(
+        @sources::text[], @positions::int[], @names::text[], @versions::text[], @versions_new::text[], @pinned::boolean[]
+    ) AS p(source, position, name, version, version_new, pinned);
+
+-- name: DeleteNodePackages :exec
+DELETE FROM
+    node_packages
+WHERE
+    node_id=@node_id AND id = ANY(@ids::bigint[]);
+
+-- name: MoveNodePackages :exec
+-- packages which are still reported but at a different position within their list
+UPDATE
+    node_packages
+SET
+    position=moved.position
+FROM
+    unnest(@ids::bigint[], @positions::int[]) AS moved(id, position)
+WHERE
+    node_packages.node_id=@node_id AND node_packages.id=moved.id;
+
+-- name: SetNodePackagesUpdated :exec
+UPDATE
+    nodes
+SET
+    packages_updated_at=CURRENT_TIMESTAMP
+WHERE
+    id=$1;
+
+-- name: CreateNodePackageChanges :exec
+-- every change reported by a single inventory update shares the same timestamp
+INSERT INTO
+    node_package_changelog (
+        node_id, organization_id, source, change, name, version, timestamp
+    )
+SELECT
+    @node_id, @organization_id, c.source, c.change, c.name, c.version, CURRENT_TIMESTAMP
+FROM
+    unnest(@sources::text[], @changes::text[], @names::text[], @versions::text[]) AS c(source, change, name, version);
+
+-- name: GetNodePackageChangelog :many
+SELECT
+    *
+FROM
+    node_package_changelog
+WHERE
+    node_id=@node_id AND timestamp >= @ts_from::timestamp AND timestamp <= @ts_to::timestamp
+ORDER BY timestamp DESC, id ASC;
+
+-- name: GetOrganizationPackageChangelog :many
+SELECT
+    *
+FROM
+    node_package_changelog
+WHERE
+    organization_id=@organization_id AND timestamp >= @since::timestamp
+ORDER BY timestamp DESC, node_id, id ASC;
+
+-- name: SearchNodePackages :many
+-- the packages of the organization's nodes whose name matches exactly, by prefix, or by regex (all case-insensitive),
+-- continuing after the given package when after_node_id is set so the results can be read in batches
+SELECT
+    nodes.id AS node_id, nodes.hostname, nodes.label, np.source, np.position, np.name, np.version
+FROM
+    node_packages np
+JOIN
+    nodes ON nodes.id = np.node_id
+WHERE
+    np.organization_id=@organization_id AND np.source IN ('choco', 'system')
+    AND (sqlc.narg(source)::text IS NULL OR np.source=sqlc.narg(source)::text)
+    AND (
+        (@match::text = 'exact' AND lower(np.name) = lower(@name::text))
+        OR (@match::text = 'prefix' AND lower(np.name) LIKE replace(replace(replace(lower(@name::text), '\', '\\'), '%', '\%'), '_', '\_') || '%')
+        OR (@match::text = 'regex' AND np.name ~* @name::text)
+    )
+    AND (
+        sqlc.narg(after_node_id)::uuid IS NULL
+        OR (@sort::text = 'DESC' AND nodes.hostname < @after_hostname::text)
+        OR (@sort::text != 'DESC' AND nodes.hostname > @after_hostname::text)
+        OR (nodes.hostname = @after_hostname::text
+            AND (nodes.id, np.source, np.position) > (sqlc.narg(after_node_id)::uuid, @after_source::text, @after_position::int))
+    )
+ORDER BY
+    CASE WHEN @sort::text = 'DESC' THEN nodes.hostname END DESC,
+    nodes.hostname ASC, nodes.id, np.source, np.position
+LIMIT @page_limit::int OFFSET @page_offset::int;
@@ -0,0 +1,63 @@
+-- name: GetRegistrationTokensByOrgID :many
+SELECT
+    *
+FROM
+    registration_tokens
+WHERE
+    organization_id=@organization_id
+ORDER BY
+    CASE WHEN @sort::text = 'DESC' THEN created_at END DESC,
+    created_at ASC
+LIMIT @page_limit::int OFFSET @page_offset::int;
+
+
+-- name: GetRegistrationToken :one
+SELECT
+    *
+FROM
+    registration_tokens
+WHERE
+    id=$1 AND organization_id=$2
+LIMIT 1;
+
+
+-- name: CreateRegistrationToken :one
+INSERT INTO
+    registration_tokens (
+        organization_id, name, expires_at, max_uses, auto_approve
+    )
+VALUES (
+    $1, $2, $3, $4, $5
+)
+RETURNING *;
+
+
+-- name: UpdateRegistrationToken :one
+UPDATE
+    registration_tokens
+SET
+    name=$3,
+    expires_at=$4,
+    max_uses=$5,
+    auto_approve=$6
+WHERE
+    id=$1 AND organization_id=$2
+RETURNING *;
+
+
+-- name: DeleteRegistrationToken :execrows
+DELETE FROM
+    registration_tokens
+WHERE
+    id=$1 AND organization_id=$2;
+
+
+-- name: UseRegistrationToken :one
+-- consume a single use of a valid registration token
+UPDATE
+    registration_tokens
+SET
+    uses=uses+1
+WHERE
+    id=$1 AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP) AND (max_uses IS NULL OR uses < max_uses)
+RETURNING organization_id;
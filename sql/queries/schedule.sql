@@ -85,6 +85,178 @@ JOIN
 WHERE
     nodes.id = $1;
 
+-- name: GetSchedulesByOrgID :many
+SELECT
+    *
+FROM
+    schedules
+WHERE
+    organization_id=@organization_id
+ORDER BY
+    CASE WHEN @sort::text = 'DESC' THEN name END DESC,
+    name ASC
+LIMIT @page_limit::int OFFSET @page_offset::int;
+
+
+-- name: GetSchedule :one
+SELECT
+    *
+FROM
+    schedules
+WHERE
+    id=$1 AND organization_id=$2
+LIMIT 1;
+
+
+-- name: CreateSchedule :one
+INSERT INTO
+    schedules (
+        organization_id, name, entries
+    )
+VALUES (
+    $1, $2, $3
+)
+RETURNING *;
+
+
+-- name: UpdateSchedule :one
+UPDATE
+    schedules
+SET
+    name=$3,
+    entries=$4
+WHERE
+    id=$1 AND organization_id=$2
+RETURNING *;
+
+
+-- name: DeleteSchedule :execrows
+DELETE FROM
+    schedules
+WHERE
+    id=$1 AND organization_id=$2;
+
+
+-- name: DeleteScheduleAssignments :exec
+-- remove every assignment of a schedule so the schedule itself can be deleted
+WITH node_assignments AS (
+    DELETE FROM node_schedule_assignments nsa WHERE nsa.schedule_id=$1
+), group_assignments AS (
+    DELETE FROM group_schedule_assignments gsa WHERE gsa.schedule_id=$1
+)
+DELETE FROM
+    organization_schedule_assignments osa
+WHERE
+    osa.schedule_id=$1;
+
+
+-- name: CreateNodeScheduleAssignment :exec
+INSERT INTO
+    node_schedule_assignments (
+        schedule_id, node_id, organization_id
+    )
+VALUES (
+    $1, $2, $3
+)
+ON CONFLICT DO NOTHING;
+
+
+-- name: DeleteNodeScheduleAssignment :execrows
+DELETE FROM
+    node_schedule_assignments
+WHERE
+    schedule_id=$1 AND node_id=$2 AND organization_id=$3;
+
+
+-- name: CreateGroupScheduleAssignment :exec
+INSERT INTO
+    group_schedule_assignments (
+        schedule_id, group_id, organization_id
+    )
+VALUES (
+    $1, $2, $3
+)
+ON CONFLICT DO NOTHING;
+
+
+-- name: DeleteGroupScheduleAssignment :execrows
+DELETE FROM
+    group_schedule_assignments
+WHERE
+    schedule_id=$1 AND group_id=$2 AND organization_id=$3;
+
+
+-- name: CreateOrganizationScheduleAssignment :exec
+INSERT INTO
+    organization_schedule_assignments (
+        schedule_id, organization_id
+    )
+VALUES (
+    $1, $2
+)
+ON CONFLICT DO NOTHING;
+
+
+-- name: DeleteOrganizationScheduleAssignment :execrows
+DELETE FROM
+    organization_schedule_assignments
+WHERE
+    schedule_id=$1 AND organization_id=$2;
+
+
+-- name: SetSchedulePendingByScheduleID :exec
+-- flag every node which inherits the schedule through any of its assignments
+UPDATE
+    nodes
+SET
+    pending_schedule=TRUE
+WHERE
+    nodes.organization_id=@organization_id AND (
+        nodes.id IN (
+            SELECT nsa.node_id FROM node_schedule_assignments nsa WHERE nsa.schedule_id=@schedule_id
+        ) OR nodes.id IN (
+            SELECT
+                nga.node_id
+            FROM
+                node_group_assignments nga
+            JOIN
+                group_schedule_assignments gsa ON gsa.group_id = nga.group_id
+            WHERE
+                gsa.schedule_id=@schedule_id
+        ) OR EXISTS (
+            SELECT 1 FROM organization_schedule_assignments osa WHERE osa.schedule_id=@schedule_id AND osa.organization_id=nodes.organization_id
+        )
+    );
+
+
+-- name: SetSchedulePendingByNodeID :exec
+UPDATE
+    nodes
+SET
+    pending_schedule=TRUE
+WHERE
+    id=$1 AND organization_id=$2;
+
+
+-- name: SetSchedulePendingByGroupID :exec
+UPDATE
+    nodes
+SET
+    pending_schedule=TRUE
+WHERE
+    id IN (
+        SELECT nga.node_id FROM node_group_assignments nga WHERE nga.group_id=$1 AND nga.organization_id=$2
+    );
+
+
+-- name: SetSchedulePendingByOrgID :exec
+UPDATE
+    nodes
+SET
+    pending_schedule=TRUE
+WHERE
+    organization_id=$1;
+
 -- -- name: GetSchedulesByNodeID :many
 -- -- type: schedules
 -- WITH node_schedules AS (
@@ -0,0 +1,126 @@
+-- name: GetCombinedSourcesByNode :many
+-- every source which applies to a node, ordered from the least to the most specific assignment
+SELECT
+    sources.*,
+    0::int AS precedence
+FROM
+    sources
+JOIN
+    organization_source_assignments osa ON osa.source_id = sources.id
+JOIN
+    nodes ON nodes.organization_id = osa.organization_id
+WHERE
+    nodes.id = @node_id
+UNION ALL
+SELECT
+    sources.*,
+    1::int AS precedence
+FROM
+    sources
+JOIN
+    group_source_assignments gsa ON gsa.source_id = sources.id
+JOIN
+    node_group_assignments nga ON nga.group_id = gsa.group_id
+WHERE
+    nga.node_id = @node_id
+UNION ALL
+SELECT
+    sources.*,
+    2::int AS precedence
+FROM
+    sources
+JOIN
+    node_source_assignments nsa ON nsa.source_id = sources.id
+WHERE
+    nsa.node_id = @node_id
+ORDER BY
+    precedence ASC,
+    name ASC;
+
+
+-- name: GetNodeSources :one
+SELECT
+    *
+FROM
+    node_sources
+WHERE
+    node_id=$1
+LIMIT 1;
+
+
+-- name: UpdateNodeSources :exec
+INSERT INTO
+    node_sources (
+        node_id, sources
+    )
+VALUES (
+    $1, $2
+)
+ON CONFLICT (node_id) DO UPDATE SET
+    sources=EXCLUDED.sources,
+    reported_at=CURRENT_TIMESTAMP;
+
+
+-- name: GetSource :one
+SELECT
+    *
+FROM
+    sources
+WHERE
+    id=$1 AND organization_id=$2
+LIMIT 1;
+
+
+-- name: GetSourceCredentials :many
+-- the credentials of every entry within the given sources
+SELECT
+    *
+FROM
+    source_credentials
+WHERE
+    source_id = ANY(@source_ids::uuid[]);
+
+
+-- name: UpdateSourceCredentials :exec
+INSERT INTO
+    source_credentials (
+        source_id, name, password, certificate_password
+    )
+VALUES (
+    $1, $2, $3, $4
+)
+ON CONFLICT (source_id, name) DO UPDATE SET
+    password=EXCLUDED.password,
+    certificate_password=EXCLUDED.certificate_password;
+
+
+-- name: DeleteSourceCredentials :execrows
+DELETE FROM
+    source_credentials
+WHERE
+    source_id=$1 AND name=$2;
+
+
+-- name: SetSourcePendingBySourceID :exec
+-- flag every node which inherits the source through any of its assignments
+UPDATE
+    nodes
+SET
+    pending_sources=TRUE
+WHERE
+    nodes.organization_id=@organization_id AND (
+        nodes.id IN (
+            SELECT nsa.node_id FROM node_source_assignments nsa WHERE nsa.source_id=@source_id
+        ) OR nodes.id IN (
+            SELECT
+                nga.node_id
+            FROM
+                node_group_assignments nga
+            JOIN
+                group_source_assignments gsa ON gsa.group_id = nga.group_id
+            WHERE
+                gsa.source_id=@source_id
+        ) OR EXISTS (
+            SELECT 1 FROM organization_source_assignments osa WHERE osa.source_id=@source_id AND osa.organization_id=nodes.organization_id
+        )
+    );
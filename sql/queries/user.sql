@@ -0,0 +1,104 @@
+-- name: GetUserByEmail :one
+SELECT
+    *,
+    (locked_until IS NOT NULL AND locked_until > CURRENT_TIMESTAMP)::boolean AS locked
+FROM
+    users
+WHERE
+    email=$1
+LIMIT 1;
+
+
+-- name: GetUserOrganizationRoles :many
+SELECT
+    organization_id, role
+FROM
+    user_organization_assignments
+WHERE
+    user_id=$1;
+
+
+-- name: CreateUser :one
+INSERT INTO
+    users (
+        email, password, superadmin
+    )
+VALUES (
+    $1, $2, $3
+)
+ON CONFLICT (email) DO NOTHING
+RETURNING *;
+
+
+-- name: UpdateUserLoginSuccess :exec
+UPDATE
+    users
+SET
+    last_login=CURRENT_TIMESTAMP,
+    failed_logins=0,
+    locked_until=NULL
+WHERE
+    id=$1;
+
+
+-- name: UpdateUserLoginFailure :exec
+-- the failure counter is reset each time the user becomes locked out
+UPDATE
+    users
+SET
+    failed_logins=CASE WHEN failed_logins+1 >= @max_failures::int THEN 0 ELSE failed_logins+1 END,
+    locked_until=CASE WHEN failed_logins+1 >= @max_failures::int THEN CURRENT_TIMESTAMP + make_interval(secs => @lockout_seconds::int) ELSE locked_until END
+WHERE
+    id=@id;
+
+
+-- name: GetUserByID :one
+SELECT
+    *,
+    (locked_until IS NOT NULL AND locked_until > CURRENT_TIMESTAMP)::boolean AS locked
+FROM
+    users
+WHERE
+    id=$1
+LIMIT 1;
+
+
+-- name: SetUserMfaPending :exec
+UPDATE
+    users
+SET
+    mfatoken_pending=@secret::text
+WHERE
+    id=@id;
+
+
+-- name: EnableUserMfa :execrows
+-- the pending secret must still match the one the code was verified against, whose time step cannot be used again
+UPDATE
+    users
+SET
+    mfatoken=mfatoken_pending,
+    mfatoken_pending=NULL,
+    mfa_recovery_codes=@recovery_codes::text[],
+    totp_last_counter=@counter::bigint
+WHERE
+    id=@id AND mfatoken_pending=@secret::text;
+
+
+-- name: UseUserMfaRecoveryCode :execrows
+UPDATE
+    users
+SET
+    mfa_recovery_codes=array_remove(mfa_recovery_codes, @code_hash::text)
+WHERE
+    id=@id AND @code_hash::text = ANY(mfa_recovery_codes);
+
+
+-- name: UseUserTotpCounter :execrows
+-- a TOTP code is only accepted once, and never after a code of a later time step
+UPDATE
+    users
+SET
+    totp_last_counter=@counter::bigint
+WHERE
+    id=@id AND totp_last_counter < @counter::bigint;
@@ -15,8 +15,14 @@ CREATE TABLE IF NOT EXISTS users (
   email CITEXT NOT NULL, -- case-insensitive email address
   password TEXT NOT NULL, -- hashed password
   mfatoken TEXT DEFAULT NULL, -- MFA secret token
+  mfatoken_pending TEXT DEFAULT NULL, -- MFA secret token awaiting its first verified code during enrollment
+  mfa_recovery_codes TEXT[] NOT NULL DEFAULT '{}', -- SHA-256 hashes of the unused MFA recovery codes
   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, -- when the user was created
   last_login TIMESTAMP DEFAULT NULL, -- when the user last logged in
+  superadmin BOOLEAN NOT NULL DEFAULT FALSE, -- super admins have full access to every organization
+  failed_logins INT NOT NULL DEFAULT 0, -- consecutive failed login attempts since the last success
+  locked_until TIMESTAMP DEFAULT NULL, -- logins are refused until this time after too many failures
+  totp_last_counter BIGINT NOT NULL DEFAULT 0, -- time step of the last accepted TOTP code, codes up to it are refused
   UNIQUE(email) -- all email addresses must be unique
 );
 
@@ -51,6 +57,9 @@ CREATE TABLE IF NOT EXISTS registration_tokens (
   name CITEXT NOT NULL, -- a name for the registration token
   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, -- when this registration token was created
   expires_at TIMESTAMP DEFAULT NULL, -- when this registration token expires
+  max_uses INT DEFAULT NULL, -- how many nodes can register with this token, unlimited if NULL
+  uses INT NOT NULL DEFAULT 0, -- how many nodes have registered with this token
+  auto_approve BOOLEAN NOT NULL DEFAULT FALSE, -- nodes registered with this token are approved immediately
   UNIQUE(organization_id, name) -- name should be unique within an organization
 );
 
@@ -69,26 +78,44 @@ CREATE TABLE IF NOT EXISTS nodes (
   os_major INT NOT NULL, -- the OS major version
   os_minor INT NOT NULL, -- the OS minor version
   os_build INT NOT NULL, -- the OS build version
-  packages_choco JSONB NOT NULL, -- SoftwareList managed by chocolatey
-  packages_system JSONB NOT NULL, -- SoftwareList NOT managed by chocolatey
-  packages_outdated JSONB NOT NULL, --  SoftwareOutdatedList managed by chocolatey
   packages_updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, -- last time the packages were updated
   connected_on TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, -- when the node initially registered
   approved_on TIMESTAMP DEFAULT NULL, -- when the node was originally approvied
   last_seen TIMESTAMP DEFAULT NULL, -- when the node has most recently checked in
-  approved BOOLEAN NOT NULL DEFAULT FALSE -- determines whether the device is approved or not
+  approved BOOLEAN NOT NULL DEFAULT FALSE, -- determines whether the device is approved or not
+  rejected_on TIMESTAMP DEFAULT NULL, -- when the node's registration was rejected without being approved
+  revoked_on TIMESTAMP DEFAULT NULL -- when the node's approval was most recently revoked
 );
 
--- this is meant to keep a complete history of all package changes
+-- The inventory of each node, one row for every package in each list the node reports
+CREATE TABLE IF NOT EXISTS node_packages (
+  id BIGSERIAL PRIMARY KEY,
+  node_id UUID NOT NULL REFERENCES nodes(id),
+  organization_id UUID NOT NULL REFERENCES organizations(id), -- the node's organization, so the org's inventory can be searched
+  source TEXT NOT NULL, -- the list the package is reported in ('choco', 'system' or 'outdated')
+  position INT NOT NULL, -- where the package is within its list, the list is always returned in the order it was reported
+  name TEXT NOT NULL, -- the package name
+  version TEXT NOT NULL, -- the installed version
+  version_new TEXT DEFAULT NULL, -- the newer version available, only for outdated packages
+  pinned BOOLEAN NOT NULL DEFAULT FALSE -- whether the package is pinned to its version, only for outdated packages
+);
+CREATE INDEX IF NOT EXISTS node_packages_node_idx ON node_packages(node_id, source, position);
+CREATE INDEX IF NOT EXISTS node_packages_name_idx ON node_packages(organization_id, lower(name) text_pattern_ops);
+
+-- this is meant to keep a complete history of all package changes, one row for every package installed on or removed
+-- from the choco or system list of a node (a new version is the removal of the old version and install of the new one)
 CREATE TABLE IF NOT EXISTS node_package_changelog (
-  id SERIAL PRIMARY KEY,
+  id BIGSERIAL PRIMARY KEY,
   node_id UUID NOT NULL REFERENCES nodes(id),
   organization_id UUID NOT NULL REFERENCES organizations(id),
-  packages_choco JSONB NOT NULL,
-  packages_system JSONB NOT NULL,
-  packages_outdated JSONB NOT NULL,
-  timestamp TIMESTAMP NOT NULL
+  source TEXT NOT NULL, -- the list the package is reported in ('choco' or 'system')
+  change TEXT NOT NULL, -- whether the package was 'installed' or 'removed'
+  name TEXT NOT NULL, -- the package name
+  version TEXT NOT NULL, -- the version which was installed or removed
+  timestamp TIMESTAMP NOT NULL -- when the node reported the change
 );
+CREATE INDEX IF NOT EXISTS node_package_changelog_node_idx ON node_package_changelog(node_id, timestamp);
+CREATE INDEX IF NOT EXISTS node_package_changelog_org_idx ON node_package_changelog(organization_id, timestamp);
 
 -- Groups will be statically composed of nodes
 CREATE TABLE IF NOT EXISTS groups (
@@ -105,12 +132,55 @@ CREATE TABLE IF NOT EXISTS node_group_assignments (
   PRIMARY KEY (node_id, group_id, organization_id)
 );
 
+-- Baselines are the chocolatey packages which must (or must not) be installed on the nodes they are assigned to
+CREATE TABLE IF NOT EXISTS baselines (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(), -- unique ID for each baseline
+  organization_id UUID NOT NULL REFERENCES organizations(id), -- organization in which the baseline exists
+  name CITEXT NOT NULL, -- the unique name of the baseline
+  entries JSONB NOT NULL, -- a list of entries each requiring a package to be present (optionally at a minimum version) or absent
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, -- failed remediation jobs are only queued again after the baseline changes
+  UNIQUE(organization_id, name) -- each baseline must have a unique name within the organization
+);
+
+-- Baselines assigned to individual nodes
+CREATE TABLE IF NOT EXISTS node_baseline_assignments (
+  baseline_id UUID NOT NULL REFERENCES baselines(id),
+  node_id UUID NOT NULL REFERENCES nodes(id),
+  organization_id UUID NOT NULL REFERENCES organizations(id),
+  PRIMARY KEY(baseline_id, node_id, organization_id)
+);
+
+-- Baselines assigned to groups
+CREATE TABLE IF NOT EXISTS group_baseline_assignments (
+  baseline_id UUID NOT NULL REFERENCES baselines(id),
+  group_id UUID NOT NULL REFERENCES groups(id),
+  organization_id UUID NOT NULL REFERENCES organizations(id),
+  PRIMARY KEY(baseline_id, group_id, organization_id)
+);
+
+-- Baselines assigned to the entire organization
+CREATE TABLE IF NOT EXISTS organization_baseline_assignments (
+  baseline_id UUID NOT NULL REFERENCES baselines(id),
+  organization_id UUID NOT NULL REFERENCES organizations(id),
+  PRIMARY KEY(baseline_id, organization_id)
+);
+
+-- The compliance of each node with its baseline, evaluated whenever the node reports its packages or its baseline changes
+CREATE TABLE IF NOT EXISTS node_baseline_compliance (
+  node_id UUID PRIMARY KEY REFERENCES nodes(id),
+  compliant BOOLEAN NOT NULL,
+  drift JSONB NOT NULL, -- a list of the baseline entries the node does not comply with
+  evaluated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
 CREATE TABLE IF NOT EXISTS package_jobs(
   id UUID PRIMARY KEY DEFAULT gen_random_uuid(), -- random job UUID
   node_id UUID NOT NULL REFERENCES nodes(id),
   group_id UUID REFERENCES groups(id) DEFAULT NULL,
+  baseline_id UUID REFERENCES baselines(id) ON DELETE SET NULL DEFAULT NULL, -- the baseline which queued the job to remediate drift
   organization_id UUID NOT NULL REFERENCES organizations(id),
-  attempts INTEGER NOT NULL DEFAULT 0, -- number of attempts made by the client to install
+  state TEXT NOT NULL DEFAULT 'queued' CHECK (state IN ('queued', 'leased', 'running', 'succeeded', 'failed', 'expired', 'cancelled')),
+  attempts INTEGER NOT NULL DEFAULT 0, -- number of leases granted to the node to run the job
   -- PARAMETERS:
   action INTEGER NOT NULL, -- install, upgrade, uninstall
   name CITEXT NOT NULL, -- the chocolatey package name for any action
@@ -120,7 +190,9 @@ CREATE TABLE IF NOT EXISTS package_jobs(
   force BOOLEAN NOT NULL DEFAULT FALSE,
   verbose_output BOOLEAN NOT NULL DEFAULT FALSE,
   not_silent BOOLEAN NOT NULL DEFAULT FALSE,
+  skip_powershell BOOLEAN NOT NULL DEFAULT FALSE, -- register the package without running its scripts (to adopt existing software)
   timeout INTEGER NOT NULL DEFAULT 600, -- default of 10 minutes to perform an install/uninstall, best to set the timeout per job
+  retry_policy JSONB DEFAULT NULL, -- overrides the retry policy of the organization
   -- RESULT:
   status INTEGER NOT NULL DEFAULT 0,
   exit_code INTEGER DEFAULT NULL,
@@ -128,9 +200,64 @@ CREATE TABLE IF NOT EXISTS package_jobs(
   error TEXT DEFAULT NULL,
   -- metadata
   attempted_at TIMESTAMP DEFAULT NULL,
+  lease_expires_at TIMESTAMP DEFAULT NULL, -- the lease is reclaimed if the node stops heartbeating before this time
   completed_at TIMESTAMP DEFAULT NULL,
-  expires_at TIMESTAMP DEFAULT NULL,
-  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+  expires_at TIMESTAMP DEFAULT NULL, -- the job is no longer run after this time and is marked as expired
+  not_before TIMESTAMP DEFAULT NULL, -- the job is not provided to the node before this time
+  deadline TIMESTAMP DEFAULT NULL, -- the node runs the job outside of its maintenance schedule after this time
+  retry_at TIMESTAMP DEFAULT NULL, -- a job requeued after a transient failure is not provided to the node before this time
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  batch_id UUID DEFAULT NULL -- shared by every job fanned out to a group by the same request
+);
+CREATE INDEX IF NOT EXISTS package_jobs_batch_idx ON package_jobs(group_id, batch_id);
+
+-- Secret parameters of package jobs (e.g. license keys), encrypted by the server and only ever delivered sealed to a node's key
+CREATE TABLE IF NOT EXISTS package_job_secrets (
+  job_id UUID PRIMARY KEY REFERENCES package_jobs(id),
+  package_parameters BYTEA NOT NULL -- encrypted chocolatey package parameters
+);
+
+-- History of every lease granted for a package job and how it ended
+CREATE TABLE IF NOT EXISTS package_job_attempts (
+  job_id UUID NOT NULL REFERENCES package_jobs(id),
+  attempt INTEGER NOT NULL, -- the attempt number, starting at 1
+  outcome TEXT DEFAULT NULL, -- succeeded, failed, cancelled, or lease_expired (NULL while the attempt is in progress)
+  status INTEGER DEFAULT NULL,
+  exit_code INTEGER DEFAULT NULL,
+  output TEXT DEFAULT NULL,
+  error TEXT DEFAULT NULL,
+  leased_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  started_at TIMESTAMP DEFAULT NULL, -- when the node first heartbeated while running the job
+  finished_at TIMESTAMP DEFAULT NULL,
+  decision TEXT DEFAULT NULL, -- retry, permanent, or exhausted when the attempt did not succeed
+  retry_at TIMESTAMP DEFAULT NULL, -- when the job may be attempted again if it was retried
+  PRIMARY KEY (job_id, attempt)
+);
+
+-- Retry policy of the package jobs within an organization which do not set their own
+CREATE TABLE IF NOT EXISTS organization_retry_policies (
+  organization_id UUID PRIMARY KEY REFERENCES organizations(id),
+  retry_policy JSONB NOT NULL -- maximum attempts and the exponential backoff between them
+);
+
+-- Automatic upgrade policy of the nodes within an organization
+CREATE TABLE IF NOT EXISTS organization_upgrade_policies (
+  organization_id UUID PRIMARY KEY REFERENCES organizations(id),
+  upgrade_policy JSONB NOT NULL -- which outdated packages are upgraded when a node reports them
+);
+
+-- Software maps of an organization, matching system-reported software to the chocolatey packages which can adopt it
+CREATE TABLE IF NOT EXISTS organization_software_maps (
+  organization_id UUID PRIMARY KEY REFERENCES organizations(id),
+  include_defaults BOOLEAN NOT NULL DEFAULT TRUE, -- the built-in software maps are checked after the organization's own
+  software_maps JSONB NOT NULL -- a list of regexes and the chocolatey package each one maps to, checked in order
+);
+
+-- Automatic upgrade policies of groups, these replace the organization's policy for the members of the group
+CREATE TABLE IF NOT EXISTS group_upgrade_policies (
+  group_id UUID PRIMARY KEY REFERENCES groups(id),
+  organization_id UUID NOT NULL REFERENCES organizations(id),
+  upgrade_policy JSONB NOT NULL -- which outdated packages are upgraded when a node reports them
 );
 
 -- Schedules are iCal RRules along with start/end times
@@ -197,6 +324,22 @@ CREATE TABLE IF NOT EXISTS organization_source_assignments (
   PRIMARY KEY(source_id, organization_id)
 );
 
+-- Credentials of the source entries, encrypted by the server and only ever delivered sealed to a node's key
+CREATE TABLE IF NOT EXISTS source_credentials (
+  source_id UUID NOT NULL REFERENCES sources(id),
+  name CITEXT NOT NULL, -- the name of the source entry using the credentials
+  password BYTEA DEFAULT NULL, -- encrypted password of the source entry's user
+  certificate_password BYTEA DEFAULT NULL, -- encrypted password of the source entry's client certificate
+  PRIMARY KEY(source_id, name)
+);
+
+-- The chocolatey sources most recently reported by each node after reconciling them with its assigned sources
+CREATE TABLE IF NOT EXISTS node_sources (
+  node_id UUID PRIMARY KEY REFERENCES nodes(id),
+  sources JSONB NOT NULL, -- a list of the chocolatey sources configured on the node
+  reported_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP -- when the node last reported its sources
+);
+
 /*
 DROP TABLE IF EXISTS group_schedule_assignments;
 DROP TABLE IF EXISTS node_schedule_assignments;